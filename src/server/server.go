@@ -9,8 +9,10 @@ import (
 	"gitlab.com/crypto_project/core/strategy_service/src/logging"
 	"gitlab.com/crypto_project/core/strategy_service/src/service"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/signals"
 	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
 	"go.uber.org/zap"
+	"os"
 	"sync"
 )
 
@@ -32,8 +34,10 @@ func RunServer(wg *sync.WaitGroup) {
 	router := fasthttprouter.New()
 	router.GET("/", Index)
 	router.GET("/healthz", Healthz)
+	router.GET("/readyz", Readyz)
 	router.POST("/createOrder", CreateOrder)
 	router.POST("/cancelOrder", CancelOrder)
+	router.POST("/fireSignal", FireSignal)
 	log.Info("Listening on port :8080")
 	if err := fasthttp.ListenAndServe(*addr, router.Handler); err != nil {
 		wg.Done()
@@ -48,6 +52,17 @@ func Healthz(ctx *fasthttp.RequestCtx) {
 	fmt.Fprint(ctx, "alive!\n")
 }
 
+// Readyz is a handler to answer to strategy service readiness check requests, not ready while market data active
+// strategies run on is stale. Staleness of other feeds is reported by metrics.
+func Readyz(ctx *fasthttp.RequestCtx) {
+	if stale := service.GetStrategyService().ActiveStaleFeeds(); len(stale) > 0 {
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		fmt.Fprintf(ctx, "stale feeds: %v\n", stale)
+		return
+	}
+	fmt.Fprint(ctx, "ready!\n")
+}
+
 // CreateOrder is a handler to pass a request to create a smart trade to service instance and return a status for the attempt.
 func CreateOrder(ctx *fasthttp.RequestCtx) {
 	var createOrder orders.CreateOrderRequest
@@ -74,6 +89,23 @@ func CancelOrder(ctx *fasthttp.RequestCtx) {
 	_, _ = fmt.Fprint(ctx, string(jsonStr))
 }
 
+// FireSignal is a handler for external pushes to fire a signal, it checks the X-Signal-Token header if SIGNAL_WEBHOOK_TOKEN set.
+func FireSignal(ctx *fasthttp.RequestCtx) {
+	if token := os.Getenv("SIGNAL_WEBHOOK_TOKEN"); token != "" && string(ctx.Request.Header.Peek("X-Signal-Token")) != token {
+		ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+		return
+	}
+	var fireSignal signals.FireSignalRequest
+	_ = json.Unmarshal(ctx.PostBody(), &fireSignal)
+	log.Info("incoming", zap.String("request", fmt.Sprintf("%+v", fireSignal)))
+	response := service.GetStrategyService().FireSignal(fireSignal)
+	jsonStr, err := json.Marshal(response)
+	if err != nil {
+		log.Error("", zap.Error(err))
+	}
+	_, _ = fmt.Fprint(ctx, string(jsonStr))
+}
+
 func Index(ctx *fasthttp.RequestCtx) {
 	fmt.Fprintf(ctx, "Hello, world!\n\n")
 
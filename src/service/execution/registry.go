@@ -0,0 +1,28 @@
+package execution
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"sync"
+)
+
+// MakerOnly is the order type executed by maker-only orders chasing the best price with post-only limits.
+const MakerOnly = "maker-only"
+
+var (
+	algorithms    = map[string]interfaces.IExecutionAlgorithm{}
+	algorithmsMux sync.RWMutex
+)
+
+// Register makes the algorithm given execute orders of the type given.
+func Register(orderType string, algorithm interfaces.IExecutionAlgorithm) {
+	algorithmsMux.Lock()
+	defer algorithmsMux.Unlock()
+	algorithms[orderType] = algorithm
+}
+
+// Get returns the algorithm executing orders of the type given or nil if the type is placed on exchange as is.
+func Get(orderType string) interfaces.IExecutionAlgorithm {
+	algorithmsMux.RLock()
+	defer algorithmsMux.RUnlock()
+	return algorithms[orderType]
+}
@@ -0,0 +1,264 @@
+package indicators
+
+import (
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultCandlesLimit is how many latest candles an aggregator keeps.
+const DefaultCandlesLimit = 500
+
+const pollInterval = 1 * time.Second
+
+// Timeframes are the ones data feeds aggregate candles of from ticks, in seconds.
+var Timeframes = []int64{1, 60, 300, 3600}
+
+// A CandleAggregator builds candles of the timeframe from price ticks keeping latest ones only in a ring buffer.
+type CandleAggregator struct {
+	Timeframe int64 // seconds
+	Limit     int
+	candles   []interfaces.Candle // ring buffer of Limit candles at most
+	first     int                 // index of the oldest candle
+	mux       sync.Mutex
+}
+
+// NewCandleAggregator instantiates an aggregator for the timeframe given in seconds.
+func NewCandleAggregator(timeframe int64, limit int) *CandleAggregator {
+	if limit <= 0 {
+		limit = DefaultCandlesLimit
+	}
+	return &CandleAggregator{Timeframe: timeframe, Limit: limit}
+}
+
+// AddTick updates the forming candle with the price given or opens a new one, flat candles fill a gap without ticks.
+func (ca *CandleAggregator) AddTick(price, volume float64, at time.Time) {
+	if price <= 0 || ca.Timeframe <= 0 {
+		return
+	}
+	ca.mux.Lock()
+	defer ca.mux.Unlock()
+	timestamp := at.Unix() - at.Unix()%ca.Timeframe
+	if last := ca.last(); last != nil {
+		if timestamp < last.Timestamp {
+			return // late tick
+		}
+		if timestamp == last.Timestamp {
+			last.High = math.Max(last.High, price)
+			last.Low = math.Min(last.Low, price)
+			last.Close = price
+			last.Volume += volume
+			return
+		}
+		start := last.Timestamp + ca.Timeframe
+		if kept := timestamp - int64(ca.Limit)*ca.Timeframe; start < kept {
+			start = kept // older gap candles would be dropped anyway
+		}
+		for gap := start; gap < timestamp; gap += ca.Timeframe {
+			prev := ca.last().Close
+			ca.append(interfaces.Candle{
+				OHLCV:     interfaces.OHLCV{Open: prev, High: prev, Low: prev, Close: prev},
+				Timestamp: gap,
+			})
+		}
+	}
+	ca.append(interfaces.Candle{
+		OHLCV:     interfaces.OHLCV{Open: price, High: price, Low: price, Close: price, Volume: volume},
+		Timestamp: timestamp,
+	})
+}
+
+// last returns the latest candle or nil if there are none.
+func (ca *CandleAggregator) last() *interfaces.Candle {
+	if len(ca.candles) == 0 {
+		return nil
+	}
+	return &ca.candles[(ca.first+len(ca.candles)-1)%len(ca.candles)]
+}
+
+func (ca *CandleAggregator) append(candle interfaces.Candle) {
+	if len(ca.candles) < ca.Limit {
+		ca.candles = append(ca.candles, candle)
+		return
+	}
+	ca.candles[ca.first] = candle // overwrite the oldest
+	ca.first = (ca.first + 1) % len(ca.candles)
+}
+
+// GetCandles returns a copy of candles aggregated, oldest first, the last one may be still forming.
+func (ca *CandleAggregator) GetCandles() []interfaces.Candle {
+	return ca.GetLastCandles(ca.Limit)
+}
+
+// GetLastCandles returns a copy of n latest candles at most, oldest first, the last one may be still forming.
+func (ca *CandleAggregator) GetLastCandles(n int) []interfaces.Candle {
+	ca.mux.Lock()
+	defer ca.mux.Unlock()
+	count := len(ca.candles)
+	if n < count {
+		count = n
+	}
+	if count < 0 {
+		count = 0
+	}
+	candles := make([]interfaces.Candle, count)
+	for i := range candles {
+		candles[i] = ca.candles[(ca.first+len(ca.candles)-count+i)%len(ca.candles)]
+	}
+	return candles
+}
+
+// A CandleStore aggregates ticks of markets into candles of all the Timeframes, data feeds supply it as ticks come.
+// Limit candles of each timeframe are kept per market, DefaultCandlesLimit if not set. On demand, only markets
+// candles were asked for are aggregated, from the first ask on, so feeds of all the pairs don't hold candles of them.
+type CandleStore struct {
+	Limit    int
+	OnDemand bool
+	markets  sync.Map // market key -> map[int64]*CandleAggregator, timeframe -> aggregator
+}
+
+// AddTick supplies the price and volume traded since the previous tick to aggregators of the market, ticks of
+// markets not asked for are skipped on demand.
+func (cs *CandleStore) AddTick(pair, exchange string, marketType int64, price, volume float64, at time.Time) {
+	value, ok := cs.markets.Load(marketKey(pair, exchange, marketType))
+	if !ok && cs.OnDemand {
+		return
+	}
+	if !ok {
+		value = cs.aggregate(pair, exchange, marketType)
+	}
+	for _, aggregator := range value.(map[int64]*CandleAggregator) {
+		aggregator.AddTick(price, volume, at)
+	}
+}
+
+// aggregate starts aggregating candles of the market, returns its aggregators by timeframe.
+func (cs *CandleStore) aggregate(pair, exchange string, marketType int64) interface{} {
+	aggregators := make(map[int64]*CandleAggregator, len(Timeframes))
+	for _, timeframe := range Timeframes {
+		aggregators[timeframe] = NewCandleAggregator(timeframe, cs.Limit)
+	}
+	value, _ := cs.markets.LoadOrStore(marketKey(pair, exchange, marketType), aggregators)
+	return value
+}
+
+// GetCandles returns n latest candles of the market and timeframe given, oldest first, the last one may be still
+// forming. Returns nil if the market had no ticks or the timeframe is not aggregated. On demand, the first ask
+// starts aggregating the market and candles are empty until ticks come.
+func (cs *CandleStore) GetCandles(pair, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	value, ok := cs.markets.Load(marketKey(pair, exchange, marketType))
+	if !ok && cs.OnDemand {
+		value = cs.aggregate(pair, exchange, marketType)
+	} else if !ok {
+		return nil
+	}
+	aggregator, ok := value.(map[int64]*CandleAggregator)[timeframe]
+	if !ok {
+		return nil
+	}
+	return aggregator.GetLastCandles(n)
+}
+
+func marketKey(pair, exchange string, marketType int64) string {
+	return fmt.Sprintf("%s:%s:%d", exchange, pair, marketType)
+}
+
+// A marketCandles polls the data feed for a market to supply ticks to aggregators of all timeframes requested.
+type marketCandles struct {
+	aggregators sync.Map // timeframe -> *CandleAggregator
+	watchers    int      // holding candles aggregated, see Watch
+	usedAt      time.Time
+}
+
+var (
+	markets    = map[string]*marketCandles{} // market key -> candles polled
+	marketsMux sync.Mutex
+)
+
+// pollIdleTimeout is how long polling goes on for a market no one watches after candles were asked for last time.
+const pollIdleTimeout = 1 * time.Minute
+
+// GetCandles returns candles of the market and timeframe given the data feed aggregates, otherwise ones aggregated
+// by polling the data feed. Polling for the market starts on the first call, so candles history fills up with time,
+// and stops once the market is not watched or asked for, see Watch.
+func GetCandles(df interfaces.IDataFeed, pair, exchange string, marketType int64, timeframe int64) []interfaces.Candle {
+	if candles := df.GetCandles(pair, exchange, marketType, timeframe, DefaultCandlesLimit); candles != nil {
+		return candles
+	}
+	return pollMarket(df, pair, exchange, marketType, 0).aggregator(timeframe).GetCandles()
+}
+
+// Watch keeps candles of the market and timeframes given the data feed doesn't aggregate itself polled, so history of
+// them fills up before they are asked for. Polling stops once every watcher called the release returned.
+func Watch(df interfaces.IDataFeed, pair, exchange string, marketType int64, timeframes ...int64) (release func()) {
+	var polled []int64
+	for _, timeframe := range timeframes {
+		if df.GetCandles(pair, exchange, marketType, timeframe, 1) == nil {
+			polled = append(polled, timeframe)
+		}
+	}
+	if len(polled) == 0 {
+		return func() {}
+	}
+	market := pollMarket(df, pair, exchange, marketType, 1)
+	for _, timeframe := range polled {
+		market.aggregator(timeframe)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			marketsMux.Lock()
+			defer marketsMux.Unlock()
+			market.watchers--
+			market.usedAt = time.Now()
+		})
+	}
+}
+
+// pollMarket returns candles polled of the market adding the watchers given, polling starts if the market is new.
+func pollMarket(df interfaces.IDataFeed, pair, exchange string, marketType int64, watchers int) *marketCandles {
+	key := marketKey(pair, exchange, marketType)
+	marketsMux.Lock()
+	defer marketsMux.Unlock()
+	market, ok := markets[key]
+	if !ok {
+		market = &marketCandles{}
+		markets[key] = market
+		go market.poll(df, key, pair, exchange, marketType)
+	}
+	market.watchers += watchers
+	market.usedAt = time.Now()
+	return market
+}
+
+func (mc *marketCandles) aggregator(timeframe int64) *CandleAggregator {
+	aggregator, _ := mc.aggregators.LoadOrStore(timeframe, NewCandleAggregator(timeframe, DefaultCandlesLimit))
+	return aggregator.(*CandleAggregator)
+}
+
+// idle removes the market if it's not watched and not asked for since the idle timeout, tells whether it did.
+func (mc *marketCandles) idle(key string, now time.Time) bool {
+	marketsMux.Lock()
+	defer marketsMux.Unlock()
+	if mc.watchers > 0 || now.Sub(mc.usedAt) < pollIdleTimeout {
+		return false
+	}
+	delete(markets, key)
+	return true
+}
+
+func (mc *marketCandles) poll(df interfaces.IDataFeed, key, pair, exchange string, marketType int64) {
+	for !mc.idle(key, time.Now()) {
+		if ohlcv := df.GetPriceForPairAtExchange(pair, exchange, marketType); ohlcv != nil {
+			now := time.Now()
+			// feed volume is not a volume traded since the previous tick, so vwap weights equally
+			mc.aggregators.Range(func(_, aggregator interface{}) bool {
+				aggregator.(*CandleAggregator).AddTick(ohlcv.Close, 0, now)
+				return true
+			})
+		}
+		time.Sleep(pollInterval)
+	}
+}
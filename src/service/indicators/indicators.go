@@ -0,0 +1,224 @@
+// Package indicators computes technical indicators over candles aggregated from the data feed.
+package indicators
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"math"
+)
+
+// Indicators supported.
+const (
+	SMA       = "sma"
+	EMA       = "ema"
+	RSI       = "rsi"
+	Bollinger = "bollinger"
+	VWAP      = "vwap"
+)
+
+// Operators compare an indicator with the reference: threshold, slow moving average or band.
+const (
+	Above      = "above"
+	Below      = "below"
+	CrossAbove = "crossAbove"
+	CrossBelow = "crossBelow"
+)
+
+const defaultDeviations = 2.0
+
+// Closes returns close prices of candles given.
+func Closes(candles []interfaces.Candle) []float64 {
+	closes := make([]float64, len(candles))
+	for i, candle := range candles {
+		closes[i] = candle.Close
+	}
+	return closes
+}
+
+// SimpleMovingAverage returns an average of last period values, false if there are not enough values.
+func SimpleMovingAverage(values []float64, period int) (float64, bool) {
+	if period <= 0 || len(values) < period {
+		return 0, false
+	}
+	sum := 0.0
+	for _, value := range values[len(values)-period:] {
+		sum += value
+	}
+	return sum / float64(period), true
+}
+
+// ExponentialMovingAverage returns an EMA seeded with SMA of the first period values, false if there are not enough values.
+func ExponentialMovingAverage(values []float64, period int) (float64, bool) {
+	if period <= 0 || len(values) < period {
+		return 0, false
+	}
+	ema, _ := SimpleMovingAverage(values[:period], period)
+	k := 2 / float64(period+1)
+	for _, value := range values[period:] {
+		ema = value*k + ema*(1-k)
+	}
+	return ema, true
+}
+
+// RelativeStrengthIndex returns Wilder's RSI over the period, false if there are not enough values.
+func RelativeStrengthIndex(values []float64, period int) (float64, bool) {
+	if period <= 0 || len(values) < period+1 {
+		return 0, false
+	}
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+	}
+	if loss == 0 {
+		if gain == 0 {
+			return 50, true
+		}
+		return 100, true
+	}
+	return 100 - 100/(1+gain/loss), true
+}
+
+// BollingerBands returns lower and upper bands distant by deviations given from SMA of the period.
+func BollingerBands(values []float64, period int, deviations float64) (float64, float64, bool) {
+	middle, ok := SimpleMovingAverage(values, period)
+	if !ok {
+		return 0, 0, false
+	}
+	variance := 0.0
+	for _, value := range values[len(values)-period:] {
+		variance += (value - middle) * (value - middle)
+	}
+	deviation := math.Sqrt(variance/float64(period)) * deviations
+	return middle - deviation, middle + deviation, true
+}
+
+// VolumeWeightedAveragePrice returns VWAP of typical prices over last period candles or all candles if period is zero.
+// Candles weight equally if there is no volume.
+func VolumeWeightedAveragePrice(candles []interfaces.Candle, period int) (float64, bool) {
+	if period > 0 {
+		if len(candles) < period {
+			return 0, false
+		}
+		candles = candles[len(candles)-period:]
+	}
+	if len(candles) == 0 {
+		return 0, false
+	}
+	weighted, volume, sum := 0.0, 0.0, 0.0
+	for _, candle := range candles {
+		typical := (candle.High + candle.Low + candle.Close) / 3
+		weighted += typical * candle.Volume
+		volume += candle.Volume
+		sum += typical
+	}
+	if volume == 0 {
+		return sum / float64(len(candles)), true
+	}
+	return weighted / volume, true
+}
+
+// AverageTrueRange returns Wilder's ATR over the period, false if there are not enough candles.
+func AverageTrueRange(candles []interfaces.Candle, period int) (float64, bool) {
+	if period <= 0 || len(candles) < period+1 {
+		return 0, false
+	}
+	trueRange := func(i int) float64 {
+		previousClose := candles[i-1].Close
+		return math.Max(candles[i].High-candles[i].Low,
+			math.Max(math.Abs(candles[i].High-previousClose), math.Abs(candles[i].Low-previousClose)))
+	}
+	atr := 0.0
+	for i := 1; i <= period; i++ {
+		atr += trueRange(i)
+	}
+	atr /= float64(period)
+	for i := period + 1; i < len(candles); i++ {
+		atr = (atr*float64(period-1) + trueRange(i)) / float64(period)
+	}
+	return atr, true
+}
+
+// Evaluate tells whether the condition holds over candles given, false if there are not enough candles.
+func Evaluate(condition *models.MongoIndicatorCondition, candles []interfaces.Candle) bool {
+	value, reference, ok := compute(condition, candles)
+	if !ok {
+		return false
+	}
+	switch condition.Operator {
+	case Above:
+		return value > reference
+	case Below:
+		return value < reference
+	case CrossAbove, CrossBelow:
+		if len(candles) < 2 {
+			return false
+		}
+		previousValue, previousReference, ok := compute(condition, candles[:len(candles)-1])
+		if !ok {
+			return false
+		}
+		if condition.Operator == CrossAbove {
+			return previousValue <= previousReference && value > reference
+		}
+		return previousValue >= previousReference && value < reference
+	}
+	return false
+}
+
+// compute returns indicator value at the latest candle and the reference value the operator compares it with.
+func compute(condition *models.MongoIndicatorCondition, candles []interfaces.Candle) (float64, float64, bool) {
+	closes := Closes(candles)
+	if len(closes) == 0 {
+		return 0, 0, false
+	}
+	price := closes[len(closes)-1]
+	period := int(condition.Period)
+	switch condition.Indicator {
+	case SMA, EMA:
+		average := SimpleMovingAverage
+		if condition.Indicator == EMA {
+			average = ExponentialMovingAverage
+		}
+		fast, ok := average(closes, period)
+		if !ok {
+			return 0, 0, false
+		}
+		if condition.SlowPeriod == 0 {
+			return price, fast, true
+		}
+		slow, ok := average(closes, int(condition.SlowPeriod))
+		return fast, slow, ok
+	case RSI:
+		rsi, ok := RelativeStrengthIndex(closes, period)
+		return rsi, condition.Value, ok
+	case Bollinger:
+		deviations := condition.Value
+		if deviations == 0 {
+			deviations = defaultDeviations
+		}
+		lower, upper, ok := BollingerBands(closes, period, deviations)
+		if condition.Operator == Below || condition.Operator == CrossBelow {
+			return price, lower, ok
+		}
+		return price, upper, ok
+	case VWAP:
+		vwap, ok := VolumeWeightedAveragePrice(candles, period)
+		if !ok || vwap == 0 {
+			return 0, 0, false
+		}
+		return (price/vwap - 1) * 100, condition.Value, true
+	}
+	return 0, 0, false
+}
@@ -3,4 +3,41 @@ package interfaces
 type IDataFeed interface {
 	GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *OHLCV
 	GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *SpreadData
+	// IsStale reports whether the price of the pair is missing or older than the max age of the exchange.
+	IsStale(pair string, exchange string, marketType int64) bool
+	// GetCandles returns n latest candles of the timeframe in seconds, oldest first, the last one may be still forming.
+	// Returns nil if the feed doesn't aggregate the timeframe.
+	GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []Candle
+	// GetMarkPrice returns the mark and index price of the futures market, nil if the feed has none for the market.
+	GetMarkPrice(pair string, exchange string, marketType int64) *MarkPrice
+	// GetOrderBook returns depth levels of each side of the level 2 order book, all of them if depth is 0. Returns nil
+	// while the book is not in sync or if the feed doesn't keep books, asking for it starts keeping the book.
+	GetOrderBook(pair string, exchange string, marketType int64, depth int) *OrderBook
+	// Subscribe returns updates of the market, an update not taken yet is merged into the next one so slow
+	// subscribers get the latest values. Returns nil if the feed doesn't push updates and has to be polled.
+	Subscribe(pair string, exchange string, marketType int64) <-chan Update
+	// Unsubscribe stops and closes updates returned by Subscribe.
+	Unsubscribe(pair string, exchange string, marketType int64, updates <-chan Update)
+}
+
+// An IFeedHealth reports feeds not updated for longer than the max age of their exchange, e.g. "binance.ticker.1".
+type IFeedHealth interface {
+	StaleFeeds() []string
+}
+
+// An IOrderBookKeeper keeps order books of markets strategies run on, books kept are not released for other markets.
+// KeepOrderBook and ReleaseOrderBook are called once per strategy, the book is released once the last one stops.
+type IOrderBookKeeper interface {
+	KeepOrderBook(pair string, exchange string, marketType int64)
+	ReleaseOrderBook(pair string, exchange string, marketType int64)
+}
+
+// An IConnectionHealth reports states of feed stream connections by name, e.g. "binance.ticker.1": "connected".
+type IConnectionHealth interface {
+	ConnectionStates() map[string]string
+}
+
+// An IFeedErrors reports counts of feed messages not decoded by feed and reason, e.g. "redis.spreads.schema": 3.
+type IFeedErrors interface {
+	FeedErrors() map[string]int64
 }
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
+)
+
+// An IExecutionAlgorithm executes an order by child orders of its own, e.g. maker-only, TWAP or iceberg. The order
+// is known by the id Execute returns, its updates with the total filled are reported to the callback after Execute
+// returns.
+type IExecutionAlgorithm interface {
+	Execute(request orders.CreateOrderRequest, onOrderUpdate func(order *models.MongoOrder)) orders.OrderResponse
+	Cancel(request orders.CancelOrderRequest) orders.OrderResponse
+}
@@ -1,5 +1,7 @@
 package interfaces
 
+import "gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+
 type IStrategyRuntime interface {
 	Stop()
 	Start()
@@ -9,3 +11,8 @@ type IStrategyRuntime interface {
 	SetSelectedExitTarget(selectedExitTarget int)
 	IsOrderExistsInMap(orderId string) bool
 }
+
+// An IReloadableRuntime is updated on hot reload of its strategy, it gets the model as it was before the reload.
+type IReloadableRuntime interface {
+	HotReload(previous models.MongoStrategy)
+}
@@ -25,9 +25,25 @@ type IStateMgmt interface {
 	SavePNL(templateStrategyId *primitive.ObjectID, profitAmount float64)
 	SaveStrategyConditions(strategy *models.MongoStrategy)
 	SaveStrategy(strategy *models.MongoStrategy) *models.MongoStrategy
-	CreateStrategy(strategy *models.MongoStrategy) *models.MongoStrategy
+	CreateStrategy(strategy *models.MongoStrategy) (*models.MongoStrategy, error)
 	EnableHedgeLossStrategy(strategyId *primitive.ObjectID)
 	SaveOrder(order models.MongoOrder, keyId *primitive.ObjectID, marketType int64)
 	UpdateStrategyState(strategyId *primitive.ObjectID, state *models.MongoStrategyState)
 	UpdateStateAndConditions(strategyId *primitive.ObjectID, model *models.MongoStrategy)
+	UpdateEntrySignal(strategyId *primitive.ObjectID, state *models.MongoStrategyState)
+	UpdateAtr(strategyId *primitive.ObjectID, state *models.MongoStrategyState)
+	UpdateEntrySlices(strategyId *primitive.ObjectID, state *models.MongoStrategyState)
+	UpdateReEntry(strategyId *primitive.ObjectID, state *models.MongoStrategyState)
+	UpdateCopyLeaderPrices(strategyId *primitive.ObjectID, entryPrice float64, exitPrice float64)
+	GetKeyAssetFree(keyAssetId *primitive.ObjectID) float64
+	GetKeyAssetEquity(keyAssetId *primitive.ObjectID) float64
+	InitSignalsWatch()
+	GetSignal(signalId *primitive.ObjectID) *models.MongoSignal
+	SubscribeToSignal(signalId *primitive.ObjectID, subscriberId string, onSignalFired func(signal *models.MongoSignal)) error
+	UnsubscribeFromSignal(signalId *primitive.ObjectID, subscriberId string)
+	UpdateSignalState(signalId *primitive.ObjectID, state *models.MongoSignalState)
+	FireSignal(signal *models.MongoSignal)
+	DisableSignal(signalId *primitive.ObjectID, state *models.MongoSignalState)
+	GetTemplateChildren(templateId *primitive.ObjectID) []*models.MongoStrategy
+	UpdateTemplateState(templateId *primitive.ObjectID, state *models.MongoStrategyTemplateState)
 }
@@ -14,6 +14,7 @@ type ITrading interface {
 	CreateOrder(order orders.CreateOrderRequest) orders.OrderResponse
 	CancelOrder(params orders.CancelOrderRequest) orders.OrderResponse
 	PlaceHedge(parentSmarOrder *models.MongoStrategy) orders.OrderResponse
+	PlaceCopy(keyId *primitive.ObjectID, conditions *models.MongoStrategyCondition) orders.OrderResponse
 
 	UpdateLeverage(keyId *primitive.ObjectID, leverage float64, symbol string) orders.UpdateLeverageResponse
 	Transfer(request orders.TransferRequest) orders.OrderResponse
@@ -0,0 +1,10 @@
+package interfaces
+
+// A MarkPrice is the mark and index price of a futures market with its funding.
+type MarkPrice struct {
+	Mark            float64 `json:"mark"`
+	Index           float64 `json:"index"`
+	FundingRate     float64 `json:"fundingRate"`
+	NextFundingTime int64   `json:"nextFundingTime"` // unix milliseconds
+	UpdatedAt       int64   `json:"updatedAt"`       // unix milliseconds the feed received the data at
+}
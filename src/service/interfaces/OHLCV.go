@@ -2,4 +2,11 @@ package interfaces
 
 type OHLCV struct {
 	Open, High, Low, Close, Volume float64
+	UpdatedAt                      int64 // unix milliseconds the feed received the data at, 0 if unknown
+}
+
+// A Candle is OHLCV data of the timeframe started at the timestamp given in unix seconds.
+type Candle struct {
+	OHLCV
+	Timestamp int64
 }
@@ -0,0 +1,12 @@
+package interfaces
+
+// A PriceLevel is the amount of base resting at the price.
+type PriceLevel struct {
+	Price, Amount float64
+}
+
+// An OrderBook is a level 2 order book, bids go from the best one down and asks from the best one up.
+type OrderBook struct {
+	Bids, Asks []PriceLevel
+	UpdatedAt  int64 // unix milliseconds the feed received the last update at
+}
@@ -1,7 +1,10 @@
 package interfaces
 
 type SpreadData struct {
-	BestAsk float64 `json:"bestAsk,float"`
-	BestBid float64 `json:"bestBid,float"`
-	Close   float64 `json:"close,float"`
+	BestAsk    float64 `json:"bestAsk,float"`
+	BestBid    float64 `json:"bestBid,float"`
+	BestAskQty float64 `json:"bestAskQty,float"`
+	BestBidQty float64 `json:"bestBidQty,float"`
+	Close      float64 `json:"close,float"`
+	UpdatedAt  int64   `json:"updatedAt"` // unix milliseconds the feed received the data at, 0 if unknown
 }
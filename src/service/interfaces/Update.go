@@ -0,0 +1,8 @@
+package interfaces
+
+// An Update tells subscribers of a market its price, spread or mark price changed, the ones not changed are nil.
+type Update struct {
+	Price     *OHLCV
+	Spread    *SpreadData
+	MarkPrice *MarkPrice
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
+)
+
+// A makerOnlyAlgorithm executes orders by maker-only orders the service runs, the synthetic order they update
+// reports the total filled.
+type makerOnlyAlgorithm struct {
+	ss *StrategyService
+}
+
+func (a *makerOnlyAlgorithm) Execute(request orders.CreateOrderRequest, onOrderUpdate func(order *models.MongoOrder)) orders.OrderResponse {
+	response := a.ss.CreateOrder(request)
+	if response.Status == "OK" && response.Data.OrderId != "" {
+		_ = a.ss.stateMgmt.SubscribeToOrder(response.Data.OrderId, onOrderUpdate)
+	}
+	return response
+}
+
+func (a *makerOnlyAlgorithm) Cancel(request orders.CancelOrderRequest) orders.OrderResponse {
+	return a.ss.CancelOrder(request)
+}
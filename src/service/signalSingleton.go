@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/signals"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+// GetSignal creates signal runtime instance for the model given.
+func (ss *StrategyService) GetSignal(model *models.MongoSignal) *signals.Signal {
+	logger, _ := logging.GetZapLogger()
+	logger = logger.With(zap.String("logger", fmt.Sprintf("sig-%v", model.Id.Hex())))
+	signal := signals.New(model, ss.dataFeed, ss.stateMgmt, &ss.statsd, logger)
+	signal.SettlementMutex = redis.GetRedsync().NewMutex(fmt.Sprintf("signal:%v", model.Id.Hex()),
+		redsync.WithTries(2),
+		redsync.WithRetryDelay(1*time.Second),
+		redsync.WithExpiry(10*time.Second),
+	)
+	return signal
+}
+
+// AddSignal instantiates given signal to store in the service instance and start evaluating it.
+func (ss *StrategyService) AddSignal(model *models.MongoSignal) {
+	ss.signalsMux.Lock()
+	defer ss.signalsMux.Unlock()
+	if ss.signals[model.Id.Hex()] != nil {
+		return
+	}
+	if _, ok := ss.pairs[int8(model.Condition.MarketType)][model.Condition.Pair]; !ok && model.MonType.SigType != models.SignalTypeWebhook {
+		return // skip a foreign pair
+	}
+	signal := ss.GetSignal(model)
+	if ok, err := signal.Settle(); !ok || err != nil {
+		return
+	}
+	ss.log.Info("adding signal", zap.String("id", model.Id.Hex()))
+	ss.signals[model.Id.Hex()] = signal
+	go func() {
+		signal.Start()
+		ss.signalsMux.Lock()
+		delete(ss.signals, model.Id.Hex())
+		ss.signalsMux.Unlock()
+	}()
+	ss.statsd.Inc("strategy_service.add_signal")
+}
+
+// InitSignals loads enabled signals from persistent storage and watches for new ones to evaluate.
+func (ss *StrategyService) InitSignals() {
+	ctx := context.Background()
+	coll := mongodb.GetCollection("core_signals")
+	cur, err := coll.Find(ctx, bson.D{{"enabled", true}})
+	if err != nil {
+		ss.log.Error("can't read signals", zap.Error(err))
+		return
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var model models.MongoSignal
+		if err := cur.Decode(&model); err != nil {
+			ss.log.Error("signal decode", zap.Error(err))
+			continue
+		}
+		ss.AddSignal(&model)
+	}
+	ss.WatchSignals()
+}
+
+// WatchSignals subscribes to signals to add new signals to runtime or update local data together with persistent storage updates.
+func (ss *StrategyService) WatchSignals() {
+	ss.log.Info("watching for new signals in the storage")
+	ctx := context.Background()
+	coll := mongodb.GetCollection("core_signals")
+	cs, err := coll.Watch(ctx, mongo.Pipeline{}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		ss.log.Error("can't watch for signals", zap.Error(err))
+		return
+	}
+	defer cs.Close(ctx)
+	for cs.Next(ctx) {
+		var event models.MongoSignalUpdateEvent
+		if err := cs.Decode(&event); err != nil {
+			ss.log.Info("event decode error on processing signal", zap.Error(err))
+			continue
+		}
+		ss.signalsMux.Lock()
+		signal := ss.signals[event.FullDocument.Id.Hex()]
+		ss.signalsMux.Unlock()
+		if signal != nil {
+			signal.HotReload(event.FullDocument)
+		} else if event.FullDocument.Enabled {
+			ss.AddSignal(&event.FullDocument)
+		}
+	}
+	ss.log.Fatal("new signals watch")
+}
+
+// FireSignal fires the signal requested by external push, no matter which instance evaluates it.
+func (ss *StrategyService) FireSignal(request signals.FireSignalRequest) signals.FireSignalResponse {
+	ss.statsd.Inc("strategy_service.fire_signal_request")
+	id, err := primitive.ObjectIDFromHex(request.SignalId)
+	if err != nil {
+		return signals.FireSignalResponse{Status: "ERR", Msg: "invalid signal id"}
+	}
+	ss.signalsMux.Lock()
+	signal := ss.signals[id.Hex()]
+	ss.signalsMux.Unlock()
+	if signal == nil {
+		model := ss.stateMgmt.GetSignal(&id)
+		if model == nil {
+			return signals.FireSignalResponse{Status: "ERR", Msg: "signal not found"}
+		}
+		signal = ss.GetSignal(model)
+	}
+	if !signal.Fire(request.Price, signals.FiredByWebhook) {
+		return signals.FireSignalResponse{Status: "ERR", Msg: "signal disabled"}
+	}
+	return signals.FireSignalResponse{Status: "OK"}
+}
@@ -0,0 +1,12 @@
+package signals
+
+// A FireSignalRequest is an external push to fire a signal, the price is optional and informational only.
+type FireSignalRequest struct {
+	SignalId string  `json:"signalId"`
+	Price    float64 `json:"price,omitempty"`
+}
+
+type FireSignalResponse struct {
+	Status string `json:"status"`
+	Msg    string `json:"msg,omitempty"`
+}
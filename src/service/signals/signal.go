@@ -0,0 +1,267 @@
+package signals
+
+import (
+	"fmt"
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const checkInterval = 1 * time.Second
+
+// Who fired a signal.
+const (
+	FiredByFeed    = "feed"
+	FiredByWebhook = "webhook"
+)
+
+// A Signal evaluates published market condition against data feed until it fires and releases strategies linked.
+type Signal struct {
+	Model           *models.MongoSignal
+	SettlementMutex *redsync.Mutex
+	DataFeed        interfaces.IDataFeed
+	StateMgmt       interfaces.IStateMgmt
+	Statsd          interfaces.IStatsClient
+	Log             interfaces.ILogger
+	mux             sync.Mutex
+	direction       string // "above" or "below" the target price, derived from first price seen if not specified
+}
+
+// New instantiates a signal runtime for the model given.
+func New(model *models.MongoSignal, df interfaces.IDataFeed, sm interfaces.IStateMgmt, statsd interfaces.IStatsClient, logger interfaces.ILogger) *Signal {
+	if model.State == nil {
+		model.State = &models.MongoSignalState{}
+	}
+	return &Signal{
+		Model:     model,
+		DataFeed:  df,
+		StateMgmt: sm,
+		Statsd:    statsd,
+		Log:       logger,
+		direction: model.TriggerWhen.TrigType,
+	}
+}
+
+// ID returns unique identifier the signal holds.
+func (s *Signal) ID() string {
+	return fmt.Sprintf("%q", s.Model.Id.Hex())
+}
+
+// Start evaluates the signal periodically until it fires (if not open ended), expires or gets disabled.
+func (s *Signal) Start() {
+	s.Statsd.Inc("signal.start")
+	for s.isEnabled() {
+		if s.isExpired(time.Now()) {
+			s.Log.Info("signal expired", zap.String("id", s.ID()))
+			s.Disable()
+			s.Statsd.Inc("signal.expired")
+			break
+		}
+		if s.Model.MonType.SigType != models.SignalTypeWebhook {
+			s.evaluate()
+		}
+		time.Sleep(checkInterval)
+	}
+	s.Log.Info("stopped signal", zap.String("id", s.ID()))
+}
+
+// Disable stops the runtime and saves the signal disabled, it's not recorded as fired.
+func (s *Signal) Disable() {
+	s.mux.Lock()
+	s.Model.Enabled = false
+	state := *s.Model.State
+	s.mux.Unlock()
+	s.StateMgmt.DisableSignal(&s.Model.Id, &state)
+}
+
+// HotReload updates the signal in runtime to keep consistency with persistent state.
+func (s *Signal) HotReload(model models.MongoSignal) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.Model.Enabled = model.Enabled
+	s.Model.Condition = model.Condition
+	s.Model.TriggerWhen = model.TriggerWhen
+	s.Model.Expiration = model.Expiration
+	s.Model.OpenEnded = model.OpenEnded
+	s.direction = model.TriggerWhen.TrigType
+}
+
+func (s *Signal) isEnabled() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.Model.Enabled
+}
+
+func (s *Signal) isExpired(now time.Time) bool {
+	expiration := s.Model.Expiration.ExpirationTimestamp
+	return expiration > 0 && now.Unix() >= expiration
+}
+
+// evaluate takes the latest market data for the signal pair and fires the signal if condition held long enough.
+func (s *Signal) evaluate() {
+	condition := s.Model.Condition
+	var price float64
+	var spread *interfaces.SpreadData
+	switch s.Model.MonType.SigType {
+	case models.SignalTypeSpread:
+		spread = s.DataFeed.GetSpreadForPairAtExchange(condition.Pair, condition.Exchange, condition.MarketType)
+		if spread == nil {
+			return
+		}
+		price = spread.Close
+	default:
+		ohlcv := s.DataFeed.GetPriceForPairAtExchange(condition.Pair, condition.Exchange, condition.MarketType)
+		if ohlcv == nil {
+			return
+		}
+		price = ohlcv.Close
+	}
+	if s.ShouldFire(price, spread, time.Now()) {
+		s.Fire(price, FiredByFeed)
+	}
+}
+
+// ShouldFire tells whether the condition is met at the moment given and it has been met for the trigger period.
+func (s *Signal) ShouldFire(price float64, spread *interfaces.SpreadData, now time.Time) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.Model.Enabled {
+		return false
+	}
+	state := s.Model.State
+	if !s.isMet(price, spread) {
+		state.HoldingSince = 0
+		return false
+	}
+	if state.HoldingSince == 0 {
+		state.HoldingSince = now.Unix()
+	}
+	return now.Unix()-state.HoldingSince >= s.Model.TriggerWhen.Period
+}
+
+// isMet checks the signal condition against the price and spread given.
+func (s *Signal) isMet(price float64, spread *interfaces.SpreadData) bool {
+	condition := s.Model.Condition
+	switch s.Model.MonType.SigType {
+	case models.SignalTypePrice:
+		if s.direction == "" {
+			// fire on crossing, so wait for the price to reach the target from the current side
+			if price < condition.TargetPrice {
+				s.direction = models.TriggerAbove
+			} else {
+				s.direction = models.TriggerBelow
+			}
+			return false
+		}
+		switch s.direction {
+		case models.TriggerAbove:
+			return price >= condition.TargetPrice
+		case models.TriggerBelow:
+			return price <= condition.TargetPrice
+		}
+	case models.SignalTypePercentChange:
+		referencePrice := condition.Price
+		if referencePrice == 0 {
+			if s.Model.State.ReferencePrice == 0 {
+				s.Model.State.ReferencePrice = price
+				state := *s.Model.State // saved while evaluation goes on changing the state
+				go s.StateMgmt.UpdateSignalState(&s.Model.Id, &state)
+				return false
+			}
+			referencePrice = s.Model.State.ReferencePrice
+		}
+		change := (price/referencePrice - 1) * 100
+		if condition.PercentChange < 0 {
+			return change <= condition.PercentChange
+		}
+		return change >= condition.PercentChange
+	case models.SignalTypeSpread:
+		if spread == nil || spread.BestBid == 0 {
+			return false
+		}
+		return (spread.BestAsk/spread.BestBid-1)*100 >= condition.Spread
+	}
+	return false
+}
+
+// Fire marks the signal fired and saves it so strategies subscribed got released, returns false if the signal is disabled.
+func (s *Signal) Fire(price float64, firedBy string) bool {
+	s.mux.Lock()
+	if !s.Model.Enabled {
+		s.mux.Unlock()
+		return false
+	}
+	now := time.Now().Unix()
+	state := s.Model.State
+	state.FiredAt = now
+	state.FiredPrice = price
+	state.FiredBy = firedBy
+	state.FiredCount += 1
+	state.HoldingSince = 0
+	if s.Model.OpenEnded {
+		// re-arm relative to the price fired at
+		if s.Model.Condition.Price == 0 && s.Model.MonType.SigType == models.SignalTypePercentChange {
+			state.ReferencePrice = price
+		}
+		s.direction = s.Model.TriggerWhen.TrigType
+	} else {
+		s.Model.Enabled = false
+	}
+	s.Model.Events = append(s.Model.Events, models.MongoSignalEvent{
+		T: now,
+		Data: models.MongoSignalState{
+			FiredAt:    state.FiredAt,
+			FiredPrice: price,
+			FiredBy:    firedBy,
+			FiredCount: state.FiredCount,
+		},
+	})
+	if len(s.Model.Events) > models.MaxSignalEvents {
+		s.Model.Events = s.Model.Events[len(s.Model.Events)-models.MaxSignalEvents:]
+	}
+	// save a copy, evaluation goes on changing the signal
+	fired := *s.Model
+	firedState := *state
+	fired.State = &firedState
+	fired.Events = append([]models.MongoSignalEvent(nil), s.Model.Events...)
+	s.mux.Unlock()
+
+	s.Log.Info("signal fired",
+		zap.String("id", s.ID()),
+		zap.Float64("price", price),
+		zap.String("firedBy", firedBy),
+		zap.Int64("firedCount", firedState.FiredCount),
+	)
+	s.StateMgmt.FireSignal(&fired)
+	s.Statsd.Inc("signal.fired_by_" + firedBy)
+	return true
+}
+
+// Settle takes the signal to evaluate in the instance trying to set a distributed lock.
+func (s *Signal) Settle() (bool, error) {
+	if err := s.SettlementMutex.Lock(); err != nil {
+		if err == redsync.ErrFailed {
+			return false, nil // already locked
+		}
+		return false, err // unexpected error
+	}
+	// extend settlement
+	go func() {
+		for s.isEnabled() {
+			time.Sleep(3 * time.Second)
+			success, err := s.SettlementMutex.Extend()
+			if !success || err != nil {
+				s.Log.Error("signal settlement mutex extension",
+					zap.Bool("success", success),
+					zap.String("name", s.SettlementMutex.Name()),
+					zap.Error(err),
+				)
+				return
+			}
+		}
+	}()
+	return true, nil
+}
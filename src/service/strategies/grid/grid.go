@@ -0,0 +1,463 @@
+package grid
+
+import (
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"math"
+	"sync"
+	"time"
+)
+
+// Grid states.
+const (
+	Active   = "Active"
+	End      = "End"
+	Error    = "Error"
+	Canceled = "Canceled"
+)
+
+// Grid order placement retries, the grid fails once an order is not placed in max attempts.
+const (
+	maxPlaceAttempts = 5
+	maxRetryDelay    = 60 // seconds
+)
+
+// Grid keeps buy orders below and sell orders above the price on levels between lower and upper prices. Every fill
+// places the opposite order one level away, so each pair of fills realises the distance between levels.
+type Grid struct {
+	Strategy        interfaces.IStrategy
+	KeyId           *primitive.ObjectID
+	DataFeed        interfaces.IDataFeed
+	ExchangeApi     interfaces.ITrading
+	StateMgmt       interfaces.IStateMgmt
+	Statsd          interfaces.IStatsClient
+	ExchangeName    string
+	PricePrecision  int64
+	AmountPrecision int64
+	Levels          []float64
+
+	mux     sync.Mutex
+	filled  map[string]bool
+	stopped bool
+}
+
+// New creates a grid runtime for the strategy.
+func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAPI interfaces.ITrading, Statsd interfaces.IStatsClient, keyId *primitive.ObjectID, stateMgmt interfaces.IStateMgmt) *Grid {
+	model := strategy.GetModel()
+	grid := &Grid{
+		Strategy:     strategy,
+		KeyId:        keyId,
+		DataFeed:     DataFeed,
+		ExchangeApi:  TradingAPI,
+		StateMgmt:    stateMgmt,
+		Statsd:       Statsd,
+		ExchangeName: model.Conditions.Exchange,
+		filled:       map[string]bool{},
+	}
+	if grid.ExchangeName == "" {
+		grid.ExchangeName = "binance"
+	}
+	grid.PricePrecision, grid.AmountPrecision = stateMgmt.GetMarketPrecision(model.Conditions.Pair, model.Conditions.MarketType)
+	if model.Conditions.GridLevels > 1 {
+		grid.Levels = Levels(model.Conditions)
+		for i := range grid.Levels {
+			grid.Levels[i] = toFixed(grid.Levels[i], grid.PricePrecision, math.Round)
+		}
+	}
+	if model.State == nil {
+		model.State = &models.MongoStrategyState{}
+	}
+	return grid
+}
+
+// Start places the grid or resumes it after a restart and watches the price for stop bounds while enabled.
+func (g *Grid) Start() {
+	model := g.Strategy.GetModel()
+	if err := Validate(model.Conditions); err != nil {
+		g.Strategy.GetLogger().Error("invalid grid conditions", zap.Error(err))
+		model.State.State = Error
+		model.State.Msg = err.Error()
+		g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+		g.StateMgmt.DisableStrategy(model.ID)
+		return
+	}
+	g.Statsd.Inc("grid.start")
+	if model.State.State == Active && len(model.State.GridOrders) > 0 {
+		g.Strategy.GetLogger().Info("resume grid", zap.Int("orders", len(model.State.GridOrders)))
+		for _, gridOrder := range model.State.GridOrders {
+			if gridOrder.OrderId != "" { // orders not placed are retried below
+				go g.StateMgmt.SubscribeToOrder(gridOrder.OrderId, g.onOrderStatusUpdate)
+			}
+		}
+	} else if !g.placeGrid() {
+		return
+	}
+	for model.Enabled && !g.isStopped() {
+		if !g.retryGridOrders(time.Now()) {
+			return
+		}
+		if ohlcv := g.DataFeed.GetPriceForPairAtExchange(model.Conditions.Pair, g.ExchangeName, model.Conditions.MarketType); ohlcv != nil {
+			isBelow := model.Conditions.GridStopLowerPrice > 0 && ohlcv.Close < model.Conditions.GridStopLowerPrice
+			isAbove := model.Conditions.GridStopUpperPrice > 0 && ohlcv.Close > model.Conditions.GridStopUpperPrice
+			if isBelow || isAbove {
+				g.Strategy.GetLogger().Info("price left grid stop bounds", zap.Float64("price", ohlcv.Close))
+				g.Statsd.Inc("grid.stop_bounds")
+				g.closeAll(ohlcv.Close, End)
+				return
+			}
+		}
+		time.Sleep(1 * time.Second)
+	}
+	g.Stop()
+}
+
+// Stop cancels grid orders keeping the position, it's safe to call it more than once.
+func (g *Grid) Stop() {
+	g.mux.Lock()
+	if g.stopped {
+		g.mux.Unlock()
+		return
+	}
+	g.stopped = true
+	model := g.Strategy.GetModel()
+	orderIds := g.orderIds()
+	g.mux.Unlock()
+
+	g.Strategy.GetLogger().Info("stop grid", zap.Int("orders", len(orderIds)))
+	g.TryCancelAllOrdersConsistently(orderIds)
+	model.State.State = Canceled
+	g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+}
+
+// placeGrid buys or sells at market for the grid bias and places limit orders on levels, returns false on failure.
+func (g *Grid) placeGrid() bool {
+	model := g.Strategy.GetModel()
+	var ohlcv *interfaces.OHLCV
+	for attempt := 0; attempt < 10 && ohlcv == nil; attempt++ {
+		if ohlcv = g.DataFeed.GetPriceForPairAtExchange(model.Conditions.Pair, g.ExchangeName, model.Conditions.MarketType); ohlcv == nil {
+			time.Sleep(1 * time.Second)
+		}
+	}
+	if ohlcv == nil {
+		g.fail("no price to place grid")
+		return false
+	}
+	gridOrders, marketAmount := InitialOrders(model.Conditions, ohlcv.Close)
+	g.Strategy.GetLogger().Info("place grid",
+		zap.Float64("price", ohlcv.Close),
+		zap.Int("orders", len(gridOrders)),
+		zap.Float64("market amount", marketAmount),
+	)
+	if marketAmount != 0 {
+		side := "buy"
+		if marketAmount < 0 {
+			side = "sell"
+		}
+		response := g.createOrder(side, "market", 0, math.Abs(marketAmount), false)
+		if response.Status != "OK" {
+			g.fail(response.Data.Msg)
+			return false
+		}
+		model.State.GridPosition += marketAmount
+	}
+
+	g.mux.Lock()
+	model.State.State = Active
+	model.State.GridOrders = []*models.MongoGridOrder{}
+	g.mux.Unlock()
+	for _, gridOrder := range gridOrders {
+		gridOrder.Price = g.Levels[gridOrder.Level]
+		g.placeGridOrder(gridOrder)
+	}
+	g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	return true
+}
+
+// placeGridOrder places a limit order for the level and subscribes to its updates. The order failed to place is kept
+// in the state without id to be retried with backoff, so the level is not lost.
+func (g *Grid) placeGridOrder(gridOrder *models.MongoGridOrder) {
+	model := g.Strategy.GetModel()
+	isReduceOnly := model.Conditions.MarketType == 1 && gridOrder.EntryPrice > 0
+	response := g.createOrder(gridOrder.Side, "limit", gridOrder.Price, gridOrder.Amount, isReduceOnly)
+	isPlaced := response.Status == "OK" && response.Data.OrderId != ""
+	g.mux.Lock()
+	if isPlaced {
+		gridOrder.OrderId = response.Data.OrderId
+		gridOrder.Attempts = 0
+		gridOrder.RetryAt = 0
+	} else {
+		gridOrder.Attempts += 1
+		gridOrder.RetryAt = time.Now().Unix() + int64(math.Min(math.Pow(2, float64(gridOrder.Attempts)), maxRetryDelay))
+	}
+	isKept := false
+	for _, kept := range model.State.GridOrders {
+		if kept == gridOrder {
+			isKept = true
+			break
+		}
+	}
+	if !isKept {
+		model.State.GridOrders = append(model.State.GridOrders, gridOrder)
+	}
+	g.mux.Unlock()
+	if !isPlaced {
+		g.Strategy.GetLogger().Error("can't place grid order",
+			zap.Int("level", gridOrder.Level),
+			zap.String("side", gridOrder.Side),
+			zap.Int("attempts", gridOrder.Attempts),
+			zap.String("msg", response.Data.Msg),
+		)
+		g.Statsd.Inc("grid.place_order_error")
+		return
+	}
+	go g.StateMgmt.SubscribeToOrder(gridOrder.OrderId, g.onOrderStatusUpdate)
+}
+
+// retryGridOrders places again orders failed to place once their retry time comes, returns false if the grid failed
+// as an order was not placed in max attempts.
+func (g *Grid) retryGridOrders(now time.Time) bool {
+	model := g.Strategy.GetModel()
+	var due []*models.MongoGridOrder
+	g.mux.Lock()
+	for _, gridOrder := range model.State.GridOrders {
+		if gridOrder.OrderId != "" {
+			continue
+		}
+		if gridOrder.Attempts >= maxPlaceAttempts {
+			g.mux.Unlock()
+			g.fail(fmt.Sprintf("can't place grid %v order on level %v in %v attempts", gridOrder.Side, gridOrder.Level, gridOrder.Attempts))
+			return false
+		}
+		if gridOrder.RetryAt <= now.Unix() {
+			due = append(due, gridOrder)
+		}
+	}
+	g.mux.Unlock()
+	if len(due) == 0 {
+		return true
+	}
+	for _, gridOrder := range due {
+		g.Statsd.Inc("grid.place_order_retry")
+		g.placeGridOrder(gridOrder)
+	}
+	g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	return true
+}
+
+// onOrderStatusUpdate realises profit of the filled grid order and places the opposite one a level away. An order
+// canceled not by the grid is countered for the amount it filled and placed again for the rest.
+func (g *Grid) onOrderStatusUpdate(order *models.MongoOrder) {
+	if order == nil || order.Status != "filled" && order.Status != "canceled" {
+		return
+	}
+	model := g.Strategy.GetModel()
+	g.mux.Lock()
+	if g.stopped || g.filled[order.OrderId] {
+		g.mux.Unlock()
+		return
+	}
+	var filled *models.MongoGridOrder
+	for i, gridOrder := range model.State.GridOrders {
+		if gridOrder.OrderId == order.OrderId {
+			filled = gridOrder
+			model.State.GridOrders = append(model.State.GridOrders[:i], model.State.GridOrders[i+1:]...)
+			break
+		}
+	}
+	if filled == nil {
+		g.mux.Unlock()
+		return
+	}
+	g.filled[order.OrderId] = true
+	isCanceled := order.Status == "canceled"
+	price, amount := order.Average, order.Filled
+	if price == 0 {
+		price = filled.Price
+	}
+	if amount == 0 && !isCanceled {
+		amount = filled.Amount
+	}
+	var rest *models.MongoGridOrder
+	if isCanceled && filled.Amount-amount > 0 {
+		rest = &models.MongoGridOrder{
+			Level:      filled.Level,
+			Side:       filled.Side,
+			Price:      filled.Price,
+			Amount:     filled.Amount - amount,
+			EntryPrice: filled.EntryPrice,
+		}
+	}
+	if filled.Side == "buy" {
+		model.State.GridPosition += amount
+	} else {
+		model.State.GridPosition -= amount
+	}
+	pnl := RealizedPnl(filled, price, amount)
+	if filled.EntryPrice > 0 && amount > 0 {
+		model.State.GridRealizedPnl += pnl
+		model.State.ReceivedProfitAmount += pnl
+		model.State.GridTrades += 1
+	}
+	g.mux.Unlock()
+
+	if amount > 0 {
+		g.Strategy.GetLogger().Info("grid order filled",
+			zap.Int("level", filled.Level),
+			zap.String("side", filled.Side),
+			zap.Float64("price", price),
+			zap.Float64("amount", amount),
+			zap.Float64("pnl", pnl),
+		)
+		g.Statsd.Inc("grid.order_filled")
+		if filled.EntryPrice > 0 && model.Conditions.CreatedByTemplate {
+			go g.StateMgmt.SavePNL(model.Conditions.TemplateStrategyId, pnl)
+		}
+		if next := NextOrder(g.Levels, filled, price, amount); next != nil {
+			g.placeGridOrder(next)
+		}
+	}
+	if rest != nil {
+		g.Strategy.GetLogger().Info("grid order canceled, place the rest again",
+			zap.Int("level", rest.Level),
+			zap.String("side", rest.Side),
+			zap.Float64("amount", rest.Amount),
+		)
+		g.Statsd.Inc("grid.order_replaced")
+		g.placeGridOrder(rest)
+	}
+	g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+}
+
+// closeAll cancels grid orders, closes the position at market realising its profit and disables the strategy.
+func (g *Grid) closeAll(price float64, state string) {
+	g.mux.Lock()
+	if g.stopped {
+		g.mux.Unlock()
+		return
+	}
+	g.stopped = true
+	model := g.Strategy.GetModel()
+	orderIds := g.orderIds()
+	g.mux.Unlock()
+
+	g.TryCancelAllOrdersConsistently(orderIds)
+	pnl := 0.0
+	for _, gridOrder := range model.State.GridOrders {
+		pnl += RealizedPnl(gridOrder, price, gridOrder.Amount)
+	}
+	if position := toFixed(model.State.GridPosition, g.AmountPrecision, math.Round); position != 0 {
+		side := "sell"
+		if position < 0 {
+			side = "buy"
+		}
+		response := g.createOrder(side, "market", 0, math.Abs(position), model.Conditions.MarketType == 1)
+		if response.Status != "OK" {
+			g.Strategy.GetLogger().Error("can't close grid position", zap.String("msg", response.Data.Msg))
+		}
+		model.State.GridPosition = 0
+	}
+	model.State.GridRealizedPnl += pnl
+	model.State.ReceivedProfitAmount += pnl
+	if model.Conditions.CreatedByTemplate && pnl != 0 {
+		go g.StateMgmt.SavePNL(model.Conditions.TemplateStrategyId, pnl)
+	}
+	model.State.GridOrders = []*models.MongoGridOrder{}
+	model.State.State = state
+	g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	g.StateMgmt.DisableStrategy(model.ID)
+}
+
+func (g *Grid) fail(msg string) {
+	model := g.Strategy.GetModel()
+	g.Strategy.GetLogger().Error("grid failed", zap.String("msg", msg))
+	model.State.State = Error
+	model.State.Msg = msg
+	g.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	g.StateMgmt.DisableStrategy(model.ID)
+}
+
+func (g *Grid) createOrder(side, orderType string, price, amount float64, reduceOnly bool) orders.OrderResponse {
+	model := g.Strategy.GetModel()
+	request := orders.CreateOrderRequest{
+		KeyId: g.KeyId,
+		KeyParams: orders.Order{
+			Symbol:     model.Conditions.Pair,
+			MarketType: model.Conditions.MarketType,
+			Type:       orderType,
+			Side:       side,
+			Amount:     toFixed(amount, g.AmountPrecision, math.Floor),
+			Price:      price,
+			ReduceOnly: &reduceOnly,
+		},
+	}
+	if model.Conditions.MarketType == 1 {
+		request.KeyParams.PositionSide = "BOTH"
+	}
+	return g.ExchangeApi.CreateOrder(request)
+}
+
+// orderIds returns ids of open grid orders, the caller should hold the mutex.
+func (g *Grid) orderIds() []string {
+	orderIds := make([]string, 0, len(g.Strategy.GetModel().State.GridOrders))
+	for _, gridOrder := range g.Strategy.GetModel().State.GridOrders {
+		if gridOrder.OrderId != "" {
+			orderIds = append(orderIds, gridOrder.OrderId)
+		}
+	}
+	return orderIds
+}
+
+func (g *Grid) isStopped() bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.stopped
+}
+
+// PlaceOrder is not used by the grid, orders are placed by levels only.
+func (g *Grid) PlaceOrder(price, amount float64, step string) {}
+
+// SetSelectedExitTarget is not used by the grid.
+func (g *Grid) SetSelectedExitTarget(selectedExitTarget int) {}
+
+func (g *Grid) IsOrderExistsInMap(orderId string) bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	for _, gridOrder := range g.Strategy.GetModel().State.GridOrders {
+		if gridOrder.OrderId != "" && gridOrder.OrderId == orderId {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Grid) TryCancelAllOrdersConsistently(orderIds []string) {
+	for _, orderId := range orderIds {
+		g.ExchangeApi.CancelOrder(g.cancelRequest(orderId))
+	}
+}
+
+func (g *Grid) TryCancelAllOrders(orderIds []string) {
+	for _, orderId := range orderIds {
+		go g.ExchangeApi.CancelOrder(g.cancelRequest(orderId))
+	}
+}
+
+func (g *Grid) cancelRequest(orderId string) orders.CancelOrderRequest {
+	return orders.CancelOrderRequest{
+		KeyId: g.KeyId,
+		KeyParams: orders.CancelOrderRequestParams{
+			OrderId:    orderId,
+			MarketType: g.Strategy.GetModel().Conditions.MarketType,
+			Pair:       g.Strategy.GetModel().Conditions.Pair,
+		},
+	}
+}
+
+func toFixed(value float64, precision int64, round func(float64) float64) float64 {
+	pow := math.Pow(10, float64(precision))
+	return round(value*pow) / pow
+}
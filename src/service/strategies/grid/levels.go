@@ -0,0 +1,120 @@
+package grid
+
+import (
+	"errors"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"math"
+)
+
+// Grid biases.
+const (
+	Neutral = "neutral"
+	Long    = "long"
+	Short   = "short"
+)
+
+// Validate checks grid conditions are consistent.
+func Validate(conditions *models.MongoStrategyCondition) error {
+	switch {
+	case conditions.GridLowerPrice <= 0 || conditions.GridUpperPrice <= conditions.GridLowerPrice:
+		return errors.New("grid upper price should be above positive lower price")
+	case conditions.GridLevels < 2:
+		return errors.New("grid should have two levels at least")
+	case conditions.GridAmount <= 0:
+		return errors.New("grid amount should be positive")
+	case conditions.GridStopLowerPrice >= conditions.GridLowerPrice:
+		return errors.New("grid lower stop should be below the range")
+	case conditions.GridStopUpperPrice > 0 && conditions.GridStopUpperPrice <= conditions.GridUpperPrice:
+		return errors.New("grid upper stop should be above the range")
+	}
+	switch Bias(conditions) {
+	case Neutral, Long, Short:
+		return nil
+	}
+	return errors.New("unknown grid bias")
+}
+
+// Bias returns the grid bias, spot grids can't go short so they are long always.
+func Bias(conditions *models.MongoStrategyCondition) string {
+	if conditions.MarketType == 0 {
+		return Long
+	}
+	if conditions.GridBias == "" {
+		return Neutral
+	}
+	return conditions.GridBias
+}
+
+// Levels returns prices of levels evenly spaced from the lower to the upper price.
+func Levels(conditions *models.MongoStrategyCondition) []float64 {
+	levels := make([]float64, conditions.GridLevels)
+	step := (conditions.GridUpperPrice - conditions.GridLowerPrice) / float64(conditions.GridLevels-1)
+	for i := range levels {
+		levels[i] = conditions.GridLowerPrice + step*float64(i)
+	}
+	return levels
+}
+
+// ClosestLevel returns index of the level nearest to the price, no order is placed there initially.
+func ClosestLevel(levels []float64, price float64) int {
+	closest := 0
+	for i, level := range levels {
+		if math.Abs(level-price) < math.Abs(levels[closest]-price) {
+			closest = i
+		}
+	}
+	return closest
+}
+
+// InitialOrders returns orders to place around the price, buys below and sells above. The amount the grid should
+// buy (positive) or sell (negative) at market first is such that sells of long grid or buys of short one close it.
+func InitialOrders(conditions *models.MongoStrategyCondition, price float64) ([]*models.MongoGridOrder, float64) {
+	levels := Levels(conditions)
+	closest := ClosestLevel(levels, price)
+	bias := Bias(conditions)
+	gridOrders := make([]*models.MongoGridOrder, 0, len(levels)-1)
+	marketAmount := 0.0
+	for i, level := range levels {
+		if i == closest {
+			continue
+		}
+		gridOrder := &models.MongoGridOrder{Level: i, Side: "buy", Price: level, Amount: conditions.GridAmount}
+		if i > closest {
+			gridOrder.Side = "sell"
+		}
+		if bias == Long && gridOrder.Side == "sell" {
+			gridOrder.EntryPrice = price
+			marketAmount += conditions.GridAmount
+		} else if bias == Short && gridOrder.Side == "buy" {
+			gridOrder.EntryPrice = price
+			marketAmount -= conditions.GridAmount
+		}
+		gridOrders = append(gridOrders, gridOrder)
+	}
+	return gridOrders, marketAmount
+}
+
+// NextOrder returns an opposite order one level away from the order filled at the price, nil if out of range.
+func NextOrder(levels []float64, filled *models.MongoGridOrder, price, amount float64) *models.MongoGridOrder {
+	next := &models.MongoGridOrder{Level: filled.Level + 1, Side: "sell", Amount: amount, EntryPrice: price}
+	if filled.Side == "sell" {
+		next.Level = filled.Level - 1
+		next.Side = "buy"
+	}
+	if next.Level < 0 || next.Level >= len(levels) {
+		return nil
+	}
+	next.Price = levels[next.Level]
+	return next
+}
+
+// RealizedPnl returns profit of the order filled at the price if it closes a previous fill, zero otherwise.
+func RealizedPnl(filled *models.MongoGridOrder, price, amount float64) float64 {
+	if filled.EntryPrice == 0 {
+		return 0
+	}
+	if filled.Side == "sell" {
+		return (price - filled.EntryPrice) * amount
+	}
+	return (filled.EntryPrice - price) * amount
+}
@@ -0,0 +1,163 @@
+package makeronly_order
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
+	"log"
+	"math"
+	"time"
+)
+
+// Chase keeps the post-only order at the best price within the chase limits, re-pricing it not more often than the
+// re-price interval, and falls back once a limit is hit.
+func (mo *MakerOnlyOrder) Chase(now time.Time) {
+	model := mo.Strategy.GetModel()
+	if model.State.State == Fallback {
+		return
+	}
+	price, err := mo.getBestAskOrBidPrice()
+	if err != nil {
+		return
+	}
+	if model.State.EntryOrderId == "" {
+		mo.PlaceOrder(price, 0.0, PlaceOrder)
+		return
+	}
+	if limit := mo.chaseLimitReached(price, now); limit != "" {
+		if model.Conditions.MakerFallback != "" {
+			mo.fallback(limit, price)
+		}
+		return // the order rests at the last price without fallback
+	}
+	if price == model.State.MakerPrice || now.Unix() < model.State.MakerRepricedAt+model.Conditions.MakerRepriceInterval {
+		return
+	}
+	mo.PlaceOrder(price, 0.0, PlaceOrder)
+}
+
+// onPosted remembers the price the order was posted at, the first one starts the chase.
+func (mo *MakerOnlyOrder) onPosted(price float64, now time.Time) {
+	state := mo.Strategy.GetModel().State
+	if state.MakerInitialPrice == 0 {
+		state.MakerInitialPrice = price
+		state.MakerChaseStartedAt = now.Unix()
+	}
+	state.MakerPrice = price
+	state.MakerRepricedAt = now.Unix()
+}
+
+// chaseLimitReached returns the limit hit chasing to the price given or empty string if there is none.
+func (mo *MakerOnlyOrder) chaseLimitReached(price float64, now time.Time) string {
+	model := mo.Strategy.GetModel()
+	if model.State.MakerChaseStartedAt == 0 {
+		return ""
+	}
+	if model.Conditions.MakerMaxChaseTime > 0 && now.Unix() >= model.State.MakerChaseStartedAt+model.Conditions.MakerMaxChaseTime {
+		return "max chase time"
+	}
+	if model.Conditions.MakerMaxDistance > 0 && model.State.MakerInitialPrice > 0 {
+		distance := (price/model.State.MakerInitialPrice - 1) * 100
+		if model.Conditions.EntryOrder.Side == "sell" {
+			distance = -distance
+		}
+		if distance > model.Conditions.MakerMaxDistance {
+			return "max distance"
+		}
+	}
+	return ""
+}
+
+// limitPrice returns the worst price the max distance lets execute at, the price given if the distance is not set.
+func (mo *MakerOnlyOrder) limitPrice(price float64) float64 {
+	model := mo.Strategy.GetModel()
+	if model.Conditions.MakerMaxDistance == 0 || model.State.MakerInitialPrice == 0 {
+		return price
+	}
+	distance := model.Conditions.MakerMaxDistance / 100
+	if model.Conditions.EntryOrder.Side == "sell" {
+		distance = -distance
+	}
+	return toFixed(model.State.MakerInitialPrice*(1+distance), mo.QuantityPricePrecision, math.Round)
+}
+
+// fallback cancels the post-only order and takes the rest at market, with an immediate-or-cancel limit or cancels
+// the maker-only order as set.
+func (mo *MakerOnlyOrder) fallback(limit string, price float64) {
+	model := mo.Strategy.GetModel()
+	log.Println("maker-only chase limit reached ", limit, " fallback ", model.Conditions.MakerFallback)
+	orderId := model.State.EntryOrderId
+	response := mo.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+		KeyId: mo.KeyId,
+		KeyParams: orders.CancelOrderRequestParams{
+			OrderId:    orderId,
+			MarketType: model.Conditions.MarketType,
+			Pair:       model.Conditions.Pair,
+		},
+	})
+	if response.Status != "OK" {
+		log.Println("maker-only order already executed ", orderId)
+		return
+	}
+	mo.OrdersMux.Lock()
+	delete(mo.OrdersMap, orderId)
+	mo.OrdersMux.Unlock()
+	if response.Data.Filled > 0 {
+		mo.onFill(orderId, response.Data.Filled, response.Data.Average)
+	}
+	model.State.EntryOrderId = ""
+	if mo.remainingAmount() == 0 {
+		return
+	}
+	_ = mo.State.Fire(TriggerFallback)
+	model.State.State = Fallback
+
+	if model.Conditions.MakerFallback == models.MakerFallbackCancel {
+		mo.cancel()
+		return
+	}
+	order := orders.Order{
+		Side:         model.Conditions.EntryOrder.Side,
+		Amount:       mo.remainingAmount(),
+		Symbol:       model.Conditions.Pair,
+		MarketType:   model.Conditions.MarketType,
+		ReduceOnly:   &model.Conditions.EntryOrder.ReduceOnly,
+		PositionSide: mo.positionSide(),
+		Type:         "market",
+	}
+	if model.Conditions.MakerFallback == models.MakerFallbackIOC {
+		order.Type = "limit"
+		order.Price = mo.limitPrice(price)
+		order.TimeInForce = "IOC"
+	}
+	response = mo.ExchangeApi.CreateOrder(orders.CreateOrderRequest{
+		KeyId:     model.AccountId,
+		KeyParams: order,
+	})
+	if response.Data.OrderId == "" {
+		log.Println("maker-only fallback order error ", response.Data.Msg)
+		model.Enabled = false
+		model.State.State = Error
+		model.State.Msg = response.Data.Msg
+		go mo.StateMgmt.UpdateState(model.ID, model.State)
+		return
+	}
+	mo.OrdersMux.Lock()
+	mo.OrdersMap[response.Data.OrderId] = true
+	mo.OrdersMux.Unlock()
+	model.State.EntryOrderId = response.Data.OrderId
+	go mo.StateMgmt.UpdateState(model.ID, model.State)
+	go mo.waitForOrder(response.Data.OrderId, model.State.State)
+}
+
+// cancel ends the maker-only order not filled, the order gets canceled on stop.
+func (mo *MakerOnlyOrder) cancel() {
+	model := mo.Strategy.GetModel()
+	model.Enabled = false
+	go mo.StateMgmt.DisableStrategy(model.ID)
+	go mo.StateMgmt.UpdateState(model.ID, model.State)
+}
+
+func toFixed(value float64, precision int64, round func(float64) float64) float64 {
+	pow := math.Pow(10, float64(precision))
+	return round(value*pow) / pow
+}
@@ -0,0 +1,99 @@
+package makeronly_order
+
+import (
+	"context"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"log"
+	"math"
+	"time"
+)
+
+// remainingAmount returns the amount left to fill by the next order.
+func (mo *MakerOnlyOrder) remainingAmount() float64 {
+	model := mo.Strategy.GetModel()
+	remaining := toFixed(model.Conditions.EntryOrder.Amount-model.State.ExecutedAmount, mo.QuantityAmountPrecision, math.Round)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// onFill takes the amount the order filled in total at its average price, fills of the order taken already are
+// skipped. The maker-only order gets partially filled or filled, the synthetic order gets the total filled.
+func (mo *MakerOnlyOrder) onFill(orderId string, filled, average float64) {
+	if !mo.recordFill(orderId, filled, average) {
+		return
+	}
+	model := mo.Strategy.GetModel()
+	log.Println("maker-only order filled ", model.State.ExecutedAmount, " at ", model.State.EntryPrice)
+	go mo.StateMgmt.UpdateEntryPrice(model.ID, model.State)
+	go mo.StateMgmt.UpdateExecutedAmount(model.ID, model.State)
+
+	if mo.remainingAmount() > 0 {
+		if err := mo.State.Fire(TriggerPartialFill); err == nil {
+			model.State.State = PartiallyFilled
+		}
+		go mo.StateMgmt.UpdateState(model.ID, model.State)
+		mo.saveMakerOnlyOrder("open")
+		return
+	}
+	mo.saveMakerOnlyOrder("filled")
+	err := mo.State.Fire(CheckExistingOrders)
+	mo.enterFilled(context.TODO())
+	if err != nil {
+		log.Println("waitOrder err ", err.Error())
+	}
+}
+
+// recordFill updates fills with the order given and sums them up into executed amount and entry price.
+// Returns false if the fill was taken already.
+func (mo *MakerOnlyOrder) recordFill(orderId string, filled, average float64) bool {
+	mo.FillsMux.Lock()
+	defer mo.FillsMux.Unlock()
+	state := mo.Strategy.GetModel().State
+	var orderFill *models.MongoMakerFill
+	for _, fill := range state.MakerFills {
+		if fill.OrderId == orderId {
+			orderFill = fill
+			break
+		}
+	}
+	if orderFill == nil {
+		orderFill = &models.MongoMakerFill{OrderId: orderId}
+		state.MakerFills = append(state.MakerFills, orderFill)
+	}
+	if filled <= orderFill.Filled {
+		return false
+	}
+	orderFill.Filled = filled
+	orderFill.Average = average
+
+	var total, cost float64
+	for _, fill := range state.MakerFills {
+		total += fill.Filled
+		cost += fill.Filled * fill.Average
+	}
+	state.ExecutedAmount = toFixed(total, mo.QuantityAmountPrecision, math.Round)
+	state.EntryPrice = cost / total
+	return true
+}
+
+// saveMakerOnlyOrder writes the total filled to the synthetic order once it's loaded.
+func (mo *MakerOnlyOrder) saveMakerOnlyOrder(status string) {
+	state := mo.Strategy.GetModel().State
+	filled, average := state.ExecutedAmount, state.EntryPrice
+	go func() {
+		for {
+			if mo.MakerOnlyOrder != nil {
+				mo.MakerOnlyOrder.Average = average
+				mo.MakerOnlyOrder.Filled = filled
+				mo.MakerOnlyOrder.Status = status
+				go mo.StateMgmt.SaveOrder(*mo.MakerOnlyOrder, mo.KeyId, mo.Strategy.GetModel().Conditions.MarketType)
+				break
+			} else {
+				time.Sleep(300 * time.Millisecond)
+				continue
+			}
+		}
+	}()
+}
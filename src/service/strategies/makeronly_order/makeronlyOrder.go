@@ -19,12 +19,20 @@ const (
 	Filled          = "Filled"
 	Canceled        = "Canceled"
 	Error           = "Error"
+	Fallback        = "Fallback" // chase limit hit, the rest is taken by the fallback order
 )
 
 const (
 	TriggerSpread        = "Spread"
 	TriggerOrderExecuted = "TriggerOrderExecuted"
 	CheckExistingOrders  = "CheckExistingOrders"
+	TriggerFallback      = "TriggerFallback"
+	TriggerPartialFill   = "TriggerPartialFill"
+)
+
+const (
+	orderEndTimeout  = 5 * time.Second // for the update of the order executed while being canceled
+	orderEndInterval = 100 * time.Millisecond
 )
 
 type MakerOnlyOrder struct {
@@ -46,6 +54,7 @@ type MakerOnlyOrder struct {
 	SelectedExitTarget      int
 	TemplateOrderId         string
 	OrdersMux               sync.Mutex
+	FillsMux                sync.Mutex
 	MakerOnlyOrder          *models.MongoOrder
 
 	OrderParams orders.Order
@@ -104,24 +113,62 @@ func (sm *MakerOnlyOrder) Stop() {
 	}
 }
 
-func (sm *MakerOnlyOrder) CancelEntryOrder() {
+// CancelEntryOrder cancels the order placed and takes its fill. Returns false if the order is not canceled and its
+// final state is unknown yet, so the rest must not be placed again.
+func (sm *MakerOnlyOrder) CancelEntryOrder() bool {
 	model := sm.Strategy.GetModel()
 	if model.State.EntryOrderId != "" {
+		orderId := model.State.EntryOrderId
 		response := sm.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
 			KeyId: sm.KeyId,
 			KeyParams: orders.CancelOrderRequestParams{
-				OrderId:    model.State.EntryOrderId,
+				OrderId:    orderId,
 				MarketType: model.Conditions.MarketType,
 				Pair:       model.Conditions.Pair,
 			},
 		})
-		model.State.EntryOrderId = ""
 		if response.Data.OrderId == "" {
-			// order was executed should be processed in other thread
-			return
+			// order was executed, its fill is taken by the order update or the order fetched
+			if !sm.waitForOrderEnd(orderId) {
+				log.Println("maker-only order not canceled and not ended ", orderId)
+				return false
+			}
+			model.State.EntryOrderId = ""
+			return true
+		}
+		if response.Data.Filled > 0 { // the rest is placed by the next order
+			sm.onFill(orderId, response.Data.Filled, response.Data.Average)
 		}
+		model.State.EntryOrderId = ""
 		// we canceled prev order now time to place new one
 	}
+	return true
+}
+
+// waitForOrderEnd waits for the update of the order ended or fetches its final state and takes the fill.
+// Returns false if the order is still open after the timeout.
+func (sm *MakerOnlyOrder) waitForOrderEnd(orderId string) bool {
+	for timeout := time.Now().Add(orderEndTimeout); ; time.Sleep(orderEndInterval) {
+		sm.OrdersMux.Lock()
+		_, isOpen := sm.OrdersMap[orderId]
+		sm.OrdersMux.Unlock()
+		if !isOpen {
+			return true
+		}
+		order := sm.StateMgmt.GetOrder(orderId)
+		if order != nil && (order.Status == "filled" || order.Status == "canceled" || order.Status == "expired") {
+			sm.OrdersMux.Lock()
+			delete(sm.OrdersMap, orderId)
+			sm.OrdersMux.Unlock()
+			if order.Filled > 0 {
+				sm.onFill(orderId, order.Filled, order.Average)
+			}
+			return true
+		}
+		if time.Now().After(timeout) {
+			return false
+		}
+	}
 }
 func (sm *MakerOnlyOrder) TryCancelAllOrders(orderIds []string)             {}
 func (sm *MakerOnlyOrder) TryCancelAllOrdersConsistently(orderIds []string) {}
@@ -129,6 +176,7 @@ func NewMakerOnlyOrder(strategy interfaces.IStrategy, DataFeed interfaces.IDataF
 	PO := &MakerOnlyOrder{Strategy: strategy, DataFeed: DataFeed, ExchangeApi: TradingAPI, KeyId: keyId, StateMgmt: stateMgmt, Lock: false, SelectedExitTarget: 0, OrdersMap: map[string]bool{}}
 	initState := PlaceOrder
 	model := strategy.GetModel()
+	PO.QuantityPricePrecision, PO.QuantityAmountPrecision = stateMgmt.GetMarketPrecision(model.Conditions.Pair, model.Conditions.MarketType)
 	go func() {
 		var mongoOrder *models.MongoOrder
 		for {
@@ -154,7 +202,15 @@ func NewMakerOnlyOrder(strategy interfaces.IStrategy, DataFeed interfaces.IDataF
 			2) wait N time
 			3) if possible place at better/worse price or stay
 	*/
-	State.Configure(PlaceOrder).Permit(CheckExistingOrders, Filled)
+	State.Configure(PlaceOrder).
+		Permit(CheckExistingOrders, Filled).
+		Permit(TriggerPartialFill, PartiallyFilled).
+		Permit(TriggerFallback, Fallback)
+	State.Configure(PartiallyFilled).
+		PermitReentry(TriggerPartialFill).
+		Permit(CheckExistingOrders, Filled).
+		Permit(TriggerFallback, Fallback)
+	State.Configure(Fallback).Permit(CheckExistingOrders, Filled)
 	State.Configure(Filled).OnEntry(PO.enterFilled)
 
 	State.Activate()
@@ -169,10 +225,17 @@ func NewMakerOnlyOrder(strategy interfaces.IStrategy, DataFeed interfaces.IDataF
 	return PO
 }
 
+// chaseInterval is how often the chase runs without spread updates, time limits of the chase are checked then.
+const chaseInterval = 3 * time.Second
+
+// Start chases the best price on every spread update of the market and once a chase interval until the order is filled or
+// canceled.
 func (sm *MakerOnlyOrder) Start() {
 	ctx := context.TODO()
 	state, _ := sm.State.State(ctx)
 	localState := sm.Strategy.GetModel().State.State
+	conditions := sm.Strategy.GetModel().Conditions
+	updates := sm.DataFeed.Subscribe(conditions.Pair, sm.ExchangeName, conditions.MarketType)
 
 	for state != Filled && state != Canceled && (sm.MakerOnlyOrder == nil || sm.MakerOnlyOrder.Status == "open") &&
 		localState != Filled && localState != Canceled {
@@ -182,25 +245,37 @@ func (sm *MakerOnlyOrder) Start() {
 		if !sm.Lock {
 			sm.processEventLoop()
 		}
-		time.Sleep(3 * time.Second)
+		updates = waitForSpreadUpdate(updates)
 		state, _ = sm.State.State(ctx)
 		localState = sm.Strategy.GetModel().State.State
-		log.Println("localState ", localState)
-		log.Println("sm.Strategy.GetModel().Enabled", sm.Strategy.GetModel().Enabled)
-		log.Println("lastUpdate", sm.Strategy.GetModel().LastUpdate)
+	}
+	if updates != nil {
+		sm.DataFeed.Unsubscribe(conditions.Pair, sm.ExchangeName, conditions.MarketType, updates)
 	}
 	sm.Stop()
 	println("STOPPED postonly")
 }
 
-func (sm *MakerOnlyOrder) processEventLoop() {
-	log.Println("loop")
-	currentSpread := sm.DataFeed.GetSpreadForPairAtExchange(sm.Strategy.GetModel().Conditions.Pair, sm.ExchangeName, sm.Strategy.GetModel().Conditions.MarketType)
-	if currentSpread != nil {
-		if sm.Strategy.GetModel().State.EntryOrderId == "" {
-			sm.PlaceOrder(0, 0.0, PlaceOrder)
-		} else if sm.Strategy.GetModel().State.EntryPrice != currentSpread.BestBid && sm.Strategy.GetModel().State.EntryPrice != currentSpread.BestAsk {
-			sm.PlaceOrder(0, 0.0, PlaceOrder)
+// waitForSpreadUpdate blocks until the spread of the market changes or for the chase interval. Returns nil updates
+// once they are closed, the feed is polled then.
+func waitForSpreadUpdate(updates <-chan interfaces.Update) <-chan interfaces.Update {
+	timer := time.NewTimer(chaseInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if update.Spread != nil { // price only updates leave the best bid and ask the same
+				return updates
+			}
+		case <-timer.C:
+			return updates
 		}
 	}
 }
+
+func (sm *MakerOnlyOrder) processEventLoop() {
+	sm.Chase(time.Now())
+}
@@ -7,39 +7,39 @@ import (
 	"time"
 )
 
-func (mo *MakerOnlyOrder) PlaceOrder(anything, amount float64, step string) {
+// PlaceOrder re-posts the post-only order for the rest to fill at the price given, at the best one if zero or on retries.
+func (mo *MakerOnlyOrder) PlaceOrder(price, amount float64, step string) {
 	log.Println("place order")
 	model := mo.Strategy.GetModel()
 	attemptsToPlaceOrder := 0
-	mo.CancelEntryOrder()
+	if !mo.CancelEntryOrder() {
+		return
+	}
 	orderId := ""
+	isFirstAttempt := true
 	for orderId == "" {
-		price, err := mo.getBestAskOrBidPrice()
-		if err != nil || (mo.MakerOnlyOrder != nil && mo.MakerOnlyOrder.Status == "filled") {
-			return
-		}
-		positionSide := ""
-		if model.Conditions.MarketType == 1 {
-			if model.Conditions.HedgeMode {
-				if model.Conditions.EntryOrder.Side == "sell" && model.Conditions.EntryOrder.ReduceOnly == false || model.Conditions.EntryOrder.Side == "buy" && model.Conditions.EntryOrder.ReduceOnly == true {
-					positionSide = "SHORT"
-				} else {
-					positionSide = "LONG"
-				}
-			} else {
-				positionSide = "BOTH"
+		if price == 0 || !isFirstAttempt {
+			var err error
+			price, err = mo.getBestAskOrBidPrice()
+			if err != nil {
+				return
 			}
 		}
+		isFirstAttempt = false
+		amount := mo.remainingAmount()
+		if amount == 0 || mo.MakerOnlyOrder != nil && mo.MakerOnlyOrder.Status == "filled" {
+			return
+		}
 		postOnly := true
 		order := orders.Order{
 			Side:         model.Conditions.EntryOrder.Side,
 			Price:        price,
-			Amount:       model.Conditions.EntryOrder.Amount,
+			Amount:       amount,
 			PostOnly:     &postOnly,
 			Symbol:       model.Conditions.Pair,
 			MarketType:   model.Conditions.MarketType,
 			ReduceOnly:   &model.Conditions.EntryOrder.ReduceOnly,
-			PositionSide: positionSide,
+			PositionSide: mo.positionSide(),
 			Type:         "limit",
 		}
 		if model.Conditions.MarketType == 1 {
@@ -60,6 +60,7 @@ func (mo *MakerOnlyOrder) PlaceOrder(anything, amount float64, step string) {
 			mo.OrdersMux.Lock()
 			mo.OrdersMap[response.Data.OrderId] = true
 			mo.OrdersMux.Unlock()
+			mo.onPosted(price, time.Now())
 			break
 		}
 		if len(response.Data.Msg) > 0 {
@@ -97,3 +98,17 @@ func (mo *MakerOnlyOrder) PlaceOrder(anything, amount float64, step string) {
 	go mo.StateMgmt.UpdateState(model.ID, model.State)
 	go mo.waitForOrder(orderId, model.State.State)
 }
+
+func (mo *MakerOnlyOrder) positionSide() string {
+	conditions := mo.Strategy.GetModel().Conditions
+	if conditions.MarketType != 1 {
+		return ""
+	}
+	if !conditions.HedgeMode {
+		return "BOTH"
+	}
+	if conditions.EntryOrder.Side == "sell" && conditions.EntryOrder.ReduceOnly == false || conditions.EntryOrder.Side == "buy" && conditions.EntryOrder.ReduceOnly == true {
+		return "SHORT"
+	}
+	return "LONG"
+}
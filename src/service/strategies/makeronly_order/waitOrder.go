@@ -1,10 +1,8 @@
 package makeronly_order
 
 import (
-	"context"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
 	"log"
-	"time"
 )
 
 func (mo *MakerOnlyOrder) waitForOrder(orderId string, orderStatus string) {
@@ -13,47 +11,33 @@ func (mo *MakerOnlyOrder) waitForOrder(orderId string, orderStatus string) {
 	_ = mo.StateMgmt.SubscribeToOrder(orderId, mo.orderCallback)
 }
 
+// orderCallback takes fills of orders placed, fallback order ended not filled up cancels the maker-only order.
 func (mo *MakerOnlyOrder) orderCallback(order *models.MongoOrder) {
-	ctx := context.TODO()
 	log.Println("order callback")
-	if order == nil || order.OrderId == "" || !(order.Status == "filled" || order.Status == "canceled") {
+	if order == nil || order.OrderId == "" {
 		return
 	}
+	isEnded := order.Status == "filled" || order.Status == "canceled" || order.Status == "expired"
 	mo.OrdersMux.Lock()
-	if _, ok := mo.OrdersMap[order.OrderId]; ok {
+	_, ok := mo.OrdersMap[order.OrderId]
+	if ok && isEnded {
 		delete(mo.OrdersMap, order.OrderId)
-	} else {
-		mo.OrdersMux.Unlock()
-		return
 	}
 	mo.OrdersMux.Unlock()
-	if order.Status == "filled" {
-		log.Println("in waitOrder")
-		state := mo.Strategy.GetModel().State
-		state.EntryPrice = order.Average
-		state.ExecutedAmount = order.Filled
-		go mo.StateMgmt.UpdateEntryPrice(mo.Strategy.GetModel().ID, state)
-		go mo.StateMgmt.UpdateExecutedAmount(mo.Strategy.GetModel().ID, state)
-
-		go func() {
-			for {
-				if mo.MakerOnlyOrder != nil {
-					mo.MakerOnlyOrder.Average = order.Average
-					mo.MakerOnlyOrder.Filled = order.Filled
-					mo.MakerOnlyOrder.Status = order.Status
-					go mo.StateMgmt.SaveOrder(*mo.MakerOnlyOrder, mo.KeyId, mo.Strategy.GetModel().Conditions.MarketType)
-					break
-				} else {
-					time.Sleep(300 * time.Millisecond)
-					continue
-				}
-			}
-		}()
-
-		err := mo.State.Fire(CheckExistingOrders)
-		mo.enterFilled(ctx)
-		if err != nil {
-			log.Println("waitOrder err ", err.Error())
-		}
+	if !ok {
+		return
+	}
+	if order.Filled > 0 {
+		mo.onFill(order.OrderId, order.Filled, order.Average)
+	}
+	model := mo.Strategy.GetModel()
+	if !isEnded || order.OrderId != model.State.EntryOrderId || mo.remainingAmount() == 0 {
+		return
+	}
+	if model.State.State == Fallback {
+		log.Println("maker-only fallback order not filled ", order.OrderId)
+		mo.cancel()
+		return
 	}
+	model.State.EntryOrderId = "" // the rest gets posted again
 }
@@ -0,0 +1,393 @@
+package pair
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"math"
+	"sync"
+	"time"
+)
+
+// Pair trading states.
+const (
+	WaitForEntry = "WaitForEntry"
+	Entering     = "Entering"
+	InEntry      = "InEntry"
+	Exiting      = "Exiting"
+	End          = "End"
+	Error        = "Error"
+)
+
+const (
+	checkInterval    = 1 * time.Second
+	legFillTimeout   = 10 * time.Second
+	legCancelTimeout = 5 * time.Second // for the canceled order update after the fill timeout
+	closeAttempts    = 3
+)
+
+// Pair trades the spread between two pairs: it goes long one pair and short the other when z-score of the spread
+// is beyond the entry threshold and exits both legs when the spread reverts to the mean or diverges to the stop.
+type Pair struct {
+	Strategy        interfaces.IStrategy
+	KeyId           *primitive.ObjectID
+	DataFeed        interfaces.IDataFeed
+	ExchangeApi     interfaces.ITrading
+	StateMgmt       interfaces.IStateMgmt
+	Statsd          interfaces.IStatsClient
+	ExchangeName    string
+	AmountPrecision map[string]int64
+
+	mux     sync.Mutex
+	stopped bool
+}
+
+// New creates a pair trading runtime for the strategy.
+func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAPI interfaces.ITrading, Statsd interfaces.IStatsClient, keyId *primitive.ObjectID, stateMgmt interfaces.IStateMgmt) *Pair {
+	model := strategy.GetModel()
+	pair := &Pair{
+		Strategy:        strategy,
+		KeyId:           keyId,
+		DataFeed:        DataFeed,
+		ExchangeApi:     TradingAPI,
+		StateMgmt:       stateMgmt,
+		Statsd:          Statsd,
+		ExchangeName:    model.Conditions.Exchange,
+		AmountPrecision: map[string]int64{},
+	}
+	if pair.ExchangeName == "" {
+		pair.ExchangeName = "binance"
+	}
+	for _, symbol := range []string{model.Conditions.Pair, model.Conditions.PairB} {
+		_, pair.AmountPrecision[symbol] = stateMgmt.GetMarketPrecision(symbol, model.Conditions.MarketType)
+	}
+	if model.State == nil {
+		model.State = &models.MongoStrategyState{}
+	}
+	return pair
+}
+
+// Start watches z-score of the spread to enter and exit legs while enabled.
+func (p *Pair) Start() {
+	model := p.Strategy.GetModel()
+	if err := Validate(model.Conditions); err != nil {
+		p.fail(err.Error())
+		return
+	}
+	switch model.State.State {
+	case Entering, Exiting:
+		// fills of orders placed before the restart are unknown, so legs may be unhedged
+		p.fail("interrupted while executing legs, check positions of both pairs")
+		return
+	case "", End:
+		model.State.State = WaitForEntry
+	}
+	p.Statsd.Inc("pair.start")
+	for model.Enabled && !p.isStopped() {
+		if z, priceA, priceB, hedgeRatio, ok := p.zScore(); ok {
+			switch model.State.State {
+			case WaitForEntry:
+				if side := EntrySide(model.Conditions, z); side != 0 {
+					p.Strategy.GetLogger().Info("enter spread", zap.Float64("z-score", z), zap.Int64("side", side))
+					p.enter(side, priceA, priceB, hedgeRatio)
+				}
+			case InEntry:
+				if isExit, reason := IsExit(model.Conditions, model.State.PairSide, z); isExit {
+					p.Strategy.GetLogger().Info("exit spread", zap.Float64("z-score", z), zap.String("reason", reason))
+					p.Statsd.Inc("pair.exit")
+					p.exit()
+				}
+			}
+		}
+		time.Sleep(checkInterval)
+	}
+	p.Stop()
+}
+
+// Stop stops watching the spread, legs entered are kept.
+func (p *Pair) Stop() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.stopped = true
+}
+
+// zScore returns z-score of the current spread over the lookback of closed candles with prices and hedge ratio.
+func (p *Pair) zScore() (float64, float64, float64, float64, bool) {
+	conditions := p.Strategy.GetModel().Conditions
+	timeframe, lookback := conditions.PairTimeframe, int(conditions.PairLookback)
+	if timeframe == 0 {
+		timeframe = DefaultTimeframe
+	}
+	if lookback == 0 {
+		lookback = DefaultLookback
+	}
+	candlesA := indicators.GetCandles(p.DataFeed, conditions.Pair, p.ExchangeName, conditions.MarketType, timeframe)
+	candlesB := indicators.GetCandles(p.DataFeed, conditions.PairB, p.ExchangeName, conditions.MarketType, timeframe)
+	closesA, closesB := AlignedCloses(candlesA, candlesB)
+	if len(closesA) < lookback {
+		return 0, 0, 0, 0, false
+	}
+	closesA, closesB = closesA[len(closesA)-lookback:], closesB[len(closesB)-lookback:]
+	hedgeRatio, ok := 1.0, true
+	if conditions.PairSpreadMode != RatioMode {
+		hedgeRatio, ok = HedgeRatio(conditions, closesA, closesB)
+	}
+	ohlcvA := p.DataFeed.GetPriceForPairAtExchange(conditions.Pair, p.ExchangeName, conditions.MarketType)
+	ohlcvB := p.DataFeed.GetPriceForPairAtExchange(conditions.PairB, p.ExchangeName, conditions.MarketType)
+	if !ok || ohlcvA == nil || ohlcvB == nil {
+		return 0, 0, 0, 0, false
+	}
+	history := make([]float64, lookback)
+	for i := range history {
+		history[i] = Spread(conditions.PairSpreadMode, closesA[i], closesB[i], hedgeRatio)
+	}
+	z, ok := ZScore(history, Spread(conditions.PairSpreadMode, ohlcvA.Close, ohlcvB.Close, hedgeRatio))
+	return z, ohlcvA.Close, ohlcvB.Close, hedgeRatio, ok
+}
+
+// enter executes both legs at market at once and trims the leg filled over the hedge, so no leg is left naked.
+func (p *Pair) enter(side int64, priceA, priceB, hedgeRatio float64) {
+	model := p.Strategy.GetModel()
+	amountA, amountB := LegAmounts(model.Conditions, priceA, priceB, hedgeRatio)
+	amountA = p.toFixed(model.Conditions.Pair, amountA)
+	amountB = p.toFixed(model.Conditions.PairB, amountB)
+	if amountA <= 0 || amountB <= 0 {
+		p.Strategy.GetLogger().Warn("leg amount is below precision", zap.Float64("amount A", amountA), zap.Float64("amount B", amountB))
+		return
+	}
+	sideA, sideB := "buy", "sell"
+	if side == ShortSpread {
+		sideA, sideB = "sell", "buy"
+	}
+	model.State.State = Entering
+	model.State.PairSide = side
+	model.State.PairLegs = []*models.MongoPairLeg{
+		{Pair: model.Conditions.Pair, Side: sideA, Amount: amountA},
+		{Pair: model.Conditions.PairB, Side: sideB, Amount: amountB},
+	}
+	p.StateMgmt.UpdateStrategyState(model.ID, model.State)
+
+	var wg sync.WaitGroup
+	for _, leg := range model.State.PairLegs {
+		wg.Add(1)
+		go func(leg *models.MongoPairLeg) {
+			defer wg.Done()
+			leg.Filled, leg.EntryPrice = p.execute(leg.Pair, leg.Side, leg.Amount, false)
+		}(leg)
+	}
+	wg.Wait()
+
+	legA, legB := model.State.PairLegs[0], model.State.PairLegs[1]
+	hedgedA, hedgedB := Hedged(legA.Filled, legB.Filled, amountB/amountA)
+	if legA.Filled != amountA || legB.Filled != amountB {
+		p.Strategy.GetLogger().Warn("legs filled partially",
+			zap.Float64("filled A", legA.Filled),
+			zap.Float64("filled B", legB.Filled),
+			zap.Float64("hedged A", hedgedA),
+			zap.Float64("hedged B", hedgedB),
+		)
+		p.Statsd.Inc("pair.partial_legs")
+		p.closeLeg(legA, legA.Filled-p.toFixed(legA.Pair, hedgedA))
+		p.closeLeg(legB, legB.Filled-p.toFixed(legB.Pair, hedgedB))
+	}
+	if p.toFixed(legA.Pair, hedgedA) <= 0 || p.toFixed(legB.Pair, hedgedB) <= 0 {
+		// a leg failed, don't retry entering over and over
+		if p.finish(WaitForEntry) { // failed already if a leg is left open
+			p.fail("legs were not filled, nothing is hedged")
+		}
+		return
+	}
+	model.State.State = InEntry
+	model.State.EntryPrice = Spread(model.Conditions.PairSpreadMode, legA.EntryPrice, legB.EntryPrice, hedgeRatio)
+	p.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	p.Statsd.Inc("pair.enter")
+}
+
+// exit closes what is left of both legs at market and finishes the iteration.
+func (p *Pair) exit() {
+	model := p.Strategy.GetModel()
+	model.State.State = Exiting
+	p.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	var wg sync.WaitGroup
+	for _, leg := range model.State.PairLegs {
+		wg.Add(1)
+		go func(leg *models.MongoPairLeg) {
+			defer wg.Done()
+			p.closeLeg(leg, leg.Filled-leg.Closed)
+		}(leg)
+	}
+	wg.Wait()
+	state := End
+	if model.Conditions.ContinueIfEnded {
+		state = WaitForEntry
+	}
+	p.finish(state)
+}
+
+// closeLeg closes the amount of the leg at market retrying the rest if it's filled partially.
+func (p *Pair) closeLeg(leg *models.MongoPairLeg, amount float64) {
+	side := "sell"
+	if leg.Side == "sell" {
+		side = "buy"
+	}
+	for attempt := 0; attempt < closeAttempts && p.toFixed(leg.Pair, amount) > 0; attempt++ {
+		closed, price := p.execute(leg.Pair, side, amount, true)
+		if closed > 0 {
+			leg.ExitPrice = (leg.ExitPrice*leg.Closed + price*closed) / (leg.Closed + closed)
+			leg.Closed += closed
+			amount -= closed
+		}
+	}
+}
+
+// finish realises profit of legs closed and goes to the state given unless a leg is left open, returns false if the
+// strategy failed with a leg left open.
+func (p *Pair) finish(state string) bool {
+	model := p.Strategy.GetModel()
+	pnl := 0.0
+	for _, leg := range model.State.PairLegs {
+		pnl += LegPnl(leg)
+		if left := p.toFixed(leg.Pair, leg.Filled-leg.Closed); left > 0 {
+			p.fail("can't close " + leg.Pair + " leg, close it manually")
+			return false
+		}
+	}
+	model.State.ReceivedProfitAmount += pnl
+	if model.Conditions.CreatedByTemplate && pnl != 0 {
+		go p.StateMgmt.SavePNL(model.Conditions.TemplateStrategyId, pnl)
+	}
+	p.Strategy.GetLogger().Info("legs closed", zap.Float64("pnl", pnl), zap.String("state", state))
+	model.State.PairLegs = []*models.MongoPairLeg{}
+	model.State.PairSide = 0
+	model.State.State = state
+	if state == WaitForEntry {
+		model.State.Iteration += 1
+	}
+	p.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	if state == End {
+		p.Stop()
+		p.StateMgmt.DisableStrategy(model.ID)
+	}
+	return true
+}
+
+// execute places a market order and waits for its fill, returns amount filled and average price. The order not
+// filled in time is canceled and the amount it filled by then is returned, so legs are hedged by what they hold.
+func (p *Pair) execute(symbol, side string, amount float64, reduceOnly bool) (float64, float64) {
+	model := p.Strategy.GetModel()
+	request := orders.CreateOrderRequest{
+		KeyId: p.KeyId,
+		KeyParams: orders.Order{
+			Symbol:       symbol,
+			MarketType:   model.Conditions.MarketType,
+			Type:         "market",
+			Side:         side,
+			Amount:       p.toFixed(symbol, amount),
+			ReduceOnly:   &reduceOnly,
+			PositionSide: "BOTH",
+		},
+	}
+	response := p.ExchangeApi.CreateOrder(request)
+	if response.Status != "OK" || response.Data.OrderId == "" {
+		p.Strategy.GetLogger().Error("can't place leg order",
+			zap.String("pair", symbol),
+			zap.String("side", side),
+			zap.String("msg", response.Data.Msg),
+		)
+		p.Statsd.Inc("pair.order_error")
+		return 0, 0
+	}
+	orderId := response.Data.OrderId
+	updates := make(chan *models.MongoOrder, 1)
+	go p.StateMgmt.SubscribeToOrder(orderId, func(order *models.MongoOrder) {
+		if order.Status == "filled" || order.Status == "canceled" {
+			select {
+			case updates <- order:
+			default:
+			}
+		}
+	})
+	select {
+	case order := <-updates:
+		return p.legFill(symbol, order.Status, order.Filled, order.Average, request.KeyParams.Amount)
+	case <-time.After(legFillTimeout):
+	}
+
+	p.Strategy.GetLogger().Error("leg order not filled in time, cancel", zap.String("orderId", orderId))
+	p.Statsd.Inc("pair.leg_timeout")
+	cancelResponse := p.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+		KeyId: p.KeyId,
+		KeyParams: orders.CancelOrderRequestParams{
+			OrderId:    orderId,
+			MarketType: model.Conditions.MarketType,
+			Pair:       symbol,
+		},
+	})
+	select {
+	case order := <-updates:
+		return p.legFill(symbol, order.Status, order.Filled, order.Average, request.KeyParams.Amount)
+	case <-time.After(legCancelTimeout):
+	}
+	if order := p.StateMgmt.GetOrder(orderId); order != nil && (order.Status == "filled" || order.Status == "canceled") {
+		return p.legFill(symbol, order.Status, order.Filled, order.Average, request.KeyParams.Amount)
+	}
+	p.Strategy.GetLogger().Warn("no final state of leg order, filled amount is taken from cancel",
+		zap.String("orderId", orderId),
+		zap.String("status", cancelResponse.Status),
+		zap.Float64("filled", cancelResponse.Data.Filled),
+	)
+	return p.legFill(symbol, cancelResponse.Data.Status, cancelResponse.Data.Filled, cancelResponse.Data.Average, request.KeyParams.Amount)
+}
+
+// legFill returns amount filled and average price of the leg order, the price of the pair if the average is unknown.
+func (p *Pair) legFill(symbol, status string, filled, price, amount float64) (float64, float64) {
+	if status == "filled" && filled == 0 {
+		filled = amount
+	}
+	if price == 0 && filled > 0 {
+		model := p.Strategy.GetModel()
+		if ohlcv := p.DataFeed.GetPriceForPairAtExchange(symbol, p.ExchangeName, model.Conditions.MarketType); ohlcv != nil {
+			price = ohlcv.Close
+		}
+	}
+	return filled, price
+}
+
+func (p *Pair) fail(msg string) {
+	model := p.Strategy.GetModel()
+	p.Strategy.GetLogger().Error("pair trading failed", zap.String("msg", msg))
+	model.State.State = Error
+	model.State.Msg = msg
+	p.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	p.Stop()
+	p.StateMgmt.DisableStrategy(model.ID)
+}
+
+func (p *Pair) toFixed(symbol string, amount float64) float64 {
+	pow := math.Pow(10, float64(p.AmountPrecision[symbol]))
+	return math.Floor(amount*pow+1e-9) / pow
+}
+
+func (p *Pair) isStopped() bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.stopped
+}
+
+// PlaceOrder is not used by pair trading, legs are executed at market only.
+func (p *Pair) PlaceOrder(price, amount float64, step string) {}
+
+// SetSelectedExitTarget is not used by pair trading.
+func (p *Pair) SetSelectedExitTarget(selectedExitTarget int) {}
+
+// IsOrderExistsInMap is always false since pair trading keeps no orders open.
+func (p *Pair) IsOrderExistsInMap(orderId string) bool {
+	return false
+}
+
+func (p *Pair) TryCancelAllOrders(orderIds []string) {}
+
+func (p *Pair) TryCancelAllOrdersConsistently(orderIds []string) {}
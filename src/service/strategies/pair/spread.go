@@ -0,0 +1,175 @@
+package pair
+
+import (
+	"errors"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"math"
+)
+
+// Spread modes.
+const (
+	SpreadMode = "spread"
+	RatioMode  = "ratio"
+)
+
+// Spread sides.
+const (
+	LongSpread  = 1  // long A, short B
+	ShortSpread = -1 // short A, long B
+)
+
+// Defaults for conditions not set.
+const (
+	DefaultTimeframe   = 60
+	DefaultLookback    = 20
+	DefaultEntryZScore = 2
+)
+
+// Validate checks pair trading conditions are consistent, legs are shorted so it's futures only.
+func Validate(conditions *models.MongoStrategyCondition) error {
+	switch {
+	case conditions.MarketType != 1:
+		return errors.New("pair trading needs futures to short a leg")
+	case conditions.Pair == "" || conditions.PairB == "" || conditions.Pair == conditions.PairB:
+		return errors.New("pair trading needs two different pairs")
+	case conditions.PairAmount <= 0:
+		return errors.New("pair amount should be positive")
+	case conditions.PairHedgeRatio < 0:
+		return errors.New("hedge ratio should not be negative")
+	case conditions.PairSpreadMode != "" && conditions.PairSpreadMode != SpreadMode && conditions.PairSpreadMode != RatioMode:
+		return errors.New("unknown spread mode")
+	case conditions.PairStopZScore > 0 && conditions.PairStopZScore <= entryZScore(conditions):
+		return errors.New("stop z-score should be beyond entry z-score")
+	case conditions.PairExitZScore >= entryZScore(conditions):
+		return errors.New("exit z-score should be within entry z-score")
+	}
+	return nil
+}
+
+func entryZScore(conditions *models.MongoStrategyCondition) float64 {
+	if conditions.PairEntryZScore > 0 {
+		return conditions.PairEntryZScore
+	}
+	return DefaultEntryZScore
+}
+
+// AlignedCloses returns closes of candles both pairs closed at the same time, a forming candle is skipped.
+func AlignedCloses(candlesA, candlesB []interfaces.Candle) ([]float64, []float64) {
+	if len(candlesA) == 0 || len(candlesB) == 0 {
+		return nil, nil
+	}
+	candlesA, candlesB = candlesA[:len(candlesA)-1], candlesB[:len(candlesB)-1]
+	closesB := make(map[int64]float64, len(candlesB))
+	for _, candle := range candlesB {
+		closesB[candle.Timestamp] = candle.Close
+	}
+	var closesA, alignedB []float64
+	for _, candle := range candlesA {
+		if closeB, ok := closesB[candle.Timestamp]; ok {
+			closesA = append(closesA, candle.Close)
+			alignedB = append(alignedB, closeB)
+		}
+	}
+	return closesA, alignedB
+}
+
+// HedgeRatio returns the ratio from conditions or estimates it by least squares regression of A on B.
+func HedgeRatio(conditions *models.MongoStrategyCondition, closesA, closesB []float64) (float64, bool) {
+	if conditions.PairHedgeRatio > 0 {
+		return conditions.PairHedgeRatio, true
+	}
+	n := float64(len(closesA))
+	if len(closesA) < 2 {
+		return 0, false
+	}
+	var sumA, sumB, sumAB, sumBB float64
+	for i := range closesA {
+		sumA += closesA[i]
+		sumB += closesB[i]
+		sumAB += closesA[i] * closesB[i]
+		sumBB += closesB[i] * closesB[i]
+	}
+	variance := n*sumBB - sumB*sumB
+	if variance == 0 {
+		return 0, false
+	}
+	ratio := (n*sumAB - sumA*sumB) / variance
+	return ratio, ratio > 0
+}
+
+// Spread returns the spread of prices, hedge ratio is not used in ratio mode.
+func Spread(mode string, priceA, priceB, hedgeRatio float64) float64 {
+	if mode == RatioMode {
+		return priceA / priceB
+	}
+	return priceA - hedgeRatio*priceB
+}
+
+// ZScore returns how many standard deviations of the history the value is away from its mean.
+func ZScore(history []float64, value float64) (float64, bool) {
+	if len(history) < 2 {
+		return 0, false
+	}
+	mean := 0.0
+	for _, v := range history {
+		mean += v
+	}
+	mean /= float64(len(history))
+	variance := 0.0
+	for _, v := range history {
+		variance += (v - mean) * (v - mean)
+	}
+	deviation := math.Sqrt(variance / float64(len(history)))
+	if deviation == 0 {
+		return 0, false
+	}
+	return (value - mean) / deviation, true
+}
+
+// LegAmounts returns base amounts of A and B legs, ratio mode keeps notionals equal.
+func LegAmounts(conditions *models.MongoStrategyCondition, priceA, priceB, hedgeRatio float64) (float64, float64) {
+	if conditions.PairSpreadMode == RatioMode {
+		return conditions.PairAmount, conditions.PairAmount * priceA / priceB
+	}
+	return conditions.PairAmount, conditions.PairAmount * hedgeRatio
+}
+
+// EntrySide returns the side to enter the spread at the z-score, zero if it's within the entry threshold.
+func EntrySide(conditions *models.MongoStrategyCondition, z float64) int64 {
+	threshold := entryZScore(conditions)
+	switch {
+	case z <= -threshold:
+		return LongSpread
+	case z >= threshold:
+		return ShortSpread
+	}
+	return 0
+}
+
+// IsExit tells whether the spread reverted to the mean or diverged to the stop for the side entered.
+func IsExit(conditions *models.MongoStrategyCondition, side int64, z float64) (bool, string) {
+	directed := z * float64(side) // negative while the spread is away from the mean on the side entered
+	if directed >= -conditions.PairExitZScore {
+		return true, "mean reversion"
+	}
+	if conditions.PairStopZScore > 0 && directed <= -conditions.PairStopZScore {
+		return true, "stop"
+	}
+	return false, ""
+}
+
+// Hedged returns amounts of legs hedging each other given filled amounts and B units per unit of A.
+func Hedged(filledA, filledB, factor float64) (float64, float64) {
+	hedgedA := math.Min(filledA, filledB/factor)
+	return hedgedA, hedgedA * factor
+}
+
+// LegPnl returns profit of the leg closed so far.
+func LegPnl(leg *models.MongoPairLeg) float64 {
+	pnl := (leg.ExitPrice - leg.EntryPrice) * leg.Closed
+	if leg.Side == "sell" {
+		return -pnl
+	}
+	return pnl
+}
@@ -0,0 +1,53 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/pair"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// RunPair starts a pair trading runtime for the strategy with given interfaces to market data and trading API.
+func RunPair(strategy *Strategy, df interfaces.IDataFeed, td interfaces.ITrading, st interfaces.IStatsClient, keyId *primitive.ObjectID) interfaces.IStrategyRuntime {
+	if strategy.Model.Conditions.Leverage == 0 {
+		strategy.Model.Conditions.Leverage = 1
+	}
+	if keyId == nil {
+		KeyAssets := mongodb.GetCollection("core_key_assets") // TODO: move to statemgmt, avoid any direct dependecies here
+		var keyAsset KeyAsset
+		err := KeyAssets.FindOne(context.Background(), bson.D{{"_id", strategy.Model.Conditions.KeyAssetId}}).Decode(&keyAsset)
+		if err != nil {
+			strategy.Log.Error("can't find a key asset",
+				zap.String("key asset", fmt.Sprintf("%+v", keyAsset)),
+				zap.String("cursor err", err.Error()),
+			)
+		}
+		keyId = &keyAsset.KeyId
+	}
+	if strategy.Model.State == nil {
+		strategy.Model.State = &models.MongoStrategyState{}
+	}
+	isFirstStart := strategy.Model.State.State == ""
+	if strategy.Model.Conditions.MarketType == 1 && isFirstStart && !strategy.Model.Conditions.SkipInitialSetup {
+		for _, symbol := range []string{strategy.Model.Conditions.Pair, strategy.Model.Conditions.PairB} {
+			res := td.UpdateLeverage(keyId, strategy.Model.Conditions.Leverage, symbol)
+			if res.Status != "OK" {
+				strategy.Log.Error("can't update leverage",
+					zap.String("pair", symbol),
+					zap.String("trading interface response", res.ErrorMessage),
+				)
+			}
+		}
+	}
+
+	runtime := pair.New(strategy, df, td, st, keyId, strategy.StateMgmt)
+	strategy.Log.Info("start pair trading runtime")
+	go runtime.Start()
+
+	return runtime
+}
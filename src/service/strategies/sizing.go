@@ -0,0 +1,122 @@
+package strategies
+
+import (
+	"errors"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+	"math"
+	"time"
+)
+
+// Taker fees in percents used to estimate sizing if no fee given.
+const (
+	spotFeeEstimate    = 0.1
+	futuresFeeEstimate = 0.04
+)
+
+// IsRiskSizing tells whether the entry amount should be derived from risk per trade.
+func IsRiskSizing(conditions *models.MongoStrategyCondition) bool {
+	return conditions.RiskPercent > 0 || conditions.RiskAmount > 0
+}
+
+// StopLossPriceFor returns the price stop-loss triggers at for the entry price given, zero if there is no stop-loss.
+func StopLossPriceFor(conditions *models.MongoStrategyCondition, entryPrice float64) float64 {
+	if conditions.StopLossPrice > 0 {
+		return conditions.StopLossPrice
+	}
+	if conditions.StopLoss <= 0 {
+		return 0
+	}
+	leverage := math.Max(conditions.Leverage, 1)
+	distance := conditions.StopLoss / 100 / leverage
+	if conditions.EntryOrder.Side == "sell" {
+		return entryPrice * (1 + distance)
+	}
+	return entryPrice * (1 - distance)
+}
+
+// CalculateRiskEntryAmount returns an amount losing the risk given on stop-loss, capped by leverage and max notional
+// and rounded down to the amount precision.
+func CalculateRiskEntryAmount(conditions *models.MongoStrategyCondition, balance, entryPrice float64, amountPrecision int64) (float64, error) {
+	if entryPrice <= 0 {
+		return 0, errors.New("unknown entry price")
+	}
+	stopPrice := StopLossPriceFor(conditions, entryPrice)
+	if stopPrice <= 0 {
+		return 0, errors.New("stop-loss is required for risk based sizing")
+	}
+	isWrongSide := conditions.EntryOrder.Side == "sell" && stopPrice <= entryPrice ||
+		conditions.EntryOrder.Side != "sell" && stopPrice >= entryPrice
+	if isWrongSide {
+		return 0, errors.New("stop-loss price is on the wrong side of entry")
+	}
+	risk := conditions.RiskAmount
+	if risk <= 0 {
+		risk = balance / 100 * conditions.RiskPercent
+	}
+	fee := conditions.FeeEstimate
+	if fee == 0 {
+		fee = spotFeeEstimate
+		if conditions.MarketType == 1 {
+			fee = futuresFeeEstimate
+		}
+	}
+	lossPerUnit := math.Abs(entryPrice-stopPrice) + (entryPrice+stopPrice)*fee/100 + stopPrice*conditions.Slippage/100
+	amount := risk / lossPerUnit
+
+	leverage := math.Max(conditions.Leverage, 1)
+	if maxAmount := balance * leverage / entryPrice; amount > maxAmount {
+		amount = maxAmount
+	}
+	if conditions.MaxNotional > 0 && amount*entryPrice > conditions.MaxNotional {
+		amount = conditions.MaxNotional / entryPrice
+	}
+	rank := math.Pow(10, float64(amountPrecision))
+	amount = math.Floor(amount*rank) / rank
+	if amount <= 0 {
+		return 0, errors.New("risk is too small for the market precision")
+	}
+	return amount, nil
+}
+
+// DetermineRiskEntryAmount sets entry amount from the risk per trade, the stop-loss distance and the equity of the key
+// asset, so funds locked in open orders and positions count.
+func DetermineRiskEntryAmount(strategy *Strategy, df interfaces.IDataFeed) {
+	conditions := strategy.Model.Conditions
+	entryPrice := conditions.EntryOrder.Price
+	if conditions.EntryOrder.OrderType != "limit" { // market and maker-only
+		for attempts := 0; attempts <= 10; attempts++ {
+			if currentOHLCVp := df.GetPriceForPairAtExchange(conditions.Pair, conditions.Exchange, conditions.MarketType); currentOHLCVp != nil {
+				entryPrice = currentOHLCVp.Close
+				break
+			}
+			time.Sleep(1 * time.Second)
+		}
+	}
+	_, amountPrecision := strategy.StateMgmt.GetMarketPrecision(conditions.Pair, conditions.MarketType)
+	var equity float64
+	if conditions.KeyAssetId != nil {
+		equity = strategy.StateMgmt.GetKeyAssetEquity(conditions.KeyAssetId)
+	}
+	amount, err := CalculateRiskEntryAmount(conditions, equity, entryPrice, amountPrecision)
+	if err != nil {
+		strategy.Model.State = &models.MongoStrategyState{
+			State: smart_order.Error,
+			Msg:   "can't calc risk based entry: " + err.Error(),
+		}
+		strategy.Log.Warn("can't calc risk based entry",
+			zap.Float64("entry price", entryPrice),
+			zap.Error(err),
+		)
+		return
+	}
+	conditions.EntryOrder.Amount = amount
+	strategy.Log.Info("risk based amount calculated",
+		zap.Float64("amount", amount),
+		zap.Float64("entry price", entryPrice),
+		zap.Float64("equity", equity),
+		zap.Float64("stop-loss price", StopLossPriceFor(conditions, entryPrice)),
+	)
+}
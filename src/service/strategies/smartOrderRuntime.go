@@ -27,6 +27,8 @@ func RunSmartOrder(strategy *Strategy, df interfaces.IDataFeed, td interfaces.IT
 	if strategy.Model.Conditions.MarketType == 0 {
 		strategy.Model.Conditions.Leverage = 1
 	}
+	isFirstStart := strategy.Model.State == nil || strategy.Model.State.State == ""
+	isRiskSizing := IsRiskSizing(strategy.Model.Conditions) && isFirstStart
 	if keyId == nil || strategy.Model.Conditions.EntryOrder.Type == 1 {
 		KeyAssets := mongodb.GetCollection("core_key_assets") // TODO: move to statemgmt, avoid any direct dependecies here
 		keyAssetId := strategy.Model.Conditions.KeyAssetId.String()
@@ -51,6 +53,9 @@ func RunSmartOrder(strategy *Strategy, df interfaces.IDataFeed, td interfaces.IT
 		// type 1 for entry point - relative amount
 		DetermineRelativeEntryAmount(strategy, keyAsset, df) // TODO(khassanov): call for relative only
 	}
+	if isRiskSizing {
+		DetermineRiskEntryAmount(strategy, df)
+	}
 
 	if strategy.Model.Conditions.MarketType == 1 && !strategy.Model.Conditions.SkipInitialSetup {
 		res := td.UpdateLeverage(keyId, strategy.Model.Conditions.Leverage, strategy.Model.Conditions.Pair)
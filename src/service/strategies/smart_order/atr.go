@@ -0,0 +1,112 @@
+package smart_order
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ATR defaults if not specified in conditions.
+const (
+	defaultAtrTimeframe = 60
+	defaultAtrPeriod    = 14
+)
+
+// atrAdjusted returns stop or target distance in percents of price multiplied by leverage, in ATR mode the value
+// given is a multiple of ATR frozen for the iteration.
+func (sm *SmartOrder) atrAdjusted(value float64) float64 {
+	model := sm.Strategy.GetModel()
+	if !model.Conditions.AtrMode || model.State.Atr == 0 || model.State.AtrPrice == 0 {
+		return value
+	}
+	return value * model.State.Atr / model.State.AtrPrice * 100 * model.Conditions.Leverage
+}
+
+// isAtrFrozen tells whether stops and targets can be computed, always true if ATR mode is off.
+func (sm *SmartOrder) isAtrFrozen() bool {
+	model := sm.Strategy.GetModel()
+	return !model.Conditions.AtrMode || model.State.Atr > 0
+}
+
+// atrTimeframe returns the candle size and the number of candles ATR is measured over.
+func (sm *SmartOrder) atrTimeframe() (timeframe int64, period int64) {
+	conditions := sm.Strategy.GetModel().Conditions
+	timeframe, period = conditions.AtrTimeframe, conditions.AtrPeriod
+	if timeframe == 0 {
+		timeframe = defaultAtrTimeframe
+	}
+	if period == 0 {
+		period = defaultAtrPeriod
+	}
+	return timeframe, period
+}
+
+// checkAtr freezes ATR for the iteration as soon as there are enough candles to compute it, the entry waits for it.
+func (sm *SmartOrder) checkAtr() {
+	if sm.isAtrFrozen() {
+		return
+	}
+	model := sm.Strategy.GetModel()
+	timeframe, period := sm.atrTimeframe()
+	candles := indicators.GetCandles(sm.DataFeed, model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType, timeframe)
+	atr, ok := indicators.AverageTrueRange(candles, int(period))
+	if !ok || atr == 0 {
+		sm.checkAtrWarmup(timeframe * (period + 1))
+		return
+	}
+	sm.freezeAtr(atr, candles[len(candles)-1].Close)
+}
+
+// freezeAtr saves ATR for the iteration with the price it was measured at and releases the entry.
+func (sm *SmartOrder) freezeAtr(atr float64, price float64) {
+	model := sm.Strategy.GetModel()
+	model.State.Atr = atr
+	model.State.AtrPrice = price
+	sm.Strategy.GetLogger().Info("atr frozen",
+		zap.Float64("atr", atr),
+		zap.Float64("price", model.State.AtrPrice),
+		zap.Int("iteration", model.State.Iteration),
+	)
+	sm.StateMgmt.UpdateAtr(model.ID, model.State)
+	sm.checkIfPlaceOrderInstantlyOnStart()
+}
+
+// checkAtrWarmup freezes ATR of the fallback given in percents of price once ATR didn't warm up in time, candles
+// history may take hours to fill up otherwise. Without the fallback the entry keeps waiting, stops and targets given
+// in ATR are never read as percents.
+func (sm *SmartOrder) checkAtrWarmup(defaultWarmup int64) {
+	model := sm.Strategy.GetModel()
+	if model.State.AtrWaitSince == 0 {
+		model.State.AtrWaitSince = time.Now().Unix()
+		sm.StateMgmt.UpdateAtr(model.ID, model.State)
+	}
+	warmup := model.Conditions.AtrWarmup
+	if warmup == 0 {
+		warmup = defaultWarmup
+	}
+	if time.Now().Unix()-model.State.AtrWaitSince < warmup {
+		return
+	}
+	if model.Conditions.AtrFallback == 0 {
+		if !sm.IsAtrWarmupOver {
+			sm.IsAtrWarmupOver = true
+			sm.Strategy.GetLogger().Warn("atr not warmed up, entry keeps waiting",
+				zap.Int64("warmup", warmup),
+				zap.Int("iteration", model.State.Iteration),
+			)
+			sm.Statsd.Inc("smart_order.atr_warmup_over")
+		}
+		return
+	}
+	currentOHLCVp := sm.DataFeed.GetPriceForPairAtExchange(model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType)
+	if currentOHLCVp == nil || currentOHLCVp.Close == 0 {
+		return
+	}
+	sm.Strategy.GetLogger().Warn("atr not warmed up, fallback atr",
+		zap.Int64("warmup", warmup),
+		zap.Float64("atrFallback", model.Conditions.AtrFallback),
+		zap.Int("iteration", model.State.Iteration),
+	)
+	sm.Statsd.Inc("smart_order.atr_fallback")
+	sm.freezeAtr(currentOHLCVp.Close*model.Conditions.AtrFallback/100, currentOHLCVp.Close)
+}
@@ -0,0 +1,296 @@
+package smart_order
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// CopyAmount returns the follower entry amount for the leader amount at the price given, capped by follower max notional.
+func CopyAmount(follower *models.MongoCopyFollower, leaderAmount, price, leaderFree, followerFree float64) float64 {
+	var amount float64
+	switch follower.SizeMode {
+	case models.CopySizeNotional:
+		if price <= 0 {
+			return 0
+		}
+		amount = follower.Notional / price
+	case models.CopySizeBalanceRatio:
+		if leaderFree <= 0 {
+			return 0
+		}
+		amount = leaderAmount * followerFree / leaderFree
+	default:
+		multiplier := follower.Multiplier
+		if multiplier == 0 {
+			multiplier = 1
+		}
+		amount = leaderAmount * multiplier
+	}
+	if follower.MaxNotional > 0 && price > 0 && amount*price > follower.MaxNotional {
+		amount = follower.MaxNotional / price
+	}
+	return amount
+}
+
+func (sm *SmartOrder) isCopyLeader() bool {
+	conditions := sm.Strategy.GetModel().Conditions
+	return len(conditions.Followers) > 0 && conditions.CopyLeaderId == nil
+}
+
+// syncCopy syncs followers once in the iteration and mirrors the leader entry price when it changes, edits of the
+// leader are pushed to followers on hot reload.
+func (sm *SmartOrder) syncCopy() {
+	model := sm.Strategy.GetModel()
+	if !sm.isCopyLeader() {
+		return
+	}
+	sm.CopyMux.Lock()
+	isSynced := sm.isCopySynced
+	isEntered := model.State.EntryPrice > 0 && model.State.EntryPrice != sm.copiedEntryPrice
+	sm.isCopySynced = true
+	sm.copiedEntryPrice = model.State.EntryPrice
+	sm.CopyMux.Unlock()
+	if !isSynced {
+		go sm.syncFollowers()
+	} else if isEntered {
+		go sm.mirrorEntryToFollowers()
+	}
+}
+
+// reloadCopy pushes leader conditions to followers if the reload edited them.
+func (sm *SmartOrder) reloadCopy(previous *models.MongoStrategyCondition) {
+	model := sm.Strategy.GetModel()
+	if !sm.isCopyLeader() || previous == nil || copyHash(previous) == copyHash(model.Conditions) {
+		return
+	}
+	sm.CopyMux.Lock()
+	isSynced := sm.isCopySynced
+	sm.CopyMux.Unlock()
+	if !isSynced {
+		return // synced on the next cycle anyway
+	}
+	sm.Strategy.GetLogger().Info("leader conditions edited, syncing followers")
+	go sm.syncFollowers()
+}
+
+// copyHash returns a hash of the leader conditions followers copy, follower ids and amounts set by syncing left out,
+// so conditions reloaded after the leader saved them don't count as edited.
+func copyHash(conditions *models.MongoStrategyCondition) string {
+	copied := *conditions
+	copied.Followers = make([]*models.MongoCopyFollower, 0, len(conditions.Followers))
+	for _, follower := range conditions.Followers {
+		settings := *follower
+		settings.StrategyId = nil
+		settings.LeaderAmount = 0
+		settings.Amount = 0
+		copied.Followers = append(copied.Followers, &settings)
+	}
+	jsonStr, _ := json.Marshal(copied)
+	hash := sha256.Sum256(jsonStr)
+	return string(hash[:])
+}
+
+// syncFollowers creates follower strategies missing and pushes the leader conditions to existing ones. Followers
+// created are remembered by key id, so conditions reloaded before the leader saved them don't create them twice.
+func (sm *SmartOrder) syncFollowers() {
+	sm.CopyMux.Lock()
+	defer sm.CopyMux.Unlock()
+	model := sm.Strategy.GetModel()
+	if sm.followerIds == nil {
+		sm.followerIds = map[string]*primitive.ObjectID{}
+	}
+	isCreated := false
+	for _, follower := range model.Conditions.Followers {
+		if follower.KeyId == nil {
+			continue
+		}
+		if follower.StrategyId == nil && sm.followerIds[follower.KeyId.Hex()] != nil {
+			follower.StrategyId = sm.followerIds[follower.KeyId.Hex()]
+			isCreated = true
+		}
+		sm.sizeFollower(follower)
+		if follower.Amount <= 0 {
+			sm.Strategy.GetLogger().Warn("follower amount is zero, skipping",
+				zap.String("keyId", follower.KeyId.Hex()),
+				zap.String("sizeMode", follower.SizeMode),
+			)
+			continue
+		}
+		conditions := sm.followerConditions(follower)
+		if follower.StrategyId != nil {
+			sm.StateMgmt.UpdateConditions(follower.StrategyId, conditions)
+			sm.Statsd.Inc("smart_order.copy_follower_updated")
+			continue
+		}
+		response := sm.ExchangeApi.PlaceCopy(follower.KeyId, conditions)
+		if response.Data.OrderId == "" {
+			sm.Strategy.GetLogger().Error("can't create follower",
+				zap.String("keyId", follower.KeyId.Hex()),
+				zap.String("status", response.Status),
+				zap.String("msg", response.Data.Msg),
+			)
+			sm.Statsd.Inc("smart_order.copy_follower_error")
+			continue
+		}
+		strategyId, _ := primitive.ObjectIDFromHex(response.Data.OrderId)
+		follower.StrategyId = &strategyId
+		sm.followerIds[follower.KeyId.Hex()] = &strategyId
+		isCreated = true
+		sm.Strategy.GetLogger().Info("follower created",
+			zap.String("keyId", follower.KeyId.Hex()),
+			zap.String("strategyId", strategyId.Hex()),
+			zap.Float64("amount", follower.Amount),
+		)
+		sm.Statsd.Inc("smart_order.copy_follower_created")
+	}
+	if isCreated {
+		sm.saveFollowerIds()
+	}
+}
+
+// saveFollowerIds sets ids of followers created to the current leader conditions, which may be reloaded while
+// followers were being created, and saves them.
+func (sm *SmartOrder) saveFollowerIds() {
+	model := sm.Strategy.GetModel()
+	conditions := model.Conditions
+	for _, follower := range conditions.Followers {
+		if follower.KeyId != nil && follower.StrategyId == nil {
+			follower.StrategyId = sm.followerIds[follower.KeyId.Hex()]
+		}
+	}
+	sm.StateMgmt.UpdateConditions(model.ID, conditions)
+}
+
+// sizeFollower sets the follower amount if it was not sized yet or the leader amount changed since.
+func (sm *SmartOrder) sizeFollower(follower *models.MongoCopyFollower) {
+	model := sm.Strategy.GetModel()
+	leaderAmount := model.Conditions.EntryOrder.Amount
+	if follower.Amount > 0 && follower.LeaderAmount == leaderAmount {
+		return
+	}
+	price := model.State.EntryPrice
+	if price == 0 {
+		price = model.Conditions.EntryOrder.Price
+	}
+	if price == 0 {
+		if currentOHLCVp := sm.DataFeed.GetPriceForPairAtExchange(model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType); currentOHLCVp != nil {
+			price = currentOHLCVp.Close
+		}
+	}
+	var leaderFree, followerFree float64
+	if follower.SizeMode == models.CopySizeBalanceRatio && model.Conditions.KeyAssetId != nil && follower.KeyAssetId != nil {
+		leaderFree = sm.StateMgmt.GetKeyAssetFree(model.Conditions.KeyAssetId)
+		followerFree = sm.StateMgmt.GetKeyAssetFree(follower.KeyAssetId)
+	}
+	amount := CopyAmount(follower, leaderAmount, price, leaderFree, followerFree)
+	follower.Amount = sm.toFixed(amount, sm.QuantityAmountPrecision, Floor)
+	follower.LeaderAmount = leaderAmount
+}
+
+// followerConditions clones the leader conditions for the follower key with the follower amount and risk caps, the
+// follower enters at market once the leader entered, so leader entry options are not copied.
+func (sm *SmartOrder) followerConditions(follower *models.MongoCopyFollower) *models.MongoStrategyCondition {
+	model := sm.Strategy.GetModel()
+	var jsonStr, _ = json.Marshal(model.Conditions)
+	var conditions models.MongoStrategyCondition
+	_ = json.Unmarshal(jsonStr, &conditions)
+
+	conditions.AccountId = follower.KeyId
+	conditions.KeyAssetId = follower.KeyAssetId
+	conditions.Followers = nil
+	conditions.CopyLeaderId = model.ID
+	conditions.CopyLeaderEntryPrice = model.State.EntryPrice
+	conditions.CopyLeaderExitPrice = 0
+	conditions.Hedging = false
+	conditions.HedgeStrategyId = nil
+	conditions.TemplateToken = ""
+	conditions.CreatedByTemplate = false
+	conditions.TemplateStrategyId = nil
+	conditions.ContinueIfEnded = false // the leader creates followers each iteration
+	conditions.ReEntryAttempts = 0
+	conditions.RiskPercent = 0
+	conditions.RiskAmount = 0
+	conditions.EntryLevels = nil
+	conditions.EntrySlices = 0
+	conditions.EntrySpreadHunter = false
+	conditions.EntryIndicators = nil
+	conditions.WaitingEntryTimeout = 0
+	conditions.EntryOrder.Type = 0
+	conditions.EntryOrder.OrderType = "market"
+	conditions.EntryOrder.Price = 0
+	conditions.EntryOrder.ActivatePrice = 0
+	conditions.EntryOrder.EntryDeviation = 0
+	conditions.EntryOrder.Amount = follower.Amount
+
+	if follower.MaxLeverage > 0 && conditions.Leverage > follower.MaxLeverage {
+		conditions.Leverage = follower.MaxLeverage
+	}
+	if follower.MaxStopLoss > 0 && (conditions.StopLoss == 0 || conditions.StopLoss > follower.MaxStopLoss) {
+		conditions.StopLoss = follower.MaxStopLoss
+	}
+	return &conditions
+}
+
+// mirrorEntryToFollowers releases follower entries with the leader entry price.
+func (sm *SmartOrder) mirrorEntryToFollowers() {
+	sm.CopyMux.Lock()
+	defer sm.CopyMux.Unlock()
+	model := sm.Strategy.GetModel()
+	for _, follower := range model.Conditions.Followers {
+		if follower.StrategyId != nil {
+			sm.StateMgmt.UpdateCopyLeaderPrices(follower.StrategyId, model.State.EntryPrice, 0)
+		}
+	}
+	sm.Statsd.Inc("smart_order.copy_entry_mirrored")
+}
+
+// exitFollowers closes follower positions together with the leader reporting the price it exited at, followers of the
+// next iteration are created anew.
+func (sm *SmartOrder) exitFollowers() {
+	if !sm.isCopyLeader() {
+		return
+	}
+	sm.CopyMux.Lock()
+	defer sm.CopyMux.Unlock()
+	model := sm.Strategy.GetModel()
+	for _, follower := range model.Conditions.Followers {
+		if follower.StrategyId == nil {
+			continue
+		}
+		sm.StateMgmt.UpdateCopyLeaderPrices(follower.StrategyId, model.State.EntryPrice, model.State.ExitPrice)
+		sm.StateMgmt.DisableStrategy(follower.StrategyId)
+		sm.Strategy.GetLogger().Info("follower exited with leader",
+			zap.String("strategyId", follower.StrategyId.Hex()),
+			zap.Float64("leader exit price", model.State.ExitPrice),
+		)
+		follower.StrategyId = nil
+	}
+	sm.isCopySynced = false
+	sm.copiedEntryPrice = 0
+	sm.followerIds = nil
+	sm.StateMgmt.UpdateConditions(model.ID, model.Conditions)
+	sm.Statsd.Inc("smart_order.copy_exit_mirrored")
+}
+
+// reportCopySlippage measures follower entry and exit prices against the leader ones.
+func (sm *SmartOrder) reportCopySlippage(entryPrice float64) {
+	model := sm.Strategy.GetModel()
+	sideCoefficient := 1.0
+	if model.Conditions.EntryOrder.Side == "sell" {
+		sideCoefficient = -1.0
+	}
+	if leaderEntryPrice := model.Conditions.CopyLeaderEntryPrice; leaderEntryPrice > 0 && entryPrice > 0 {
+		model.State.CopyEntrySlippage = (entryPrice/leaderEntryPrice - 1) * 100 * sideCoefficient
+	}
+	if leaderExitPrice := model.Conditions.CopyLeaderExitPrice; leaderExitPrice > 0 && model.State.ExitPrice > 0 {
+		model.State.CopyExitSlippage = (leaderExitPrice/model.State.ExitPrice - 1) * 100 * sideCoefficient
+	}
+	sm.Strategy.GetLogger().Info("copy slippage",
+		zap.Float64("entry slippage", model.State.CopyEntrySlippage),
+		zap.Float64("exit slippage", model.State.CopyExitSlippage),
+	)
+	sm.Statsd.Inc("smart_order.copy_slippage_reported")
+}
@@ -17,6 +17,12 @@ func (sm *SmartOrder) checkTimeouts() {
 				zap.Bool("sm.Lock", sm.Lock),
 				zap.Bool("iteration == sm.Strategy.GetModel().State.Iteration ", iteration == sm.Strategy.GetModel().State.Iteration),
 			)
+			if currentState == PartiallyEntry && sm.Lock == false && iteration == sm.Strategy.GetModel().State.Iteration {
+				sm.Lock = true
+				sm.timeoutPartiallyEntry()
+				sm.Lock = false
+				return
+			}
 			if (currentState == WaitForEntry || currentState == TrailingEntry) && sm.Lock == false && iteration == sm.Strategy.GetModel().State.Iteration {
 				sm.Lock = true
 				switch len(sm.Strategy.GetModel().State.Orders) {
@@ -112,11 +118,14 @@ func (sm *SmartOrder) checkTimeouts() {
 }
 
 func (sm *SmartOrder) tryCancelEntryOrder() orders.OrderResponse {
+	if len(sm.Strategy.GetModel().State.Orders) == 0 {
+		return orders.OrderResponse{Status: "ERR"}
+	}
 	orderId := sm.Strategy.GetModel().State.Orders[0]
 	sm.Strategy.GetLogger().Info("orderId in check timeout")
 	var res orders.OrderResponse
 	if orderId != "0" {
-		res = sm.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+		res = sm.cancelOrder(orders.CancelOrderRequest{
 			KeyId: sm.KeyId,
 			KeyParams: orders.CancelOrderRequestParams{
 				OrderId:    orderId,
@@ -0,0 +1,85 @@
+package smart_order
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/execution"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+)
+
+// storeAlgorithmOrder routes cancels of the order to the algorithm executing it and keeps it in the state, so they
+// are routed after restarts too.
+func (sm *SmartOrder) storeAlgorithmOrder(orderId, orderType string, algorithm interfaces.IExecutionAlgorithm) {
+	sm.AlgorithmByOrderId.Store(orderId, algorithm)
+	state := sm.Strategy.GetModel().State
+	state.AlgorithmOrders = append(state.AlgorithmOrders, &models.MongoAlgorithmOrder{OrderId: orderId, OrderType: orderType})
+}
+
+// restoreAlgorithmOrders routes cancels of orders executed by algorithms before the restart to them.
+func (sm *SmartOrder) restoreAlgorithmOrders() {
+	state := sm.Strategy.GetModel().State
+	if state == nil {
+		return
+	}
+	for _, order := range state.AlgorithmOrders {
+		if algorithm := execution.Get(order.OrderType); algorithm != nil {
+			sm.AlgorithmByOrderId.Store(order.OrderId, algorithm)
+		}
+	}
+}
+
+// exitAlgorithmType returns the order type of the exit target selected, the last one if all are reached, if an
+// execution algorithm executes it. Break-even exits are executed by it too. Returns empty string otherwise.
+func (sm *SmartOrder) exitAlgorithmType() string {
+	levels := sm.Strategy.GetModel().Conditions.ExitLevels
+	if len(levels) == 0 {
+		return ""
+	}
+	target := levels[len(levels)-1]
+	if sm.SelectedExitTarget < len(levels) {
+		target = levels[sm.SelectedExitTarget]
+	}
+	if execution.Get(target.OrderType) == nil {
+		return ""
+	}
+	return target.OrderType
+}
+
+// waitForWithoutLoss makes the break-even exit wait for the price to get to it, an algorithm doesn't rest on
+// exchange as stop or take-profit orders do.
+func (sm *SmartOrder) waitForWithoutLoss(price, amount float64, isStop bool) {
+	model := sm.Strategy.GetModel()
+	model.State.WithoutLossPrice = price
+	model.State.WithoutLossAmount = amount
+	model.State.WithoutLossStop = isStop
+	sm.Strategy.GetLogger().Info("break-even exit waits for the price",
+		zap.Float64("price", price),
+		zap.Float64("amount", amount),
+		zap.Bool("stop", isStop),
+	)
+	go sm.StateMgmt.UpdateStrategyState(model.ID, model.State)
+}
+
+// checkWithoutLoss executes the break-even exit waiting for the price once the price gets back to break-even for
+// the stop or reaches it for the take-profit. Returns true if the exit is executed.
+func (sm *SmartOrder) checkWithoutLoss(ohlcv interfaces.OHLCV) bool {
+	model := sm.Strategy.GetModel()
+	price := model.State.WithoutLossPrice
+	if price == 0 {
+		return false
+	}
+	isReached := ohlcv.Close >= price
+	isBack := ohlcv.Close <= price
+	if model.Conditions.EntryOrder.Side == "sell" {
+		isReached, isBack = isBack, isReached
+	}
+	if model.State.WithoutLossStop && !isBack || !model.State.WithoutLossStop && !isReached {
+		return false
+	}
+	sm.PlaceOrder(price, model.State.WithoutLossAmount, "WithoutLoss")
+	model.State.WithoutLossPrice = 0
+	model.State.WithoutLossAmount = 0
+	model.State.WithoutLossStop = false
+	go sm.StateMgmt.UpdateStrategyState(model.ID, model.State)
+	return true
+}
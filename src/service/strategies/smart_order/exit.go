@@ -11,7 +11,7 @@ func (sm *SmartOrder) exit(ctx context.Context, args ...interface{}) (stateless.
 	state, _ := sm.State.State(context.TODO())
 	nextState := End
 	model := sm.Strategy.GetModel()
-	amount := model.Conditions.EntryOrder.Amount
+	amount := sm.getPositionTargetAmount()
 	if model.Conditions.MarketType == 0 {
 		amount = amount - sm.Strategy.GetModel().State.Commission
 	}
@@ -29,6 +29,10 @@ func (sm *SmartOrder) exit(ctx context.Context, args ...interface{}) (stateless.
 		zap.Bool("is executed amount >= amount in exit", model.State.ExecutedAmount >= amount),
 	)
 	if model.State.State != WaitLossHedge && model.State.ExecutedAmount >= amount { // all trades executed, nothing more to trade
+		if sm.tryReEntry() {
+			sm.exitFollowers()
+			return WaitForReEntry, nil
+		}
 		if model.Conditions.ContinueIfEnded {
 			isParentHedge := model.Conditions.Hedging == true
 			isTrailingHedgeOrder := model.Conditions.HedgeStrategyId != nil || isParentHedge
@@ -36,6 +40,7 @@ func (sm *SmartOrder) exit(ctx context.Context, args ...interface{}) (stateless.
 			if isTrailingHedgeOrder && !isParentHedge {
 				return End, nil
 			}
+			sm.exitFollowers() // the leader is flat till the next iteration
 			//oppositeSide := model.Conditions.EntryOrder.Side
 			//if oppositeSide == "buy" {
 			//	oppositeSide = "sell"
@@ -134,7 +139,12 @@ func (sm *SmartOrder) exit(ctx context.Context, args ...interface{}) (stateless.
 	sm.Strategy.GetLogger().Info("next state in end",
 		zap.String("next state", nextState),
 	)
+	if nextState == End && sm.tryReEntry() {
+		sm.exitFollowers()
+		return WaitForReEntry, nil
+	}
 	if nextState == End && model.Conditions.ContinueIfEnded {
+		sm.exitFollowers()
 		newState := models.MongoStrategyState{
 			State:              WaitForEntry,
 			TrailingEntryPrice: 0,
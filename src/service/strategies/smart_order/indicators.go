@@ -0,0 +1,81 @@
+package smart_order
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+	"time"
+)
+
+const indicatorsCheckInterval = 1 * time.Second
+
+// areIndicatorsMet tells whether all the conditions given hold over candles aggregated for the smart order pair.
+func (sm *SmartOrder) areIndicatorsMet(conditions []*models.MongoIndicatorCondition) bool {
+	model := sm.Strategy.GetModel()
+	for _, condition := range conditions {
+		candles := indicators.GetCandles(sm.DataFeed, model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType, condition.Timeframe)
+		if !indicators.Evaluate(condition, candles) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndicatorsCheckDue throttles indicators evaluation since candles don't change as fast as the event loop goes.
+func (sm *SmartOrder) isIndicatorsCheckDue() bool {
+	now := time.Now()
+	if now.Sub(sm.LastIndicatorsCheckAt) < indicatorsCheckInterval {
+		return false
+	}
+	sm.LastIndicatorsCheckAt = now
+	return true
+}
+
+// checkEntryIndicators releases the entry once all entry indicators hold in the current iteration.
+func (sm *SmartOrder) checkEntryIndicators() {
+	model := sm.Strategy.GetModel()
+	if len(model.Conditions.EntryIndicators) == 0 || sm.EntryIndicatorsMet || !sm.isIndicatorsCheckDue() {
+		return
+	}
+	if !sm.areIndicatorsMet(model.Conditions.EntryIndicators) {
+		return
+	}
+	sm.EntryIndicatorsMet = true
+	sm.Strategy.GetLogger().Info("entry released by indicators", zap.Int("iteration", model.State.Iteration))
+	sm.Statsd.Inc("smart_order.entry_released_by_indicators")
+	sm.checkIfPlaceOrderInstantlyOnStart()
+}
+
+// checkExitIndicators exits the position at market once all exit indicators hold.
+func (sm *SmartOrder) checkExitIndicators() {
+	model := sm.Strategy.GetModel()
+	if len(model.Conditions.ExitIndicators) == 0 || sm.ExitIndicatorsMet || !sm.isIndicatorsCheckDue() {
+		return
+	}
+	if !sm.areIndicatorsMet(model.Conditions.ExitIndicators) {
+		return
+	}
+	sm.ExitIndicatorsMet = true
+	sm.Strategy.GetLogger().Info("exit by indicators", zap.Float64("entry price", model.State.EntryPrice))
+	sm.Statsd.Inc("smart_order.exit_by_indicators")
+	// release amount locked by take-profit orders, reduce-only exit orders of futures would stay open otherwise
+	sm.cancelExits()
+	go sm.PlaceOrder(-1, 0.0, TakeProfit)
+}
+
+// watchCandles keeps candles of indicator and ATR timeframes aggregated while the smart order runs, returns the release.
+func (sm *SmartOrder) watchCandles() (release func()) {
+	conditions := sm.Strategy.GetModel().Conditions
+	var timeframes []int64
+	for _, condition := range conditions.EntryIndicators {
+		timeframes = append(timeframes, condition.Timeframe)
+	}
+	for _, condition := range conditions.ExitIndicators {
+		timeframes = append(timeframes, condition.Timeframe)
+	}
+	if conditions.AtrMode {
+		timeframe, _ := sm.atrTimeframe()
+		timeframes = append(timeframes, timeframe)
+	}
+	return indicators.Watch(sm.DataFeed, conditions.Pair, sm.ExchangeName, conditions.MarketType, timeframes...)
+}
@@ -0,0 +1,96 @@
+package smart_order
+
+import (
+	"context"
+	"github.com/qmuntal/stateless"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+)
+
+// onPartialFill fires partial fill of the entry order, slices and averaging entries account fills of own orders.
+func (sm *SmartOrder) onPartialFill(order *models.MongoOrder) {
+	step, ok := sm.StatusByOrderId.Load(order.OrderId)
+	if !ok || step != WaitForEntry || sm.isSlicedEntry() || len(sm.Strategy.GetModel().Conditions.EntryLevels) > 0 {
+		return
+	}
+	if err := sm.State.Fire(TriggerPartialFill, *order); err != nil {
+		sm.Strategy.GetLogger().Warn("fire partial fill error", zap.Error(err))
+	}
+}
+
+// checkPartialFill is a guard taking the entry amount filled so far if it grew, the last fill comes as filled order.
+func (sm *SmartOrder) checkPartialFill(ctx context.Context, args ...interface{}) bool {
+	order := args[0].(models.MongoOrder)
+	model := sm.Strategy.GetModel()
+	if order.Filled <= model.State.EntryFilledAmount || order.Filled >= model.Conditions.EntryOrder.Amount {
+		return false
+	}
+	sm.takePartialFill(order)
+	model.State.State = PartiallyEntry
+	sm.StateMgmt.UpdateEntryPrice(model.ID, model.State)
+	return true
+}
+
+// takePartialFill sets the position to the amount filled by the entry order at its average price.
+func (sm *SmartOrder) takePartialFill(order models.MongoOrder) {
+	model := sm.Strategy.GetModel()
+	model.State.EntryFilledAmount = order.Filled
+	model.State.PositionAmount = order.Filled
+	if order.Average > 0 {
+		model.State.EntryPrice = order.Average
+	} else if model.State.EntryPrice == 0 {
+		model.State.EntryPrice = order.Price
+	}
+	sm.Strategy.GetLogger().Info("entry filled partially",
+		zap.Float64("filled", order.Filled),
+		zap.Float64("entry price", model.State.EntryPrice),
+	)
+	sm.Statsd.Inc("smart_order.entry_partially_filled")
+}
+
+// enterPartiallyEntry places exits or resizes them to the amount filled so far.
+func (sm *SmartOrder) enterPartiallyEntry(ctx context.Context, args ...interface{}) error {
+	go sm.replaceExits(PartiallyEntry)
+	return nil
+}
+
+// exitPartiallyEntry goes in entry once the entry order filled, a stop loss hit or exits filled in the meantime cancel
+// the rest of entry.
+func (sm *SmartOrder) exitPartiallyEntry(ctx context.Context, args ...interface{}) (stateless.State, error) {
+	switch sm.Strategy.GetModel().State.State {
+	case InEntry:
+		sm.cancelExits() // in entry places exits for the whole amount
+		return InEntry, nil
+	case Stoploss:
+		sm.tryCancelEntryOrder() // stop loss closes the amount filled so far
+		return Stoploss, nil
+	}
+	sm.tryCancelEntryOrder()
+	return sm.exit(ctx, args...)
+}
+
+// timeoutPartiallyEntry cancels the rest of entry order and goes in entry with the amount filled.
+func (sm *SmartOrder) timeoutPartiallyEntry() {
+	if res := sm.tryCancelEntryOrder(); res.Status != "OK" {
+		sm.Strategy.GetLogger().Info("entry order already filled")
+		return
+	}
+	sm.Strategy.GetLogger().Info("continue with entry filled partially",
+		zap.Float64("filled", sm.Strategy.GetModel().State.EntryFilledAmount),
+	)
+	sm.cancelExits()
+	if err := sm.State.Fire(TriggerTimeout); err != nil {
+		sm.Strategy.GetLogger().Warn("fire partially entry timeout error", zap.Error(err))
+	}
+}
+
+// onEntryCanceledFilled takes fills of the entry order came after the last partial fill seen before it got canceled.
+func (sm *SmartOrder) onEntryCanceledFilled(order models.MongoOrder) {
+	model := sm.Strategy.GetModel()
+	if sm.isSlicedEntry() || model.State.EntryFilledAmount == 0 || order.Filled <= model.State.EntryFilledAmount {
+		return
+	}
+	sm.takePartialFill(order)
+	sm.StateMgmt.UpdateEntryPrice(model.ID, model.State)
+	go sm.replaceExits(InEntry)
+}
@@ -3,6 +3,7 @@ package smart_order
 import (
 	"context"
 	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/execution"
 	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
 	"go.uber.org/zap"
 	"strings"
@@ -71,9 +72,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		}
 		side = model.Conditions.EntryOrder.Side
 		if side == "sell" {
-			orderPrice = model.State.TrailingEntryPrice * (1 - model.Conditions.EntryOrder.EntryDeviation/100/leverage)
+			orderPrice = model.State.TrailingEntryPrice * (1 - sm.atrAdjusted(model.Conditions.EntryOrder.EntryDeviation)/100/leverage)
 		} else {
-			orderPrice = model.State.TrailingEntryPrice * (1 + model.Conditions.EntryOrder.EntryDeviation/100/leverage)
+			orderPrice = model.State.TrailingEntryPrice * (1 + sm.atrAdjusted(model.Conditions.EntryOrder.EntryDeviation)/100/leverage)
 		}
 		break
 	case InEntry:
@@ -96,7 +97,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		side = model.Conditions.EntryOrder.Side
 		baseAmount = model.Conditions.EntryOrder.Amount
 
-		if len(model.Conditions.EntryLevels) > 0 {
+		if len(model.Conditions.EntryLevels) > 0 || sm.isSlicedEntry() {
 			baseAmount = amount
 		}
 		//log.Print("orderPrice in waitForEntry", orderPrice)
@@ -122,7 +123,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		break
 	case Stoploss:
 		reduceOnly = true
-		baseAmount = model.Conditions.EntryOrder.Amount - model.State.ExecutedAmount
+		baseAmount = sm.getPositionTargetAmount() - model.State.ExecutedAmount
 		side = oppositeSide
 
 		if model.Conditions.StopLossPrice > 0 {
@@ -139,7 +140,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 			if amount > 0 {
 				baseAmount = amount
 			}
-			stopLoss := model.Conditions.StopLoss
+			stopLoss := sm.atrAdjusted(model.Conditions.StopLoss)
 			if side == "sell" {
 				orderPrice = price * (1 - stopLoss/100/leverage)
 			} else {
@@ -161,7 +162,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		if model.Conditions.TimeoutLoss == 0 {
 			orderType = model.Conditions.StopLossType
 			isStopOrdersSupport := isFutures // || orderType == "limit"
-			stopLoss := model.Conditions.StopLoss
+			stopLoss := sm.atrAdjusted(model.Conditions.StopLoss)
 			if side == "sell" {
 				orderPrice = model.State.EntryPrice * (1 - stopLoss/100/leverage)
 			} else {
@@ -203,7 +204,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 	case "ForcedLoss":
 		reduceOnly = true
 		side = oppositeSide
-		baseAmount = model.Conditions.EntryOrder.Amount
+		baseAmount = sm.getPositionTargetAmount()
 		orderType = "market"
 
 		isTrailingHedgeOrder := model.Conditions.HedgeStrategyId != nil || model.Conditions.HedgeKeyId != nil
@@ -229,16 +230,16 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		}
 
 		if side == "sell" {
-			orderPrice = model.State.EntryPrice * (1 - model.Conditions.ForcedLoss/100/leverage)
+			orderPrice = model.State.EntryPrice * (1 - sm.atrAdjusted(model.Conditions.ForcedLoss)/100/leverage)
 		} else {
-			orderPrice = model.State.EntryPrice * (1 + model.Conditions.ForcedLoss/100/leverage)
+			orderPrice = model.State.EntryPrice * (1 + sm.atrAdjusted(model.Conditions.ForcedLoss)/100/leverage)
 		}
 
 		if len(model.Conditions.EntryLevels) > 0 {
 			if side == "sell" {
-				orderPrice = price * (1 - model.Conditions.ForcedLoss/100/leverage)
+				orderPrice = price * (1 - sm.atrAdjusted(model.Conditions.ForcedLoss)/100/leverage)
 			} else {
-				orderPrice = price * (1 + model.Conditions.ForcedLoss/100/leverage)
+				orderPrice = price * (1 + sm.atrAdjusted(model.Conditions.ForcedLoss)/100/leverage)
 			}
 		}
 		break
@@ -257,6 +258,12 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 			baseAmount = amount
 		}
 
+		algorithmType := sm.exitAlgorithmType()
+		if algorithmType != "" && price > 0 && price == model.State.WithoutLossPrice {
+			orderType = algorithmType // break-even reached, executed by the algorithm
+			break
+		}
+
 		// if price 0 then market price == entry price for spot market order
 		if isSpot && price != 0 {
 			return // we cant place market order on spot at exists before it happened, because there is no stop markets
@@ -291,17 +298,18 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 
 		if len(model.Conditions.EntryLevels) > 0 {
 			orderType = "take-profit-" + "limit"
-			break
-		}
-
-		currentOHLCVp := sm.DataFeed.GetPriceForPairAtExchange(sm.Strategy.GetModel().Conditions.Pair, sm.ExchangeName, sm.Strategy.GetModel().Conditions.MarketType)
-		if currentOHLCVp != nil {
+		} else if currentOHLCVp := sm.DataFeed.GetPriceForPairAtExchange(sm.Strategy.GetModel().Conditions.Pair, sm.ExchangeName, sm.Strategy.GetModel().Conditions.MarketType); currentOHLCVp != nil {
 			currentOHLCV := *currentOHLCVp
 			if currentOHLCV.Close < orderPrice && sm.Strategy.GetModel().Conditions.EntryOrder.Side == "buy" ||
 				currentOHLCV.Close > orderPrice && sm.Strategy.GetModel().Conditions.EntryOrder.Side == "sell" {
 				orderType = "take-profit-" + "limit"
 			}
 		}
+
+		if algorithmType != "" {
+			sm.waitForWithoutLoss(orderPrice, baseAmount, orderType != "take-profit-"+"limit")
+			return
+		}
 		break
 	case TakeProfit:
 		prefix := "take-profit-"
@@ -316,17 +324,17 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		target := model.Conditions.ExitLevels[sm.SelectedExitTarget]
 		isTrailingTarget := target.ActivatePrice != 0
 		isSpotMarketOrder := target.OrderType == "market" && isSpot
+		isAlgorithmTarget := execution.Get(target.OrderType) != nil // executed once the price is reached
 		baseAmount = model.Conditions.EntryOrder.Amount
 		side = oppositeSide
 		//log.Print("take profit price, orderPrice", price, orderPrice)
 
-		//if model.Conditions.TakeProfitSpreadHunter && price > 0 {
-		//	orderType = "maker-only"
-		//	if model.Conditions.TakeProfitWaitingTime > 0 {
-		//		frequency = float64(model.Conditions.TakeProfitWaitingTime)
-		//	}
-		//	break
-		//}
+		if model.Conditions.TakeProfitSpreadHunter && price > 0 {
+			// the rest of position goes at price found in spread
+			orderType = "limit"
+			baseAmount = sm.getPositionTargetAmount() - model.State.ExecutedAmount
+			break
+		}
 
 		if price == 0 && isTrailingTarget {
 			// trailing exit, we cant place exit order now
@@ -336,7 +344,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 			)
 			return
 		}
-		if price > 0 && !isSpotMarketOrder {
+		if price > 0 && isAlgorithmTarget && !isTrailingTarget {
+			orderType = target.OrderType
+		} else if price > 0 && !isSpotMarketOrder {
 			sm.Strategy.GetLogger().Debug("don't place order",
 				zap.Float64("price", price),
 				zap.Bool("!isSpotMarketOrder", !isSpotMarketOrder),
@@ -344,8 +354,17 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 			return // order was placed before, exit
 		}
 
-		// try exit on timeoutIfProfitable
-		if (model.Conditions.TimeoutIfProfitable > 0 && price < 0) || model.Conditions.TakeProfitPrice == -1 {
+		if price == 0 && isAlgorithmTarget && !isTrailingTarget {
+			sm.Strategy.GetLogger().Debug("don't place order",
+				zap.Float64("price", price),
+				zap.String("target.OrderType", target.OrderType),
+			)
+			return // the algorithm starts executing once the target price is reached
+		}
+
+		// try exit on timeoutIfProfitable or exit indicators
+		if (model.Conditions.TimeoutIfProfitable > 0 && price < 0) || model.Conditions.TakeProfitPrice == -1 ||
+			(len(model.Conditions.ExitIndicators) > 0 && price < 0) {
 			orderType = "market"
 			break
 		}
@@ -384,9 +403,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 				break
 			case 1:
 				if side == "sell" {
-					orderPrice = model.State.EntryPrice * (1 + target.Price/100/leverage)
+					orderPrice = model.State.EntryPrice * (1 + sm.atrAdjusted(target.Price)/100/leverage)
 				} else {
-					orderPrice = model.State.EntryPrice * (1 - target.Price/100/leverage)
+					orderPrice = model.State.EntryPrice * (1 - sm.atrAdjusted(target.Price)/100/leverage)
 				}
 				break
 			}
@@ -408,9 +427,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 				return // we cant place stop-market orders on spot so we'll wait for exact price
 			}
 			if side == "sell" {
-				orderPrice = model.State.TrailingEntryPrice * (1 - target.EntryDeviation/100/leverage)
+				orderPrice = model.State.TrailingEntryPrice * (1 - sm.atrAdjusted(target.EntryDeviation)/100/leverage)
 			} else {
-				orderPrice = model.State.TrailingEntryPrice * (1 + target.EntryDeviation/100/leverage)
+				orderPrice = model.State.TrailingEntryPrice * (1 + sm.atrAdjusted(target.EntryDeviation)/100/leverage)
 			}
 			if model.Conditions.TakeProfitExternal { // TV alert?
 				orderPrice = model.Conditions.TrailingExitPrice
@@ -446,7 +465,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		}
 		side = oppositeSide
 		reduceOnly = true
-		baseAmount = model.Conditions.EntryOrder.Amount
+		baseAmount = sm.getPositionTargetAmount()
 		orderType = "market"
 		if isSpot {
 			sm.TryCancelAllOrdersConsistently(sm.Strategy.GetModel().State.Orders)
@@ -457,6 +476,12 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		break
 	}
 
+	// Exits of entry filled in parts close the amount filled so far
+	isSpreadHunterTarget := step == TakeProfit && model.Conditions.TakeProfitSpreadHunter && price > 0
+	if step == TakeProfit && !isSpreadHunterTarget && (sm.isSlicedEntry() || model.State.EntryFilledAmount > 0) && model.Conditions.EntryOrder.Amount > 0 {
+		baseAmount = baseAmount * model.State.EntryFilledAmount / model.Conditions.EntryOrder.Amount
+	}
+
 	// Respect fees paid
 	// TODO: reset commission if PlaceEntryAfterTAP set and TakeProfit executes
 	if side == "sell" && isSpot {
@@ -510,6 +535,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 			request.KeyParams.Params = orders.OrderParams{
 				Type: advancedOrderType,
 			}
+			if isFutures {
+				request.KeyParams.Params.WorkingType = stopWorkingType(model.Conditions)
+			}
 		}
 		if isSpot {
 			// if SM wants to exit in a short period after entry executed, ES may have no balance updated and set
@@ -523,7 +551,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 		if (step == TrailingEntry || isSpotTAP) && orderType != "market" && ifShouldCancelPreviousOrder && len(model.State.ExecutedOrders) > 0 {
 			count := len(model.State.ExecutedOrders)
 			existingOrderId := model.State.ExecutedOrders[count-1]
-			response := sm.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+			response := sm.cancelOrder(orders.CancelOrderRequest{
 				KeyId: sm.KeyId,
 				KeyParams: orders.CancelOrderRequestParams{
 					OrderId:    existingOrderId,
@@ -558,8 +586,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 			zap.String("request", fmt.Sprint(request)),
 		)
 		var response orders.OrderResponse
-		if request.KeyParams.Type == "maker-only" {
-			response = sm.Strategy.GetSingleton().CreateOrder(request)
+		algorithm := execution.Get(request.KeyParams.Type)
+		if algorithm != nil {
+			response = algorithm.Execute(request, sm.orderCallback)
 		} else {
 			response = sm.ExchangeApi.CreateOrder(request)
 		}
@@ -576,7 +605,7 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 				if len(model.State.ExecutedOrders) > 0 && step != TrailingEntry {
 					count := len(model.State.ExecutedOrders)
 					existingOrderId := model.State.ExecutedOrders[count-1]
-					sm.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+					sm.cancelOrder(orders.CancelOrderRequest{
 						KeyId: sm.KeyId,
 						KeyParams: orders.CancelOrderRequestParams{
 							OrderId:    existingOrderId,
@@ -591,7 +620,12 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 				sm.OrdersMux.Lock()
 				sm.OrdersMap[response.Data.OrderId] = true
 				sm.OrdersMux.Unlock()
-				go sm.waitForOrder(response.Data.OrderId, step)
+				if algorithm != nil { // the algorithm reports the order updates
+					sm.storeAlgorithmOrder(response.Data.OrderId, request.KeyParams.Type, algorithm)
+					sm.StatusByOrderId.Store(response.Data.OrderId, step)
+				} else {
+					go sm.waitForOrder(response.Data.OrderId, step)
+				}
 
 				// save placed orders id to state SL/TAP
 				if step == Stoploss {
@@ -660,9 +694,9 @@ func (sm *SmartOrder) PlaceOrder(price, amount float64, step string) {
 					} else if len(model.Conditions.EntryLevels) > 0 &&
 						(step == Stoploss || step == "ForcedLoss") && attemptsToPlaceOrder < 3 {
 
-						lossPercentage := model.Conditions.StopLoss
+						lossPercentage := sm.atrAdjusted(model.Conditions.StopLoss)
 						if step == "ForcedLoss" {
-							lossPercentage = model.Conditions.ForcedLoss
+							lossPercentage = sm.atrAdjusted(model.Conditions.ForcedLoss)
 						}
 
 						price = sm.getLastTargetPrice(model)
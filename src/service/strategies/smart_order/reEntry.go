@@ -0,0 +1,122 @@
+package smart_order
+
+import (
+	"context"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+	"math"
+	"sync"
+	"time"
+)
+
+// tryReEntry starts waiting for re-entry if the position closed at a loss and the policy allows one more attempt,
+// otherwise the attempts in a row end and the entry amount is restored.
+func (sm *SmartOrder) tryReEntry() bool {
+	model := sm.Strategy.GetModel()
+	conditions := model.Conditions
+	isHedge := conditions.HedgeStrategyId != nil || conditions.Hedging
+	if conditions.ReEntryAttempts == 0 || len(conditions.EntryLevels) > 0 || isHedge {
+		return false
+	}
+	if model.State.ReceivedProfitAmount >= 0 || model.State.ReEntryAttempts >= conditions.ReEntryAttempts {
+		sm.resetReEntry()
+		return false
+	}
+	loss := model.State.ReEntryLoss - model.State.ReceivedProfitAmount
+	if conditions.ReEntryMaxLoss > 0 && loss >= conditions.ReEntryMaxLoss {
+		sm.Strategy.GetLogger().Info("re-entry loss cap reached",
+			zap.Float64("loss", loss),
+			zap.Float64("max loss", conditions.ReEntryMaxLoss),
+		)
+		sm.resetReEntry()
+		return false
+	}
+	baseAmount := model.State.ReEntryBaseAmount
+	if baseAmount == 0 {
+		baseAmount = conditions.EntryOrder.Amount
+	}
+	rate := conditions.ReEntryAmountRate
+	if rate <= 0 {
+		rate = 1
+	}
+	attempts := model.State.ReEntryAttempts + 1
+	amount := sm.toFixed(baseAmount*math.Pow(rate, float64(attempts)), sm.QuantityAmountPrecision, Floor)
+	if amount <= 0 {
+		sm.resetReEntry()
+		return false
+	}
+
+	sm.Strategy.GetLogger().Info("waiting for re-entry",
+		zap.Int64("attempt", attempts),
+		zap.Float64("amount", amount),
+		zap.Float64("loss", loss),
+	)
+	go sm.TryCancelAllOrders(model.State.Orders)
+	model.State = &models.MongoStrategyState{
+		State:             WaitForReEntry,
+		Iteration:         model.State.Iteration + 1,
+		ReEntryAttempts:   attempts,
+		ReEntryLoss:       loss,
+		ReEntryAt:         time.Now().Unix(),
+		ReEntryBaseAmount: baseAmount,
+	}
+	conditions.EntryOrder.Amount = amount
+	sm.IsEntryOrderPlaced = false
+	sm.IsWaitingForOrder = sync.Map{}
+	sm.EntryIndicatorsMet = false
+	sm.ExitIndicatorsMet = false
+	sm.StateMgmt.UpdateExecutedAmount(model.ID, model.State)
+	sm.StateMgmt.UpdateState(model.ID, model.State)
+	sm.StateMgmt.UpdateReEntry(model.ID, model.State)
+	sm.StateMgmt.SaveStrategyConditions(model)
+	sm.Statsd.Inc("smart_order.re_entry_wait")
+	return true
+}
+
+// resetReEntry ends attempts in a row restoring the entry amount they started with.
+func (sm *SmartOrder) resetReEntry() {
+	model := sm.Strategy.GetModel()
+	if model.State.ReEntryAttempts == 0 && model.State.ReEntryBaseAmount == 0 {
+		return
+	}
+	if model.State.ReEntryBaseAmount > 0 {
+		model.Conditions.EntryOrder.Amount = model.State.ReEntryBaseAmount
+		sm.StateMgmt.SaveStrategyConditions(model)
+	}
+	model.State.ReEntryAttempts = 0
+	model.State.ReEntryLoss = 0
+	model.State.ReEntryAt = 0
+	model.State.ReEntryBaseAmount = 0
+	sm.StateMgmt.UpdateReEntry(model.ID, model.State)
+}
+
+// checkReEntry is a guard letting the entry start over once the cooldown passed and the price reclaimed the level.
+func (sm *SmartOrder) checkReEntry(ctx context.Context, args ...interface{}) bool {
+	model := sm.Strategy.GetModel()
+	conditions := model.Conditions
+	if conditions.ReEntryCooldown > 0 && time.Now().Unix() < model.State.ReEntryAt+conditions.ReEntryCooldown {
+		return false
+	}
+	if conditions.ReEntryPrice > 0 {
+		currentOHLCV := args[0].(interfaces.OHLCV)
+		isReclaimed := conditions.EntryOrder.Side == "buy" && currentOHLCV.Close >= conditions.ReEntryPrice ||
+			conditions.EntryOrder.Side == "sell" && currentOHLCV.Close <= conditions.ReEntryPrice
+		if !isReclaimed {
+			return false
+		}
+	}
+	return true
+}
+
+// exitReEntry starts the entry over, it's placed on start as for the first run.
+func (sm *SmartOrder) exitReEntry(ctx context.Context, args ...interface{}) error {
+	model := sm.Strategy.GetModel()
+	sm.Strategy.GetLogger().Info("re-entering",
+		zap.Int64("attempt", model.State.ReEntryAttempts),
+		zap.Float64("amount", model.Conditions.EntryOrder.Amount),
+	)
+	model.State.State = ""
+	sm.Statsd.Inc("smart_order.re_entry")
+	return nil
+}
@@ -0,0 +1,130 @@
+package smart_order
+
+import (
+	"context"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// subscribeToSignals makes the smart order wait for any of linked signals to fire before placing an entry.
+func (sm *SmartOrder) subscribeToSignals() {
+	model := sm.Strategy.GetModel()
+	for i := range model.SignalIds {
+		signalId := model.SignalIds[i]
+		if err := sm.StateMgmt.SubscribeToSignal(&signalId, model.ID.Hex(), sm.onSignalFired); err != nil {
+			sm.Strategy.GetLogger().Error("can't subscribe to signal",
+				zap.String("signalId", signalId.Hex()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// unsubscribeFromSignals stops waiting for linked signals, so the smart order stopped is not notified any more.
+func (sm *SmartOrder) unsubscribeFromSignals() {
+	model := sm.Strategy.GetModel()
+	for i := range model.SignalIds {
+		sm.StateMgmt.UnsubscribeFromSignal(&model.SignalIds[i], model.ID.Hex())
+	}
+}
+
+// reloadSignals subscribes to signals linked by the reload and unsubscribes from signals unlinked.
+func (sm *SmartOrder) reloadSignals(previousIds []primitive.ObjectID) {
+	model := sm.Strategy.GetModel()
+	linked := map[primitive.ObjectID]bool{}
+	for _, signalId := range model.SignalIds {
+		linked[signalId] = true
+	}
+	for i := range previousIds {
+		if linked[previousIds[i]] {
+			delete(linked, previousIds[i])
+			continue
+		}
+		sm.StateMgmt.UnsubscribeFromSignal(&previousIds[i], model.ID.Hex())
+	}
+	for signalId := range linked {
+		signalId := signalId
+		if err := sm.StateMgmt.SubscribeToSignal(&signalId, model.ID.Hex(), sm.onSignalFired); err != nil {
+			sm.Strategy.GetLogger().Error("can't subscribe to signal",
+				zap.String("signalId", signalId.Hex()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// iterationStartedAt returns the time the current iteration started at, signals fired before don't release it.
+func (sm *SmartOrder) iterationStartedAt() int64 {
+	model := sm.Strategy.GetModel()
+	if model.State.IterationStartedAt > 0 {
+		return model.State.IterationStartedAt
+	}
+	if model.CreatedAt.IsZero() {
+		return 0
+	}
+	return model.CreatedAt.Unix()
+}
+
+// isEntryReleased returns true if ATR is known in ATR mode, entry indicators held and there are no signals linked
+// or one of them fired in the current iteration.
+func (sm *SmartOrder) isEntryReleased() bool {
+	model := sm.Strategy.GetModel()
+	if !sm.isAtrFrozen() {
+		return false
+	}
+	if len(model.Conditions.EntryIndicators) > 0 && !sm.EntryIndicatorsMet {
+		return false
+	}
+	if model.Conditions.CopyLeaderId != nil && model.Conditions.CopyLeaderEntryPrice == 0 {
+		return false // a follower enters after its leader
+	}
+	if len(model.SignalIds) == 0 {
+		return true
+	}
+	sm.SignalMux.Lock()
+	defer sm.SignalMux.Unlock()
+	return model.State.SignalFiredAt > 0 && model.State.SignalIteration == model.State.Iteration
+}
+
+// onSignalFired releases the entry if the firing is newer than the one released previous iteration and happened after
+// the current iteration started.
+func (sm *SmartOrder) onSignalFired(signal *models.MongoSignal) {
+	if signal.State == nil || signal.State.FiredAt == 0 {
+		return
+	}
+	model := sm.Strategy.GetModel()
+	if !model.Enabled {
+		return
+	}
+	if signal.State.FiredAt < sm.iterationStartedAt() {
+		sm.Strategy.GetLogger().Info("signal fired before the iteration started",
+			zap.String("signalId", signal.Id.Hex()),
+			zap.Int64("firedAt", signal.State.FiredAt),
+			zap.Int("iteration", model.State.Iteration),
+		)
+		return
+	}
+	sm.SignalMux.Lock()
+	isReleased := model.State.SignalFiredAt > 0 && model.State.SignalIteration == model.State.Iteration
+	if isReleased || signal.State.FiredAt <= model.State.SignalFiredAt {
+		sm.SignalMux.Unlock()
+		return
+	}
+	model.State.SignalId = &signal.Id
+	model.State.SignalFiredAt = signal.State.FiredAt
+	model.State.SignalIteration = model.State.Iteration
+	sm.SignalMux.Unlock()
+
+	sm.Strategy.GetLogger().Info("entry released by signal",
+		zap.String("signalId", signal.Id.Hex()),
+		zap.Int64("firedAt", signal.State.FiredAt),
+		zap.Int("iteration", model.State.Iteration),
+	)
+	sm.StateMgmt.UpdateEntrySignal(model.ID, model.State)
+	sm.Statsd.Inc("smart_order.entry_released_by_signal")
+
+	if state, _ := sm.State.State(context.Background()); state == WaitForEntry {
+		sm.checkIfPlaceOrderInstantlyOnStart()
+	}
+}
@@ -0,0 +1,212 @@
+package smart_order
+
+import (
+	"context"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.uber.org/zap"
+	"math/rand"
+	"time"
+)
+
+// isSlicedEntry tells whether the entry amount is split into slices placed over time.
+func (sm *SmartOrder) isSlicedEntry() bool {
+	conditions := sm.Strategy.GetModel().Conditions
+	return conditions.EntrySlices > 1 && len(conditions.EntryLevels) == 0
+}
+
+// getPositionTargetAmount returns an amount exits should close: the entry amount or what entry filled so far if it
+// fills in parts by slices or partial fills. Position amount is not used since it decreases as exits fill.
+func (sm *SmartOrder) getPositionTargetAmount() float64 {
+	model := sm.Strategy.GetModel()
+	if sm.isSlicedEntry() || model.State.EntryFilledAmount > 0 {
+		return model.State.EntryFilledAmount
+	}
+	return model.Conditions.EntryOrder.Amount
+}
+
+// startEntrySlices runs the slices schedule unless it's running already or all slices passed.
+func (sm *SmartOrder) startEntrySlices() {
+	model := sm.Strategy.GetModel()
+	sm.SlicesMux.Lock()
+	defer sm.SlicesMux.Unlock()
+	if sm.IsSlicing || int64(model.State.EntrySlicesPlaced) >= model.Conditions.EntrySlices {
+		return
+	}
+	sm.IsSlicing = true
+	sm.IsWaitingForOrder.Store(WaitForEntry, true)
+	go sm.runEntrySlices()
+}
+
+// runEntrySlices places slices evenly over the duration while the smart order waits for entry or is in entry, a slice
+// not filled by the time the next one is placed is canceled and its rest is spread over slices left. The last slice
+// stays as the entry order.
+func (sm *SmartOrder) runEntrySlices() {
+	model := sm.Strategy.GetModel()
+	defer func() {
+		sm.SlicesMux.Lock()
+		sm.IsSlicing = false
+		sm.SlicesMux.Unlock()
+	}()
+	slices := int(model.Conditions.EntrySlices)
+	interval := float64(model.Conditions.EntrySlicesDuration) / float64(slices-1)
+	randomization := model.Conditions.EntrySlicesRandomization / 100
+	for {
+		sm.SlicesMux.Lock()
+		placed := model.State.EntrySlicesPlaced
+		sm.SlicesMux.Unlock()
+		if placed >= slices {
+			return
+		}
+		if placed > 0 {
+			delay := interval * (1 + randomization*(2*rand.Float64()-1))
+			time.Sleep(time.Duration(delay * float64(time.Second)))
+		}
+		state, _ := sm.State.State(context.Background())
+		if !model.Enabled || (state != WaitForEntry && state != InEntry) {
+			sm.Strategy.GetLogger().Info("entry slices stopped",
+				zap.String("state", state.(string)),
+				zap.Int("placed", placed),
+			)
+			return
+		}
+		sm.cancelOpenSlices()
+		sm.SlicesMux.Lock()
+		sm.placeEntrySlice(randomization)
+		sm.SlicesMux.Unlock()
+	}
+}
+
+// cancelOpenSlices cancels entry slices placed before which didn't fill yet, fills of them are taken once canceled.
+func (sm *SmartOrder) cancelOpenSlices() {
+	var openIds []string
+	sm.OrdersMux.Lock()
+	for _, orderId := range sm.Strategy.GetModel().State.WaitForEntryIds {
+		if sm.OrdersMap[orderId] {
+			openIds = append(openIds, orderId)
+		}
+	}
+	sm.OrdersMux.Unlock()
+	if len(openIds) == 0 {
+		return
+	}
+	sm.Strategy.GetLogger().Info("cancel entry slices not filled",
+		zap.Strings("order ids", openIds),
+	)
+	sm.TryCancelAllOrdersConsistently(openIds)
+}
+
+// onEntrySliceCanceled takes the amount the slice filled before it got canceled and returns the rest to slices left,
+// returns true on the first fill to get in entry.
+func (sm *SmartOrder) onEntrySliceCanceled(order models.MongoOrder) bool {
+	model := sm.Strategy.GetModel()
+	if rest := order.Amount - order.Filled; rest > 0 {
+		sm.SlicesMux.Lock()
+		model.State.EntrySlicesAmount -= rest
+		sm.StateMgmt.UpdateEntrySlices(model.ID, model.State)
+		sm.SlicesMux.Unlock()
+	}
+	sm.Statsd.Inc("smart_order.entry_slice_canceled")
+	return sm.onEntrySliceFilled(order.Average, order.Filled)
+}
+
+// placeEntrySlice places the next slice sized to spread the amount left over slices left, the last one takes the rest.
+// SlicesMux should be held.
+func (sm *SmartOrder) placeEntrySlice(randomization float64) {
+	model := sm.Strategy.GetModel()
+	slicesLeft := int(model.Conditions.EntrySlices) - model.State.EntrySlicesPlaced
+	amountLeft := model.Conditions.EntryOrder.Amount - model.State.EntrySlicesAmount
+	amount := amountLeft
+	if slicesLeft > 1 {
+		amount = amountLeft / float64(slicesLeft) * (1 + randomization*(2*rand.Float64()-1))
+	}
+	amount = sm.toFixed(amount, sm.QuantityAmountPrecision, Floor)
+	model.State.EntrySlicesPlaced += 1
+
+	price := 0.0
+	if currentOHLCVp := sm.DataFeed.GetPriceForPairAtExchange(model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType); currentOHLCVp != nil {
+		price = currentOHLCVp.Close
+	}
+	limit := model.Conditions.EntrySlicesPriceLimit
+	isBeyondLimit := price == 0 || limit > 0 &&
+		(model.Conditions.EntryOrder.Side == "buy" && price > limit || model.Conditions.EntryOrder.Side == "sell" && price < limit)
+	if isBeyondLimit || amount <= 0 {
+		sm.Strategy.GetLogger().Info("entry slice skipped",
+			zap.Float64("price", price),
+			zap.Float64("amount", amount),
+			zap.Int("slice", model.State.EntrySlicesPlaced),
+		)
+		sm.Statsd.Inc("smart_order.entry_slice_skipped")
+		sm.StateMgmt.UpdateEntrySlices(model.ID, model.State)
+		return
+	}
+	model.State.EntrySlicesAmount += amount
+	sm.StateMgmt.UpdateEntrySlices(model.ID, model.State)
+	sm.Strategy.GetLogger().Info("place entry slice",
+		zap.Float64("price", price),
+		zap.Float64("amount", amount),
+		zap.Int("slice", model.State.EntrySlicesPlaced),
+	)
+	sm.PlaceOrder(price, amount, WaitForEntry)
+	sm.Statsd.Inc("smart_order.entry_slice_placed")
+}
+
+// onEntrySliceFilled adds the slice to the position with running average entry price, returns true on the first fill
+// to get in entry, next fills replace exits for the position grown.
+func (sm *SmartOrder) onEntrySliceFilled(price, filled float64) bool {
+	model := sm.Strategy.GetModel()
+	isFirstFill := model.State.PositionAmount == 0
+	if filled <= 0 {
+		return false
+	}
+	total := model.State.EntryPrice*model.State.PositionAmount + price*filled
+	model.State.PositionAmount += filled
+	model.State.EntryFilledAmount += filled
+	model.State.EntryPrice = total / model.State.PositionAmount
+	if isFirstFill {
+		model.State.State = InEntry
+	}
+	sm.StateMgmt.UpdateEntryPrice(model.ID, model.State)
+	sm.Strategy.GetLogger().Info("entry slice filled",
+		zap.Float64("average entry price", model.State.EntryPrice),
+		zap.Float64("position amount", model.State.PositionAmount),
+	)
+	if !isFirstFill {
+		go sm.replaceExits(InEntry)
+	}
+	return isFirstFill
+}
+
+// replaceExits cancels exit orders placed and places them again for the current position and entry price.
+func (sm *SmartOrder) replaceExits(state string) {
+	sm.SlicesMux.Lock()
+	defer sm.SlicesMux.Unlock()
+	model := sm.Strategy.GetModel()
+	if currentState, _ := sm.State.State(context.Background()); currentState != state {
+		return
+	}
+	isSpot := model.Conditions.MarketType == 0
+	sm.cancelExits()
+	if !model.Conditions.TakeProfitExternal && !model.Conditions.TakeProfitSpreadHunter {
+		sm.PlaceOrder(0, 0.0, TakeProfit)
+	}
+	if !model.Conditions.StopLossExternal && !isSpot {
+		sm.PlaceOrder(0, 0.0, Stoploss)
+		if model.Conditions.ForcedLoss > 0 {
+			sm.PlaceOrder(0, 0.0, "ForcedLoss")
+		}
+	}
+}
+
+// cancelExits cancels take-profit orders and stop orders on futures, spot stops are not placed in advance.
+func (sm *SmartOrder) cancelExits() {
+	model := sm.Strategy.GetModel()
+	sm.TryCancelAllOrdersConsistently(model.State.TakeProfitOrderIds)
+	model.State.TakeProfitOrderIds = []string{}
+	sm.IsWaitingForOrder.Store(TakeProfit, false)
+	if model.Conditions.MarketType == 1 {
+		sm.TryCancelAllOrdersConsistently(model.State.StopLossOrderIds)
+		sm.TryCancelAllOrdersConsistently(model.State.ForcedLossOrderIds)
+		model.State.StopLossOrderIds = []string{}
+		model.State.ForcedLossOrderIds = []string{}
+	}
+}
@@ -30,6 +30,7 @@ const (
 	WaitOrder          = "WaitOrder"
 	End                = "End"
 	Canceled           = "Canceled"
+	WaitForReEntry     = "WaitForReEntry"
 	EnterNextTarget    = "EnterNextTarget"
 	Timeout            = "Timeout"
 	Error              = "Error"
@@ -52,6 +53,7 @@ const (
 	Restart                  = "Restart"
 	ReEntry                  = "ReEntry"
 	TriggerTimeout           = "TriggerTimeout"
+	TriggerPartialFill       = "TriggerPartialFill"
 )
 
 // A SmartOrder takes strategy to execute with context by the service runtime.
@@ -68,6 +70,7 @@ type SmartOrder struct {
 	IsEntryOrderPlaced      bool     // we need it for case when response from createOrder was returned after entryTimeout was executed
 	OrdersMap               map[string]bool
 	StatusByOrderId         sync.Map
+	AlgorithmByOrderId      sync.Map // execution algorithm by id of the order it executes
 	QuantityAmountPrecision int64
 	QuantityPricePrecision  int64
 	Lock                    bool
@@ -77,6 +80,19 @@ type SmartOrder struct {
 	SelectedEntryTarget     int // represents what amount of targets executed for the SM by averaging
 	OrdersMux               sync.Mutex
 	StopMux                 sync.Mutex
+	SignalMux               sync.Mutex
+	EntryIndicatorsMet      bool // entry indicators held in the current iteration
+	ExitIndicatorsMet       bool // exit by indicators was placed in the current iteration
+	LastIndicatorsCheckAt   time.Time
+	IsSlicing               bool // entry slices schedule runs
+	IsFeedStale             bool // price feed was stale on the last event loop check
+	IsTriggerPriceMissing   bool // mark price triggers compare against was missing or stale on the last check
+	IsAtrWarmupOver         bool // ATR didn't warm up in time in the current iteration, logged once
+	SlicesMux               sync.Mutex
+	CopyMux                 sync.Mutex
+	isCopySynced            bool                           // followers were synced in the iteration
+	copiedEntryPrice        float64                        // leader entry price followers got last time
+	followerIds             map[string]*primitive.ObjectID // followers created in the iteration by key id
 }
 
 const (
@@ -118,6 +134,7 @@ func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAP
 		SelectedExitTarget: 0,
 		OrdersMap:          map[string]bool{},
 	}
+	sm.restoreAlgorithmOrders()
 
 	initState := WaitForEntry
 	pricePrecision, amountPrecision := stateMgmt.GetMarketPrecision(strategy.GetModel().Conditions.Pair, strategy.GetModel().Conditions.MarketType)
@@ -141,15 +158,19 @@ func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAP
 	State.SetTriggerParameters(TriggerTrade, reflect.TypeOf(interfaces.OHLCV{}))
 	State.SetTriggerParameters(CheckExistingOrders, reflect.TypeOf(models.MongoOrder{}))
 	State.SetTriggerParameters(TriggerSpread, reflect.TypeOf(interfaces.SpreadData{}))
+	State.SetTriggerParameters(TriggerPartialFill, reflect.TypeOf(models.MongoOrder{}))
+	State.SetTriggerParameters(ReEntry, reflect.TypeOf(interfaces.OHLCV{}))
 
 	/*
 		Smart Order life cycle:
 			1) first need to go into entry, so we wait for entry and put orders before if possible
 			2) we may go into waiting for trailing entry if activate price was specified ( and placing stop-limit/market orders to catch entry, and cancel existing )
+			2a) entry order may fill in parts, then we are partially in entry with exits for the amount filled so far
 			3) ok we are in entry and now wait for profit or loss ( also we can try to place all orders )
 			4) we may go to exit on timeout if profit/loss
 			5) so we'll wait for any target or trailing target
 			6) or stop-loss
+			7) after a loss we may wait to re-enter with the same conditions, then start over from 1)
 	*/
 
 	State.Configure(WaitForEntry).
@@ -157,8 +178,16 @@ func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAP
 		PermitDynamic(TriggerSpread, sm.exitWaitEntry, sm.checkSpreadEntry).
 		PermitDynamic(CheckExistingOrders, sm.exitWaitEntry, sm.checkExistingOrders).
 		Permit(TriggerTimeout, Timeout).
+		Permit(TriggerPartialFill, PartiallyEntry, sm.checkPartialFill).
 		OnEntry(sm.onStart)
 
+	State.Configure(PartiallyEntry).
+		PermitReentry(TriggerPartialFill, sm.checkPartialFill).
+		PermitDynamic(CheckExistingOrders, sm.exitPartiallyEntry, sm.checkExistingOrders).
+		PermitDynamic(CheckLossTrade, sm.exitPartiallyEntry, sm.checkLoss).
+		Permit(TriggerTimeout, InEntry).
+		OnEntry(sm.enterPartiallyEntry)
+
 	State.Configure(TrailingEntry).
 		Permit(TriggerTrade, InEntry, sm.checkTrailingEntry).
 		Permit(CheckExistingOrders, InEntry, sm.checkExistingOrders).
@@ -170,6 +199,7 @@ func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAP
 		PermitDynamic(CheckLossTrade, sm.exit, sm.checkLoss).
 		PermitDynamic(CheckExistingOrders, sm.exit, sm.checkExistingOrders).
 		PermitDynamic(CheckHedgeLoss, sm.exit, sm.checkLossHedge).
+		Permit(CheckSpreadProfitTrade, TakeProfit, sm.checkSpreadTakeProfit).
 		OnEntry(sm.enterEntry)
 
 	State.Configure(InMultiEntry).
@@ -203,6 +233,10 @@ func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAP
 	State.Configure(Timeout).
 		Permit(Restart, WaitForEntry)
 
+	State.Configure(WaitForReEntry).
+		Permit(ReEntry, WaitForEntry, sm.checkReEntry).
+		OnExit(sm.exitReEntry)
+
 	State.Configure(End).
 		PermitReentry(CheckExistingOrders, sm.checkExistingOrders).
 		OnEntry(sm.enterEnd)
@@ -222,6 +256,10 @@ func New(strategy interfaces.IStrategy, DataFeed interfaces.IDataFeed, TradingAP
 	// fmt.Println("State chart graph written to ./graph.dot")
 	// os.Exit(0)
 	_ = sm.onStart(nil)
+	sm.subscribeToSignals()
+	if sm.isSlicedEntry() && sm.Strategy.GetModel().State.EntrySlicesPlaced > 0 {
+		sm.startEntrySlices() // resume the schedule after restart
+	}
 	return sm
 }
 
@@ -246,6 +284,9 @@ func (sm *SmartOrder) onStart(ctx context.Context, args ...interface{}) error {
 
 func (sm *SmartOrder) checkIfPlaceOrderInstantlyOnStart() {
 	model := sm.Strategy.GetModel()
+	if !sm.isEntryReleased() {
+		return
+	}
 	isMultiEntry := len(model.Conditions.EntryLevels) > 0
 	isFirstRunSoStateIsEmpty := model.State.State == "" ||
 		(model.State.State == WaitForEntry && model.Conditions.ContinueIfEnded && model.Conditions.WaitingEntryTimeout > 0)
@@ -254,7 +295,9 @@ func (sm *SmartOrder) checkIfPlaceOrderInstantlyOnStart() {
 	if isFirstRunSoStateIsEmpty && isFirstRunSoOrdersListsAreEmpty && model.Enabled &&
 		!model.Conditions.EntrySpreadHunter && !isMultiEntry {
 		entryIsNotTrailing := model.Conditions.EntryOrder.ActivatePrice == 0
-		if entryIsNotTrailing { // then we must know exact price
+		if entryIsNotTrailing && sm.isSlicedEntry() {
+			sm.startEntrySlices()
+		} else if entryIsNotTrailing { // then we must know exact price
 			sm.IsWaitingForOrder.Store(WaitForEntry, true)
 			sm.PlaceOrder(model.Conditions.EntryOrder.Price, 0.0, WaitForEntry)
 		}
@@ -313,6 +356,9 @@ func (sm *SmartOrder) checkWaitEntry(ctx context.Context, args ...interface{}) b
 	if len(sm.Strategy.GetModel().Conditions.EntryLevels) > 0 {
 		return false
 	}
+	if !sm.isEntryReleased() {
+		return false
+	}
 	currentOHLCV := args[0].(interfaces.OHLCV)
 	model := sm.Strategy.GetModel()
 	conditionPrice := model.Conditions.EntryOrder.Price
@@ -354,28 +400,33 @@ func (sm *SmartOrder) enterEntry(ctx context.Context, args ...interface{}) error
 
 		if isSpot {
 			sm.TryCancelAllOrdersConsistently(sm.Strategy.GetModel().State.Orders)
-			sm.PlaceOrder(0, 0.0, TakeProfit)
+			if !sm.Strategy.GetModel().Conditions.TakeProfitSpreadHunter {
+				sm.PlaceOrder(0, 0.0, TakeProfit)
+			}
 		}
 		return nil
 	}
-	if currentOHLCV, ok := args[0].(interfaces.OHLCV); ok {
-		sm.Strategy.GetModel().State.EntryPrice = currentOHLCV.Close
+	if len(args) > 0 { // no trade data when partially entry times out
+		if currentOHLCV, ok := args[0].(interfaces.OHLCV); ok {
+			sm.Strategy.GetModel().State.EntryPrice = currentOHLCV.Close
+		}
 	}
 	sm.Strategy.GetModel().State.State = InEntry
 	sm.Strategy.GetModel().State.TrailingEntryPrice = 0
 	sm.Strategy.GetModel().State.ExecutedOrders = []string{}
 	go sm.StateMgmt.UpdateState(sm.Strategy.GetModel().ID, sm.Strategy.GetModel().State)
+	sm.syncCopy()
 
 	//if !sm.Strategy.GetModel().Conditions.EntrySpreadHunter {
 	if isSpot {
 		sm.PlaceOrder(sm.Strategy.GetModel().State.EntryPrice, 0.0, InEntry)
-		if !sm.Strategy.GetModel().Conditions.TakeProfitExternal {
+		if !sm.Strategy.GetModel().Conditions.TakeProfitExternal && !sm.Strategy.GetModel().Conditions.TakeProfitSpreadHunter {
 			sm.Strategy.GetLogger().Info("placing take-profit")
 			sm.PlaceOrder(0, 0.0, TakeProfit)
 		}
 	} else {
 		go sm.PlaceOrder(sm.Strategy.GetModel().State.EntryPrice, 0.0, InEntry)
-		if !sm.Strategy.GetModel().Conditions.TakeProfitExternal {
+		if !sm.Strategy.GetModel().Conditions.TakeProfitExternal && !sm.Strategy.GetModel().Conditions.TakeProfitSpreadHunter {
 			sm.PlaceOrder(0, 0.0, TakeProfit)
 		}
 	}
@@ -449,7 +500,7 @@ func (sm *SmartOrder) checkProfit(ctx context.Context, args ...interface{}) bool
 		case "buy":
 			for i, level := range model.Conditions.ExitLevels {
 				if model.State.ReachedTargetCount < i+1 && level.ActivatePrice == 0 {
-					if level.Type == 1 && currentOHLCV.Close >= (model.State.EntryPrice*(100+level.Price/model.Conditions.Leverage)/100) ||
+					if level.Type == 1 && currentOHLCV.Close >= (model.State.EntryPrice*(100+sm.atrAdjusted(level.Price)/model.Conditions.Leverage)/100) ||
 						level.Type == 0 && currentOHLCV.Close >= level.Price {
 						model.State.ReachedTargetCount += 1
 						if level.Type == 0 {
@@ -468,7 +519,7 @@ func (sm *SmartOrder) checkProfit(ctx context.Context, args ...interface{}) bool
 		case "sell":
 			for i, level := range model.Conditions.ExitLevels {
 				if model.State.ReachedTargetCount < i+1 && level.ActivatePrice == 0 {
-					if level.Type == 1 && currentOHLCV.Close <= (model.State.EntryPrice*((100-level.Price/model.Conditions.Leverage)/100)) ||
+					if level.Type == 1 && currentOHLCV.Close <= (model.State.EntryPrice*((100-sm.atrAdjusted(level.Price)/model.Conditions.Leverage)/100)) ||
 						level.Type == 0 && currentOHLCV.Close <= level.Price {
 						model.State.ReachedTargetCount += 1
 						if level.Type == 0 {
@@ -531,8 +582,8 @@ func (sm *SmartOrder) checkLoss(ctx context.Context, args ...interface{}) bool {
 	if isTrailingHedgeOrder {
 		return false
 	}
-	stopLoss := model.Conditions.StopLoss / model.Conditions.Leverage
-	forcedLoss := model.Conditions.ForcedLoss / model.Conditions.Leverage
+	stopLoss := sm.atrAdjusted(model.Conditions.StopLoss) / model.Conditions.Leverage
+	forcedLoss := sm.atrAdjusted(model.Conditions.ForcedLoss) / model.Conditions.Leverage
 	currentState := model.State.State
 	stateFromStateMachine, _ := sm.State.State(ctx)
 
@@ -581,7 +632,7 @@ func (sm *SmartOrder) checkLoss(ctx context.Context, args ...interface{}) bool {
 			}
 
 			// if order go to StopLoss from InEntry state or returned to Stoploss while timeout
-			if currentState == InEntry {
+			if currentState == InEntry || currentState == PartiallyEntry {
 				model.State.State = Stoploss
 				sm.StateMgmt.UpdateState(model.ID, model.State)
 
@@ -611,7 +662,7 @@ func (sm *SmartOrder) checkLoss(ctx context.Context, args ...interface{}) bool {
 				model.State.Amount = model.Conditions.EntryOrder.Amount - model.State.ExecutedAmount
 			}
 
-			if currentState == InEntry {
+			if currentState == InEntry || currentState == PartiallyEntry {
 				model.State.State = Stoploss
 				sm.StateMgmt.UpdateState(model.ID, model.State)
 
@@ -705,7 +756,7 @@ func (sm *SmartOrder) IsOrderExistsInMap(orderId string) bool {
 func (sm *SmartOrder) TryCancelAllOrdersConsistently(orderIds []string) {
 	for _, orderId := range orderIds {
 		if orderId != "0" {
-			sm.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+			sm.cancelOrder(orders.CancelOrderRequest{
 				KeyId: sm.KeyId,
 				KeyParams: orders.CancelOrderRequestParams{
 					OrderId:    orderId,
@@ -720,7 +771,7 @@ func (sm *SmartOrder) TryCancelAllOrdersConsistently(orderIds []string) {
 func (sm *SmartOrder) TryCancelAllOrders(orderIds []string) {
 	for _, orderId := range orderIds {
 		if orderId != "0" {
-			go sm.ExchangeApi.CancelOrder(orders.CancelOrderRequest{
+			go sm.cancelOrder(orders.CancelOrderRequest{
 				KeyId: sm.KeyId,
 				KeyParams: orders.CancelOrderRequestParams{
 					OrderId:    orderId,
@@ -732,13 +783,21 @@ func (sm *SmartOrder) TryCancelAllOrders(orderIds []string) {
 	}
 }
 
-// Start continuously checks the state and runs another event loop cycle or stops the smart order if conditions met.
+// Start runs the event loop cycle on every update of the market and once a check interval for time based checks if
+// the market is quiet, then stops the smart order once conditions met. Feeds not pushing updates are polled instead.
 func (sm *SmartOrder) Start() {
 	ctx := context.TODO()
 
+	if sm.isTriggerPriceRejected() {
+		return
+	}
 	state, _ := sm.State.State(context.Background())
 	localState := sm.Strategy.GetModel().State.State
 	sm.Statsd.Inc("smart_order.start")
+	conditions := sm.Strategy.GetModel().Conditions
+	updates := sm.DataFeed.Subscribe(conditions.Pair, sm.ExchangeName, conditions.MarketType)
+	releaseCandles := sm.watchCandles()
+	defer releaseCandles()
 	var lastValidityCheckAt = time.Now().Add(-1 * time.Second)
 	for state != End && localState != End && state != Canceled && state != Timeout {
 		if time.Since(lastValidityCheckAt) > 2*time.Second { // TODO: remove magic number
@@ -756,17 +815,22 @@ func (sm *SmartOrder) Start() {
 			state, _ = sm.State.State(ctx)
 			break
 		}
+		sm.syncCopy()
 		if !sm.Lock {
-			if sm.Strategy.GetModel().Conditions.EntrySpreadHunter && state != InEntry {
+			conditions := sm.Strategy.GetModel().Conditions
+			if conditions.EntrySpreadHunter && state != InEntry || conditions.TakeProfitSpreadHunter && state == InEntry {
 				sm.processSpreadEventLoop()
 			} else {
 				sm.processEventLoop()
 			}
 		}
-		time.Sleep(60 * time.Millisecond)
+		updates = waitForUpdate(updates)
 		state, _ = sm.State.State(ctx)
 		localState = sm.Strategy.GetModel().State.State
 	}
+	if updates != nil {
+		sm.DataFeed.Unsubscribe(conditions.Pair, sm.ExchangeName, conditions.MarketType, updates)
+	}
 	sm.Stop()
 	sm.Strategy.GetLogger().Info("stopped smart order",
 		zap.String("state", state.(string)),
@@ -787,6 +851,7 @@ func (sm *SmartOrder) Stop() {
 
 	sm.StopLock = true
 	state, _ := sm.State.State(context.Background())
+	sm.exitFollowers()
 
 	// cancel orders
 	if model.Conditions.MarketType == 0 && state != End {
@@ -842,6 +907,9 @@ func (sm *SmartOrder) Stop() {
 		model.Conditions.ContinueIfEnded && !model.Conditions.PositionWasClosed {
 		sm.IsWaitingForOrder = sync.Map{}
 		sm.IsEntryOrderPlaced = false
+		sm.EntryIndicatorsMet = false
+		sm.ExitIndicatorsMet = false
+		sm.IsAtrWarmupOver = false
 		sm.StateMgmt.EnableStrategy(model.ID)
 		model.Enabled = true
 		stateModel := model.State
@@ -850,13 +918,30 @@ func (sm *SmartOrder) Stop() {
 		stateModel.ExecutedAmount = 0
 		stateModel.Amount = 0
 		stateModel.Orders = []string{}
-		stateModel.Iteration += 1
+		stateModel.Iteration += 1 // entry waits for a new signal firing if any signal linked
+		stateModel.IterationStartedAt = time.Now().Unix()
+		stateModel.Atr = 0        // and for ATR measured again in ATR mode
+		stateModel.AtrPrice = 0
+		stateModel.AtrWaitSince = 0
+		stateModel.EntrySlicesPlaced = 0
+		stateModel.WithoutLossPrice = 0
+		stateModel.WithoutLossAmount = 0
+		stateModel.EntrySlicesAmount = 0
+		stateModel.EntryFilledAmount = 0
+		if sm.isSlicedEntry() {
+			stateModel.PositionAmount = 0
+		}
 		sm.StateMgmt.UpdateState(model.ID, stateModel)
 		sm.StateMgmt.UpdateExecutedAmount(model.ID, stateModel)
+		sm.StateMgmt.UpdateAtr(model.ID, stateModel)
+		sm.StateMgmt.UpdateEntrySlices(model.ID, stateModel)
+		sm.StateMgmt.UpdateEntrySignal(model.ID, stateModel)
 		sm.StateMgmt.SaveStrategyConditions(model)
 		_ = sm.State.Fire(Restart)
 		//_ = sm.onStart(nil)
 		sm.Start()
+	} else {
+		sm.unsubscribeFromSignals()
 	}
 
 	if amount := sm.Strategy.GetModel().State.PositionAmount; amount != 0.0 {
@@ -868,17 +953,44 @@ func (sm *SmartOrder) Stop() {
 	sm.Statsd.Inc("smart_order.stop_attempt")
 }
 
+// HotReload applies changes of the strategy reloaded, the model is updated already.
+func (sm *SmartOrder) HotReload(previous models.MongoStrategy) {
+	sm.reloadSignals(previous.SignalIds)
+	sm.reloadCopy(previous.Conditions)
+}
+
 // processEventLoop takes new OHCLV data to supply it for the smart order state transition attempt.
 func (sm *SmartOrder) processEventLoop() {
 	currentOHLCVp := sm.DataFeed.GetPriceForPairAtExchange(sm.Strategy.GetModel().Conditions.Pair, sm.ExchangeName, sm.Strategy.GetModel().Conditions.MarketType)
-	if currentOHLCVp != nil {
-		currentOHLCV := *currentOHLCVp
+	if currentOHLCVp != nil && !sm.isFeedStale() {
+		currentOHLCV, ok := sm.triggerPrice(*currentOHLCVp)
+		if !ok {
+			return
+		}
 		state, err := sm.State.State(context.TODO())
+		if state == WaitForReEntry {
+			_ = sm.State.FireCtx(context.TODO(), ReEntry, currentOHLCV)
+			return
+		}
+		if state == WaitForEntry {
+			sm.checkAtr()
+			sm.checkEntryIndicators()
+		}
 		err = sm.State.FireCtx(context.TODO(), TriggerTrade, currentOHLCV)
 		if err == nil {
 			return
 		}
+		if state == InEntry || state == TakeProfit || state == Stoploss {
+			sm.checkExitIndicators()
+		}
+		if state == PartiallyEntry {
+			_ = sm.State.FireCtx(context.TODO(), CheckLossTrade, currentOHLCV)
+			return
+		}
 		if state == InEntry || state == TakeProfit || state == Stoploss || state == HedgeLoss {
+			if sm.checkWithoutLoss(currentOHLCV) {
+				return
+			}
 			err = sm.State.FireCtx(context.TODO(), CheckLossTrade, currentOHLCV)
 			if err == nil {
 				return
@@ -902,12 +1014,20 @@ func (sm *SmartOrder) processEventLoop() {
 
 func (sm *SmartOrder) processSpreadEventLoop() {
 	currentSpreadP := sm.DataFeed.GetSpreadForPairAtExchange(sm.Strategy.GetModel().Conditions.Pair, sm.ExchangeName, sm.Strategy.GetModel().Conditions.MarketType)
-	if currentSpreadP != nil {
+	if currentSpreadP != nil && !sm.isFeedStale() {
 		currentSpread := *currentSpreadP
 		ohlcv := interfaces.OHLCV{
 			Close: currentSpread.BestBid,
 		}
 		state, err := sm.State.State(context.TODO())
+		if state == WaitForReEntry {
+			_ = sm.State.FireCtx(context.TODO(), ReEntry, ohlcv)
+			return
+		}
+		if state == WaitForEntry {
+			sm.checkAtr()
+			sm.checkEntryIndicators()
+		}
 		err = sm.State.FireCtx(context.TODO(), TriggerSpread, currentSpread)
 		if err == nil {
 			return
@@ -917,6 +1037,17 @@ func (sm *SmartOrder) processSpreadEventLoop() {
 			if err == nil {
 				return
 			}
+		}
+		// loss, profit and trailing work by the price exchange stop orders work by as in the price loop
+		ohlcv, ok := sm.triggerPrice(ohlcv)
+		if !ok {
+			return
+		}
+		if state == PartiallyEntry {
+			_ = sm.State.FireCtx(context.TODO(), CheckLossTrade, ohlcv)
+			return
+		}
+		if state == InEntry || state == TakeProfit || state == Stoploss || state == HedgeLoss {
 			err = sm.State.FireCtx(context.TODO(), CheckLossTrade, ohlcv)
 			if err == nil {
 				return
@@ -3,16 +3,48 @@ package smart_order
 import (
 	"context"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
 	"go.uber.org/zap"
+	"time"
 )
 
-func (sm *SmartOrder) checkSpreadCondition(spread interfaces.SpreadData) bool {
-	fee := 0.0012
-	if (spread.BestAsk/spread.BestBid - 1) > fee {
-		return true
-	}
+const (
+	defaultSpreadHunterMinSpread = 0.0012 // covers fees paid both ways
+	maxSpreadHunterOffset        = 0.5    // up to the middle of the spread, not to cross it
+)
 
-	return false
+// spreadPrice returns a price to place an order of the side given inside the spread if the spread is worth hunting.
+func (sm *SmartOrder) spreadPrice(spread interfaces.SpreadData, side string) (float64, bool) {
+	conditions := sm.Strategy.GetModel().Conditions
+	if spread.BestBid <= 0 || spread.BestAsk <= spread.BestBid {
+		return 0, false
+	}
+	minSpread := conditions.SpreadHunterMinSpread
+	if minSpread == 0 {
+		minSpread = defaultSpreadHunterMinSpread
+	}
+	if spread.BestAsk/spread.BestBid-1 <= minSpread {
+		return 0, false
+	}
+	offset := conditions.SpreadHunterOffset
+	if offset < 0 {
+		offset = 0
+	} else if offset > maxSpreadHunterOffset {
+		offset = maxSpreadHunterOffset
+	}
+	offset = offset * (spread.BestAsk - spread.BestBid)
+	// thin best level may go any moment, so we don't join it
+	if side == "sell" {
+		if spread.BestAskQty < conditions.SpreadHunterMinQty {
+			return 0, false
+		}
+		return spread.BestAsk - offset, true
+	}
+	if spread.BestBidQty < conditions.SpreadHunterMinQty {
+		return 0, false
+	}
+	return spread.BestBid + offset, true
 }
 
 func (sm *SmartOrder) checkSpreadEntry(ctx context.Context, args ...interface{}) bool {
@@ -20,18 +52,28 @@ func (sm *SmartOrder) checkSpreadEntry(ctx context.Context, args ...interface{})
 	if ok && isWaitingForOrder.(bool) {
 		return false
 	}
-	if !sm.Strategy.GetModel().Conditions.EntrySpreadHunter {
+	model := sm.Strategy.GetModel()
+	if !model.Conditions.EntrySpreadHunter {
+		return false
+	}
+	if !sm.isEntryReleased() {
 		return false
 	}
 	currentSpread := args[0].(interfaces.SpreadData)
-	if sm.checkSpreadCondition(currentSpread) {
-		sm.Strategy.GetLogger().Info("place waitForEntry",
-			zap.Float64("best bid", currentSpread.BestBid),
-			zap.Float64("close", currentSpread.Close),
-			zap.Float64("amount", sm.Strategy.GetModel().Conditions.EntryOrder.Amount),
-		)
-		//TODO: do we want to use bestbid in this strategy?
-		sm.PlaceOrder(currentSpread.BestBid, 0.0, WaitForEntry)
+	price, ok := sm.spreadPrice(currentSpread, model.Conditions.EntryOrder.Side)
+	if !ok {
+		return false
+	}
+	sm.Strategy.GetLogger().Info("place waitForEntry",
+		zap.Float64("best bid", currentSpread.BestBid),
+		zap.Float64("best ask", currentSpread.BestAsk),
+		zap.Float64("price", price),
+		zap.Float64("amount", model.Conditions.EntryOrder.Amount),
+	)
+	placed := len(model.State.WaitForEntryIds)
+	sm.PlaceOrder(price, 0.0, WaitForEntry)
+	if model.Conditions.EntryWaitingTime > 0 && len(model.State.WaitForEntryIds) > placed {
+		go sm.repriceSpreadOrder(WaitForEntry, WaitForEntry, model.State.WaitForEntryIds[placed], model.Conditions.EntryWaitingTime)
 	}
 	return false
 }
@@ -41,14 +83,108 @@ func (sm *SmartOrder) checkSpreadTakeProfit(ctx context.Context, args ...interfa
 	if ok && isWaitingForOrder.(bool) {
 		return false
 	}
-	if !sm.Strategy.GetModel().Conditions.TakeProfitSpreadHunter {
+	model := sm.Strategy.GetModel()
+	if !model.Conditions.TakeProfitSpreadHunter {
 		return false
 	}
 	currentSpread := args[0].(interfaces.SpreadData)
-
-	if sm.checkSpreadCondition(currentSpread) {
-		sm.PlaceOrder(currentSpread.BestBid, 0.0, TakeProfit)
+	side := "sell"
+	if model.Conditions.EntryOrder.Side == "sell" {
+		side = "buy"
+	}
+	price, ok := sm.spreadPrice(currentSpread, side)
+	if !ok || !sm.isSpreadTargetReached(model, price) {
+		return false
+	}
+	sm.Strategy.GetLogger().Info("place take-profit in spread",
+		zap.Float64("best bid", currentSpread.BestBid),
+		zap.Float64("best ask", currentSpread.BestAsk),
+		zap.Float64("price", price),
+	)
+	placed := len(model.State.TakeProfitOrderIds)
+	sm.PlaceOrder(price, 0.0, TakeProfit)
+	if model.Conditions.TakeProfitWaitingTime > 0 && len(model.State.TakeProfitOrderIds) > placed {
+		go sm.repriceSpreadOrder(InEntry, TakeProfit, model.State.TakeProfitOrderIds[placed], model.Conditions.TakeProfitWaitingTime)
 	}
 
 	return false
 }
+
+// isSpreadTargetReached tells if the price in spread is not worse than the first exit target.
+func (sm *SmartOrder) isSpreadTargetReached(model *models.MongoStrategy, price float64) bool {
+	if len(model.Conditions.ExitLevels) == 0 {
+		return false
+	}
+	target := model.Conditions.ExitLevels[0]
+	targetPrice := target.Price
+	if target.Type == 1 {
+		leverage := model.Conditions.Leverage
+		if model.Conditions.MarketType == 0 || leverage == 0 {
+			leverage = 1
+		}
+		if model.Conditions.EntryOrder.Side == "buy" {
+			targetPrice = model.State.EntryPrice * (1 + sm.atrAdjusted(target.Price)/100/leverage)
+		} else {
+			targetPrice = model.State.EntryPrice * (1 - sm.atrAdjusted(target.Price)/100/leverage)
+		}
+	}
+	if model.Conditions.EntryOrder.Side == "buy" {
+		return price >= targetPrice
+	}
+	return price <= targetPrice
+}
+
+// repriceSpreadOrder cancels the order placed in spread if it is still open after waiting time, so it can be placed
+// again at the current spread.
+func (sm *SmartOrder) repriceSpreadOrder(state string, step string, orderId string, waitingTime int64) {
+	time.Sleep(time.Duration(waitingTime) * time.Millisecond)
+	if currentState, _ := sm.State.State(context.Background()); currentState != state {
+		return
+	}
+	sm.OrdersMux.Lock()
+	_, isOpen := sm.OrdersMap[orderId]
+	sm.OrdersMux.Unlock()
+	if !isOpen {
+		return
+	}
+	model := sm.Strategy.GetModel()
+	res := sm.cancelOrder(orders.CancelOrderRequest{
+		KeyId: sm.KeyId,
+		KeyParams: orders.CancelOrderRequestParams{
+			OrderId:    orderId,
+			MarketType: model.Conditions.MarketType,
+			Pair:       model.Conditions.Pair,
+		},
+	})
+	if res.Status != "OK" {
+		sm.Strategy.GetLogger().Info("spread order already executed", zap.String("orderId", orderId))
+		return
+	}
+	sm.Strategy.GetLogger().Info("spread order not filled in time, repricing",
+		zap.String("orderId", orderId),
+		zap.String("step", step),
+	)
+	if step == TakeProfit {
+		takeProfitOrderIds := make([]string, 0, len(model.State.TakeProfitOrderIds))
+		for _, id := range model.State.TakeProfitOrderIds {
+			if id != orderId {
+				takeProfitOrderIds = append(takeProfitOrderIds, id)
+			}
+		}
+		model.State.TakeProfitOrderIds = takeProfitOrderIds
+	}
+	sm.IsWaitingForOrder.Store(step, false)
+	sm.Statsd.Inc("smart_order.spread_order_repriced")
+}
+
+// onSpreadTakeProfitCanceled accounts the part of take-profit filled before it was canceled to reprice.
+func (sm *SmartOrder) onSpreadTakeProfitCanceled(order models.MongoOrder) {
+	model := sm.Strategy.GetModel()
+	if !model.Conditions.TakeProfitSpreadHunter || order.Filled <= 0 {
+		return
+	}
+	model.State.ExecutedAmount += order.Filled
+	model.State.ExitPrice = order.Average
+	sm.calculateAndSavePNL(model, TakeProfit, order.Filled)
+	sm.StateMgmt.UpdateExecutedAmount(model.ID, model.State)
+}
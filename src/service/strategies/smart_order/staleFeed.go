@@ -0,0 +1,23 @@
+package smart_order
+
+import (
+	"go.uber.org/zap"
+)
+
+// isFeedStale tells whether the price feed of the pair is stale, price triggered transitions must not happen then
+// since e.g. a market stop-out at a price an hour old can't be undone.
+func (sm *SmartOrder) isFeedStale() bool {
+	model := sm.Strategy.GetModel()
+	isStale := sm.DataFeed.IsStale(model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType)
+	if isStale && !sm.IsFeedStale {
+		sm.Strategy.GetLogger().Warn("price feed stale, holding price triggers",
+			zap.String("pair", model.Conditions.Pair),
+			zap.String("exchange", sm.ExchangeName),
+		)
+		sm.Statsd.Inc("smart_order.feed_stale")
+	} else if !isStale && sm.IsFeedStale {
+		sm.Strategy.GetLogger().Info("price feed fresh again")
+	}
+	sm.IsFeedStale = isStale
+	return isStale
+}
@@ -29,7 +29,7 @@ func (sm *SmartOrder) checkTrailingEntry(ctx context.Context, args ...interface{
 		activateTrailing = true
 	}
 	// log.Print(currentOHLCV.Close, edgePrice, currentOHLCV.Close/edgePrice-1)
-	deviation := sm.Strategy.GetModel().Conditions.EntryOrder.EntryDeviation / sm.Strategy.GetModel().Conditions.Leverage
+	deviation := sm.atrAdjusted(sm.Strategy.GetModel().Conditions.EntryOrder.EntryDeviation) / sm.Strategy.GetModel().Conditions.Leverage
 	side := sm.Strategy.GetModel().Conditions.EntryOrder.Side
 	isSpotMarketEntry := sm.Strategy.GetModel().Conditions.MarketType == 0 && sm.Strategy.GetModel().Conditions.EntryOrder.OrderType == "market"
 	switch side {
@@ -73,8 +73,8 @@ func (sm *SmartOrder) checkTrailingProfit(ctx context.Context, args ...interface
 			isTrailingTarget := target.ActivatePrice != 0
 			if isTrailingTarget {
 				isActivated := i < len(model.State.TrailingExitPrices)
-				deviation := target.EntryDeviation / 100 / model.Conditions.Leverage
-				activateDeviation := target.ActivatePrice / 100 / model.Conditions.Leverage
+				deviation := sm.atrAdjusted(target.EntryDeviation) / 100 / model.Conditions.Leverage
+				activateDeviation := sm.atrAdjusted(target.ActivatePrice) / 100 / model.Conditions.Leverage
 
 				activatePrice := target.ActivatePrice
 				if target.Type == 1 {
@@ -120,8 +120,8 @@ func (sm *SmartOrder) checkTrailingProfit(ctx context.Context, args ...interface
 			isTrailingTarget := target.ActivatePrice != 0
 			if isTrailingTarget {
 				isActivated := i < len(sm.Strategy.GetModel().State.TrailingExitPrices)
-				deviation := target.EntryDeviation / 100 / sm.Strategy.GetModel().Conditions.Leverage
-				activateDeviation := target.ActivatePrice / 100 / sm.Strategy.GetModel().Conditions.Leverage
+				deviation := sm.atrAdjusted(target.EntryDeviation) / 100 / sm.Strategy.GetModel().Conditions.Leverage
+				activateDeviation := sm.atrAdjusted(target.ActivatePrice) / 100 / sm.Strategy.GetModel().Conditions.Leverage
 
 				activatePrice := target.ActivatePrice
 				if target.Type == 1 {
@@ -0,0 +1,70 @@
+package smart_order
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
+	"go.uber.org/zap"
+	"time"
+)
+
+// triggerPrice returns the price triggers compare against, the last trade price given unless futures trigger by mark
+// price. False means the mark price is missing or stale and triggers must be held, falling back to the last
+// price would bring back wicks the exchange side stop orders never trigger on.
+func (sm *SmartOrder) triggerPrice(last interfaces.OHLCV) (interfaces.OHLCV, bool) {
+	model := sm.Strategy.GetModel()
+	source := model.Conditions.TriggerPriceSource
+	if model.Conditions.MarketType != 1 || source == "" || source == models.TriggerPriceLast {
+		return last, true
+	}
+	markPrice := sm.DataFeed.GetMarkPrice(model.Conditions.Pair, sm.ExchangeName, model.Conditions.MarketType)
+	isMissing := markPrice == nil || staleness.IsStale(sm.ExchangeName, markPrice.UpdatedAt, time.Now())
+	if isMissing && !sm.IsTriggerPriceMissing {
+		sm.Strategy.GetLogger().Warn("mark price missing or stale, holding price triggers",
+			zap.String("pair", model.Conditions.Pair),
+			zap.String("exchange", sm.ExchangeName),
+			zap.String("triggerPriceSource", source),
+		)
+		sm.Statsd.Inc("smart_order.mark_price_missing")
+	} else if !isMissing && sm.IsTriggerPriceMissing {
+		sm.Strategy.GetLogger().Info("mark price fresh again")
+	}
+	sm.IsTriggerPriceMissing = isMissing
+	if isMissing {
+		return last, false
+	}
+	price := markPrice.Mark
+	return interfaces.OHLCV{
+		Open:      price,
+		High:      price,
+		Low:       price,
+		Close:     price,
+		Volume:    last.Volume,
+		UpdatedAt: markPrice.UpdatedAt,
+	}, true
+}
+
+// stopWorkingType returns the price futures stop orders trigger by on the exchange.
+func stopWorkingType(conditions *models.MongoStrategyCondition) string {
+	if conditions.TriggerPriceSource == models.TriggerPriceMark {
+		return "MARK_PRICE"
+	}
+	return "CONTRACT_PRICE"
+}
+
+// isTriggerPriceRejected puts futures smart orders triggering by index price into the error state. Their exchange stop
+// orders would work by mark price as Binance has no index working type, while the service triggers by index.
+func (sm *SmartOrder) isTriggerPriceRejected() bool {
+	model := sm.Strategy.GetModel()
+	if model.Conditions.MarketType != 1 || model.Conditions.TriggerPriceSource != models.TriggerPriceIndex {
+		return false
+	}
+	sm.Strategy.GetLogger().Error("index trigger price is not supported by exchange stop orders, disabling")
+	model.Enabled = false
+	model.State.State = Error
+	model.State.Msg = "index trigger price is not supported by exchange stop orders, use mark"
+	sm.Statsd.Inc("smart_order.error_state")
+	sm.StateMgmt.UpdateState(model.ID, model.State)
+	sm.StateMgmt.DisableStrategy(model.ID)
+	return true
+}
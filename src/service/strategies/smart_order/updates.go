@@ -0,0 +1,33 @@
+package smart_order
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"time"
+)
+
+const (
+	// checkInterval is how often time based checks run while the market has no updates.
+	checkInterval = 1 * time.Second
+	// pollInterval is how often the price is taken from feeds not pushing updates.
+	pollInterval = 60 * time.Millisecond
+)
+
+// waitForUpdate blocks until the next update of the market or for the check interval if the market is quiet, the
+// poll interval if the feed doesn't push updates. Returns nil updates once they are closed, the feed is polled then.
+func waitForUpdate(updates <-chan interfaces.Update) <-chan interfaces.Update {
+	interval := checkInterval
+	if updates == nil {
+		interval = pollInterval
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case _, ok := <-updates:
+		if !ok {
+			return nil
+		}
+		return updates
+	case <-timer.C:
+		return updates
+	}
+}
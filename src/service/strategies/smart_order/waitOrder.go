@@ -3,7 +3,9 @@ package smart_order
 import (
 	"context"
 	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
 	"go.uber.org/zap"
 	"strconv"
 )
@@ -22,6 +24,15 @@ func (sm *SmartOrder) orderCallback(order *models.MongoOrder) {
 	}
 	//currentState, _ := sm.State.State(context.Background())
 	//model := sm.Strategy.GetModel()
+	if order.Status == "open" && order.Filled > 0 {
+		sm.OrdersMux.Lock()
+		_, ok := sm.OrdersMap[order.OrderId]
+		sm.OrdersMux.Unlock()
+		if ok {
+			sm.onPartialFill(order)
+		}
+		return
+	}
 	if !(order.Status == "filled" || order.Status == "canceled") {
 		return
 	}
@@ -113,6 +124,15 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 			sm.Strategy.GetLogger().Info("model.State.EntryPrice in waitForEntry",
 				zap.Float64("model.State.EntryPrice", model.State.EntryPrice),
 			)
+			if sm.isSlicedEntry() {
+				return sm.onEntrySliceFilled(order.Average, order.Filled)
+			}
+			if model.State.State == PartiallyEntry {
+				sm.takePartialFill(order)
+				model.State.State = InEntry
+				sm.StateMgmt.UpdateEntryPrice(model.ID, model.State)
+				return true
+			}
 			if model.State.EntryPrice > 0 && !isMultiEntry {
 				return false
 			}
@@ -156,7 +176,7 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 			return true
 		case TakeProfit:
 			sm.IsWaitingForOrder.Store(TakeProfit, false)
-			amount := model.Conditions.EntryOrder.Amount
+			amount := sm.getPositionTargetAmount()
 
 			model.State.ExitPrice = order.Average
 			if order.Filled > 0 {
@@ -202,7 +222,7 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 				model.State.ExecutedAmount += order.Filled
 			}
 			model.State.ExitPrice = order.Average
-			amount := model.Conditions.EntryOrder.Amount
+			amount := sm.getPositionTargetAmount()
 			if model.Conditions.MarketType == 0 {
 				amount = amount - sm.Strategy.GetModel().State.Commission
 				amount = sm.toFixed(amount, sm.QuantityAmountPrecision, Floor)
@@ -225,7 +245,7 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 				model.State.ExecutedAmount += order.Filled
 			}
 			model.State.ExitPrice = order.Average
-			amount := model.Conditions.EntryOrder.Amount
+			amount := sm.getPositionTargetAmount()
 			if model.Conditions.MarketType == 0 {
 				amount = amount - sm.Strategy.GetModel().State.Commission
 			}
@@ -242,7 +262,7 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 				model.State.ExecutedAmount += order.Filled
 			}
 			model.State.ExitPrice = order.Average
-			amount := model.Conditions.EntryOrder.Amount
+			amount := sm.getPositionTargetAmount()
 			if model.Conditions.MarketType == 0 {
 				amount = amount - sm.Strategy.GetModel().State.Commission
 			}
@@ -260,7 +280,7 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 				model.State.ExecutedAmount += order.Filled
 			}
 			model.State.ExitPrice = order.Average
-			amount := model.Conditions.EntryOrder.Amount
+			amount := sm.getPositionTargetAmount()
 			if model.Conditions.MarketType == 0 {
 				amount = amount - sm.Strategy.GetModel().State.Commission
 			}
@@ -285,6 +305,16 @@ func (sm *SmartOrder) checkExistingOrders(ctx context.Context, args ...interface
 		//		return true
 		//	}
 		//	break
+	case "canceled":
+		switch step {
+		case WaitForEntry:
+			if sm.isSlicedEntry() {
+				return sm.onEntrySliceCanceled(order)
+			}
+			sm.onEntryCanceledFilled(order) // it may fill more after the last partial fill seen
+		case TakeProfit:
+			sm.onSpreadTakeProfitCanceled(order) // repriced take-profit may be filled in part
+		}
 	}
 	return false
 }
@@ -342,6 +372,9 @@ func (sm *SmartOrder) calculateAndSavePNL(model *models.MongoStrategy, step inte
 	if model.Conditions.CreatedByTemplate {
 		go sm.Strategy.GetStateMgmt().SavePNL(model.Conditions.TemplateStrategyId, profitAmount)
 	}
+	if model.Conditions.CopyLeaderId != nil {
+		sm.reportCopySlippage(entryPrice)
+	}
 
 	// if we got profit from target from averaging
 	if (step == TakeProfit || step == "WithoutLoss") && isMultiEntry {
@@ -354,3 +387,11 @@ func (sm *SmartOrder) calculateAndSavePNL(model *models.MongoStrategy, step inte
 
 	return profitAmount
 }
+
+// cancelOrder cancels the order by the execution algorithm executing it or on exchange.
+func (sm *SmartOrder) cancelOrder(request orders.CancelOrderRequest) orders.OrderResponse {
+	if algorithm, ok := sm.AlgorithmByOrderId.Load(request.KeyParams.OrderId); ok {
+		return algorithm.(interfaces.IExecutionAlgorithm).Cancel(request)
+	}
+	return sm.ExchangeApi.CancelOrder(request)
+}
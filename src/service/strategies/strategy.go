@@ -110,6 +110,20 @@ func (strategy *Strategy) Start() {
 			zap.Int64("type", strategy.Model.Type),
 		)
 		strategy.StrategyRuntime = RunMakerOnlyOrder(strategy, strategy.Datafeed, strategy.Trading, strategy.Model.AccountId)
+	case 3:
+		strategy.Log.Info("running grid",
+			zap.String("id", strategy.ID()),
+			zap.Int64("type", strategy.Model.Type),
+		)
+		strategy.StrategyRuntime = RunGrid(strategy, strategy.Datafeed, strategy.Trading, strategy.Statsd, strategy.Model.AccountId)
+		strategy.Statsd.Inc("grid.runtime_start")
+	case 4:
+		strategy.Log.Info("running pair trading",
+			zap.String("id", strategy.ID()),
+			zap.Int64("type", strategy.Model.Type),
+		)
+		strategy.StrategyRuntime = RunPair(strategy, strategy.Datafeed, strategy.Trading, strategy.Statsd, strategy.Model.AccountId)
+		strategy.Statsd.Inc("pair.runtime_start")
 	default:
 		strategy.Log.Warn("strategy type not supported",
 			zap.String("id", strategy.ID()),
@@ -123,12 +137,16 @@ func (strategy *Strategy) HotReload(mongoStrategy models.MongoStrategy) {
 	strategy.Log.Info("hot reloading",
 		zap.String("id", strategy.ID()),
 	)
+	previous := *strategy.Model
 	strategy.Model.Enabled = mongoStrategy.Enabled
 	strategy.Model.Conditions = mongoStrategy.Conditions
+	strategy.Model.SignalIds = mongoStrategy.SignalIds
 	if mongoStrategy.Enabled == false {
 		if strategy.StrategyRuntime != nil {
 			strategy.StrategyRuntime.Stop() // stop runtime if disabled by DB, externally
 		}
+	} else if runtime, ok := strategy.StrategyRuntime.(interfaces.IReloadableRuntime); ok {
+		runtime.HotReload(previous)
 	}
 	strategy.Statsd.Inc("strategy.hot_reload")
 }
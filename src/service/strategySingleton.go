@@ -5,14 +5,18 @@ import (
 	"fmt"
 	"github.com/go-redsync/redsync/v4"
 	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/execution"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/signals"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/makeronly_order"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/templates"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources/redis"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/supervisor"
 	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
 	"log"
 
@@ -27,6 +31,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
 	"os"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -40,11 +45,19 @@ type StrategyService struct {
 	dataFeed   interfaces.IDataFeed
 	dataFeedSerum   interfaces.IDataFeed
 	stateMgmt  interfaces.IStateMgmt
+	signals    map[string]*signals.Signal
+	signalsMux sync.Mutex
+	templates    map[string]*templates.Template
+	templatesMux sync.Mutex
+	orderBooks    map[string]*models.MongoStrategyCondition // conditions books were kept by, by strategy id
+	orderBooksMux sync.Mutex
 	statsd     statsd_client.StatsdClient
 	log        interfaces.ILogger
 	full       bool // indicates whether an instance full or can take more strategies
 	ramFull    bool // indicates close to RAM limit
 	cpuFull    bool // indicates out of CPU usage limit
+	activeStaleFeeds    []string // stale feeds active strategies run on, readiness depends on them only
+	activeStaleFeedsMux sync.Mutex
 }
 
 var singleton *StrategyService
@@ -62,16 +75,20 @@ func GetStrategyService() *StrategyService {
 		tr := trading.InitTrading()
 		statsd := statsd_client.StatsdClient{}
 		statsd.Init()
-		sm := mongodb.StateMgmt{Statsd: &statsd}
+		sm := mongodb.StateMgmt{Statsd: &statsd, SignalCallbacks: &sync.Map{}}
 		singleton = &StrategyService{
 			pairs:      map[int8]map[string]struct{}{0: map[string]struct{}{}, 1: map[string]struct{}{}},
 			strategies: map[string]*strategies.Strategy{},
+			signals:    map[string]*signals.Signal{},
+			templates:  map[string]*templates.Template{},
+			orderBooks: map[string]*models.MongoStrategyCondition{},
 			dataFeed:   df,
 			trading:    tr,
 			stateMgmt:  &sm,
 			statsd:     statsd,
 			log:        logger,
 		}
+		execution.Register(execution.MakerOnly, &makerOnlyAlgorithm{ss: singleton})
 		logger.Info("strategy service instantiated")
 		statsd.Inc("strategy_service.instantiated")
 	})
@@ -167,6 +184,7 @@ func (ss *StrategyService) Init(wg *sync.WaitGroup, isLocalBuild bool) {
 			zap.String("ObjectID", strategy.Model.ID.String()),
 		)
 		GetStrategyService().strategies[strategy.Model.ID.String()] = strategy
+		ss.keepOrderBook(strategy.Model)
 		go strategy.Start()
 		strategiesAdded++
 	}
@@ -176,9 +194,13 @@ func (ss *StrategyService) Init(wg *sync.WaitGroup, isLocalBuild bool) {
 
 	go ss.InitPositionsWatch()                     // subscribe to position updates
 	go ss.stateMgmt.InitOrdersWatch()              // subscribe to order updates
+	go ss.stateMgmt.InitSignalsWatch()             // subscribe to fired signals to release strategies entries
+	go ss.InitSignals()                            // evaluate enabled signals and watch for new ones
+	go ss.InitTemplates()                          // spawn children by enabled templates and watch for new ones
 	go ss.WatchStrategies(isLocalBuild, accountId) // subscribe to new smart trades to add them into runtime
 	go ss.runReporting()
 	go ss.runIsFullTracking()
+	go ss.runFeedTracking()
 
 	if err := cur.Err(); err != nil { // TODO(khassanov): can we retry here?
 		wg.Done()
@@ -216,6 +238,44 @@ func GetStrategy(strategy *models.MongoStrategy, df interfaces.IDataFeed, tr int
 	}
 }
 
+// keepOrderBook pins the book of the strategy pair in the feed, books are kept for pairs with active strategies.
+// Feeds not pinning books are just asked for it.
+func (ss *StrategyService) keepOrderBook(strategy *models.MongoStrategy) {
+	conditions := strategy.Conditions
+	if conditions == nil {
+		return
+	}
+	keeper, ok := ss.dataFeed.(interfaces.IOrderBookKeeper)
+	if !ok {
+		ss.dataFeed.GetOrderBook(conditions.Pair, conditions.Exchange, conditions.MarketType, 1)
+		return
+	}
+	ss.orderBooksMux.Lock()
+	defer ss.orderBooksMux.Unlock()
+	if _, ok := ss.orderBooks[strategy.ID.String()]; ok {
+		return
+	}
+	ss.orderBooks[strategy.ID.String()] = conditions
+	keeper.KeepOrderBook(conditions.Pair, conditions.Exchange, conditions.MarketType)
+}
+
+// releaseOrderBook unpins the book kept for the strategy stopped, conditions it was kept by are taken as reloaded
+// ones may differ.
+func (ss *StrategyService) releaseOrderBook(strategy *models.MongoStrategy) {
+	keeper, ok := ss.dataFeed.(interfaces.IOrderBookKeeper)
+	if !ok {
+		return
+	}
+	ss.orderBooksMux.Lock()
+	defer ss.orderBooksMux.Unlock()
+	conditions, ok := ss.orderBooks[strategy.ID.String()]
+	if !ok {
+		return
+	}
+	delete(ss.orderBooks, strategy.ID.String())
+	keeper.ReleaseOrderBook(conditions.Pair, conditions.Exchange, conditions.MarketType)
+}
+
 // AddStrategy instantiates given strategy to store in the service instance and start it.
 func (ss *StrategyService) AddStrategy(strategy *models.MongoStrategy) {
 	if ss.strategies[strategy.ID.String()] == nil {
@@ -227,6 +287,7 @@ func (ss *StrategyService) AddStrategy(strategy *models.MongoStrategy) {
 			zap.String("ObjectID", sig.Model.ID.Hex()),
 		)
 		ss.strategies[sig.Model.ID.String()] = sig
+		ss.keepOrderBook(sig.Model)
 		go sig.Start()
 		ss.statsd.Inc("strategy_service.add_strategy")
 		ss.statsd.Gauge("strategy_service.active_strategies", int64(len(ss.strategies)))
@@ -274,6 +335,10 @@ func (ss *StrategyService) CreateOrder(request orders.CreateOrderRequest) orders
 			HedgeKeyId:             nil,
 			HedgeStrategyId:        nil,
 			MakerOrderId:           &id,
+			MakerMaxDistance:       request.KeyParams.Params.MakerMaxDistance,
+			MakerMaxChaseTime:      request.KeyParams.Params.MakerMaxChaseTime,
+			MakerRepriceInterval:   request.KeyParams.Params.MakerRepriceInterval,
+			MakerFallback:          request.KeyParams.Params.MakerFallback,
 			TemplateToken:          "",
 			MandatoryForcedLoss:    false,
 			PositionWasClosed:      false,
@@ -548,6 +613,7 @@ func (ss *StrategyService) WatchStrategies(isLocalBuild bool, accountId string)
 			ss.strategies[event.FullDocument.ID.String()].HotReload(event.FullDocument)
 			ss.EditConditions(ss.strategies[event.FullDocument.ID.String()])
 			if event.FullDocument.Enabled == false {
+				ss.releaseOrderBook(&event.FullDocument)
 				delete(ss.strategies, event.FullDocument.ID.String())
 			}
 		} else { // brand new smart trade
@@ -850,6 +916,12 @@ func (ss *StrategyService) setPairs(ctx context.Context, collection *mongo.Colle
 	)
 }
 
+// Load average per core the instance gets full above and takes strategies again below.
+const (
+	cpuFullLoad = 12.0
+	cpuFreeLoad = 10.0
+)
+
 // trackIsFull monitors resources continuously and sets or resets 'full' flag when instance is close to memory limit
 // or CPU usage limit.
 func (ss *StrategyService) runIsFullTracking() {
@@ -862,7 +934,8 @@ func (ss *StrategyService) runIsFullTracking() {
 	var isFullPrev bool
 	for {
 		isFullPrev = ss.full
-		// check CPU usage
+		// check CPU usage, the load has to go down below the lower threshold to take strategies again so the flag
+		// doesn't flap around a single one
 		loadAvg, err = cpu_load.Avg()
 		if err != nil {
 			ss.log.Error("load avg read", zap.Error(err))
@@ -871,11 +944,13 @@ func (ss *StrategyService) runIsFullTracking() {
 		if err != nil {
 			ss.log.Error("cpu count", zap.Error(err))
 		}
-		loadAvgScaled = loadAvg.Load5 / float64(cpuCoresCount)
-		if loadAvgScaled > 12.0 { // TODO(khassanov): remove magic number
-			ss.cpuFull = true
-		} else {
-			ss.cpuFull = false
+		if loadAvg != nil && cpuCoresCount > 0 {
+			loadAvgScaled = loadAvg.Load5 / float64(cpuCoresCount)
+			if loadAvgScaled > cpuFullLoad {
+				ss.cpuFull = true
+			} else if loadAvgScaled < cpuFreeLoad {
+				ss.cpuFull = false
+			}
 		}
 		// check for free RAM
 		err = syscall.Sysinfo(&sysinfo)
@@ -901,9 +976,86 @@ func (ss *StrategyService) runIsFullTracking() {
 		ss.log.Debug("resources check",
 			zap.Uint64("free RAM, bytes", sysinfo.Freeram),
 			zap.Float64("load avg 5 scaled", loadAvgScaled),
-			zap.Float64("load avg 5", loadAvg.Load5),
 			zap.Int("cpu count", cpuCoresCount),
 		)
 		time.Sleep(1 * time.Second)
 	}
 }
+
+// StaleFeeds returns market data feeds not updated for longer than the max age of their exchange.
+func (ss *StrategyService) StaleFeeds() []string {
+	if feedHealth, ok := ss.dataFeed.(interfaces.IFeedHealth); ok {
+		return feedHealth.StaleFeeds()
+	}
+	return nil
+}
+
+// ActiveStaleFeeds returns stale market data feeds active strategies run on, as of the last feed tracking.
+func (ss *StrategyService) ActiveStaleFeeds() []string {
+	ss.activeStaleFeedsMux.Lock()
+	defer ss.activeStaleFeedsMux.Unlock()
+	return ss.activeStaleFeeds
+}
+
+// filterActiveFeeds returns feeds of exchanges and market types active strategies run on out of feeds given, named
+// as <exchange>.<feed>.<market type>.
+func (ss *StrategyService) filterActiveFeeds(feeds []string) []string {
+	markets := map[string]struct{}{}
+	for _, strategy := range ss.strategies {
+		if strategy.Model == nil || strategy.Model.Conditions == nil || !strategy.Model.Enabled {
+			continue
+		}
+		exchange := strategy.Model.Conditions.Exchange
+		if exchange == "" {
+			exchange = "binance"
+		}
+		markets[fmt.Sprintf("%v.%v", exchange, strategy.Model.Conditions.MarketType)] = struct{}{}
+	}
+	var active []string
+	for _, feed := range feeds {
+		parts := strings.Split(feed, ".")
+		if _, ok := markets[parts[0]+"."+parts[len(parts)-1]]; ok {
+			active = append(active, feed)
+		}
+	}
+	return active
+}
+
+// runFeedTracking reports stale market data feeds, smart orders hold price triggers on them, states of feed stream
+// connections and counts of feed messages not decoded. Stale feeds active strategies run on make the service
+// not ready, the rest are reported by metrics only.
+func (ss *StrategyService) runFeedTracking() {
+	ss.log.Info("starting feed staleness tracking")
+	var stalePrev int
+	for {
+		if connectionHealth, ok := ss.dataFeed.(interfaces.IConnectionHealth); ok {
+			for name, state := range connectionHealth.ConnectionStates() {
+				connected := int64(0)
+				if state == supervisor.Connected {
+					connected = 1
+				}
+				ss.statsd.Gauge(fmt.Sprintf("strategy_service.feed_connections.%v.connected", name), connected)
+			}
+		}
+		if feedErrors, ok := ss.dataFeed.(interfaces.IFeedErrors); ok {
+			for name, count := range feedErrors.FeedErrors() {
+				ss.statsd.Gauge(fmt.Sprintf("strategy_service.feed_errors.%v", name), count)
+			}
+		}
+		stale := ss.StaleFeeds()
+		ss.statsd.Gauge("strategy_service.stale_feeds", int64(len(stale)))
+		for _, feed := range stale {
+			ss.statsd.Inc(fmt.Sprintf("strategy_service.stale_feed.%v", feed))
+		}
+		if len(stale) != stalePrev {
+			ss.log.Warn("stale feeds changed", zap.Strings("feeds", stale))
+		}
+		stalePrev = len(stale)
+		activeStale := ss.filterActiveFeeds(stale)
+		ss.statsd.Gauge("strategy_service.active_stale_feeds", int64(len(activeStale)))
+		ss.activeStaleFeedsMux.Lock()
+		ss.activeStaleFeeds = activeStale
+		ss.activeStaleFeedsMux.Unlock()
+		time.Sleep(5 * time.Second)
+	}
+}
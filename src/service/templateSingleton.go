@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/templates"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"time"
+)
+
+// GetTemplate creates template runtime instance for the model given.
+func (ss *StrategyService) GetTemplate(model *models.MongoStrategyTemplate) *templates.Template {
+	logger, _ := logging.GetZapLogger()
+	logger = logger.With(zap.String("logger", fmt.Sprintf("tpl-%v", model.Id.Hex())))
+	template := templates.New(model, ss.dataFeed, ss.stateMgmt, &ss.statsd, logger)
+	template.SettlementMutex = redis.GetRedsync().NewMutex(fmt.Sprintf("template:%v", model.Id.Hex()),
+		redsync.WithTries(2),
+		redsync.WithRetryDelay(1*time.Second),
+		redsync.WithExpiry(10*time.Second),
+	)
+	return template
+}
+
+// AddTemplate instantiates given template to store in the service instance and start spawning children by it.
+// Children are created in storage, so they get settled by instances processing their pairs.
+func (ss *StrategyService) AddTemplate(model *models.MongoStrategyTemplate) {
+	ss.templatesMux.Lock()
+	defer ss.templatesMux.Unlock()
+	if ss.templates[model.Id.Hex()] != nil {
+		return
+	}
+	template := ss.GetTemplate(model)
+	if ok, err := template.Settle(); !ok || err != nil {
+		return
+	}
+	ss.log.Info("adding template", zap.String("id", model.Id.Hex()))
+	ss.templates[model.Id.Hex()] = template
+	go func() {
+		template.Start()
+		ss.templatesMux.Lock()
+		delete(ss.templates, model.Id.Hex())
+		ss.templatesMux.Unlock()
+	}()
+	ss.statsd.Inc("strategy_service.add_template")
+}
+
+// InitTemplates loads enabled templates from persistent storage and watches for new ones to run.
+func (ss *StrategyService) InitTemplates() {
+	ctx := context.Background()
+	coll := mongodb.GetCollection("core_strategy_templates")
+	cur, err := coll.Find(ctx, bson.D{{"enabled", true}})
+	if err != nil {
+		ss.log.Error("can't read templates", zap.Error(err))
+		return
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var model models.MongoStrategyTemplate
+		if err := cur.Decode(&model); err != nil {
+			ss.log.Error("template decode", zap.Error(err))
+			continue
+		}
+		ss.AddTemplate(&model)
+	}
+	ss.WatchTemplates()
+}
+
+// WatchTemplates subscribes to templates to add new templates to runtime or update local data together with persistent storage updates.
+func (ss *StrategyService) WatchTemplates() {
+	ss.log.Info("watching for new templates in the storage")
+	ctx := context.Background()
+	coll := mongodb.GetCollection("core_strategy_templates")
+	cs, err := coll.Watch(ctx, mongo.Pipeline{}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		ss.log.Error("can't watch for templates", zap.Error(err))
+		return
+	}
+	defer cs.Close(ctx)
+	for cs.Next(ctx) {
+		var event models.MongoStrategyTemplateUpdateEvent
+		if err := cs.Decode(&event); err != nil {
+			ss.log.Info("event decode error on processing template", zap.Error(err))
+			continue
+		}
+		ss.templatesMux.Lock()
+		template := ss.templates[event.FullDocument.Id.Hex()]
+		ss.templatesMux.Unlock()
+		if template != nil {
+			template.HotReload(event.FullDocument)
+		} else if event.FullDocument.Enabled {
+			ss.AddTemplate(&event.FullDocument)
+		}
+	}
+	ss.log.Fatal("new templates watch")
+}
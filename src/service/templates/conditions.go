@@ -0,0 +1,97 @@
+package templates
+
+import (
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+)
+
+var placeholder = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+// Instantiate fills placeholders of the conditions document with values given and decodes child conditions.
+// A string holding the only placeholder takes the value as is, so numbers stay numbers, otherwise the value is printed
+// into the string. An unknown placeholder is an error not to spawn a child with conditions half filled.
+func Instantiate(conditions map[string]interface{}, values map[string]interface{}) (*models.MongoStrategyCondition, error) {
+	filled, err := fill(conditions, values)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := bson.Marshal(filled)
+	if err != nil {
+		return nil, err
+	}
+	var childConditions models.MongoStrategyCondition
+	if err := bson.Unmarshal(raw, &childConditions); err != nil {
+		return nil, err
+	}
+	return &childConditions, nil
+}
+
+// fill walks the document recursively returning a copy with placeholders replaced.
+func fill(value interface{}, values map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return fillString(v, values)
+	case map[string]interface{}:
+		filled := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			filledItem, err := fill(item, values)
+			if err != nil {
+				return nil, err
+			}
+			filled[key] = filledItem
+		}
+		return filled, nil
+	case primitive.M:
+		return fill(map[string]interface{}(v), values)
+	case primitive.D:
+		filled := make(primitive.D, 0, len(v))
+		for _, item := range v {
+			filledItem, err := fill(item.Value, values)
+			if err != nil {
+				return nil, err
+			}
+			filled = append(filled, primitive.E{Key: item.Key, Value: filledItem})
+		}
+		return filled, nil
+	case []interface{}:
+		filled := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			filledItem, err := fill(item, values)
+			if err != nil {
+				return nil, err
+			}
+			filled = append(filled, filledItem)
+		}
+		return filled, nil
+	case primitive.A:
+		return fill([]interface{}(v), values)
+	}
+	return value, nil
+}
+
+func fillString(s string, values map[string]interface{}) (interface{}, error) {
+	if match := placeholder.FindStringSubmatch(s); match != nil && match[0] == s {
+		value, ok := values[match[1]]
+		if !ok {
+			return nil, fmt.Errorf("no value for placeholder %q", match[1])
+		}
+		return value, nil
+	}
+	var err error
+	filled := placeholder.ReplaceAllStringFunc(s, func(found string) string {
+		name := placeholder.FindStringSubmatch(found)[1]
+		value, ok := values[name]
+		if !ok {
+			err = fmt.Errorf("no value for placeholder %q", name)
+			return found
+		}
+		return fmt.Sprint(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filled, nil
+}
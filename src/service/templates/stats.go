@@ -0,0 +1,66 @@
+package templates
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"sort"
+)
+
+// Aggregate sums children results into the template state given. A child is active while enabled and ended once
+// disabled, ended children with profit or loss count to the win rate and to the drawdown of their pnl sum taken in
+// the order children were spawned.
+func Aggregate(state *models.MongoStrategyTemplateState, children []*models.MongoStrategy) {
+	ended := make([]*models.MongoStrategy, 0, len(children))
+	state.Active = 0
+	state.Pnl = 0
+	state.Wins = 0
+	state.Losses = 0
+	for _, child := range children {
+		var received float64
+		if child.State != nil {
+			received = child.State.ReceivedProfitAmount
+		}
+		state.Pnl += received
+		if child.Enabled {
+			state.Active++
+			continue
+		}
+		if received > 0 {
+			state.Wins++
+		} else if received < 0 {
+			state.Losses++
+		} else {
+			continue // canceled before entry or closed flat
+		}
+		ended = append(ended, child)
+	}
+	state.WinRate = 0
+	if state.Wins+state.Losses > 0 {
+		state.WinRate = float64(state.Wins) / float64(state.Wins+state.Losses) * 100
+	}
+
+	sort.SliceStable(ended, func(i, j int) bool {
+		return ended[i].ID.Timestamp().Before(ended[j].ID.Timestamp())
+	})
+	var sum, peak, drawdown float64
+	for _, child := range ended {
+		sum += child.State.ReceivedProfitAmount
+		if sum > peak {
+			peak = sum
+		}
+		if peak-sum > drawdown {
+			drawdown = peak - sum
+		}
+	}
+	state.MaxDrawdown = drawdown
+}
+
+// activeByPair counts enabled children on each pair.
+func activeByPair(children []*models.MongoStrategy) map[string]int64 {
+	active := map[string]int64{}
+	for _, child := range children {
+		if child.Enabled && child.Conditions != nil {
+			active[child.Conditions.Pair]++
+		}
+	}
+	return active
+}
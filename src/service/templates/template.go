@@ -0,0 +1,389 @@
+package templates
+
+import (
+	"fmt"
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	checkInterval    = 5 * time.Second
+	defaultExchange  = "binance"
+	smartOrderType   = 1
+	pairPlaceholder  = "pair"
+	pricePlaceholder = "price"
+)
+
+// A Template spawns child strategies by its rule within concurrency limits and aggregates their results.
+type Template struct {
+	Model           *models.MongoStrategyTemplate
+	SettlementMutex *redsync.Mutex
+	DataFeed        interfaces.IDataFeed
+	StateMgmt       interfaces.IStateMgmt
+	Statsd          interfaces.IStatsClient
+	Log             interfaces.ILogger
+	mux             sync.Mutex
+	children        []*models.MongoStrategy     // loaded on refresh and spawned since
+	spawning        map[string]int64            // children being created by pair, they count to limits
+	signals         map[primitive.ObjectID]bool // signals subscribed to, firing of the current one spawns only
+}
+
+// New instantiates a template runtime for the model given.
+func New(model *models.MongoStrategyTemplate, df interfaces.IDataFeed, sm interfaces.IStateMgmt, statsd interfaces.IStatsClient, logger interfaces.ILogger) *Template {
+	if model.State == nil {
+		model.State = &models.MongoStrategyTemplateState{}
+	}
+	return &Template{
+		Model:     model,
+		DataFeed:  df,
+		StateMgmt: sm,
+		Statsd:    statsd,
+		Log:       logger,
+		spawning:  map[string]int64{},
+		signals:   map[primitive.ObjectID]bool{},
+	}
+}
+
+// ID returns unique identifier the template holds.
+func (t *Template) ID() string {
+	return fmt.Sprintf("%q", t.Model.Id.Hex())
+}
+
+// Start spawns children by the template rule and aggregates their results until the template gets disabled.
+func (t *Template) Start() {
+	t.Statsd.Inc("template.start")
+	t.mux.Lock()
+	if t.Model.State.StartedAt == 0 {
+		t.Model.State.StartedAt = time.Now().Unix()
+	}
+	t.mux.Unlock()
+	t.subscribeToSignal()
+	for t.isEnabled() {
+		t.Refresh()
+		t.SpawnByRule(time.Now())
+		time.Sleep(checkInterval)
+	}
+	t.Refresh()
+	t.Log.Info("stopped template", zap.String("id", t.ID()))
+}
+
+// HotReload updates the template in runtime to keep consistency with persistent state, the state stays local.
+func (t *Template) HotReload(model models.MongoStrategyTemplate) {
+	t.mux.Lock()
+	t.Model.Enabled = model.Enabled
+	t.Model.StrategyType = model.StrategyType
+	t.Model.Conditions = model.Conditions
+	t.Model.Params = model.Params
+	t.Model.Spawn = model.Spawn
+	t.Model.MaxActive = model.MaxActive
+	t.Model.MaxActivePerPair = model.MaxActivePerPair
+	t.Model.MaxChildren = model.MaxChildren
+	t.mux.Unlock()
+	t.subscribeToSignal()
+}
+
+// subscribeToSignal subscribes to the signal of signal rule unless subscribed already.
+func (t *Template) subscribeToSignal() {
+	t.mux.Lock()
+	signalId := t.Model.Spawn.SignalId
+	isNew := t.Model.Spawn.Rule == models.TemplateSpawnBySignal && signalId != nil && !t.signals[*signalId]
+	if isNew {
+		t.signals[*signalId] = true
+	}
+	t.mux.Unlock()
+	if !isNew {
+		return
+	}
+	if err := t.StateMgmt.SubscribeToSignal(signalId, t.ID(), t.OnSignalFired); err != nil {
+		t.Log.Error("can't subscribe to signal",
+			zap.String("id", t.ID()),
+			zap.String("signalId", signalId.Hex()),
+			zap.Error(err),
+		)
+		t.mux.Lock()
+		delete(t.signals, *signalId)
+		t.mux.Unlock()
+	}
+}
+
+func (t *Template) isEnabled() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.Model.Enabled
+}
+
+// Refresh loads children to aggregate their results onto the template and saves the template state.
+func (t *Template) Refresh() {
+	children := t.StateMgmt.GetTemplateChildren(&t.Model.Id)
+	t.mux.Lock()
+	t.children = children
+	Aggregate(t.Model.State, t.children)
+	state := *t.Model.State
+	t.mux.Unlock()
+	t.StateMgmt.UpdateTemplateState(&t.Model.Id, &state)
+}
+
+// SpawnByRule spawns children due at the moment given for schedule and pairs rules, signal rule spawns on firing.
+func (t *Template) SpawnByRule(now time.Time) {
+	t.mux.Lock()
+	rule := t.Model.Spawn.Rule
+	isDue := true
+	if rule == models.TemplateSpawnBySchedule {
+		isDue = t.Model.Spawn.Interval > 0 && now.Unix() >= t.Model.State.LastSpawnAt+t.Model.Spawn.Interval
+		if isDue {
+			t.Model.State.LastSpawnAt = now.Unix() // the schedule goes on even if limits don't let spawn
+		}
+	}
+	pairs := t.pairs()
+	t.mux.Unlock()
+	if !isDue || rule != models.TemplateSpawnBySchedule && rule != models.TemplateSpawnByPairs {
+		return
+	}
+	for _, pair := range pairs {
+		t.Spawn(pair, 0)
+	}
+}
+
+// OnSignalFired spawns children for the firing if it happened after the template started and wasn't handled yet.
+func (t *Template) OnSignalFired(signal *models.MongoSignal) {
+	if signal.State == nil || signal.State.FiredAt == 0 {
+		return
+	}
+	t.mux.Lock()
+	isCurrent := t.Model.Spawn.Rule == models.TemplateSpawnBySignal && t.Model.Spawn.SignalId != nil &&
+		*t.Model.Spawn.SignalId == signal.Id
+	isNew := t.Model.Enabled && isCurrent && signal.State.FiredAt > t.Model.State.SignalFiredAt &&
+		signal.State.FiredAt >= t.Model.State.StartedAt
+	if isNew {
+		t.Model.State.SignalFiredAt = signal.State.FiredAt
+	}
+	pairs := t.pairs()
+	t.mux.Unlock()
+	if !isNew {
+		return
+	}
+	t.Log.Info("spawning on signal fired",
+		zap.String("id", t.ID()),
+		zap.String("signalId", signal.Id.Hex()),
+		zap.Int64("firedAt", signal.State.FiredAt),
+	)
+	if len(pairs) == 1 && pairs[0] == "" && signal.Condition.Pair != "" {
+		pairs = []string{signal.Condition.Pair}
+	}
+	for _, pair := range pairs {
+		t.Spawn(pair, signal.State.FiredPrice)
+	}
+}
+
+// pairs returns pairs to spawn on, empty pair stands for the one set in conditions.
+func (t *Template) pairs() []string {
+	if len(t.Model.Spawn.Pairs) == 0 {
+		return []string{""}
+	}
+	pairs := make([]string, len(t.Model.Spawn.Pairs))
+	copy(pairs, t.Model.Spawn.Pairs)
+	return pairs
+}
+
+// Spawn creates a child on the pair given if limits let, the price fills the price placeholder, current one if zero.
+// Returns nil if the child is not spawned. The child takes its place in limits while it's being created, so the price
+// and the strategy are looked up and saved without the template locked.
+func (t *Template) Spawn(pair string, price float64) *models.MongoStrategy {
+	t.mux.Lock()
+	if !t.Model.Enabled {
+		t.mux.Unlock()
+		return nil
+	}
+	if pair == "" {
+		conditionsPair, _ := t.Model.Conditions["pair"].(string)
+		if filled, err := fillString(conditionsPair, t.Model.Params); err == nil {
+			pair, _ = filled.(string)
+		}
+	}
+	if limit := t.limitReached(pair); limit != "" {
+		t.mux.Unlock()
+		t.Log.Info("template limit reached",
+			zap.String("id", t.ID()),
+			zap.String("pair", pair),
+			zap.String("limit", limit),
+		)
+		t.Statsd.Inc("template.spawn_limited")
+		return nil
+	}
+	t.spawning[pair]++
+	t.Model.State.Spawned++
+	templateConditions := t.Model.Conditions
+	values := make(map[string]interface{}, len(t.Model.Params)+2)
+	for name, value := range t.Model.Params {
+		values[name] = value
+	}
+	strategyType := t.Model.StrategyType
+	exchange, marketType := t.exchange(), t.marketType()
+	t.mux.Unlock()
+
+	child := t.newChild(pair, price, templateConditions, values, strategyType, exchange, marketType)
+	if child != nil {
+		if _, err := t.StateMgmt.CreateStrategy(child); err != nil {
+			t.Log.Error("can't create child",
+				zap.String("id", t.ID()),
+				zap.String("pair", pair),
+				zap.Error(err),
+			)
+			child = nil // the child isn't written, so it doesn't count to limits
+		}
+	}
+
+	t.mux.Lock()
+	t.spawning[pair]--
+	if t.spawning[pair] == 0 {
+		delete(t.spawning, pair)
+	}
+	if child == nil {
+		t.Model.State.Spawned--
+		t.mux.Unlock()
+		t.Statsd.Inc("template.spawn_error")
+		return nil
+	}
+	t.children = append(t.children, child)
+	t.Model.State.Active++
+	t.Model.State.LastSpawnAt = time.Now().Unix()
+	state := *t.Model.State
+	t.mux.Unlock()
+	t.StateMgmt.UpdateTemplateState(&t.Model.Id, &state)
+	t.Log.Info("spawned child",
+		zap.String("id", t.ID()),
+		zap.String("childId", child.ID.Hex()),
+		zap.String("pair", pair),
+		zap.Int64("spawned", state.Spawned),
+	)
+	t.Statsd.Inc("template.spawned")
+	return child
+}
+
+// newChild instantiates the child strategy on the pair from conditions and values of the template, nil on failure.
+func (t *Template) newChild(pair string, price float64, templateConditions map[string]interface{}, values map[string]interface{}, strategyType int64, exchange string, marketType int64) *models.MongoStrategy {
+	if pair != "" {
+		values[pairPlaceholder] = pair
+	}
+	if price == 0 {
+		if ohlcv := t.DataFeed.GetPriceForPairAtExchange(pair, exchange, marketType); ohlcv != nil {
+			price = ohlcv.Close
+		}
+	}
+	if price > 0 {
+		values[pricePlaceholder] = price
+	}
+	conditions, err := Instantiate(templateConditions, values)
+	if err != nil {
+		t.Log.Error("can't instantiate template conditions",
+			zap.String("id", t.ID()),
+			zap.String("pair", pair),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if pair != "" {
+		conditions.Pair = pair
+	}
+	conditions.CreatedByTemplate = true
+	conditions.TemplateStrategyId = &t.Model.Id
+	if conditions.AccountId == nil {
+		conditions.AccountId = t.Model.AccountId
+	}
+	if strategyType == 0 {
+		strategyType = smartOrderType
+	}
+	id := primitive.NewObjectID()
+	return &models.MongoStrategy{
+		ID:         &id,
+		Type:       strategyType,
+		Enabled:    true,
+		AccountId:  t.Model.AccountId,
+		OwnerId:    t.Model.OwnerId,
+		Conditions: conditions,
+		State:      &models.MongoStrategyState{},
+		CreatedAt:  time.Now(),
+	}
+}
+
+// limitReached returns the limit not letting spawn a child on the pair given or empty string if there is none.
+func (t *Template) limitReached(pair string) string {
+	if t.Model.MaxChildren > 0 && t.Model.State.Spawned >= t.Model.MaxChildren {
+		return "max children"
+	}
+	active := activeByPair(t.children)
+	for spawningPair, count := range t.spawning {
+		active[spawningPair] += count
+	}
+	var total int64
+	for _, count := range active {
+		total += count
+	}
+	if t.Model.MaxActive > 0 && total >= t.Model.MaxActive {
+		return "max active"
+	}
+	if t.Model.MaxActivePerPair > 0 && active[pair] >= t.Model.MaxActivePerPair {
+		return "max active per pair"
+	}
+	if t.Model.Spawn.Rule == models.TemplateSpawnByPairs && active[pair] > 0 {
+		return "pair taken"
+	}
+	return ""
+}
+
+// exchange reads exchange from conditions to look the current price up, binance if it's not set.
+func (t *Template) exchange() string {
+	conditionsExchange, _ := t.Model.Conditions["exchange"].(string)
+	if filled, err := fillString(conditionsExchange, t.Model.Params); err == nil {
+		if exchange, _ := filled.(string); exchange != "" {
+			return exchange
+		}
+	}
+	return defaultExchange
+}
+
+// marketType reads market type from conditions to look the current price up, spot if it's not a number.
+func (t *Template) marketType() int64 {
+	switch marketType := t.Model.Conditions["marketType"].(type) {
+	case int32:
+		return int64(marketType)
+	case int64:
+		return marketType
+	case float64:
+		return int64(marketType)
+	case int:
+		return int64(marketType)
+	}
+	return 0
+}
+
+// Settle takes the template to run in the instance trying to set a distributed lock.
+func (t *Template) Settle() (bool, error) {
+	if err := t.SettlementMutex.Lock(); err != nil {
+		if err == redsync.ErrFailed {
+			return false, nil // already locked
+		}
+		return false, err // unexpected error
+	}
+	// extend settlement
+	go func() {
+		for t.isEnabled() {
+			time.Sleep(3 * time.Second)
+			success, err := t.SettlementMutex.Extend()
+			if !success || err != nil {
+				t.Log.Error("template settlement mutex extension",
+					zap.Bool("success", success),
+					zap.String("name", t.SettlementMutex.Name()),
+					zap.Error(err),
+				)
+				return
+			}
+		}
+	}()
+	return true, nil
+}
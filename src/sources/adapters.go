@@ -0,0 +1,7 @@
+package sources
+
+// Exchange feed adapters built in, each registers itself to the default registry. A new adapter is added here.
+import (
+	_ "gitlab.com/crypto_project/core/strategy_service/src/sources/binance"
+	_ "gitlab.com/crypto_project/core/strategy_service/src/sources/redis"
+)
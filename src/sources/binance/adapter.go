@@ -0,0 +1,64 @@
+package binance
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/supervisor"
+)
+
+// Config keys of the adapter, FEED_BINANCE_<KEY> environment variables.
+const (
+	KeySpotStreamUrl    = "SPOT_STREAM_URL"
+	KeyFuturesStreamUrl = "FUTURES_STREAM_URL"
+	KeySpotRestUrl      = "SPOT_REST_URL"
+	KeyFuturesRestUrl   = "FUTURES_REST_URL"
+)
+
+func init() {
+	registry.Register("binance", New, "binance", "")
+}
+
+// New returns the loop of the endpoints configured with all its streams connecting, see registry.Factory.
+func New(config registry.Config) (registry.Adapter, error) {
+	rl := &BinanceLoop{
+		StreamUrls: [2]string{config.Get(KeySpotStreamUrl, ""), config.Get(KeyFuturesStreamUrl, "")},
+		RestUrls:   [2]string{config.Get(KeySpotRestUrl, ""), config.Get(KeyFuturesRestUrl, "")},
+		Candles:    indicators.CandleStore{OnDemand: true}, // streams cover all the pairs
+	}
+	rl.SubscribeToPairs()
+	return rl, nil
+}
+
+// Capabilities tells streams cover all the data of spot and futures.
+func (rl *BinanceLoop) Capabilities() registry.Capabilities {
+	return registry.Capabilities{
+		Ticker:      true,
+		Spread:      true,
+		Depth:       true,
+		Candles:     true,
+		MarkPrice:   true,
+		MarketTypes: []int64{0, 1},
+	}
+}
+
+// Stop closes all the streams.
+func (rl *BinanceLoop) Stop() {
+	rl.Streams.Range(func(name, stream interface{}) bool {
+		stream.(*supervisor.Stream).Stop()
+		return true
+	})
+}
+
+func (rl *BinanceLoop) streamUrl(marketType int64) string {
+	if rl.StreamUrls[marketType] != "" {
+		return rl.StreamUrls[marketType]
+	}
+	return defaultStreamUrls[marketType]
+}
+
+func (rl *BinanceLoop) restUrl(marketType int64) string {
+	if rl.RestUrls[marketType] != "" {
+		return rl.RestUrls[marketType]
+	}
+	return defaultRestUrls[marketType]
+}
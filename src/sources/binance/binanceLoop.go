@@ -2,21 +2,54 @@ package binance
 
 import (
 	"encoding/json"
-	"github.com/Cryptocurrencies-AI/go-binance"
 	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/fanout"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/orderbook"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/recorder"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/supervisor"
 	"go.uber.org/zap"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type BinanceLoop struct {
-	OhlcvMap  sync.Map // <string: exchange+pair+o/h/l/c/v, OHLCV: ohlcv>
-	SpreadMap sync.Map
+	OhlcvMap    sync.Map // <string: exchange+pair+o/h/l/c/v, OHLCV: ohlcv>
+	SpreadMap   sync.Map
+	Feeds       staleness.Tracker
+	Candles     indicators.CandleStore
+	Updates     fanout.Hub    // <string: exchange+pair+marketType as in the maps>
+	volumes     sync.Map      // <string: pair+marketType, float64: 24h volume of the previous tick>
+	spotSpreads subscriptions // spot book tickers of pairs spreads were asked for
+	Streams     sync.Map      // <string: name, *supervisor.Stream>
+	// level 2 books of pairs asked for by market type
+	depths    [2]subscriptions
+	books     sync.Map // <string: symbol+marketType, *orderbook.Book>
+	snapshots sync.Map // <string: symbol+marketType, bool: fetching>
+	// FetchDepthSnapshot takes book snapshots, REST API is used if nil
+	FetchDepthSnapshot func(symbol string, marketType int64) (orderbook.Snapshot, error)
+	// <string: exchange+pair+marketType, MarkPrice: mark and index price> of futures
+	MarkPriceMap sync.Map
+	// raw streams and REST API endpoints by market type, Binance ones if empty
+	StreamUrls [2]string
+	RestUrls   [2]string
 }
 
-var binanceLoop *BinanceLoop
+// maxSpotSpreads is how many spot book ticker streams one connection takes, the pair asked for least recently is
+// released for a new one.
+const maxSpotSpreads = 1024
+
+// Gaps streams are considered stalled after, all market streams push every second while spot book tickers of
+// symbols not traded idle between pings.
+const (
+	tickersMaxGap    = 30 * time.Second
+	spotSpreadMaxGap = 5 * time.Minute
+)
+
 var log interfaces.ILogger
 
 func init() {
@@ -24,41 +57,45 @@ func init() {
 	log = logger.With(zap.String("logger", "binanceLoop"))
 }
 
-func InitBinance() interfaces.IDataFeed {
-	if binanceLoop == nil {
-		binanceLoop = &BinanceLoop{}
-		binanceLoop.SubscribeToPairs()
-	}
-	return binanceLoop
-}
-
 func (rl *BinanceLoop) GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.OHLCV {
-	if binanceLoop == nil {
-		binanceLoop = &BinanceLoop{}
-		binanceLoop.SubscribeToPairs()
-	}
-	return binanceLoop.GetPrice(pair, exchange, marketType)
+	return rl.GetPrice(pair, "binance", marketType)
 }
 
 func (rl *BinanceLoop) GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.SpreadData {
-	if binanceLoop == nil {
-		binanceLoop = &BinanceLoop{}
-		binanceLoop.SubscribeToPairs()
-	}
-	return binanceLoop.GetSpread(pair, exchange, marketType)
+	return rl.GetSpread(pair, "binance", marketType)
+}
+
+func (rl *BinanceLoop) IsStale(pair string, exchange string, marketType int64) bool {
+	ohlcv := rl.GetPriceForPairAtExchange(pair, exchange, marketType)
+	return ohlcv == nil || staleness.IsStale("binance", ohlcv.UpdatedAt, time.Now())
+}
+
+func (rl *BinanceLoop) GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	return rl.Candles.GetCandles(strings.Replace(pair, "_", "", -1), "binance", marketType, timeframe, n)
+}
+
+func (rl *BinanceLoop) Subscribe(pair string, exchange string, marketType int64) <-chan interfaces.Update {
+	return rl.Updates.Subscribe("binance" + strings.Replace(pair, "_", "", -1) + strconv.FormatInt(marketType, 10))
+}
+
+func (rl *BinanceLoop) Unsubscribe(pair string, exchange string, marketType int64, updates <-chan interfaces.Update) {
+	rl.Updates.Unsubscribe("binance"+strings.Replace(pair, "_", "", -1)+strconv.FormatInt(marketType, 10), updates)
+}
+
+// StaleFeeds returns ticker and spread streams not updated for longer than the max age.
+func (rl *BinanceLoop) StaleFeeds() []string {
+	return rl.Feeds.StaleFeeds(time.Now())
 }
 
 type MiniTicker struct {
-	// EventType string `json:"e,string"` // "24hrMiniTicker"
-	// EventTime time.Time `json:"E,number"` // 123456789
-	// Symbol string `json:"s,string"` // "BNBBTC"
-	// Close float64 `json:"c,string"` // "0.0025"
-	Symbol string `json:"s"` // "BNBBTC"
-	Close  string `json:"c"` // "0.0025"
+	EventType string `json:"e"` // "24hrMiniTicker", taken so it's not decoded into EventTime case-insensitively
+	EventTime int64  `json:"E"` // 123456789, unix milliseconds
+	Symbol    string `json:"s"` // "BNBBTC"
+	Close     string `json:"c"` // "0.0025"
 	// Open float64 `json:"o,string"` // "0.0010"
 	// High float64 `json:"h,string"` // "0.0025"
 	// Low float64 `json:"l,string"` // "0.0010"
-	// Volume float64 `json:"v,string"` // "10000"
+	Volume string `json:"v"` // "10000", base asset volume of the last 24 hours
 	// Quote float64 `json:"q,string"` // "18"
 }
 
@@ -71,14 +108,39 @@ type RawSpread struct {
 }
 
 func (rl *BinanceLoop) SubscribeToPairs() {
-	go ListenBinancePrice(func(data *binance.RawEvent, marketType int8) error {
-		go rl.UpdateOHLCV(data.Data, marketType)
-		return nil
-	})
+	for _, marketType := range []int8{0, 1} {
+		marketType := marketType
+		rl.supervise("ticker."+strconv.Itoa(int(marketType)), tickersMaxGap, func() (<-chan []byte, <-chan struct{}, func(), error) {
+			return connectStream(rl.streamUrl(int64(marketType))+miniTickersPath, "miniTicker")
+		}, func(data []byte) {
+			rl.UpdateOHLCV(data, marketType) // serially, volumes of the previous tick are read in order
+		})
+	}
 	rl.SubscribeToSpread()
+	rl.SubscribeToMarkPrices()
+	rl.SubscribeToDepth()
+}
+
+// supervise keeps the stream of the name given connected in background.
+func (rl *BinanceLoop) supervise(name string, maxGap time.Duration, connect supervisor.Connect, onMessage func(data []byte)) {
+	stream := supervisor.NewStream("binance."+name, connect, onMessage)
+	stream.MaxGap = maxGap
+	rl.Streams.Store(stream.Name, stream)
+	go stream.Run()
+}
+
+// ConnectionStates returns states of the streams by name.
+func (rl *BinanceLoop) ConnectionStates() map[string]string {
+	states := map[string]string{}
+	rl.Streams.Range(func(name, stream interface{}) bool {
+		states[name.(string)] = stream.(*supervisor.Stream).State()
+		return true
+	})
+	return states
 }
 
-// UpdateOHLCV decodes raw OHLCV data and writes them to OHLCVMap for future use.
+// UpdateOHLCV decodes raw OHLCV data, aggregates them into candles and writes the forming minute candle to OHLCVMap
+// for future use.
 func (rl *BinanceLoop) UpdateOHLCV(data []byte, marketType int8) {
 	var allMarketOHLCV []MiniTicker
 	err := json.Unmarshal(data, &allMarketOHLCV)
@@ -88,6 +150,8 @@ func (rl *BinanceLoop) UpdateOHLCV(data []byte, marketType int8) {
 		)
 		return
 	}
+	updatedAt := staleness.Now()
+	rl.Feeds.Touch("binance", "ticker."+strconv.FormatInt(int64(marketType), 10), updatedAt)
 	for _, ohlcv := range allMarketOHLCV {
 		pair := ohlcv.Symbol
 		price, err := strconv.ParseFloat(ohlcv.Close, 10)
@@ -97,17 +161,50 @@ func (rl *BinanceLoop) UpdateOHLCV(data []byte, marketType int8) {
 			)
 			continue
 		}
-		ohlcvToSave := interfaces.OHLCV{
-			Open:   price,
-			High:   price,
-			Low:    price,
-			Close:  price,
-			Volume: price,
+		at := time.Now()
+		if ohlcv.EventTime > 0 {
+			at = time.Unix(0, ohlcv.EventTime*int64(time.Millisecond))
+		}
+		key := pair + strconv.FormatInt(int64(marketType), 10)
+		volume := rl.tradedVolume(key, ohlcv.Volume)
+		recorder.Record(recorder.Event{
+			At:         updatedAt,
+			EventTime:  at.UnixNano() / int64(time.Millisecond),
+			Kind:       recorder.KindTick,
+			Exchange:   "binance",
+			Pair:       pair,
+			MarketType: int64(marketType),
+			Price:      &interfaces.OHLCV{Open: price, High: price, Low: price, Close: price, Volume: volume, UpdatedAt: updatedAt},
+		})
+		rl.Candles.AddTick(pair, "binance", int64(marketType), price, volume, at)
+		ohlcvToSave := interfaces.OHLCV{Open: price, High: price, Low: price, Close: price}
+		if forming := rl.Candles.GetCandles(pair, "binance", int64(marketType), 60, 1); len(forming) > 0 {
+			ohlcvToSave = forming[0].OHLCV
+		}
+		ohlcvToSave.UpdatedAt = updatedAt
+		previous, ok := rl.OhlcvMap.Load("binance" + key)
+		rl.OhlcvMap.Store("binance"+key, ohlcvToSave)
+		if !ok || previous.(interfaces.OHLCV).Close != ohlcvToSave.Close {
+			rl.Updates.Publish("binance"+key, interfaces.Update{Price: &ohlcvToSave})
 		}
-		rl.OhlcvMap.Store("binance"+pair+strconv.FormatInt(int64(marketType), 10), ohlcvToSave)
 	}
 }
 
+// tradedVolume returns volume traded since the previous tick of the market given by the 24h volume growth, the
+// volume leaving the rolling window makes it an estimate. Tickers of a market type are to be processed serially.
+func (rl *BinanceLoop) tradedVolume(key string, volume24h string) float64 {
+	volume, err := strconv.ParseFloat(volume24h, 64)
+	if err != nil {
+		return 0
+	}
+	previous, ok := rl.volumes.Load(key)
+	rl.volumes.Store(key, volume)
+	if !ok || volume < previous.(float64) {
+		return 0
+	}
+	return volume - previous.(float64)
+}
+
 func (rl *BinanceLoop) GetPrice(pair, exchange string, marketType int64) *interfaces.OHLCV {
 	ohlcvRaw, ob := rl.OhlcvMap.Load(exchange + strings.Replace(pair, "_", "", -1) + strconv.FormatInt(marketType, 10))
 	if ob == true {
@@ -118,35 +215,63 @@ func (rl *BinanceLoop) GetPrice(pair, exchange string, marketType int64) *interf
 }
 
 func (rl *BinanceLoop) SubscribeToSpread() {
-	go ListenBinanceSpread(func(data *binance.SpreadAllEvent) error {
-		go rl.UpdateSpread(data.Data)
-		return nil
+	rl.supervise("spread.1", tickersMaxGap, func() (<-chan []byte, <-chan struct{}, func(), error) {
+		return connectStream(rl.streamUrl(1)+bookTickersPath, "bookTicker")
+	}, func(data []byte) {
+		go rl.UpdateSpread(data, 1)
+	})
+	rl.supervise("spread.0", spotSpreadMaxGap, func() (<-chan []byte, <-chan struct{}, func(), error) {
+		rl.spotSpreads.resubscribe()
+		return connectSubscribing(rl.streamUrl(0), "@bookTicker", rl.spotSpreads.pending, rl.spotSpreads.unsubscribing)
+	}, func(data []byte) {
+		rl.UpdateSpread(data, 0)
 	})
 }
 
-func (rl *BinanceLoop) UpdateSpread(data []byte) {
+// UpdateSpread decodes a book ticker of the market type given and writes it to SpreadMap.
+func (rl *BinanceLoop) UpdateSpread(data []byte, marketType int64) {
 	var spread RawSpread
 	tryparse := json.Unmarshal(data, &spread)
 	if tryparse != nil {
 		log.Info("can't parse spread data",
 			zap.String("err", tryparse.Error()),
 		)
+		return
+	}
+	if spread.Symbol == "" {
+		return // subscription response
 	}
 
 	exchange := "binance"
-	marketType := 1
+	updatedAt := staleness.Now()
+	rl.Feeds.Touch(exchange, "spread."+strconv.FormatInt(marketType, 10), updatedAt)
 
 	spreadData := interfaces.SpreadData{
-		Close:   spread.BestBidPrice,
-		BestBid: spread.BestBidPrice,
-		BestAsk: spread.BestAskPrice,
+		Close:      spread.BestBidPrice,
+		BestBid:    spread.BestBidPrice,
+		BestAsk:    spread.BestAskPrice,
+		BestBidQty: spread.BestBidQty,
+		BestAskQty: spread.BestAskQty,
+		UpdatedAt:  updatedAt,
 	}
 
-	rl.SpreadMap.Store(exchange+spread.Symbol+strconv.FormatInt(int64(marketType), 10), spreadData)
+	recorder.Record(recorder.Event{At: updatedAt, Kind: recorder.KindSpread, Exchange: exchange, Pair: spread.Symbol, MarketType: marketType, Spread: &spreadData})
+	key := exchange + spread.Symbol + strconv.FormatInt(marketType, 10)
+	previous, ok := rl.SpreadMap.Load(key)
+	rl.SpreadMap.Store(key, spreadData)
+	if !ok || previous.(interfaces.SpreadData).BestBid != spreadData.BestBid || previous.(interfaces.SpreadData).BestAsk != spreadData.BestAsk {
+		rl.Updates.Publish(key, interfaces.Update{Spread: &spreadData})
+	}
 }
 
 func (rl *BinanceLoop) GetSpread(pair, exchange string, marketType int64) *interfaces.SpreadData {
-	spreadRaw, ok := rl.SpreadMap.Load(exchange + strings.Replace(pair, "_", "", -1) + strconv.FormatInt(marketType, 10))
+	symbol := strings.Replace(pair, "_", "", -1)
+	if marketType == 0 {
+		if released := rl.spotSpreads.request(symbol, maxSpotSpreads); released != "" {
+			rl.SpreadMap.Delete(exchange + released + "0")
+		}
+	}
+	spreadRaw, ok := rl.SpreadMap.Load(exchange + symbol + strconv.FormatInt(marketType, 10))
 	//log.Println("spreadRaw ", spreadRaw)
 	if ok == true {
 		spread := spreadRaw.(interfaces.SpreadData)
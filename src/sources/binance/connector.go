@@ -1,101 +1,116 @@
 package binance
 
 import (
-	"context"
 	"fmt"
-	"github.com/Cryptocurrencies-AI/go-binance"
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
-	"os"
-	"os/signal"
+	"strings"
+	"sync"
+	"time"
 )
 
-func GetBinanceClientInstance() (binance.Binance, context.CancelFunc) {
-	ctx, cancelCtx := context.WithCancel(context.Background())
-	// use second return value for cancelling request when shutting down the app
+// Raw streams endpoints by market type. All market streams are opened by path, the all market spot book ticker stream
+// is gone so spot book tickers are subscribed by symbol as well as diff-depth streams.
+var defaultStreamUrls = [2]string{
+	"wss://stream.binance.com:9443/ws",
+	"wss://fstream.binance.com/ws",
+}
+
+// REST API endpoints by market type.
+var defaultRestUrls = [2]string{
+	"https://api.binance.com",
+	"https://fapi.binance.com",
+}
+
+// All market streams paths.
+const (
+	miniTickersPath = "/!miniTicker@arr"
+	bookTickersPath = "/!bookTicker" // futures only
+	markPricesPath  = "/!markPrice@arr@1s"
+)
 
-	binanceService := binance.NewAPIService(
-		"https://www.binance.com",
-		"",
-		nil,
-		nil,
-		ctx,
-	)
-	b := binance.NewBinance(binanceService)
-	return b, cancelCtx
+// connectStream opens the raw stream of the url given, see supervisor.Connect.
+func connectStream(url string, name string) (<-chan []byte, <-chan struct{}, func(), error) {
+	return connectSubscribing(url, name, nil, nil)
 }
 
-func ListenBinancePrice(onMessage func(data *binance.RawEvent, marketType int8) error) error {
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	binance, cancelCtx := GetBinanceClientInstance()
-	kechSpot, doneSpot, err := binance.SpotAllMarketMiniTickersStreamWebsocket()
+// connectSubscribing opens raw streams endpoint given subscribing streams of the suffix for symbols pending returns
+// and unsubscribing ones of symbols unsubscribing returns, they are asked every second since subscriptions are rate
+// limited, see supervisor.Connect. Nothing is subscribed or unsubscribed if the func is nil.
+func connectSubscribing(url string, suffix string, pending func() []string, unsubscribing func() []string) (<-chan []byte, <-chan struct{}, func(), error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
-		return fmt.Errorf("listen spot: %v", err)
+		return nil, nil, nil, fmt.Errorf("dial %v streams: %v", suffix, err)
 	}
-	kechFutures, doneFutures, err := binance.FuturesAllMarketMiniTickersStreamWebsocket()
-	if err != nil {
-		return fmt.Errorf("listen futures: %v", err)
+	if pending == nil {
+		pending = func() []string { return nil }
 	}
-
+	if unsubscribing == nil {
+		unsubscribing = func() []string { return nil }
+	}
+	messages := make(chan []byte)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	conn.SetPingHandler(func(appData string) error {
+		select {
+		case messages <- nil: // alive with no symbols subscribed
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(10*time.Second))
+	})
 	go func() {
+		defer close(done)
 		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				log.Warn("read streams", zap.String("streams", suffix), zap.Error(err))
+				return
+			}
 			select {
-			case e := <-kechFutures:
-				onMessage(e, 1)
-			case e := <-kechSpot:
-				onMessage(e, 0)
-			case <-doneSpot:
-				break
-			case <-doneFutures:
-				break
+			case messages <- data:
+			case <-stop:
+				return
 			}
 		}
 	}()
-
-	log.Info("waiting for interrupt")
-	<-interrupt
-	log.Info("canceling context")
-	cancelCtx()
-	log.Info("waiting for signal")
-	<-doneSpot
-	<-doneFutures
-	log.Info("exit")
-	return nil
-}
-
-func ListenBinanceSpread(onMessage func(data *binance.SpreadAllEvent) error) error {
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-
-	binanceInstance, cancelCtx := GetBinanceClientInstance()
-	kech, done, err := binanceInstance.SpreadAllWebsocket()
-
-	log.Info("here",
-		zap.String("done", fmt.Sprintf("%v", done)),
-		zap.Error(err),
-	)
-
-	if err != nil {
-		panic(err)
-	}
 	go func() {
-		for {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+		for id := 1; ; id++ {
 			select {
-			case ke := <-kech:
-				//fmt.Printf("%#v\n", ke)
-				_ = onMessage(ke)
 			case <-done:
-				break
+				return
+			case <-ticker.C:
+				for _, method := range []string{"UNSUBSCRIBE", "SUBSCRIBE"} {
+					next := pending
+					if method == "UNSUBSCRIBE" {
+						next = unsubscribing
+					}
+					symbols := next()
+					if len(symbols) == 0 {
+						continue
+					}
+					streams := make([]string, len(symbols))
+					for i, symbol := range symbols {
+						streams[i] = strings.ToLower(symbol) + suffix
+					}
+					request := map[string]interface{}{"method": method, "params": streams, "id": id}
+					id++
+					if err := conn.WriteJSON(request); err != nil {
+						log.Warn("subscribe streams", zap.String("streams", suffix), zap.String("method", method), zap.Error(err))
+						_ = conn.Close()
+						return
+					}
+					log.Info("subscribed streams", zap.String("streams", suffix), zap.String("method", method), zap.Strings("symbols", symbols))
+				}
 			}
 		}
 	}()
-
-	log.Info("waiting for interrupt")
-	<-interrupt
-	log.Info("canceling context")
-	cancelCtx()
-	log.Info("waiting for signal")
-	<-done
-	log.Info("exit")
-	return nil
+	var once sync.Once
+	return messages, done, func() {
+		once.Do(func() {
+			close(stop)
+			_ = conn.Close()
+		})
+	}, nil
 }
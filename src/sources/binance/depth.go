@@ -0,0 +1,188 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/orderbook"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	depthStreamSuffix   = "@depth@100ms"
+	maxDepthBooks       = 200 // books of a market type one connection keeps, each pushes up to ten times a second
+	depthSnapshotLimit  = 1000
+	depthSnapshotTries  = 3
+	depthSnapshotMaxGap = 5 * time.Minute // books of pairs not traded idle between pings
+)
+
+// REST API paths of depth snapshots by market type.
+var depthSnapshotPaths = [2]string{
+	"/api/v3/depth",
+	"/fapi/v1/depth",
+}
+
+var snapshotClient = &http.Client{Timeout: 5 * time.Second}
+
+type DepthEvent struct {
+	Symbol            string      `json:"s"`
+	FirstUpdateId     int64       `json:"U"`
+	FinalUpdateId     int64       `json:"u"`
+	PrevFinalUpdateId int64       `json:"pu"` // futures only
+	Bids              [][2]string `json:"b"`
+	Asks              [][2]string `json:"a"`
+}
+
+type DepthSnapshot struct {
+	LastUpdateId int64       `json:"lastUpdateId"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
+
+// SubscribeToDepth keeps diff-depth streams of symbols books were asked for connected.
+func (rl *BinanceLoop) SubscribeToDepth() {
+	for _, marketType := range []int64{0, 1} {
+		marketType := marketType
+		rl.supervise("depth."+strconv.FormatInt(marketType, 10), depthSnapshotMaxGap, func() (<-chan []byte, <-chan struct{}, func(), error) {
+			rl.depths[marketType].resubscribe() // books get out of sync on the gap and recover by snapshots
+			return connectSubscribing(rl.streamUrl(marketType), depthStreamSuffix, rl.depths[marketType].pending, rl.depths[marketType].unsubscribing)
+		}, func(data []byte) {
+			rl.UpdateDepth(data, marketType) // diffs have to be applied in order
+		})
+	}
+}
+
+// GetOrderBook subscribes to the book of the pair unless it is subscribed already, the book not kept asked for least
+// recently is released for it once maxDepthBooks are kept.
+func (rl *BinanceLoop) GetOrderBook(pair string, exchange string, marketType int64, depth int) *interfaces.OrderBook {
+	if marketType != 0 && marketType != 1 {
+		return nil
+	}
+	symbol := strings.Replace(pair, "_", "", -1)
+	if released := rl.depths[marketType].request(symbol, maxDepthBooks); released != "" {
+		rl.books.Delete(released + strconv.FormatInt(marketType, 10))
+	}
+	book, ok := rl.books.Load(symbol + strconv.FormatInt(marketType, 10))
+	if !ok {
+		return nil
+	}
+	return book.(*orderbook.Book).Get(depth)
+}
+
+// KeepOrderBook pins the book of the pair, so it's not released for other pairs till ReleaseOrderBook is called as
+// many times.
+func (rl *BinanceLoop) KeepOrderBook(pair string, exchange string, marketType int64) {
+	if marketType != 0 && marketType != 1 {
+		return
+	}
+	if released := rl.depths[marketType].pin(strings.Replace(pair, "_", "", -1), maxDepthBooks); released != "" {
+		rl.books.Delete(released + strconv.FormatInt(marketType, 10))
+	}
+}
+
+// ReleaseOrderBook unpins the book of the pair, the book is released once no strategy keeps it.
+func (rl *BinanceLoop) ReleaseOrderBook(pair string, exchange string, marketType int64) {
+	if marketType != 0 && marketType != 1 {
+		return
+	}
+	symbol := strings.Replace(pair, "_", "", -1)
+	if rl.depths[marketType].unpin(symbol) {
+		rl.books.Delete(symbol + strconv.FormatInt(marketType, 10))
+	}
+}
+
+// UpdateDepth decodes a diff-depth event of the market type given and applies it to the book of the symbol, the book
+// out of sync gets a snapshot.
+func (rl *BinanceLoop) UpdateDepth(data []byte, marketType int64) {
+	var event DepthEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		log.Debug("decode diff-depth event", zap.Error(err))
+		return
+	}
+	if event.Symbol == "" {
+		return // subscription response
+	}
+	if !rl.depths[marketType].has(event.Symbol) {
+		return // released, not unsubscribed yet
+	}
+	diff := orderbook.Diff{
+		FirstUpdateId:     event.FirstUpdateId,
+		FinalUpdateId:     event.FinalUpdateId,
+		PrevFinalUpdateId: event.PrevFinalUpdateId,
+		Bids:              parseLevels(event.Bids),
+		Asks:              parseLevels(event.Asks),
+	}
+	book, _ := rl.books.LoadOrStore(event.Symbol+strconv.FormatInt(marketType, 10), &orderbook.Book{})
+	if !book.(*orderbook.Book).Update(diff, staleness.Now()) {
+		rl.requestDepthSnapshot(event.Symbol, marketType, book.(*orderbook.Book))
+	}
+}
+
+// requestDepthSnapshot resets the book by a snapshot in background unless it's being fetched already, a snapshot
+// older than diffs buffered is fetched again.
+func (rl *BinanceLoop) requestDepthSnapshot(symbol string, marketType int64, book *orderbook.Book) {
+	key := symbol + strconv.FormatInt(marketType, 10)
+	if _, fetching := rl.snapshots.LoadOrStore(key, true); fetching {
+		return
+	}
+	fetch := rl.FetchDepthSnapshot
+	if fetch == nil {
+		fetch = rl.fetchDepthSnapshot
+	}
+	go func() {
+		defer rl.snapshots.Delete(key)
+		for try := 1; try <= depthSnapshotTries; try++ {
+			snapshot, err := fetch(symbol, marketType)
+			if err != nil {
+				log.Warn("fetch depth snapshot", zap.String("symbol", symbol), zap.Error(err))
+			} else if book.Reset(snapshot, staleness.Now()) {
+				return
+			}
+			time.Sleep(1 * time.Second)
+		}
+		log.Warn("order book not synced", zap.String("symbol", symbol), zap.Int64("market type", marketType))
+	}()
+}
+
+// fetchDepthSnapshot takes the book snapshot by REST API.
+func (rl *BinanceLoop) fetchDepthSnapshot(symbol string, marketType int64) (orderbook.Snapshot, error) {
+	url := fmt.Sprintf("%v%v?symbol=%v&limit=%v", rl.restUrl(marketType), depthSnapshotPaths[marketType], symbol, depthSnapshotLimit)
+	response, err := snapshotClient.Get(url)
+	if err != nil {
+		return orderbook.Snapshot{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return orderbook.Snapshot{}, fmt.Errorf("depth snapshot status %v", response.Status)
+	}
+	var snapshot DepthSnapshot
+	if err := json.NewDecoder(response.Body).Decode(&snapshot); err != nil {
+		return orderbook.Snapshot{}, err
+	}
+	return orderbook.Snapshot{
+		LastUpdateId: snapshot.LastUpdateId,
+		Bids:         parseLevels(snapshot.Bids),
+		Asks:         parseLevels(snapshot.Asks),
+	}, nil
+}
+
+func parseLevels(raw [][2]string) []interfaces.PriceLevel {
+	levels := make([]interfaces.PriceLevel, 0, len(raw))
+	for _, level := range raw {
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			continue
+		}
+		amount, err := strconv.ParseFloat(level[1], 64)
+		if err != nil {
+			continue
+		}
+		levels = append(levels, interfaces.PriceLevel{Price: price, Amount: amount})
+	}
+	return levels
+}
@@ -0,0 +1,74 @@
+package binance
+
+import (
+	"encoding/json"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/recorder"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+type RawMarkPrice struct {
+	Symbol          string `json:"s"`
+	MarkPrice       string `json:"p"`
+	IndexPrice      string `json:"i"`
+	FundingRate     string `json:"r"`
+	NextFundingTime int64  `json:"T"`
+}
+
+// SubscribeToMarkPrices keeps all market futures mark price stream connected.
+func (rl *BinanceLoop) SubscribeToMarkPrices() {
+	rl.supervise("markPrice.1", tickersMaxGap, func() (<-chan []byte, <-chan struct{}, func(), error) {
+		return connectStream(rl.streamUrl(1)+markPricesPath, "markPrice")
+	}, func(data []byte) {
+		go rl.UpdateMarkPrices(data)
+	})
+}
+
+// UpdateMarkPrices decodes all market mark prices and writes them to MarkPriceMap.
+func (rl *BinanceLoop) UpdateMarkPrices(data []byte) {
+	var markPrices []RawMarkPrice
+	if err := json.Unmarshal(data, &markPrices); err != nil {
+		log.Debug("decode all market mark prices", zap.Error(err))
+		return
+	}
+	updatedAt := staleness.Now()
+	rl.Feeds.Touch("binance", "markPrice.1", updatedAt)
+	for _, raw := range markPrices {
+		mark, err := strconv.ParseFloat(raw.MarkPrice, 64)
+		if err != nil {
+			continue
+		}
+		index, _ := strconv.ParseFloat(raw.IndexPrice, 64)
+		fundingRate, _ := strconv.ParseFloat(raw.FundingRate, 64)
+		markPrice := interfaces.MarkPrice{
+			Mark:            mark,
+			Index:           index,
+			FundingRate:     fundingRate,
+			NextFundingTime: raw.NextFundingTime,
+			UpdatedAt:       updatedAt,
+		}
+		recorder.Record(recorder.Event{At: updatedAt, Kind: recorder.KindMarkPrice, Exchange: "binance", Pair: raw.Symbol, MarketType: 1, MarkPrice: &markPrice})
+		key := "binance" + raw.Symbol + "1"
+		previous, ok := rl.MarkPriceMap.Load(key)
+		rl.MarkPriceMap.Store(key, markPrice)
+		if !ok || previous.(interfaces.MarkPrice).Mark != mark || previous.(interfaces.MarkPrice).Index != index {
+			rl.Updates.Publish(key, interfaces.Update{MarkPrice: &markPrice})
+		}
+	}
+}
+
+// GetMarkPrice returns the mark price of the futures pair, there are none for spot.
+func (rl *BinanceLoop) GetMarkPrice(pair string, exchange string, marketType int64) *interfaces.MarkPrice {
+	if marketType != 1 {
+		return nil
+	}
+	markPrice, ok := rl.MarkPriceMap.Load("binance" + strings.Replace(pair, "_", "", -1) + "1")
+	if !ok {
+		return nil
+	}
+	result := markPrice.(interfaces.MarkPrice)
+	return &result
+}
@@ -0,0 +1,138 @@
+package binance
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriptions keeps symbols a stream subscribed by symbol was asked for, strategies running ask for their pairs.
+// The connection has max symbols at most, the one asked for least recently is released for a new one, so pairs of
+// strategies stopped don't hold the stream. Symbols pinned are never released for new ones, the connection takes more
+// than max symbols if all of them are pinned.
+type subscriptions struct {
+	mux      sync.Mutex
+	symbols  map[string]*subscription
+	released []string // symbols subscribed on the connection the connection has to unsubscribe
+}
+
+type subscription struct {
+	subscribed  bool
+	requestedAt time.Time
+	pins        int // strategies running on the symbol
+}
+
+// request adds the symbol to subscribe, the symbol not pinned asked for least recently is released if the connection
+// has max symbols already. Returns the symbol released or empty string.
+func (s *subscriptions) request(symbol string, max int) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, released := s.add(symbol, max)
+	return released
+}
+
+// pin requests the symbol keeping it till unpinned as many times. Returns the symbol released or empty string.
+func (s *subscriptions) pin(symbol string, max int) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	sub, released := s.add(symbol, max)
+	sub.pins++
+	return released
+}
+
+// unpin releases the symbol unpinned as many times as pinned. Returns whether the symbol was released.
+func (s *subscriptions) unpin(symbol string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	sub, ok := s.symbols[symbol]
+	if !ok || sub.pins == 0 {
+		return false
+	}
+	sub.pins--
+	if sub.pins > 0 {
+		return false
+	}
+	s.release(symbol)
+	return true
+}
+
+// add adds the symbol unless it's added already marking it asked for, the lock is to be held.
+func (s *subscriptions) add(symbol string, max int) (*subscription, string) {
+	now := time.Now()
+	if sub, ok := s.symbols[symbol]; ok {
+		sub.requestedAt = now
+		return sub, ""
+	}
+	if s.symbols == nil {
+		s.symbols = map[string]*subscription{}
+	}
+	released := ""
+	if len(s.symbols) >= max {
+		for candidate, sub := range s.symbols {
+			if sub.pins == 0 && (released == "" || sub.requestedAt.Before(s.symbols[released].requestedAt)) {
+				released = candidate
+			}
+		}
+		if released != "" {
+			s.release(released)
+		}
+	}
+	sub := &subscription{requestedAt: now}
+	for i, unsubscribing := range s.released {
+		if unsubscribing == symbol { // still subscribed on the connection
+			s.released = append(s.released[:i], s.released[i+1:]...)
+			sub.subscribed = true
+			break
+		}
+	}
+	s.symbols[symbol] = sub
+	return sub, released
+}
+
+// release forgets the symbol to unsubscribe it if subscribed, the lock is to be held.
+func (s *subscriptions) release(symbol string) {
+	if s.symbols[symbol].subscribed {
+		s.released = append(s.released, symbol)
+	}
+	delete(s.symbols, symbol)
+}
+
+// has tells whether the symbol is asked for.
+func (s *subscriptions) has(symbol string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, ok := s.symbols[symbol]
+	return ok
+}
+
+// pending returns symbols requested but not subscribed yet marking them subscribed.
+func (s *subscriptions) pending() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var symbols []string
+	for symbol, sub := range s.symbols {
+		if !sub.subscribed {
+			sub.subscribed = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// unsubscribing returns symbols released while subscribed forgetting them.
+func (s *subscriptions) unsubscribing() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	symbols := s.released
+	s.released = nil
+	return symbols
+}
+
+// resubscribe marks all the symbols pending, a new connection subscribes them again.
+func (s *subscriptions) resubscribe() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, sub := range s.symbols {
+		sub.subscribed = false
+	}
+	s.released = nil
+}
@@ -3,14 +3,14 @@ package sources
 import (
 	"gitlab.com/crypto_project/core/strategy_service/src/logging"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
-	"gitlab.com/crypto_project/core/strategy_service/src/sources/binance"
-	"gitlab.com/crypto_project/core/strategy_service/src/sources/redis"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry"
 	"go.uber.org/zap"
+	"sort"
 )
 
+// A DataFeed routes markets to the adapter of their exchange registered, see registry.
 type DataFeed struct {
-	binanceLoop interfaces.IDataFeed
-	redisLoop interfaces.IDataFeed
+	feeds *registry.Registry
 }
 
 var dataFeed *DataFeed
@@ -21,54 +21,153 @@ func init() {
 	log = logger.With(zap.String("logger", "datafeed"))
 }
 
+// InitDataFeed starts adapters of the default registry.
 func InitDataFeed() interfaces.IDataFeed {
 	if dataFeed == nil {
-		dataFeed = &DataFeed{}
+		registry.Default.Start()
+		dataFeed = NewDataFeed(registry.Default)
 	}
+	return dataFeed
+}
 
-	if dataFeed.binanceLoop == nil {
-		dataFeed.binanceLoop = binance.InitBinance()
-	}
+// NewDataFeed returns the data feed of adapters of the registry given.
+func NewDataFeed(feeds *registry.Registry) *DataFeed {
+	return &DataFeed{feeds: feeds}
+}
 
-	if dataFeed.redisLoop == nil {
-		dataFeed.redisLoop = redis.InitRedis()
+// adapter returns the adapter of the exchange if it serves the market type, nil otherwise. Exchanges no adapter
+// serves are logged with the method asked for.
+func (df *DataFeed) adapter(exchange string, marketType int64, method string) registry.Adapter {
+	adapter := df.feeds.Adapter(exchange)
+	if adapter == nil {
+		log.Error("unknown exchange for "+method, zap.String("exchange", exchange))
+		return nil
 	}
-
-	return dataFeed
+	if !adapter.Capabilities().SupportsMarketType(marketType) {
+		return nil
+	}
+	return adapter
 }
 
 func (df *DataFeed) GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.OHLCV {
-	switch exchange {
-		case "serum": {
-			return df.redisLoop.GetPriceForPairAtExchange(pair, exchange, marketType)
-		}
-		case "binance": {
-			return df.binanceLoop.GetPriceForPairAtExchange(pair, exchange, marketType)
-		}
-		case "": {
-			return df.binanceLoop.GetPriceForPairAtExchange(pair, exchange, marketType)
-		}
-		default: {
-			log.Error("unknown exchange for getting GetPriceForPairAtExchange", zap.String("exchange", exchange))
-			return nil
-		}
+	adapter := df.adapter(exchange, marketType, "getting GetPriceForPairAtExchange")
+	if adapter == nil || !adapter.Capabilities().Ticker {
+		return nil
 	}
+	return adapter.GetPriceForPairAtExchange(pair, exchange, marketType)
 }
 
 func (df *DataFeed) GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.SpreadData {
-	switch exchange {
-		case "serum": {
-			return df.redisLoop.GetSpreadForPairAtExchange(pair, exchange, marketType)
-		}
-		case "binance": {
-			return df.binanceLoop.GetSpreadForPairAtExchange(pair, exchange, marketType)
+	adapter := df.adapter(exchange, marketType, "getting GetSpreadForPairAtExchange")
+	if adapter == nil || !adapter.Capabilities().Spread {
+		return nil
+	}
+	return adapter.GetSpreadForPairAtExchange(pair, exchange, marketType)
+}
+
+// IsStale reports markets of exchanges no adapter serves stale.
+func (df *DataFeed) IsStale(pair string, exchange string, marketType int64) bool {
+	adapter := df.adapter(exchange, marketType, "getting IsStale")
+	if adapter == nil {
+		return true
+	}
+	return adapter.IsStale(pair, exchange, marketType)
+}
+
+// StaleFeeds returns feeds of all the adapters not updated for longer than the max age of their exchange.
+func (df *DataFeed) StaleFeeds() []string {
+	var stale []string
+	for _, adapter := range df.feeds.Adapters() {
+		if feedHealth, ok := adapter.(interfaces.IFeedHealth); ok {
+			stale = append(stale, feedHealth.StaleFeeds()...)
 		}
-		case "": {
-			return df.binanceLoop.GetSpreadForPairAtExchange(pair, exchange, marketType)
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+func (df *DataFeed) GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	adapter := df.adapter(exchange, marketType, "getting GetCandles")
+	if adapter == nil || !adapter.Capabilities().Candles {
+		return nil
+	}
+	return adapter.GetCandles(pair, exchange, marketType, timeframe, n)
+}
+
+func (df *DataFeed) GetMarkPrice(pair string, exchange string, marketType int64) *interfaces.MarkPrice {
+	adapter := df.adapter(exchange, marketType, "getting GetMarkPrice")
+	if adapter == nil || !adapter.Capabilities().MarkPrice {
+		return nil
+	}
+	return adapter.GetMarkPrice(pair, exchange, marketType)
+}
+
+func (df *DataFeed) GetOrderBook(pair string, exchange string, marketType int64, depth int) *interfaces.OrderBook {
+	adapter := df.adapter(exchange, marketType, "getting GetOrderBook")
+	if adapter == nil || !adapter.Capabilities().Depth {
+		return nil
+	}
+	return adapter.GetOrderBook(pair, exchange, marketType, depth)
+}
+
+// KeepOrderBook pins the book of the market if its adapter keeps books.
+func (df *DataFeed) KeepOrderBook(pair string, exchange string, marketType int64) {
+	adapter := df.adapter(exchange, marketType, "KeepOrderBook")
+	if adapter == nil || !adapter.Capabilities().Depth {
+		return
+	}
+	if keeper, ok := adapter.(interfaces.IOrderBookKeeper); ok {
+		keeper.KeepOrderBook(pair, exchange, marketType)
+	}
+}
+
+// ReleaseOrderBook unpins the book of the market kept by KeepOrderBook.
+func (df *DataFeed) ReleaseOrderBook(pair string, exchange string, marketType int64) {
+	adapter := df.adapter(exchange, marketType, "ReleaseOrderBook")
+	if adapter == nil || !adapter.Capabilities().Depth {
+		return
+	}
+	if keeper, ok := adapter.(interfaces.IOrderBookKeeper); ok {
+		keeper.ReleaseOrderBook(pair, exchange, marketType)
+	}
+}
+
+func (df *DataFeed) Subscribe(pair string, exchange string, marketType int64) <-chan interfaces.Update {
+	adapter := df.adapter(exchange, marketType, "Subscribe")
+	if adapter == nil {
+		return nil
+	}
+	return adapter.Subscribe(pair, exchange, marketType)
+}
+
+func (df *DataFeed) Unsubscribe(pair string, exchange string, marketType int64, updates <-chan interfaces.Update) {
+	if adapter := df.feeds.Adapter(exchange); adapter != nil {
+		adapter.Unsubscribe(pair, exchange, marketType, updates)
+	}
+}
+
+// ConnectionStates returns states of stream connections of all the adapters by name.
+func (df *DataFeed) ConnectionStates() map[string]string {
+	states := map[string]string{}
+	for _, adapter := range df.feeds.Adapters() {
+		if connectionHealth, ok := adapter.(interfaces.IConnectionHealth); ok {
+			for name, state := range connectionHealth.ConnectionStates() {
+				states[name] = state
+			}
 		}
-		default: {
-			log.Error("unknown exchange for getting GetPriceForPairAtExchange", zap.String("exchange", exchange))
-			return nil
+	}
+	return states
+}
+
+// FeedErrors returns counts of messages not decoded of all the adapters.
+func (df *DataFeed) FeedErrors() map[string]int64 {
+	counts := map[string]int64{}
+	for _, adapter := range df.feeds.Adapters() {
+		if feedErrors, ok := adapter.(interfaces.IFeedErrors); ok {
+			for name, count := range feedErrors.FeedErrors() {
+				counts[name] = count
+			}
 		}
 	}
+	return counts
 }
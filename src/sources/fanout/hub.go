@@ -0,0 +1,106 @@
+// Package fanout delivers market updates of a feed to every subscriber of the market.
+package fanout
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"sync"
+	"sync/atomic"
+)
+
+// A Hub keeps subscribers by market key, the zero value is ready to use. Every subscriber has a buffer of one update,
+// publishing never blocks: an update the subscriber hasn't taken yet is merged into the new one.
+type Hub struct {
+	mux         sync.RWMutex
+	subscribers map[string][]*subscriber
+	coalesced   int64
+}
+
+type subscriber struct {
+	mux     sync.Mutex // serializes publishers, the subscriber only reads
+	updates chan interfaces.Update
+	closed  bool
+}
+
+// Subscribe returns updates published for the key.
+func (h *Hub) Subscribe(key string) <-chan interfaces.Update {
+	s := &subscriber{updates: make(chan interfaces.Update, 1)}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if h.subscribers == nil {
+		h.subscribers = map[string][]*subscriber{}
+	}
+	h.subscribers[key] = append(h.subscribers[key], s)
+	return s.updates
+}
+
+// Unsubscribe removes and closes updates of the key returned by Subscribe.
+func (h *Hub) Unsubscribe(key string, updates <-chan interfaces.Update) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	subscribers := h.subscribers[key]
+	for i, s := range subscribers {
+		if s.updates != updates {
+			continue
+		}
+		s.mux.Lock()
+		s.closed = true
+		close(s.updates)
+		s.mux.Unlock()
+		subscribers = append(subscribers[:i], subscribers[i+1:]...)
+		break
+	}
+	if len(subscribers) == 0 {
+		delete(h.subscribers, key)
+		return
+	}
+	h.subscribers[key] = subscribers
+}
+
+// Publish sends the update to subscribers of the key.
+func (h *Hub) Publish(key string, update interfaces.Update) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	for _, s := range h.subscribers[key] {
+		if h.send(s, update) {
+			atomic.AddInt64(&h.coalesced, 1)
+		}
+	}
+}
+
+// HasSubscribers tells whether anyone listens to the key, publishers may skip building updates otherwise.
+func (h *Hub) HasSubscribers(key string) bool {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return len(h.subscribers[key]) > 0
+}
+
+// Coalesced returns how many updates were merged into later ones as subscribers were not taking them.
+func (h *Hub) Coalesced() int64 {
+	return atomic.LoadInt64(&h.coalesced)
+}
+
+// send puts the update into the buffer of the subscriber, returns true if it was merged with the one pending.
+func (h *Hub) send(s *subscriber, update interfaces.Update) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return false
+	}
+	merged := false
+	select {
+	case pending := <-s.updates:
+		if update.Price == nil {
+			update.Price = pending.Price
+		}
+		if update.Spread == nil {
+			update.Spread = pending.Spread
+		}
+		if update.MarkPrice == nil {
+			update.MarkPrice = pending.MarkPrice
+		}
+		merged = true
+	default:
+	}
+	s.updates <- update // only publishers holding the lock send, the buffer is free now
+	return merged
+}
@@ -68,11 +68,13 @@ func Connect(url string, connectTimeout time.Duration) (*mongo.Client, error) {
 }
 
 type StateMgmt struct {
-	OrderCallbacks *sync.Map
-	Statsd         *statsd_client.StatsdClient
+	OrderCallbacks  *sync.Map
+	SignalCallbacks *sync.Map
+	Statsd          *statsd_client.StatsdClient
 }
 
 // InitOrdersWatch subscribes to orders updates and invokes StateMgnt callback on `filled` and `canceled` orders update event received.
+// Partial fills come as `open` orders with non-zero filled amount and are forwarded too.
 func (sm *StateMgmt) InitOrdersWatch() {
 	log.Info("watching for new orders in the storage")
 	sm.OrderCallbacks = &sync.Map{}
@@ -83,6 +85,7 @@ func (sm *StateMgmt) InitOrdersWatch() {
 		{"$match", bson.M{"$or": []interface{}{
 			bson.M{"fullDocument.status": "filled"},
 			bson.M{"fullDocument.status": "canceled"},
+			bson.M{"fullDocument.status": "open", "fullDocument.filled": bson.M{"$gt": 0}},
 		}},
 		},
 	}}
@@ -105,7 +108,8 @@ func (sm *StateMgmt) InitOrdersWatch() {
 			)
 		}
 		go func(event models.MongoOrderUpdateEvent) {
-			if event.FullDocument.Status == "filled" || event.FullDocument.Status == "canceled" {
+			isPartiallyFilled := event.FullDocument.Status == "open" && event.FullDocument.Filled > 0
+			if event.FullDocument.Status == "filled" || event.FullDocument.Status == "canceled" || isPartiallyFilled {
 				orderId := event.FullDocument.OrderId
 				if event.FullDocument.PostOnlyInitialOrderId != "" {
 					orderId = event.FullDocument.PostOnlyInitialOrderId
@@ -414,7 +418,8 @@ func (sm *StateMgmt) SaveStrategy(strategy *models.MongoStrategy) *models.MongoS
 	return strategy
 }
 
-func (sm *StateMgmt) CreateStrategy(strategy *models.MongoStrategy) *models.MongoStrategy {
+// CreateStrategy inserts the strategy, the error is returned if it's not written.
+func (sm *StateMgmt) CreateStrategy(strategy *models.MongoStrategy) (*models.MongoStrategy, error) {
 	t1 := time.Now()
 	log.Info("creating strategy")
 	CollName := "core_strategies"
@@ -425,7 +430,7 @@ func (sm *StateMgmt) CreateStrategy(strategy *models.MongoStrategy) *models.Mong
 		log.Error("", zap.Error(err))
 	}
 	sm.Statsd.TimingDuration("state_mgmt.create_strategy", time.Since(t1))
-	return strategy
+	return strategy, err
 }
 
 func (sm *StateMgmt) GetStrategy(strategyId *primitive.ObjectID) *models.MongoStrategy {
@@ -604,6 +609,133 @@ func (sm *StateMgmt) UpdateExecutedAmount(strategyId *primitive.ObjectID, state
 	sm.Statsd.TimingDuration("state_mgmt.update_executed_amount", time.Since(t1))
 }
 
+// UpdateAtr saves ATR frozen for the iteration.
+func (sm *StateMgmt) UpdateAtr(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	t1 := time.Now()
+	col := GetCollection("core_strategies")
+	request := bson.D{
+		{"_id", strategyId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"state.atr", state.Atr},
+				{"state.atrPrice", state.AtrPrice},
+				{"state.atrWaitSince", state.AtrWaitSince},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_atr", time.Since(t1))
+}
+
+// UpdateEntrySlices saves time sliced entry progress.
+func (sm *StateMgmt) UpdateEntrySlices(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	t1 := time.Now()
+	col := GetCollection("core_strategies")
+	request := bson.D{
+		{"_id", strategyId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"state.entrySlicesPlaced", state.EntrySlicesPlaced},
+				{"state.entrySlicesAmount", state.EntrySlicesAmount},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_entry_slices", time.Since(t1))
+}
+
+// UpdateReEntry saves re-entry attempts made after losses and their total loss.
+func (sm *StateMgmt) UpdateReEntry(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	t1 := time.Now()
+	col := GetCollection("core_strategies")
+	request := bson.D{
+		{"_id", strategyId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"state.reEntryAttempts", state.ReEntryAttempts},
+				{"state.reEntryLoss", state.ReEntryLoss},
+				{"state.reEntryAt", state.ReEntryAt},
+				{"state.reEntryBaseAmount", state.ReEntryBaseAmount},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_re_entry", time.Since(t1))
+}
+
+// UpdateCopyLeaderPrices saves leader entry and exit prices to the follower strategy, it releases the follower entry.
+func (sm *StateMgmt) UpdateCopyLeaderPrices(strategyId *primitive.ObjectID, entryPrice float64, exitPrice float64) {
+	t1 := time.Now()
+	col := GetCollection("core_strategies")
+	request := bson.D{
+		{"_id", strategyId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"conditions.copyLeaderEntryPrice", entryPrice},
+				{"conditions.copyLeaderExitPrice", exitPrice},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_copy_leader_prices", time.Since(t1))
+}
+
+// GetKeyAssetFree returns free balance of the key asset given, zero if it's not found.
+func (sm *StateMgmt) GetKeyAssetFree(keyAssetId *primitive.ObjectID) float64 {
+	t1 := time.Now()
+	col := GetCollection("core_key_assets")
+	request := bson.D{
+		{"_id", keyAssetId},
+	}
+	var keyAsset struct {
+		Free float64 `bson:"free"`
+	}
+	err := col.FindOne(context.TODO(), request).Decode(&keyAsset)
+	if err != nil {
+		log.Error("can't find a key asset", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.get_key_asset_free", time.Since(t1))
+	return keyAsset.Free
+}
+
+// GetKeyAssetEquity returns total balance of the key asset given, free and locked, zero if it's not found.
+func (sm *StateMgmt) GetKeyAssetEquity(keyAssetId *primitive.ObjectID) float64 {
+	t1 := time.Now()
+	col := GetCollection("core_key_assets")
+	request := bson.D{
+		{"_id", keyAssetId},
+	}
+	var keyAsset struct {
+		Total float64 `bson:"total"`
+	}
+	err := col.FindOne(context.TODO(), request).Decode(&keyAsset)
+	if err != nil {
+		log.Error("can't find a key asset", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.get_key_asset_equity", time.Since(t1))
+	return keyAsset.Total
+}
+
 // UpdateOrders tries to save new order IDs stored in a state provided into a strategy document specified by ID.
 func (sm *StateMgmt) UpdateOrders(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
 	t1 := time.Now()
@@ -637,6 +769,13 @@ func (sm *StateMgmt) UpdateOrders(strategyId *primitive.ObjectID, state *models.
 		}
 		update[0].Value = append(update[0].Value.(bson.D), executedOrdersUpdate)
 	}
+	if len(state.AlgorithmOrders) > 0 {
+		algorithmOrdersUpdate := bson.E{
+			Key: "state.algorithmOrders",
+			Value: bson.D{{"$each", state.AlgorithmOrders}},
+		}
+		update[0].Value = append(update[0].Value.(bson.D), algorithmOrdersUpdate)
+	}
 	// log.Debug("sending update order request",
 	// 	zap.Any("request", request),
 	// 	zap.Any("update", update),
@@ -687,6 +826,9 @@ func (sm *StateMgmt) UpdateEntryPrice(strategyId *primitive.ObjectID, state *mod
 				{
 					"state.positionAmount", state.PositionAmount,
 				},
+				{
+					"state.entryFilledAmount", state.EntryFilledAmount,
+				},
 			},
 		},
 	}
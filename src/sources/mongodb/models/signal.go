@@ -2,45 +2,81 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+type MongoSignalUpdateEvent struct {
+	FullDocument MongoSignal `json:"fullDocument" bson:"fullDocument"`
+}
+
 type MongoSignalEvent struct {
-	T    int64
-	Data interface{}
+	T    int64       `json:"t" bson:"t"`
+	Data interface{} `json:"data,omitempty" bson:"data"`
 }
 
+// A MongoSignal is a market condition published to release entries of strategies linked by SignalIds.
 type MongoSignal struct {
-	Id          primitive.ObjectID `json:"_id"`
-	MonType     MongoSignalType
-	Condition   MongoSignalCondition
-	TriggerWhen TriggerOptions
-	Expiration  ExpirationSchema
-	OpenEnded   bool
-	Events      []MongoSignalEvent
+	Id          primitive.ObjectID   `json:"_id" bson:"_id"`
+	Enabled     bool                 `json:"enabled,omitempty" bson:"enabled"`
+	MonType     MongoSignalType      `json:"monType,omitempty" bson:"monType"`
+	Condition   MongoSignalCondition `json:"condition,omitempty" bson:"condition"`
+	TriggerWhen TriggerOptions       `json:"triggerWhen,omitempty" bson:"triggerWhen"`
+	Expiration  ExpirationSchema     `json:"expiration,omitempty" bson:"expiration"`
+	OpenEnded   bool                 `json:"openEnded,omitempty" bson:"openEnded"` // re-arm after firing instead of disabling
+	Events      []MongoSignalEvent   `json:"events,omitempty" bson:"events"`
+	State       *MongoSignalState    `json:"state,omitempty" bson:"state"`
 }
 
+// MaxSignalEvents is how many latest fire events a signal keeps.
+const MaxSignalEvents = 100
+
+// MongoSignalType.SigType values, the Required field is not used by the service.
+const (
+	SignalTypePrice         = "price"         // close price reaches Condition.TargetPrice
+	SignalTypePercentChange = "percentChange" // close price moves by Condition.PercentChange from reference price
+	SignalTypeSpread        = "spread"        // bid-ask spread in percents reaches Condition.Spread
+	SignalTypeWebhook       = "webhook"       // fired by external push only
+)
+
 type MongoSignalType struct {
-	SigType  string `json:"type"`
-	Required interface{}
+	SigType  string      `json:"type" bson:"type"`
+	Required interface{} `json:"required,omitempty" bson:"required"`
 }
 
 type MongoSignalCondition struct {
-	TargetPrice   float64
-	Symbol        string
-	PortfolioId   primitive.ObjectID
-	PercentChange float64
-	Price         float64
-	Amount        float64
-	Spread        float64
-	ExchangeId    primitive.ObjectID
-	ExchangeIds   []primitive.ObjectID
-	Pair          string
+	TargetPrice   float64              `json:"targetPrice,omitempty" bson:"targetPrice"`
+	Symbol        string               `json:"symbol,omitempty" bson:"symbol"`
+	PortfolioId   primitive.ObjectID   `json:"portfolioId,omitempty" bson:"portfolioId"`
+	PercentChange float64              `json:"percentChange,omitempty" bson:"percentChange"` // signed, negative means a drop
+	Price         float64              `json:"price,omitempty" bson:"price"`                 // reference price for percent change, current price if empty
+	Amount        float64              `json:"amount,omitempty" bson:"amount"`
+	Spread        float64              `json:"spread,omitempty" bson:"spread"`
+	ExchangeId    primitive.ObjectID   `json:"exchangeId,omitempty" bson:"exchangeId"`
+	ExchangeIds   []primitive.ObjectID `json:"exchangeIds,omitempty" bson:"exchangeIds"`
+	Pair          string               `json:"pair,omitempty" bson:"pair"`
+	Exchange      string               `json:"exchange,omitempty" bson:"exchange"`
+	MarketType    int64                `json:"marketType,omitempty" bson:"marketType"`
 }
 
+// A MongoSignalState is a set of dynamic parameters for a signal.
+type MongoSignalState struct {
+	ReferencePrice float64 `json:"referencePrice,omitempty" bson:"referencePrice"`
+	HoldingSince   int64   `json:"holdingSince,omitempty" bson:"holdingSince"` // when condition met first time in a row
+	FiredAt        int64   `json:"firedAt,omitempty" bson:"firedAt"`
+	FiredPrice     float64 `json:"firedPrice,omitempty" bson:"firedPrice"`
+	FiredBy        string  `json:"firedBy,omitempty" bson:"firedBy"` // "feed" or "webhook"
+	FiredCount     int64   `json:"firedCount,omitempty" bson:"firedCount"`
+}
+
+// TriggerOptions.TrigType values for price signals, crossing in any direction if empty.
+const (
+	TriggerAbove = "above"
+	TriggerBelow = "below"
+)
+
 type TriggerOptions struct {
-	TrigType string `json:"type"`
-	Period   int64
+	TrigType string `json:"type" bson:"type"`
+	Period   int64  `json:"period,omitempty" bson:"period"` // seconds condition should hold before firing
 }
 
 type ExpirationSchema struct {
-	expirationTimestamp int64
-	openEnded           bool
+	ExpirationTimestamp int64 `json:"expirationTimestamp,omitempty" bson:"expirationTimestamp"` // unix seconds, never expires if zero
+	OpenEnded           bool  `json:"openEnded,omitempty" bson:"openEnded"`
 }
@@ -112,7 +112,7 @@ type MongoPosition struct {
 // A MongoStrategy is the root of a smart trade strategy description.
 type MongoStrategy struct {
 	ID              *primitive.ObjectID     `json:"_id" bson:"_id"`             // strategy unique identity
-	Type            int64                   `json:"type,omitempty" bson:"type"` // 1 - smart order, 2 - maker only
+	Type            int64                   `json:"type,omitempty" bson:"type"` // 1 - smart order, 2 - maker only, 3 - grid, 4 - pair trading
 	Enabled         bool                    `json:"enabled,omitempty" bson:"enabled"`
 	AccountId       *primitive.ObjectID     `json:"accountId,omitempty" bson:"accountId"`
 	Conditions      *MongoStrategyCondition `json:"conditions,omitempty" bson:"conditions"`
@@ -190,6 +190,85 @@ type MongoStrategyState struct {
 	PositionAmount           float64 `json:"positionAmount,omitempty" bson:"positionAmount"`
 	ReceivedProfitAmount     float64 `json:"receivedProfitAmount,omitempty" bson:"receivedProfitAmount"`
 	ReceivedProfitPercentage float64 `json:"receivedProfitPercentage,omitempty" bson:"receivedProfitPercentage"`
+
+	// Signal firing released the entry, the entry is released for the iteration given only.
+	SignalId        *primitive.ObjectID `json:"signalId,omitempty" bson:"signalId"`
+	SignalFiredAt   int64               `json:"signalFiredAt,omitempty" bson:"signalFiredAt"`
+	SignalIteration int                 `json:"signalIteration,omitempty" bson:"signalIteration"`
+	// Signals fired before the iteration started don't release its entry, the first one starts on creation.
+	IterationStartedAt int64 `json:"iterationStartedAt,omitempty" bson:"iterationStartedAt"`
+
+	// ATR frozen for the iteration with the price it was measured at, so restarts keep stops and targets the same,
+	// and the time ATR is waited for since, so restarts don't prolong the warmup.
+	Atr          float64 `json:"atr,omitempty" bson:"atr"`
+	AtrPrice     float64 `json:"atrPrice,omitempty" bson:"atrPrice"`
+	AtrWaitSince int64   `json:"atrWaitSince,omitempty" bson:"atrWaitSince"`
+
+	// Time sliced entry progress: slices passed including skipped ones and amount placed by them.
+	EntrySlicesPlaced int     `json:"entrySlicesPlaced,omitempty" bson:"entrySlicesPlaced"`
+	EntrySlicesAmount float64 `json:"entrySlicesAmount,omitempty" bson:"entrySlicesAmount"`
+
+	// Entry amount filled when the entry fills in parts by slices or partial fills, exits close this amount.
+	EntryFilledAmount float64 `json:"entryFilledAmount,omitempty" bson:"entryFilledAmount"`
+
+	// Re-entries done after losses in a row, total loss of them, when the last loss closed and the first entry amount.
+	ReEntryAttempts   int64   `json:"reEntryAttempts,omitempty" bson:"reEntryAttempts"`
+	ReEntryLoss       float64 `json:"reEntryLoss,omitempty" bson:"reEntryLoss"`
+	ReEntryAt         int64   `json:"reEntryAt,omitempty" bson:"reEntryAt"`
+	ReEntryBaseAmount float64 `json:"reEntryBaseAmount,omitempty" bson:"reEntryBaseAmount"`
+
+	// Copy-trading follower prices against the leader ones in percents, positive is worse for the follower.
+	CopyEntrySlippage float64 `json:"copyEntrySlippage,omitempty" bson:"copyEntrySlippage"`
+	CopyExitSlippage  float64 `json:"copyExitSlippage,omitempty" bson:"copyExitSlippage"`
+
+	// Orders executed by execution algorithms, their cancels go to the algorithm of the order type after restarts too.
+	AlgorithmOrders []*MongoAlgorithmOrder `json:"algorithmOrders,omitempty" bson:"algorithmOrders"`
+	// Break-even exit executed by an algorithm once the price gets to it: the price, amount to close and whether
+	// the price gets back to break-even (stop) or reaches it (take-profit).
+	WithoutLossPrice  float64 `json:"withoutLossPrice,omitempty" bson:"withoutLossPrice"`
+	WithoutLossAmount float64 `json:"withoutLossAmount,omitempty" bson:"withoutLossAmount"`
+	WithoutLossStop   bool    `json:"withoutLossStop,omitempty" bson:"withoutLossStop"`
+
+	// Maker-only chase: the first price posted at, the current one and when the chase started and re-priced last.
+	MakerInitialPrice   float64 `json:"makerInitialPrice,omitempty" bson:"makerInitialPrice"`
+	MakerPrice          float64 `json:"makerPrice,omitempty" bson:"makerPrice"`
+	MakerChaseStartedAt int64   `json:"makerChaseStartedAt,omitempty" bson:"makerChaseStartedAt"`
+	MakerRepricedAt     int64   `json:"makerRepricedAt,omitempty" bson:"makerRepricedAt"`
+	// Maker-only orders filled so far, their total sets executed amount and entry price.
+	MakerFills []*MongoMakerFill `json:"makerFills,omitempty" bson:"makerFills"`
+
+	// Grid orders open, the position grid fills hold (negative for short) and profit of completed round trips.
+	GridOrders      []*MongoGridOrder `json:"gridOrders,omitempty" bson:"gridOrders"`
+	GridPosition    float64           `json:"gridPosition,omitempty" bson:"gridPosition"`
+	GridRealizedPnl float64           `json:"gridRealizedPnl,omitempty" bson:"gridRealizedPnl"`
+	GridTrades      int64             `json:"gridTrades,omitempty" bson:"gridTrades"`
+
+	// Pair trading legs of the current iteration and the spread side: 1 for long A short B, -1 for short A long B.
+	PairLegs []*MongoPairLeg `json:"pairLegs,omitempty" bson:"pairLegs"`
+	PairSide int64           `json:"pairSide,omitempty" bson:"pairSide"`
+}
+
+// A MongoGridOrder is a limit order placed by grid on the level, closing orders keep the price they close the fill at.
+type MongoGridOrder struct {
+	OrderId    string  `json:"orderId,omitempty" bson:"orderId"`
+	Level      int     `json:"level" bson:"level"`
+	Side       string  `json:"side,omitempty" bson:"side"`
+	Price      float64 `json:"price,omitempty" bson:"price"`
+	Amount     float64 `json:"amount,omitempty" bson:"amount"`
+	EntryPrice float64 `json:"entryPrice,omitempty" bson:"entryPrice"`
+	Attempts   int     `json:"attempts,omitempty" bson:"attempts"` // failed placements of the order without id, retried at RetryAt
+	RetryAt    int64   `json:"retryAt,omitempty" bson:"retryAt"`
+}
+
+// A MongoPairLeg is a position on one pair of pair trading, filled amounts are what is hedged after reconciliation.
+type MongoPairLeg struct {
+	Pair       string  `json:"pair,omitempty" bson:"pair"`
+	Side       string  `json:"side,omitempty" bson:"side"`
+	Amount     float64 `json:"amount,omitempty" bson:"amount"`
+	Filled     float64 `json:"filled,omitempty" bson:"filled"`
+	EntryPrice float64 `json:"entryPrice,omitempty" bson:"entryPrice"`
+	Closed     float64 `json:"closed,omitempty" bson:"closed"`
+	ExitPrice  float64 `json:"exitPrice,omitempty" bson:"exitPrice"`
 }
 
 type MongoEntryPoint struct {
@@ -220,6 +299,17 @@ type MongoStrategyCondition struct {
 
 	MakerOrderId *primitive.ObjectID `json:"makerOrderId,omitempty" bson:"makerOrderId"`
 
+	// Maker-only chase limits: max adverse distance from the initial price in percents, max chase duration and
+	// re-price interval in seconds, what to do once a limit is hit.
+	MakerMaxDistance     float64 `json:"makerMaxDistance,omitempty" bson:"makerMaxDistance"`
+	MakerMaxChaseTime    int64   `json:"makerMaxChaseTime,omitempty" bson:"makerMaxChaseTime"`
+	MakerRepriceInterval int64   `json:"makerRepriceInterval,omitempty" bson:"makerRepriceInterval"`
+	MakerFallback        string  `json:"makerFallback,omitempty" bson:"makerFallback"`
+
+	// TriggerPriceSource is the price futures price triggers compare against and exchange stop orders work by, the
+	// last trade price if empty. Futures smart orders reject index, exchange stop orders have no index working type.
+	TriggerPriceSource string `json:"triggerPriceSource,omitempty" bson:"triggerPriceSource"`
+
 	TemplateToken          string              `json:"templateToken,omitempty" bson:"templateToken"`
 	MandatoryForcedLoss    bool                `json:"mandatoryForcedLoss,omitempty" bson:"mandatoryForcedLoss"`
 	PositionWasClosed      bool                `json:"positionWasClosed, omitempty" bson:"positionWasClosed"`
@@ -236,9 +326,12 @@ type MongoStrategyCondition struct {
 	TakeProfitExternal     bool                `json:"takeProfitExternal,omitempty" bson:"takeProfitExternal"`
 	WithoutLossAfterProfit float64             `json:"withoutLossAfterProfit,omitempty" bson:"withoutLossAfterProfit"`
 	EntrySpreadHunter      bool                `json:"entrySpreadHunter,omitempty" bson:"entrySpreadHunter"`
-	EntryWaitingTime       int64               `json:"entryWaitingTime,omitempty" bson:"entryWaitingTime"`
+	EntryWaitingTime       int64               `json:"entryWaitingTime,omitempty" bson:"entryWaitingTime"` // ms spread entry order rests before repricing
 	TakeProfitSpreadHunter bool                `json:"takeProfitSpreadHunter,omitempty" bson:"takeProfitSpreadHunter"`
-	TakeProfitWaitingTime  int64               `json:"takeProfitWaitingTime,omitempty" bson:"takeProfitWaitingTime"`
+	TakeProfitWaitingTime  int64               `json:"takeProfitWaitingTime,omitempty" bson:"takeProfitWaitingTime"` // ms spread take-profit order rests before repricing
+	SpreadHunterMinSpread  float64             `json:"spreadHunterMinSpread,omitempty" bson:"spreadHunterMinSpread"` // relative spread to hunt, 0.0012 if not set
+	SpreadHunterOffset     float64             `json:"spreadHunterOffset,omitempty" bson:"spreadHunterOffset"`       // share of spread to step inside from the best price, up to 0.5
+	SpreadHunterMinQty     float64             `json:"spreadHunterMinQty,omitempty" bson:"spreadHunterMinQty"`       // quantity at the best price required to join it
 	KeyAssetId             *primitive.ObjectID `json:"keyAssetId,omitempty" bson:"keyAssetId"`
 	Pair                   string              `json:"pair,omitempty" bson:"pair"`
 	MarketType             int64               `json:"marketType,omitempty" bson:"marketType"`
@@ -267,6 +360,59 @@ type MongoStrategyCondition struct {
 	ForcedLoss            float64 `json:"forcedLoss,omitempty" bson:"forcedLoss"`
 	HedgeLossDeviation    float64 `json:"hedgeLossDeviation,omitempty" bson:"hedgeLossDeviation"`
 
+	// Risk based sizing: entry amount is such that stop-loss hit loses the risk given, fees and slippage included.
+	RiskPercent float64 `json:"riskPercent,omitempty" bson:"riskPercent"` // risk per trade in percents of equity, the total balance
+	RiskAmount  float64 `json:"riskAmount,omitempty" bson:"riskAmount"`   // risk per trade in quote currency, preferred if set
+	FeeEstimate float64 `json:"feeEstimate,omitempty" bson:"feeEstimate"` // fee in percents per side, taker fee if zero
+	Slippage    float64 `json:"slippage,omitempty" bson:"slippage"`       // expected stop-loss slippage in percents
+	MaxNotional float64 `json:"maxNotional,omitempty" bson:"maxNotional"` // position value cap in quote currency
+
+	// ATR mode: stop-loss, forced loss, trailing deviations and relative targets are multiples of ATR.
+	AtrMode      bool    `json:"atrMode,omitempty" bson:"atrMode"`
+	AtrTimeframe int64   `json:"atrTimeframe,omitempty" bson:"atrTimeframe"` // candle size in seconds
+	AtrPeriod    int64   `json:"atrPeriod,omitempty" bson:"atrPeriod"`
+	AtrWarmup    int64   `json:"atrWarmup,omitempty" bson:"atrWarmup"`     // seconds entry waits for ATR, timeframe × (period + 1) if zero
+	AtrFallback  float64 `json:"atrFallback,omitempty" bson:"atrFallback"` // ATR in percents of price assumed after the warmup, entry keeps waiting if zero
+
+	// Time sliced entry: entry amount is split into slices placed evenly over the duration, exits follow the position.
+	EntrySlices              int64   `json:"entrySlices,omitempty" bson:"entrySlices"`                           // sliced entry if more than one
+	EntrySlicesDuration      int64   `json:"entrySlicesDuration,omitempty" bson:"entrySlicesDuration"`           // seconds
+	EntrySlicesRandomization float64 `json:"entrySlicesRandomization,omitempty" bson:"entrySlicesRandomization"` // percents intervals and amounts vary by
+	EntrySlicesPriceLimit    float64 `json:"entrySlicesPriceLimit,omitempty" bson:"entrySlicesPriceLimit"`       // cap for buy or floor for sell, slices beyond are skipped
+
+	// Re-entry after the position closed at a loss, once the cooldown passed and the price reclaimed the level if set.
+	ReEntryAttempts   int64   `json:"reEntryAttempts,omitempty" bson:"reEntryAttempts"`     // re-entries allowed after losses in a row
+	ReEntryPrice      float64 `json:"reEntryPrice,omitempty" bson:"reEntryPrice"`           // long re-enters at or above it, short at or below
+	ReEntryCooldown   int64   `json:"reEntryCooldown,omitempty" bson:"reEntryCooldown"`     // seconds
+	ReEntryAmountRate float64 `json:"reEntryAmountRate,omitempty" bson:"reEntryAmountRate"` // entry amount of an attempt relative to the previous one, 1 if not set
+	ReEntryMaxLoss    float64 `json:"reEntryMaxLoss,omitempty" bson:"reEntryMaxLoss"`       // no more attempts once their total loss reaches it
+
+	// Copy-trading: followers mirror the strategy on their keys, a follower enters once its leader entered and exits with it.
+	Followers            []*MongoCopyFollower `json:"followers,omitempty" bson:"followers"`
+	CopyLeaderId         *primitive.ObjectID  `json:"copyLeaderId,omitempty" bson:"copyLeaderId"`
+	CopyLeaderEntryPrice float64              `json:"copyLeaderEntryPrice,omitempty" bson:"copyLeaderEntryPrice"`
+	CopyLeaderExitPrice  float64              `json:"copyLeaderExitPrice,omitempty" bson:"copyLeaderExitPrice"`
+
+	// Grid strategy: limit orders on levels evenly spaced from the lower to the upper price.
+	GridLowerPrice     float64 `json:"gridLowerPrice,omitempty" bson:"gridLowerPrice"`
+	GridUpperPrice     float64 `json:"gridUpperPrice,omitempty" bson:"gridUpperPrice"`
+	GridLevels         int64   `json:"gridLevels,omitempty" bson:"gridLevels"`
+	GridAmount         float64 `json:"gridAmount,omitempty" bson:"gridAmount"`                 // base amount of each level order
+	GridBias           string  `json:"gridBias,omitempty" bson:"gridBias"`                     // "neutral", "long" or "short", spot is always long
+	GridStopLowerPrice float64 `json:"gridStopLowerPrice,omitempty" bson:"gridStopLowerPrice"` // close everything below
+	GridStopUpperPrice float64 `json:"gridStopUpperPrice,omitempty" bson:"gridStopUpperPrice"` // close everything above
+
+	// Pair trading: long Pair and short PairB or the other way round on z-score of the spread between them.
+	PairB           string  `json:"pairB,omitempty" bson:"pairB"`
+	PairSpreadMode  string  `json:"pairSpreadMode,omitempty" bson:"pairSpreadMode"`   // "spread" for A - hedge ratio * B (default) or "ratio" for A / B
+	PairHedgeRatio  float64 `json:"pairHedgeRatio,omitempty" bson:"pairHedgeRatio"`   // units of B per unit of A, regression over the lookback if zero
+	PairAmount      float64 `json:"pairAmount,omitempty" bson:"pairAmount"`           // base amount of A leg
+	PairTimeframe   int64   `json:"pairTimeframe,omitempty" bson:"pairTimeframe"`     // seconds, 60 by default
+	PairLookback    int64   `json:"pairLookback,omitempty" bson:"pairLookback"`       // candles, 20 by default
+	PairEntryZScore float64 `json:"pairEntryZScore,omitempty" bson:"pairEntryZScore"` // enter when z-score is beyond it, 2 by default
+	PairExitZScore  float64 `json:"pairExitZScore,omitempty" bson:"pairExitZScore"`   // exit when z-score reverts within it
+	PairStopZScore  float64 `json:"pairStopZScore,omitempty" bson:"pairStopZScore"`   // exit when z-score diverges beyond it
+
 	CreatedByTemplate  bool                `json:"createdByTemplate,omitempty" bson:"createdByTemplate"`
 	TemplateStrategyId *primitive.ObjectID `json:"templateStrategyId,omitempty" bson:"templateStrategyId"`
 
@@ -275,4 +421,67 @@ type MongoStrategyCondition struct {
 	ExitLevels                 []*MongoEntryPoint `json:"exitLevels,omitempty" bson:"exitLevels"`
 	CloseStrategyAfterFirstTAP bool               `json:"closeStrategyAfterFirstTAP,omitempty" bson:"closeStrategyAfterFirstTAP"`
 	PlaceEntryAfterTAP         bool               `json:"placeEntryAfterTAP,omitempty" bson:"placeEntryAfterTAP"`
+
+	// All entry indicators should hold to place an entry, all exit indicators should hold to exit at market.
+	EntryIndicators []*MongoIndicatorCondition `json:"entryIndicators,omitempty" bson:"entryIndicators"`
+	ExitIndicators  []*MongoIndicatorCondition `json:"exitIndicators,omitempty" bson:"exitIndicators"`
+}
+
+// MongoStrategyCondition.MakerFallback values, the maker-only order keeps chasing if empty.
+const (
+	MakerFallbackMarket = "market" // take the rest at market
+	MakerFallbackIOC    = "ioc"    // immediate-or-cancel limit at the max distance, at the best price if not set
+	MakerFallbackCancel = "cancel" // cancel the order
+)
+
+// MongoStrategyCondition.TriggerPriceSource values.
+const (
+	TriggerPriceLast  = "last"  // the last trade price
+	TriggerPriceMark  = "mark"  // the mark price futures are liquidated by
+	TriggerPriceIndex = "index" // the index price of spot exchanges, rejected by futures smart orders
+)
+
+// A MongoAlgorithmOrder is an order executed by the execution algorithm of its order type.
+type MongoAlgorithmOrder struct {
+	OrderId   string `json:"orderId,omitempty" bson:"orderId"`
+	OrderType string `json:"orderType,omitempty" bson:"orderType"`
+}
+
+// A MongoMakerFill is the amount a post-only or fallback order of maker-only order filled at its average price.
+type MongoMakerFill struct {
+	OrderId string  `json:"orderId,omitempty" bson:"orderId"`
+	Filled  float64 `json:"filled,omitempty" bson:"filled"`
+	Average float64 `json:"average,omitempty" bson:"average"`
+}
+
+// MongoCopyFollower.SizeMode values.
+const (
+	CopySizeMultiplier   = "multiplier"   // leader amount multiplied
+	CopySizeNotional     = "notional"     // fixed position value in quote currency
+	CopySizeBalanceRatio = "balanceRatio" // leader amount scaled by follower to leader free balance
+)
+
+// A MongoCopyFollower is a key mirroring the leader strategy, sized by the mode given within the follower risk caps.
+type MongoCopyFollower struct {
+	KeyId        *primitive.ObjectID `json:"keyId,omitempty" bson:"keyId"`
+	KeyAssetId   *primitive.ObjectID `json:"keyAssetId,omitempty" bson:"keyAssetId"` // free balance for balance ratio sizing
+	SizeMode     string              `json:"sizeMode,omitempty" bson:"sizeMode"`     // multiplier if empty
+	Multiplier   float64             `json:"multiplier,omitempty" bson:"multiplier"` // 1 if not set
+	Notional     float64             `json:"notional,omitempty" bson:"notional"`
+	MaxNotional  float64             `json:"maxNotional,omitempty" bson:"maxNotional"`   // position value cap in quote currency
+	MaxLeverage  float64             `json:"maxLeverage,omitempty" bson:"maxLeverage"`   // leverage cap
+	MaxStopLoss  float64             `json:"maxStopLoss,omitempty" bson:"maxStopLoss"`   // stop-loss cap, the follower gets it if the leader has no stop-loss
+	StrategyId   *primitive.ObjectID `json:"strategyId,omitempty" bson:"strategyId"`     // follower strategy of the current leader iteration
+	LeaderAmount float64             `json:"leaderAmount,omitempty" bson:"leaderAmount"` // leader entry amount the follower is sized for
+	Amount       float64             `json:"amount,omitempty" bson:"amount"`
+}
+
+// A MongoIndicatorCondition compares a technical indicator computed over candles aggregated from the price feed.
+type MongoIndicatorCondition struct {
+	Indicator  string  `json:"indicator,omitempty" bson:"indicator"`   // "sma", "ema", "rsi", "bollinger" or "vwap"
+	Timeframe  int64   `json:"timeframe,omitempty" bson:"timeframe"`   // candle size in seconds
+	Period     int64   `json:"period,omitempty" bson:"period"`         // fast period for moving averages crosses
+	SlowPeriod int64   `json:"slowPeriod,omitempty" bson:"slowPeriod"` // moving average compared with price if zero
+	Operator   string  `json:"operator,omitempty" bson:"operator"`     // "above", "below", "crossAbove" or "crossBelow"
+	Value      float64 `json:"value,omitempty" bson:"value"`           // rsi level, bollinger deviations or vwap distance in percents
 }
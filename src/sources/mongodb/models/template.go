@@ -0,0 +1,52 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type MongoStrategyTemplateUpdateEvent struct {
+	FullDocument MongoStrategyTemplate `json:"fullDocument" bson:"fullDocument"`
+}
+
+// A MongoStrategyTemplate spawns child strategies by the rule given, children are linked back by TemplateStrategyId.
+type MongoStrategyTemplate struct {
+	Id           primitive.ObjectID  `json:"_id" bson:"_id"`
+	Enabled      bool                `json:"enabled,omitempty" bson:"enabled"`
+	AccountId    *primitive.ObjectID `json:"accountId,omitempty" bson:"accountId"`
+	OwnerId      primitive.ObjectID  `json:"ownerId,omitempty" bson:"ownerId"`
+	StrategyType int64               `json:"strategyType,omitempty" bson:"strategyType"` // children type, smart order if empty
+	// Children conditions document, string values may hold "{{name}}" placeholders filled on spawn.
+	Conditions       map[string]interface{}      `json:"conditions,omitempty" bson:"conditions"`
+	Params           map[string]interface{}      `json:"params,omitempty" bson:"params"` // placeholder values given by user
+	Spawn            MongoTemplateSpawn          `json:"spawn,omitempty" bson:"spawn"`
+	MaxActive        int64                       `json:"maxActive,omitempty" bson:"maxActive"`               // children enabled at once, unlimited if zero
+	MaxActivePerPair int64                       `json:"maxActivePerPair,omitempty" bson:"maxActivePerPair"` // children enabled at once on a pair, unlimited if zero
+	MaxChildren      int64                       `json:"maxChildren,omitempty" bson:"maxChildren"`           // children spawned in total, unlimited if zero
+	State            *MongoStrategyTemplateState `json:"state,omitempty" bson:"state"`
+}
+
+// MongoTemplateSpawn.Rule values.
+const (
+	TemplateSpawnBySignal   = "signal"   // a child per pair each time the signal fires
+	TemplateSpawnBySchedule = "schedule" // a child per pair each interval
+	TemplateSpawnByPairs    = "pairs"    // a child kept enabled on each pair
+)
+
+type MongoTemplateSpawn struct {
+	Rule     string              `json:"rule,omitempty" bson:"rule"`
+	SignalId *primitive.ObjectID `json:"signalId,omitempty" bson:"signalId"`
+	Interval int64               `json:"interval,omitempty" bson:"interval"` // seconds between schedule spawns
+	Pairs    []string            `json:"pairs,omitempty" bson:"pairs"`       // conditions pair or fired signal pair if empty
+}
+
+// A MongoStrategyTemplateState is spawn progress and children results aggregated.
+type MongoStrategyTemplateState struct {
+	StartedAt     int64   `json:"startedAt,omitempty" bson:"startedAt"`
+	LastSpawnAt   int64   `json:"lastSpawnAt,omitempty" bson:"lastSpawnAt"`
+	SignalFiredAt int64   `json:"signalFiredAt,omitempty" bson:"signalFiredAt"` // the latest signal firing handled
+	Spawned       int64   `json:"spawned,omitempty" bson:"spawned"`
+	Active        int64   `json:"active,omitempty" bson:"active"`
+	Pnl           float64 `json:"pnl,omitempty" bson:"pnl"`   // received by all the children
+	Wins          int64   `json:"wins,omitempty" bson:"wins"` // children ended in profit
+	Losses        int64   `json:"losses,omitempty" bson:"losses"`
+	WinRate       float64 `json:"winRate,omitempty" bson:"winRate"`         // percent of wins among children ended in profit or loss
+	MaxDrawdown   float64 `json:"maxDrawdown,omitempty" bson:"maxDrawdown"` // the largest fall of ended children pnl sum from its peak
+}
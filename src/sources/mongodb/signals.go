@@ -0,0 +1,215 @@
+package mongodb
+
+import (
+	"context"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// signalSubscribers holds callbacks of all strategies linked to the same signal by subscriber id.
+type signalSubscribers struct {
+	mux       sync.Mutex
+	callbacks map[string]func(signal *models.MongoSignal)
+}
+
+func (sm *StateMgmt) getSignalSubscribers(signalId *primitive.ObjectID) *signalSubscribers {
+	subscribersRaw, _ := sm.SignalCallbacks.LoadOrStore(signalId.Hex(), &signalSubscribers{
+		callbacks: map[string]func(signal *models.MongoSignal){},
+	})
+	return subscribersRaw.(*signalSubscribers)
+}
+
+// InitSignalsWatch subscribes to signals updates and invokes StateMgmt callbacks on fired signals update event received.
+func (sm *StateMgmt) InitSignalsWatch() {
+	log.Info("watching for signals in the storage")
+	ctx := context.Background()
+	var coll = GetCollection("core_signals")
+	pipeline := mongo.Pipeline{bson.D{
+		{"$match", bson.M{"fullDocument.state.firedAt": bson.M{"$gt": 0}}},
+	}}
+	cs, err := coll.Watch(ctx, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		panic(err.Error())
+	}
+	defer cs.Close(ctx)
+	for cs.Next(ctx) {
+		var event models.MongoSignalUpdateEvent
+		err := cs.Decode(&event)
+		if err != nil {
+			log.Error("signal event decode", zap.Error(err))
+			continue
+		}
+		go sm.notifySignalSubscribers(&event.FullDocument)
+	}
+	log.Fatal("signals watch")
+}
+
+func (sm *StateMgmt) notifySignalSubscribers(signal *models.MongoSignal) {
+	subscribers := sm.getSignalSubscribers(&signal.Id)
+	subscribers.mux.Lock()
+	callbacks := make([]func(signal *models.MongoSignal), 0, len(subscribers.callbacks))
+	for _, callback := range subscribers.callbacks {
+		callbacks = append(callbacks, callback)
+	}
+	subscribers.mux.Unlock()
+	log.Info("signal fired",
+		zap.String("signalId", signal.Id.Hex()),
+		zap.Int("subscribers", len(callbacks)),
+	)
+	for _, callback := range callbacks {
+		callback(signal)
+	}
+}
+
+// SubscribeToSignal stores a callback of the subscriber to invoke each time the signal fires, the callback invoked
+// instantly if the signal fired already. Subscribing again replaces the callback of the subscriber.
+func (sm *StateMgmt) SubscribeToSignal(signalId *primitive.ObjectID, subscriberId string, onSignalFired func(signal *models.MongoSignal)) error {
+	subscribers := sm.getSignalSubscribers(signalId)
+	subscribers.mux.Lock()
+	subscribers.callbacks[subscriberId] = onSignalFired
+	subscribers.mux.Unlock()
+	signal := sm.GetSignal(signalId)
+	log.Info("subscribing to signal",
+		zap.String("signalId", signalId.Hex()),
+		zap.Bool("signal is nil", signal == nil),
+	)
+	if signal != nil && signal.State != nil && signal.State.FiredAt > 0 {
+		onSignalFired(signal)
+	}
+	return nil
+}
+
+// UnsubscribeFromSignal removes the callback of the subscriber, so strategies stopped are not notified any more.
+func (sm *StateMgmt) UnsubscribeFromSignal(signalId *primitive.ObjectID, subscriberId string) {
+	subscribers := sm.getSignalSubscribers(signalId)
+	subscribers.mux.Lock()
+	delete(subscribers.callbacks, subscriberId)
+	subscribers.mux.Unlock()
+	log.Info("unsubscribed from signal",
+		zap.String("signalId", signalId.Hex()),
+		zap.String("subscriberId", subscriberId),
+	)
+}
+
+func (sm *StateMgmt) GetSignal(signalId *primitive.ObjectID) *models.MongoSignal {
+	t1 := time.Now()
+	ctx := context.Background()
+	request := bson.D{
+		{"_id", signalId},
+	}
+	var coll = GetCollection("core_signals")
+
+	var signal *models.MongoSignal
+	err := coll.FindOne(ctx, request).Decode(&signal)
+	if err != nil {
+		log.Error("", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.get_signal", time.Since(t1))
+	return signal
+}
+
+// UpdateSignalState saves evaluation state of the signal not yet fired.
+func (sm *StateMgmt) UpdateSignalState(signalId *primitive.ObjectID, state *models.MongoSignalState) {
+	t1 := time.Now()
+	col := GetCollection("core_signals")
+	request := bson.D{
+		{"_id", signalId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{
+					"state", state,
+				},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_signal_state", time.Since(t1))
+}
+
+// FireSignal saves the signal fired with its state, enabled flag and the latest event appended, keeping
+// models.MaxSignalEvents latest events.
+func (sm *StateMgmt) FireSignal(signal *models.MongoSignal) {
+	t1 := time.Now()
+	col := GetCollection("core_signals")
+	request := bson.D{
+		{"_id", signal.Id},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"state", signal.State},
+				{"enabled", signal.Enabled},
+			},
+		},
+	}
+	if len(signal.Events) > 0 {
+		update = append(update, bson.E{Key: "$push", Value: bson.D{
+			{"events", bson.D{
+				{"$each", []models.MongoSignalEvent{signal.Events[len(signal.Events)-1]}},
+				{"$slice", -models.MaxSignalEvents},
+			}},
+		}})
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.fire_signal", time.Since(t1))
+}
+
+// DisableSignal saves the signal disabled with its state, no event is recorded.
+func (sm *StateMgmt) DisableSignal(signalId *primitive.ObjectID, state *models.MongoSignalState) {
+	t1 := time.Now()
+	col := GetCollection("core_signals")
+	request := bson.D{
+		{"_id", signalId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"state", state},
+				{"enabled", false},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.disable_signal", time.Since(t1))
+}
+
+// UpdateEntrySignal saves which signal firing released the strategy entry and when the iteration waiting for it started.
+func (sm *StateMgmt) UpdateEntrySignal(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	t1 := time.Now()
+	col := GetCollection("core_strategies")
+	request := bson.D{
+		{"_id", strategyId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{"state.signalId", state.SignalId},
+				{"state.signalFiredAt", state.SignalFiredAt},
+				{"state.signalIteration", state.SignalIteration},
+				{"state.iterationStartedAt", state.IterationStartedAt},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_entry_signal", time.Since(t1))
+}
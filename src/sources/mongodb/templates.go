@@ -0,0 +1,55 @@
+package mongodb
+
+import (
+	"context"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"time"
+)
+
+// GetTemplateChildren loads all the strategies spawned by the template given.
+func (sm *StateMgmt) GetTemplateChildren(templateId *primitive.ObjectID) []*models.MongoStrategy {
+	t1 := time.Now()
+	ctx := context.Background()
+	request := bson.D{
+		{"conditions.templateStrategyId", templateId},
+	}
+	var coll = GetCollection("core_strategies")
+	children := make([]*models.MongoStrategy, 0)
+	cur, err := coll.Find(ctx, request)
+	if err != nil {
+		log.Error("can't read template children", zap.Error(err))
+		return children
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &children); err != nil {
+		log.Error("template children decode", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.get_template_children", time.Since(t1))
+	return children
+}
+
+// UpdateTemplateState saves spawn progress and children results aggregated.
+func (sm *StateMgmt) UpdateTemplateState(templateId *primitive.ObjectID, state *models.MongoStrategyTemplateState) {
+	t1 := time.Now()
+	col := GetCollection("core_strategy_templates")
+	request := bson.D{
+		{"_id", templateId},
+	}
+	update := bson.D{
+		{
+			"$set", bson.D{
+				{
+					"state", state,
+				},
+			},
+		},
+	}
+	_, err := col.UpdateOne(context.TODO(), request, update)
+	if err != nil {
+		log.Error("error in arg", zap.Error(err))
+	}
+	sm.Statsd.TimingDuration("state_mgmt.update_template_state", time.Since(t1))
+}
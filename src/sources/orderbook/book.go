@@ -0,0 +1,156 @@
+// Package orderbook keeps local level 2 order books from a snapshot and diff-depth events following it.
+package orderbook
+
+import (
+	"errors"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"sort"
+	"sync"
+)
+
+// ErrGap is returned for a diff not following the previous one, the book needs a new snapshot then.
+var ErrGap = errors.New("order book sequence gap")
+
+// maxBuffered is how many diffs are kept while waiting for a snapshot, older ones are dropped.
+const maxBuffered = 1000
+
+// A Diff changes amounts of price levels, an amount of 0 removes the level.
+type Diff struct {
+	FirstUpdateId     int64 // the first update id in the event, "U"
+	FinalUpdateId     int64 // the final update id in the event, "u"
+	PrevFinalUpdateId int64 // the final update id of the previous event, "pu" of futures streams, 0 if not sent
+	Bids, Asks        []interfaces.PriceLevel
+}
+
+// A Snapshot is the full book at the update id.
+type Snapshot struct {
+	LastUpdateId int64
+	Bids, Asks   []interfaces.PriceLevel
+}
+
+// A Book applies diffs on top of a snapshot, the zero value waits for the snapshot. Diffs coming before the snapshot
+// are buffered and replayed once it's taken.
+type Book struct {
+	mux          sync.RWMutex
+	bids, asks   map[float64]float64
+	lastUpdateId int64
+	synced       bool
+	fresh        bool // no diffs applied since the snapshot
+	buffered     []Diff
+	updatedAt    int64
+}
+
+// Update applies the diff received at the time given in unix milliseconds. Returns false if the book is out of sync
+// and waits for a snapshot.
+func (b *Book) Update(diff Diff, updatedAt int64) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if !b.synced {
+		b.buffer(diff)
+		return false
+	}
+	if err := b.apply(diff); err != nil {
+		b.synced = false
+		b.buffered = nil
+		b.buffer(diff)
+		return false
+	}
+	b.updatedAt = updatedAt
+	return true
+}
+
+// Reset takes the snapshot replaying diffs buffered after it. Returns false if the snapshot is older than the diffs
+// buffered and a newer one is needed.
+func (b *Book) Reset(snapshot Snapshot, updatedAt int64) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.bids, b.asks = map[float64]float64{}, map[float64]float64{}
+	setLevels(b.bids, snapshot.Bids)
+	setLevels(b.asks, snapshot.Asks)
+	b.lastUpdateId = snapshot.LastUpdateId
+	b.synced, b.fresh = true, true
+	for i, diff := range b.buffered {
+		if err := b.apply(diff); err != nil {
+			b.synced = false
+			b.buffered = b.buffered[i:]
+			return false
+		}
+	}
+	b.buffered = nil
+	b.updatedAt = updatedAt
+	return true
+}
+
+// Synced tells whether the book follows the stream.
+func (b *Book) Synced() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.synced
+}
+
+// Get returns depth best levels of each side, all of them if depth is 0, or nil if the book is out of sync.
+func (b *Book) Get(depth int) *interfaces.OrderBook {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if !b.synced {
+		return nil
+	}
+	return &interfaces.OrderBook{
+		Bids:      sortedLevels(b.bids, depth, func(a, b float64) bool { return a > b }),
+		Asks:      sortedLevels(b.asks, depth, func(a, b float64) bool { return a < b }),
+		UpdatedAt: b.updatedAt,
+	}
+}
+
+// apply changes levels by the diff if it follows the book, diffs the book has already are skipped.
+func (b *Book) apply(diff Diff) error {
+	if diff.FinalUpdateId <= b.lastUpdateId {
+		return nil
+	}
+	switch {
+	case b.fresh: // the first diff has to cover the snapshot
+		if diff.FirstUpdateId > b.lastUpdateId+1 {
+			return ErrGap
+		}
+	case diff.PrevFinalUpdateId != 0:
+		if diff.PrevFinalUpdateId != b.lastUpdateId {
+			return ErrGap
+		}
+	case diff.FirstUpdateId != b.lastUpdateId+1:
+		return ErrGap
+	}
+	setLevels(b.bids, diff.Bids)
+	setLevels(b.asks, diff.Asks)
+	b.lastUpdateId = diff.FinalUpdateId
+	b.fresh = false
+	return nil
+}
+
+func (b *Book) buffer(diff Diff) {
+	if len(b.buffered) >= maxBuffered {
+		b.buffered = b.buffered[1:]
+	}
+	b.buffered = append(b.buffered, diff)
+}
+
+func setLevels(side map[float64]float64, levels []interfaces.PriceLevel) {
+	for _, level := range levels {
+		if level.Amount == 0 {
+			delete(side, level.Price)
+			continue
+		}
+		side[level.Price] = level.Amount
+	}
+}
+
+func sortedLevels(side map[float64]float64, depth int, better func(a, b float64) bool) []interfaces.PriceLevel {
+	levels := make([]interfaces.PriceLevel, 0, len(side))
+	for price, amount := range side {
+		levels = append(levels, interfaces.PriceLevel{Price: price, Amount: amount})
+	}
+	sort.Slice(levels, func(i, j int) bool { return better(levels[i].Price, levels[j].Price) })
+	if depth > 0 && len(levels) > depth {
+		levels = levels[:depth]
+	}
+	return levels
+}
@@ -0,0 +1,54 @@
+package orderbook
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"math"
+)
+
+// takenSide returns levels an order of the side given takes, buys take asks and sells take bids.
+func takenSide(book *interfaces.OrderBook, side string) []interfaces.PriceLevel {
+	if side == "buy" {
+		return book.Asks
+	}
+	return book.Bids
+}
+
+// PriceToFill returns the average and the worst price a market order of the side given fills the amount of base at.
+// Returns false if the book is not deep enough.
+func PriceToFill(book *interfaces.OrderBook, side string, amount float64) (average float64, worst float64, ok bool) {
+	if book == nil || amount <= 0 {
+		return 0, 0, false
+	}
+	var filled, cost float64
+	for _, level := range takenSide(book, side) {
+		taken := math.Min(level.Amount, amount-filled)
+		filled += taken
+		cost += taken * level.Price
+		worst = level.Price
+		if filled >= amount {
+			return cost / filled, worst, true
+		}
+	}
+	return 0, 0, false
+}
+
+// LiquidityWithin returns the amount of base a market order of the side given can take within bps basis points from
+// the best price.
+func LiquidityWithin(book *interfaces.OrderBook, side string, bps float64) float64 {
+	if book == nil {
+		return 0
+	}
+	levels := takenSide(book, side)
+	if len(levels) == 0 {
+		return 0
+	}
+	distance := levels[0].Price * bps / 10000
+	var amount float64
+	for _, level := range levels {
+		if math.Abs(level.Price-levels[0].Price) > distance {
+			break
+		}
+		amount += level.Amount
+	}
+	return amount
+}
@@ -0,0 +1,106 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A FileSink writes events as gzip compressed JSON lines to a file of the hour they were received at in the dir.
+type FileSink struct {
+	dir  string
+	hour string
+	file *os.File
+	gzip *gzip.Writer
+	json *json.Encoder
+}
+
+// NewFileSink returns the sink writing to the dir given, files are named market-data-<YYYYMMDD-HH>.jsonl.gz in UTC.
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{dir: dir}
+}
+
+func (s *FileSink) Write(event Event) error {
+	hour := time.Unix(0, event.At*int64(time.Millisecond)).UTC().Format("20060102-15")
+	if hour != s.hour || s.file == nil {
+		if err := s.open(hour); err != nil {
+			return err
+		}
+	}
+	return s.json.Encode(event)
+}
+
+func (s *FileSink) Flush() error {
+	if s.gzip == nil {
+		return nil
+	}
+	return s.gzip.Flush()
+}
+
+func (s *FileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.gzip.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.gzip, s.json = nil, nil, nil
+	return err
+}
+
+// open closes the file of the previous hour and opens the one of the hour given appending to it.
+func (s *FileSink) open(hour string) error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(s.dir, "market-data-"+hour+".jsonl.gz"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.hour, s.file = hour, file
+	s.gzip = gzip.NewWriter(file)
+	s.json = json.NewEncoder(s.gzip)
+	return nil
+}
+
+// ReadFile returns events of the file written by FileSink, files not ending with .gz are read as plain JSON lines.
+// Events written before the file got cut, by a crash for instance, are returned with no error.
+func ReadFile(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("read %v: %v", path, err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	var events []Event
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	for {
+		var event Event
+		err := decoder.Decode(&event)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, fmt.Errorf("read %v: %v", path, err)
+		}
+		events = append(events, event)
+	}
+}
@@ -0,0 +1,129 @@
+// Package recorder persists market data the feeds receive so exact historical streams can be replayed offline.
+package recorder
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of events recorded.
+const (
+	KindTick   = "tick"   // a trade price with the volume traded since the previous tick
+	KindSpread = "spread" // the best bid and ask
+	KindCandle = "candle" // a candle the feed receives complete, closes of them are ticks for candles aggregated
+
+	KindMarkPrice = "markPrice" // the mark and index price of a futures market
+)
+
+// bufferSize is how many events wait to be written, events are dropped once the buffer is full.
+const bufferSize = 10000
+
+// flushInterval is how often written events get flushed to the sink.
+const flushInterval = 5 * time.Second
+
+// An Event is market data of the pair as the feed received it.
+type Event struct {
+	At         int64                  `json:"at"`                  // unix milliseconds the feed received the data at
+	EventTime  int64                  `json:"eventTime,omitempty"` // unix milliseconds of the exchange event, At if 0
+	Kind       string                 `json:"kind"`
+	Exchange   string                 `json:"exchange"`
+	Pair       string                 `json:"pair"`
+	MarketType int64                  `json:"marketType"`
+	Price      *interfaces.OHLCV      `json:"price,omitempty"`
+	Spread     *interfaces.SpreadData `json:"spread,omitempty"`
+	MarkPrice  *interfaces.MarkPrice  `json:"markPrice,omitempty"`
+}
+
+// A Sink stores events written by the recorder.
+type Sink interface {
+	Write(event Event) error
+	Flush() error
+	Close() error
+}
+
+// A Recorder writes events to the sink in background, recording never blocks the feed.
+type Recorder struct {
+	sink    Sink
+	events  chan Event
+	dropped int64
+	closed  chan struct{}
+}
+
+var log interfaces.ILogger
+
+var defaultRecorder *Recorder
+var defaultOnce sync.Once
+
+func init() {
+	logger, _ := logging.GetZapLogger()
+	log = logger.With(zap.String("logger", "recorder"))
+}
+
+// New returns the recorder writing to the sink given.
+func New(sink Sink) *Recorder {
+	r := &Recorder{sink: sink, events: make(chan Event, bufferSize), closed: make(chan struct{})}
+	go r.run()
+	return r
+}
+
+// Record queues the event to write, the nil recorder records nothing.
+func (r *Recorder) Record(event Event) {
+	if r == nil {
+		return
+	}
+	select {
+	case r.events <- event:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Dropped returns how many events were not recorded as the sink was falling behind.
+func (r *Recorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Close writes events queued and closes the sink, nothing can be recorded after.
+func (r *Recorder) Close() error {
+	close(r.events)
+	<-r.closed
+	return r.sink.Close()
+}
+
+func (r *Recorder) run() {
+	defer close(r.closed)
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				return
+			}
+			if err := r.sink.Write(event); err != nil {
+				log.Error("write market data event", zap.Error(err))
+			}
+		case <-flush.C:
+			if err := r.sink.Flush(); err != nil {
+				log.Error("flush market data events", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Record writes the event by the recorder MARKET_DATA_RECORD_DIR environment variable sets up, nothing is recorded if
+// it's not set.
+func Record(event Event) {
+	defaultOnce.Do(func() {
+		if dir := os.Getenv("MARKET_DATA_RECORD_DIR"); dir != "" {
+			log.Info("recording market data", zap.String("dir", dir))
+			defaultRecorder = New(NewFileSink(dir))
+		}
+	})
+	defaultRecorder.Record(event)
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry"
+	"os"
+)
+
+// Config keys of the adapter, FEED_REDIS_<KEY> environment variables.
+const (
+	KeyChannels = "CHANNELS" // JSON array of channel families, see Channel, DefaultChannels if not set
+	KeyHost     = "HOST"     // of the server publishing, REDIS_HOST if not set
+	KeyPort     = "PORT"     // REDIS_PORT if not set
+	KeyPassword = "PASSWORD" // REDIS_PASSWORD if not set
+)
+
+func init() {
+	registry.Register("redis", New, "serum")
+}
+
+// New returns the loop listening to the channel families configured, see registry.Factory. Servers other than the one
+// of REDIS_* environment variables are connected to directly instead of through the shared pool.
+func New(config registry.Config) (registry.Adapter, error) {
+	rl := &RedisLoop{Candles: indicators.CandleStore{OnDemand: true}}
+	if value, ok := config.Lookup(KeyChannels); ok {
+		channels, err := ParseChannels(value)
+		if err != nil {
+			return nil, err
+		}
+		rl.Channels = channels
+	}
+	_, hostSet := config.Lookup(KeyHost)
+	_, portSet := config.Lookup(KeyPort)
+	_, passwordSet := config.Lookup(KeyPassword)
+	if hostSet || portSet || passwordSet {
+		address := config.Get(KeyHost, os.Getenv("REDIS_HOST")) + ":" + config.Get(KeyPort, os.Getenv("REDIS_PORT"))
+		password := config.Get(KeyPassword, os.Getenv("REDIS_PASSWORD"))
+		rl.Dial = func() (redis.Conn, error) {
+			return redis.Dial("tcp", address, redis.DialPassword(password))
+		}
+	}
+	rl.SubscribeToPairs()
+	return rl, nil
+}
+
+// Capabilities tells channels carry candles, closes of them being ticks, and the best bid and ask.
+func (rl *RedisLoop) Capabilities() registry.Capabilities {
+	return registry.Capabilities{
+		Ticker:      true,
+		Spread:      true,
+		Candles:     true,
+		MarketTypes: []int64{0, 1},
+	}
+}
+
+// Stop unsubscribes from all the channels.
+func (rl *RedisLoop) Stop() {
+	if rl.stop != nil {
+		rl.stop()
+	}
+}
@@ -0,0 +1,231 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"strconv"
+)
+
+// Kinds of data channel families carry.
+const (
+	KindCandle = "candle" // 60 seconds candles, closes of them are ticks for candles of other timeframes
+	KindSpread = "spread" // the best bid and ask
+)
+
+// A Channel is a family of channels subscribed by the pattern, messages of it are decoded by the schema.
+type Channel struct {
+	Name       string `json:"name"`       // errors are counted by, the kind if empty
+	Pattern    string `json:"pattern"`    // e.g. "*:0:serum:60"
+	Kind       string `json:"kind"`       // KindCandle or KindSpread
+	Exchange   string `json:"exchange"`   // of the data if the schema has no exchange field
+	MarketType int64  `json:"marketType"` // of the data if the schema has no market type field
+	Schema     Schema `json:"schema"`
+}
+
+// A Schema maps JSON keys of messages to data, a key not set leaves the data default. Numbers may be JSON numbers or
+// strings.
+type Schema struct {
+	Pair       string    `json:"pair"`      // e.g. "BTC_USDT"
+	PairParts  [2]string `json:"pairParts"` // base and quote joined by "_" if there's no pair key
+	Exchange   string    `json:"exchange"`
+	MarketType string    `json:"marketType"`
+	// candles, open, high and low are the close if not set
+	Open   string `json:"open"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Close  string `json:"close"`
+	Volume string `json:"volume"`
+	// spreads
+	BestBid    string `json:"bestBid"`
+	BestAsk    string `json:"bestAsk"`
+	BestBidQty string `json:"bestBidQty"`
+	BestAskQty string `json:"bestAskQty"`
+}
+
+// DefaultChannels are serum spot candles, channels <pair>:<market type>:<exchange>:<timeframe>, and spreads of all
+// exchanges, channels best:<exchange>:<pair>:<market type>.
+var DefaultChannels = []Channel{
+	{
+		Name:     "serum_candles",
+		Pattern:  "*:0:serum:60",
+		Kind:     KindCandle,
+		Exchange: "serum",
+		Schema: Schema{
+			PairParts: [2]string{"fsym", "tsym"},
+			Open:      "open_price",
+			High:      "high_price",
+			Low:       "low_price",
+			Close:     "close_price",
+			Volume:    "volume",
+		},
+	},
+	{
+		// {"id":41082715216,"exchange":"binance","symbol":"ALGO_USDT","bestBidPrice":"0.2800","bestBidQuantity":"1368.2","bestAskPrice":"0.2801","bestAskQuantity":"3.3","marketType":1}
+		Name:    "spreads",
+		Pattern: "best:*:*:*",
+		Kind:    KindSpread,
+		Schema: Schema{
+			Pair:       "symbol",
+			Exchange:   "exchange",
+			MarketType: "marketType",
+			BestBid:    "bestBidPrice",
+			BestAsk:    "bestAskPrice",
+			BestBidQty: "bestBidQuantity",
+			BestAskQty: "bestAskQuantity",
+		},
+	},
+}
+
+// Errors messages not decoded are counted by.
+var (
+	ErrDecode = errors.New("decode")
+	ErrSchema = errors.New("schema")
+)
+
+// ParseChannels decodes channel families of the JSON array given and checks messages of them can be decoded.
+func ParseChannels(value string) ([]Channel, error) {
+	var channels []Channel
+	if err := json.Unmarshal([]byte(value), &channels); err != nil {
+		return nil, fmt.Errorf("channels: %v", err)
+	}
+	patterns := map[string]bool{}
+	for i := range channels {
+		if channels[i].Name == "" {
+			channels[i].Name = channels[i].Kind
+		}
+		if err := channels[i].validate(); err != nil {
+			return nil, fmt.Errorf("channel %v: %v", channels[i].Name, err)
+		}
+		if patterns[channels[i].Pattern] {
+			return nil, fmt.Errorf("channel %v: pattern %q subscribed twice", channels[i].Name, channels[i].Pattern)
+		}
+		patterns[channels[i].Pattern] = true
+	}
+	return channels, nil
+}
+
+func (c Channel) validate() error {
+	schema := c.Schema
+	switch {
+	case c.Pattern == "":
+		return errors.New("no pattern")
+	case c.Kind != KindCandle && c.Kind != KindSpread:
+		return fmt.Errorf("unknown kind %q", c.Kind)
+	case schema.Pair == "" && (schema.PairParts[0] == "" || schema.PairParts[1] == ""):
+		return errors.New("no pair keys")
+	case schema.Exchange == "" && c.Exchange == "":
+		return errors.New("no exchange")
+	case c.Kind == KindCandle && schema.Close == "":
+		return errors.New("no close key")
+	case c.Kind == KindSpread && (schema.BestBid == "" || schema.BestAsk == ""):
+		return errors.New("no best bid or ask keys")
+	}
+	return nil
+}
+
+// A message is a message of the channel decoded.
+type message struct {
+	Exchange   string
+	Pair       string
+	MarketType int64
+	Candle     interfaces.OHLCV
+	Spread     interfaces.SpreadData
+}
+
+// decode returns data of the message by the schema of the channel, ErrDecode or ErrSchema wrapped if it fails.
+func (c Channel) decode(data []byte) (message, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return message{}, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	f := schemaFields{fields: fields}
+	schema := c.Schema
+	m := message{Exchange: c.Exchange, MarketType: c.MarketType}
+	if schema.Exchange != "" {
+		m.Exchange = f.text(schema.Exchange)
+	}
+	if schema.Pair != "" {
+		m.Pair = f.text(schema.Pair)
+	} else {
+		m.Pair = f.text(schema.PairParts[0]) + "_" + f.text(schema.PairParts[1])
+	}
+	if schema.MarketType != "" {
+		m.MarketType = int64(f.number(schema.MarketType, 0))
+	}
+	switch c.Kind {
+	case KindCandle:
+		closePrice := f.number(schema.Close, 0)
+		m.Candle = interfaces.OHLCV{
+			Open:   f.number(schema.Open, closePrice),
+			High:   f.number(schema.High, closePrice),
+			Low:    f.number(schema.Low, closePrice),
+			Close:  closePrice,
+			Volume: f.number(schema.Volume, 0),
+		}
+	case KindSpread:
+		m.Spread = interfaces.SpreadData{
+			BestBid:    f.number(schema.BestBid, 0),
+			BestAsk:    f.number(schema.BestAsk, 0),
+			BestBidQty: f.number(schema.BestBidQty, 0),
+			BestAskQty: f.number(schema.BestAskQty, 0),
+		}
+		m.Spread.Close = m.Spread.BestBid
+	}
+	if f.err != nil {
+		return message{}, f.err
+	}
+	if m.Exchange == "" || m.Pair == "" {
+		return message{}, fmt.Errorf("%w: no exchange or pair", ErrSchema)
+	}
+	return m, nil
+}
+
+// schemaFields reads fields of a message keeping the first error.
+type schemaFields struct {
+	fields map[string]interface{}
+	err    error
+}
+
+func (f *schemaFields) text(key string) string {
+	switch value := f.fields[key].(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	}
+	f.fail("key %q is not a string", key)
+	return ""
+}
+
+// number returns the number of the key, the default given if the key is not set in the schema.
+func (f *schemaFields) number(key string, defaultValue float64) float64 {
+	if key == "" {
+		return defaultValue
+	}
+	var text string
+	switch value := f.fields[key].(type) {
+	case json.Number:
+		text = value.String()
+	case string:
+		text = value
+	default:
+		f.fail("key %q is not a number", key)
+		return 0
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		f.fail("key %q is not a number", key)
+	}
+	return number
+}
+
+func (f *schemaFields) fail(format string, key string) {
+	if f.err == nil {
+		f.err = fmt.Errorf("%w: "+format, ErrSchema, key)
+	}
+}
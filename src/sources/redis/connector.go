@@ -147,14 +147,49 @@ func GetRedsync() *redsync.Redsync {
 	return redsyncToDLM
 }
 
+// pubsubReconnectDelay is how long listening waits to connect again after the connection failed.
+const pubsubReconnectDelay = time.Second
+
+// ListenPubSubChannels subscribes to the channel patterns given and calls onMessage with the pattern each message
+// matched until ctx is done, connecting again whenever the connection fails. Connections are made by dial, taken from
+// the pubsub pool if dial is nil.
 func ListenPubSubChannels(ctx context.Context,
+	dial func() (redis.Conn, error),
+	onStart func() error,
+	onMessage func(pattern string, channel string, data []byte) error,
+	channels ...string) error {
+	for {
+		err := listenPubSubChannels(ctx, dial, onStart, onMessage, channels...)
+		if ctx.Err() != nil {
+			return ctx.Err() // stopped
+		}
+		log.Error("pubsub listening stopped, reconnecting", zap.Strings("channels", channels), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pubsubReconnectDelay):
+		}
+	}
+}
+
+// listenPubSubChannels listens to the channels in one connection until ctx is done or the connection fails.
+func listenPubSubChannels(ctx context.Context,
+	dial func() (redis.Conn, error),
 	onStart func() error,
-	onMessage func(channel string, data []byte) error,
+	onMessage func(pattern string, channel string, data []byte) error,
 	channels ...string) error {
 	// A ping is set to the server with this period to test for the health of
 	// the connection and server.
 	const healthCheckPeriod = time.Minute
-	c := GetRedisClientInstance(true, false, false)
+	var c redis.Conn
+	if dial == nil {
+		c = GetRedisClientInstance(true, false, false)
+	} else {
+		var err error
+		if c, err = dial(); err != nil {
+			return err
+		}
+	}
 	defer c.Close()
 
 	psc := redis.PubSubConn{Conn: c}
@@ -172,7 +207,7 @@ func ListenPubSubChannels(ctx context.Context,
 				done <- n
 				return
 			case redis.Message:
-				if err := onMessage(n.Channel, n.Data); err != nil { // you can run gorouitine in onMessage or keep your processing single-threaded
+				if err := onMessage(n.Pattern, n.Channel, n.Data); err != nil { // you can run gorouitine in onMessage or keep your processing single-threaded
 					done <- err
 					return
 				}
@@ -215,19 +250,12 @@ Loop:
 	}
 
 	// Signal the receiving goroutine to exit by unsubscribing from all channels.
-	err = psc.Unsubscribe()
-	_ = psc.Close()
-	if err != nil {
-		log.Error("EXIT1 EOF", zap.Error(err))
+	if unsubscribeErr := psc.PUnsubscribe(); unsubscribeErr != nil {
+		log.Error("pubsub unsubscribe error", zap.Error(unsubscribeErr))
 	}
+	_ = psc.Close()
 
 	// Wait for goroutine to complete.
 	<-done
-	log.Info("EXIT1 EOF")
-	// os.Exit(1)
-	//if resp != nil {
-	//	log.Print("recursive call")
-	//	return ListenPubSubChannels(ctx, onStart, onMessage, channels[0])
-	//}
-	return ListenPubSubChannels(ctx, onStart, onMessage, channels[0])
+	return err
 }
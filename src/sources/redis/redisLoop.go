@@ -2,97 +2,146 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/fanout"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/recorder"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
 	"go.uber.org/zap"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type RedisLoop struct {
 	OhlcvMap  sync.Map // <string: exchange+pair+o/h/l/c/v, OHLCV: ohlcv>
 	SpreadMap sync.Map
+	Feeds     staleness.Tracker
+	Candles   indicators.CandleStore
+	Updates   fanout.Hub // <string: exchange+pair+marketType as in the maps>
+	stop      context.CancelFunc
+	// channel families listened to, DefaultChannels if nil
+	Channels []Channel
+	// Dial connects to the server publishing the channels, the pubsub pool of REDIS_* environment variables if nil
+	Dial   func() (redis.Conn, error)
+	errors sync.Map // <string: redis.<channel name>.<reason>, *int64: count>
 }
 
-var redisLoop *RedisLoop
+func (rl *RedisLoop) GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.OHLCV {
+	return rl.GetPrice(pair, exchange, marketType)
+}
 
-func InitRedis() interfaces.IDataFeed {
-	if redisLoop == nil {
-		redisLoop = &RedisLoop{}
-		redisLoop.SubscribeToPairs()
-	}
+func (rl *RedisLoop) GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.SpreadData {
+	return rl.GetSpread(pair, exchange, marketType)
+}
 
-	return redisLoop
+func (rl *RedisLoop) IsStale(pair string, exchange string, marketType int64) bool {
+	ohlcv := rl.GetPriceForPairAtExchange(pair, exchange, marketType)
+	return ohlcv == nil || staleness.IsStale(exchange, ohlcv.UpdatedAt, time.Now())
 }
 
-func (rl *RedisLoop) GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.OHLCV {
-	if redisLoop == nil {
-		redisLoop = &RedisLoop{}
-		redisLoop.SubscribeToPairs()
-	}
-	return redisLoop.GetPrice(pair, exchange, marketType)
+func (rl *RedisLoop) GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	return rl.Candles.GetCandles(pair, exchange, marketType, timeframe, n)
 }
 
-func (rl *RedisLoop) GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.SpreadData {
-	if redisLoop == nil {
-		redisLoop = &RedisLoop{}
-		redisLoop.SubscribeToPairs()
-	}
-	return redisLoop.GetSpread(pair, exchange, marketType)
+// GetMarkPrice returns nil, channels carry no mark prices.
+func (rl *RedisLoop) GetMarkPrice(pair string, exchange string, marketType int64) *interfaces.MarkPrice {
+	return nil
+}
+
+// GetOrderBook returns nil, channels carry candles and the best bid and ask only.
+func (rl *RedisLoop) GetOrderBook(pair string, exchange string, marketType int64, depth int) *interfaces.OrderBook {
+	return nil
 }
 
-type OrderbookOHLCV struct {
-	Open       float64 `json:"open_price,float"`
-	High       float64 `json:"high_price,float"`
-	Low        float64 `json:"low_price,float"`
-	MarketType int64   `json:"market_type,float"`
-	Close      float64 `json:"close_price,float"`
-	Volume     float64 `json:"volume,float"`
-	Base       string  `json:"tsym"`
-	Quote      string  `json:"fsym"`
-	Exchange   string  `json:"exchange"`
+func (rl *RedisLoop) Subscribe(pair string, exchange string, marketType int64) <-chan interfaces.Update {
+	return rl.Updates.Subscribe(exchange + pair + strconv.FormatInt(marketType, 10))
 }
 
-// {"id":41082715216,"exchange":"binance","symbol":"ALGO_USDT","bestBidPrice":"0.2800","bestBidQuantity":"1368.2","bestAskPrice":"0.2801","bestAskQuantity":"3.3","marketType":1}
-type Spread struct {
-	BestBidPrice float64 `json:"bestBidPrice,float"`
-	BestAskPrice float64 `json:"bestAskPrice,float"`
-	Exchange     string  `json:"exchange"`
-	Symbol       string  `json:"symbol"`
-	MarketType   int64   `json:"marketType"`
+func (rl *RedisLoop) Unsubscribe(pair string, exchange string, marketType int64, updates <-chan interfaces.Update) {
+	rl.Updates.Unsubscribe(exchange+pair+strconv.FormatInt(marketType, 10), updates)
 }
 
+// StaleFeeds returns candles and spread channels not updated for longer than the max age of their exchange.
+func (rl *RedisLoop) StaleFeeds() []string {
+	return rl.Feeds.StaleFeeds(time.Now())
+}
+
+// SubscribeToPairs listens to all the channel families in one connection, messages are decoded by the family of the
+// pattern they matched.
 func (rl *RedisLoop) SubscribeToPairs() {
-	go ListenPubSubChannels(context.TODO(), func() error {
+	ctx, stop := context.WithCancel(context.Background())
+	rl.stop = stop
+	channels := rl.Channels
+	if channels == nil {
+		channels = DefaultChannels
+	}
+	byPattern := map[string]Channel{}
+	patterns := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		byPattern[channel.Pattern] = channel
+		patterns = append(patterns, channel.Pattern)
+	}
+	go ListenPubSubChannels(ctx, rl.Dial, func() error {
 		return nil
-	}, func(channel string, data []byte) error {
-		if strings.Contains(channel, "best") {
-			go rl.UpdateSpread(channel, data)
-			return nil
-		}
-		go rl.UpdateOHLCV(channel, data)
+	}, func(pattern string, channel string, data []byte) error {
+		go rl.Update(byPattern[pattern], data)
 		return nil
-	}, "*:0:serum:60")
-	rl.SubscribeToSpread()
-}
-
-func (rl *RedisLoop) UpdateOHLCV(channel string, data []byte) {
-	var ohlcvOB OrderbookOHLCV
-	_ = json.Unmarshal(data, &ohlcvOB)
-	pair := ohlcvOB.Quote + "_" + ohlcvOB.Base
-	exchange := "serum"
-	ohlcv := interfaces.OHLCV{
-		Open:   ohlcvOB.Open,
-		High:   ohlcvOB.High,
-		Low:    ohlcvOB.Low,
-		Close:  ohlcvOB.Close,
-		Volume: ohlcvOB.Volume,
+	}, patterns...)
+}
+
+// Update decodes a message of the channel family given and stores its data. Messages not decoded are logged and
+// counted by the family and reason.
+func (rl *RedisLoop) Update(channel Channel, data []byte) {
+	m, err := channel.decode(data)
+	if err != nil {
+		reason := ErrSchema.Error()
+		if errors.Is(err, ErrDecode) {
+			reason = ErrDecode.Error()
+		}
+		count, _ := rl.errors.LoadOrStore("redis."+channel.Name+"."+reason, new(int64))
+		atomic.AddInt64(count.(*int64), 1)
+		log.Warn("message not decoded", zap.String("channel", channel.Name), zap.Error(err))
+		return
+	}
+	switch channel.Kind {
+	case KindCandle:
+		rl.updateOHLCV(m)
+	case KindSpread:
+		rl.updateSpread(m)
 	}
-	rl.OhlcvMap.Store(exchange+pair+strconv.FormatInt(ohlcvOB.MarketType, 10), ohlcv)
+}
+
+// FeedErrors returns counts of messages not decoded by channel family and reason, e.g. "redis.spreads.schema".
+func (rl *RedisLoop) FeedErrors() map[string]int64 {
+	counts := map[string]int64{}
+	rl.errors.Range(func(name, count interface{}) bool {
+		counts[name.(string)] = atomic.LoadInt64(count.(*int64))
+		return true
+	})
+	return counts
+}
 
+func (rl *RedisLoop) updateOHLCV(m message) {
+	updatedAt := staleness.Now()
+	rl.Feeds.Touch(m.Exchange, "candles."+strconv.FormatInt(m.MarketType, 10), updatedAt)
+	ohlcv := m.Candle
+	ohlcv.UpdatedAt = updatedAt
+	recorder.Record(recorder.Event{At: updatedAt, Kind: recorder.KindCandle, Exchange: m.Exchange, Pair: m.Pair, MarketType: m.MarketType, Price: &ohlcv})
+	key := m.Exchange + m.Pair + strconv.FormatInt(m.MarketType, 10)
+	previous, ok := rl.OhlcvMap.Load(key)
+	rl.OhlcvMap.Store(key, ohlcv)
+	if !ok || previous.(interfaces.OHLCV).Close != ohlcv.Close {
+		rl.Updates.Publish(key, interfaces.Update{Price: &ohlcv})
+	}
+	// channel candles are 60 seconds only, the close of each update is a tick for candles of all timeframes
+	rl.Candles.AddTick(m.Pair, m.Exchange, m.MarketType, ohlcv.Close, 0, time.Now())
 }
+
 func (rl *RedisLoop) FillPair(pair, exchange string) *interfaces.OHLCV {
 	redisClient := GetRedisClientInstance(false, true, false)
 	baseStr := pair + ":0:" + exchange + ":60:"
@@ -114,33 +163,18 @@ func (rl *RedisLoop) GetPrice(pair, exchange string, marketType int64) *interfac
 	return nil
 }
 
-func (rl *RedisLoop) SubscribeToSpread() {
-	go ListenPubSubChannels(context.TODO(), func() error {
-		return nil
-	}, func(channel string, data []byte) error {
-		go rl.UpdateSpread(channel, data)
-		return nil
-	}, "best:*:*:*")
-}
-
-func (rl *RedisLoop) UpdateSpread(channel string, data []byte) {
-	var spread Spread
-	tryparse := json.Unmarshal(data, &spread)
-	if tryparse != nil {
-		log.Error("", zap.Error(tryparse))
-	}
-	spreadData := interfaces.SpreadData{
-		Close:   spread.BestBidPrice,
-		BestBid: spread.BestBidPrice,
-		BestAsk: spread.BestAskPrice,
+func (rl *RedisLoop) updateSpread(m message) {
+	updatedAt := staleness.Now()
+	rl.Feeds.Touch(m.Exchange, "spread."+strconv.FormatInt(m.MarketType, 10), updatedAt)
+	spreadData := m.Spread
+	spreadData.UpdatedAt = updatedAt
+	recorder.Record(recorder.Event{At: updatedAt, Kind: recorder.KindSpread, Exchange: m.Exchange, Pair: m.Pair, MarketType: m.MarketType, Spread: &spreadData})
+	key := m.Exchange + m.Pair + strconv.FormatInt(m.MarketType, 10)
+	previous, ok := rl.SpreadMap.Load(key)
+	rl.SpreadMap.Store(key, spreadData)
+	if !ok || previous.(interfaces.SpreadData).BestBid != spreadData.BestBid || previous.(interfaces.SpreadData).BestAsk != spreadData.BestAsk {
+		rl.Updates.Publish(key, interfaces.Update{Spread: &spreadData})
 	}
-
-	//if spread.Symbol == "BTC_USDT" && spread.MarketType == 1 {
-	//	log.Println("save spread ", spread)
-	//	log.Println("string ", spread.Exchange+spread.Symbol+strconv.FormatInt(spread.MarketType, 10))
-	//}
-
-	rl.SpreadMap.Store(spread.Exchange+spread.Symbol+strconv.FormatInt(spread.MarketType, 10), spreadData)
 }
 
 func (rl *RedisLoop) GetSpread(pair, exchange string, marketType int64) *interfaces.SpreadData {
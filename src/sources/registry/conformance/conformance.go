@@ -0,0 +1,244 @@
+// Package conformance checks a feed adapter serves what its capabilities declare the way the data feed expects. The
+// adapter runs against a local stand-in server of its exchange, so the suite runs offline.
+package conformance
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// A StandIn is a local server the adapter connects to instead of the exchange. Data are sent to connections the
+// adapter has open at the time, so the suite sends them again until the adapter gets them.
+type StandIn interface {
+	// Config returns config values pointing the adapter at the stand-in.
+	Config() map[string]string
+	// Ticker sends the last trade price of the market.
+	Ticker(pair string, marketType int64, price float64)
+	// Spread sends the best bid and ask of the market.
+	Spread(pair string, marketType int64, bid float64, ask float64)
+	// MarkPrice sends the mark and index price of the futures market.
+	MarkPrice(pair string, mark float64, index float64)
+	// Book makes the order book of the market the one given and sends what the adapter needs to sync it.
+	Book(pair string, marketType int64, book interfaces.OrderBook)
+	// Disconnect drops connections of the adapter.
+	Disconnect()
+}
+
+// A Suite is the adapter checked and the stand-in it runs against.
+type Suite struct {
+	Name     string // the adapter is registered by
+	Factory  registry.Factory
+	Exchange string // the adapter serves
+	Pair     string // traded on the stand-in, e.g. BTC_USDT
+	StandIn  StandIn
+	Timeout  time.Duration // data may take to come through, 5 seconds if 0
+}
+
+// resendInterval is how often data not come through yet are sent again.
+const resendInterval = 200 * time.Millisecond
+
+// Run checks the adapter of the suite against its stand-in for each market type it supports.
+func Run(t *testing.T, suite Suite) {
+	if suite.Timeout == 0 {
+		suite.Timeout = 5 * time.Second
+	}
+	feeds := &registry.Registry{}
+	feeds.Register(suite.Name, suite.Factory, suite.Exchange)
+	feeds.Configure(suite.Name, suite.StandIn.Config())
+	adapter := feeds.Adapter(suite.Exchange)
+	if adapter == nil {
+		t.Fatalf("adapter %v not created", suite.Name)
+	}
+	defer feeds.Stop()
+	capabilities := adapter.Capabilities()
+	if len(capabilities.MarketTypes) == 0 {
+		t.Fatal("expected market types supported")
+	}
+	for _, marketType := range capabilities.MarketTypes {
+		c := check{Suite: suite, feed: sources.NewDataFeed(feeds), capabilities: capabilities, marketType: marketType}
+		name := strconv.FormatInt(marketType, 10)
+		t.Run("ticker."+name, c.ticker)
+		t.Run("candles."+name, c.candles)
+		t.Run("spread."+name, c.spread)
+		t.Run("markPrice."+name, c.markPrice)
+		t.Run("depth."+name, c.depth)
+		t.Run("unknown."+name, c.unknown)
+		t.Run("reconnect."+name, c.reconnect)
+	}
+}
+
+type check struct {
+	Suite
+	feed         *sources.DataFeed
+	capabilities registry.Capabilities
+	marketType   int64
+}
+
+// eventually sends data until got tells they came through, false if they didn't in time.
+func (c *check) eventually(send func(), got func() bool) bool {
+	deadline := time.Now().Add(c.Timeout)
+	var sentAt time.Time
+	for time.Now().Before(deadline) {
+		if time.Since(sentAt) >= resendInterval {
+			send()
+			sentAt = time.Now()
+		}
+		if got() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func (c *check) price() *interfaces.OHLCV {
+	return c.feed.GetPriceForPairAtExchange(c.Pair, c.Exchange, c.marketType)
+}
+
+func (c *check) ticker(t *testing.T) {
+	if !c.capabilities.Ticker {
+		if c.price() != nil {
+			t.Error("expected no price without ticker capability")
+		}
+		return
+	}
+	updates := c.feed.Subscribe(c.Pair, c.Exchange, c.marketType)
+	defer c.feed.Unsubscribe(c.Pair, c.Exchange, c.marketType, updates)
+	if !c.eventually(func() { c.StandIn.Ticker(c.Pair, c.marketType, 7000) }, func() bool {
+		price := c.price()
+		return price != nil && price.Close == 7000
+	}) {
+		t.Fatalf("expected price 7000, got %+v", c.price())
+	}
+	if price := c.price(); price.UpdatedAt == 0 {
+		t.Error("expected price stamped with the time received")
+	}
+	if c.feed.IsStale(c.Pair, c.Exchange, c.marketType) {
+		t.Error("expected fresh price not stale")
+	}
+	if updates == nil {
+		return // polled
+	}
+	var update interfaces.Update
+	if !c.eventually(func() { c.StandIn.Ticker(c.Pair, c.marketType, 7001) }, func() bool {
+		select {
+		case update = <-updates:
+			return update.Price != nil && update.Price.Close == 7001
+		default:
+			return false
+		}
+	}) {
+		t.Errorf("expected price update 7001, got %+v", update)
+	}
+}
+
+func (c *check) candles(t *testing.T) {
+	candles := func() []interfaces.Candle {
+		return c.feed.GetCandles(c.Pair, c.Exchange, c.marketType, 60, 1)
+	}
+	if !c.capabilities.Candles {
+		if candles() != nil {
+			t.Error("expected no candles without candles capability")
+		}
+		return
+	}
+	if !c.eventually(func() { c.StandIn.Ticker(c.Pair, c.marketType, 7002) }, func() bool {
+		forming := candles()
+		return len(forming) == 1 && forming[0].Close == 7002
+	}) {
+		t.Errorf("expected the forming minute candle closing at 7002, got %+v", candles())
+	}
+}
+
+func (c *check) spread(t *testing.T) {
+	spread := func() *interfaces.SpreadData {
+		return c.feed.GetSpreadForPairAtExchange(c.Pair, c.Exchange, c.marketType)
+	}
+	if !c.capabilities.Spread {
+		if spread() != nil {
+			t.Error("expected no spread without spread capability")
+		}
+		return
+	}
+	if !c.eventually(func() { c.StandIn.Spread(c.Pair, c.marketType, 7000.1, 7000.2) }, func() bool {
+		got := spread()
+		return got != nil && got.BestBid == 7000.1 && got.BestAsk == 7000.2
+	}) {
+		t.Errorf("expected best bid 7000.1 and ask 7000.2, got %+v", spread())
+	}
+}
+
+func (c *check) markPrice(t *testing.T) {
+	markPrice := func() *interfaces.MarkPrice {
+		return c.feed.GetMarkPrice(c.Pair, c.Exchange, c.marketType)
+	}
+	if !c.capabilities.MarkPrice || c.marketType != 1 {
+		if markPrice() != nil {
+			t.Error("expected no mark price without mark price capability or of spot")
+		}
+		return
+	}
+	if !c.eventually(func() { c.StandIn.MarkPrice(c.Pair, 6999.5, 6999.7) }, func() bool {
+		got := markPrice()
+		return got != nil && got.Mark == 6999.5 && got.Index == 6999.7
+	}) {
+		t.Errorf("expected mark price 6999.5 and index 6999.7, got %+v", markPrice())
+	}
+}
+
+func (c *check) depth(t *testing.T) {
+	orderBook := func() *interfaces.OrderBook {
+		return c.feed.GetOrderBook(c.Pair, c.Exchange, c.marketType, 10)
+	}
+	if !c.capabilities.Depth {
+		if orderBook() != nil {
+			t.Error("expected no order book without depth capability")
+		}
+		return
+	}
+	book := interfaces.OrderBook{
+		Bids: []interfaces.PriceLevel{{Price: 7000, Amount: 1}, {Price: 6999, Amount: 2}},
+		Asks: []interfaces.PriceLevel{{Price: 7001, Amount: 1.5}},
+	}
+	if !c.eventually(func() { c.StandIn.Book(c.Pair, c.marketType, book) }, func() bool {
+		got := orderBook()
+		return got != nil && len(got.Bids) == 2 && len(got.Asks) == 1 && got.Bids[0] == book.Bids[0] &&
+			got.Bids[1] == book.Bids[1] && got.Asks[0] == book.Asks[0]
+	}) {
+		t.Errorf("expected book %+v, got %+v", book, orderBook())
+	}
+}
+
+// unknown checks markets and exchanges with no data aren't made up.
+func (c *check) unknown(t *testing.T) {
+	if c.feed.GetPriceForPairAtExchange("NONE_USDT", c.Exchange, c.marketType) != nil {
+		t.Error("expected no price of the pair not traded")
+	}
+	if !c.feed.IsStale("NONE_USDT", c.Exchange, c.marketType) {
+		t.Error("expected the pair not traded stale")
+	}
+	if c.feed.GetPriceForPairAtExchange(c.Pair, c.Exchange+"-unknown", c.marketType) != nil {
+		t.Error("expected no price of the exchange not served")
+	}
+	if !c.feed.IsStale(c.Pair, c.Exchange+"-unknown", c.marketType) {
+		t.Error("expected the exchange not served stale")
+	}
+}
+
+// reconnect checks data come through again after the stand-in drops connections.
+func (c *check) reconnect(t *testing.T) {
+	if !c.capabilities.Ticker {
+		return
+	}
+	c.StandIn.Disconnect()
+	if !c.eventually(func() { c.StandIn.Ticker(c.Pair, c.marketType, 7100) }, func() bool {
+		price := c.price()
+		return price != nil && price.Close == 7100
+	}) {
+		t.Errorf("expected price 7100 after reconnect, got %+v", c.price())
+	}
+}
@@ -0,0 +1,232 @@
+// Package registry keeps exchange data feed adapters by name. The data feed routes markets to the adapter serving
+// their exchange, so an exchange is added by registering an adapter rather than changing the routing.
+package registry
+
+import (
+	"fmt"
+	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"go.uber.org/zap"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Capabilities of an adapter, the data feed doesn't ask adapters for data they don't provide.
+type Capabilities struct {
+	Ticker      bool    // last trade prices
+	Spread      bool    // best bid and ask
+	Depth       bool    // level 2 order books
+	Candles     bool    // candles aggregated of timeframes
+	MarkPrice   bool    // mark and index prices of futures
+	MarketTypes []int64 // 0 spot, 1 futures
+}
+
+// SupportsMarketType tells whether the adapter serves markets of the type given.
+func (c Capabilities) SupportsMarketType(marketType int64) bool {
+	for _, supported := range c.MarketTypes {
+		if supported == marketType {
+			return true
+		}
+	}
+	return false
+}
+
+// An Adapter is the data feed of the exchanges it's registered for.
+type Adapter interface {
+	interfaces.IDataFeed
+	Capabilities() Capabilities
+	// Stop closes connections of the adapter, it's not used after.
+	Stop()
+}
+
+// A Config is the configuration of one adapter. Values set by Registry.Configure take precedence over
+// FEED_<NAME>_<KEY> environment variables.
+type Config struct {
+	Name   string
+	values map[string]string
+}
+
+// Get returns the value of the key, the default given if it's not set.
+func (c Config) Get(key string, defaultValue string) string {
+	if value, ok := c.Lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// Lookup returns the value of the key and whether it's set.
+func (c Config) Lookup(key string) (string, bool) {
+	if value, ok := c.values[key]; ok {
+		return value, true
+	}
+	if value := os.Getenv("FEED_" + strings.ToUpper(c.Name) + "_" + key); value != "" {
+		return value, true
+	}
+	return "", false
+}
+
+// A Factory creates the adapter of the config given and starts it.
+type Factory func(config Config) (Adapter, error)
+
+// Config keys every adapter has.
+const (
+	KeyEnabled   = "ENABLED"   // "false" disables the adapter
+	KeyExchanges = "EXCHANGES" // comma separated exchanges the adapter serves instead of the ones registered
+)
+
+type registration struct {
+	factory   Factory
+	exchanges []string
+}
+
+// A Registry keeps adapter factories by name and creates adapters once they are needed, the zero value is ready to
+// use.
+type Registry struct {
+	mux           sync.Mutex
+	registrations map[string]registration
+	values        map[string]map[string]string // config values by adapter name
+	adapters      map[string]Adapter           // created by name
+	routes        map[string]Adapter           // adapters resolved by exchange
+}
+
+// Default is the registry adapters register to in their init.
+var Default = &Registry{}
+
+var log interfaces.ILogger
+
+func init() {
+	logger, _ := logging.GetZapLogger()
+	log = logger.With(zap.String("logger", "registry"))
+}
+
+// Register makes the adapter factory of the name available in the default registry, see Registry.Register.
+func Register(name string, factory Factory, exchanges ...string) {
+	Default.Register(name, factory, exchanges...)
+}
+
+// Register makes the adapter factory of the name available, the adapter serves the exchanges given unless its
+// EXCHANGES config says otherwise. A name registered again replaces the factory.
+func (r *Registry) Register(name string, factory Factory, exchanges ...string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.registrations == nil {
+		r.registrations = map[string]registration{}
+	}
+	r.registrations[name] = registration{factory: factory, exchanges: exchanges}
+	r.routes = nil
+}
+
+// Configure sets config values of the adapter of the name, they take effect once the adapter is created.
+func (r *Registry) Configure(name string, values map[string]string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.values == nil {
+		r.values = map[string]map[string]string{}
+	}
+	r.values[name] = values
+	r.routes = nil
+}
+
+// Start creates all the adapters enabled, ones failing are logged and retried on first use.
+func (r *Registry) Start() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, name := range r.names() {
+		if _, err := r.adapter(name); err != nil {
+			log.Error("start feed adapter", zap.String("adapter", name), zap.Error(err))
+		}
+	}
+}
+
+// Adapter returns the adapter serving the exchange, created on first use. Nil if no adapter enabled serves it.
+func (r *Registry) Adapter(exchange string) Adapter {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if adapter, ok := r.routes[exchange]; ok {
+		return adapter
+	}
+	for _, name := range r.names() {
+		if !r.serves(name, exchange) {
+			continue
+		}
+		adapter, err := r.adapter(name)
+		if err != nil {
+			log.Error("create feed adapter", zap.String("adapter", name), zap.Error(err))
+			return nil
+		}
+		if r.routes == nil {
+			r.routes = map[string]Adapter{}
+		}
+		r.routes[exchange] = adapter
+		return adapter
+	}
+	return nil
+}
+
+// Adapters returns the adapters created so far by name.
+func (r *Registry) Adapters() map[string]Adapter {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	adapters := make(map[string]Adapter, len(r.adapters))
+	for name, adapter := range r.adapters {
+		adapters[name] = adapter
+	}
+	return adapters
+}
+
+// Stop stops the adapters created, they are created again on next use.
+func (r *Registry) Stop() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for name, adapter := range r.adapters {
+		adapter.Stop()
+		delete(r.adapters, name)
+	}
+	r.routes = nil
+}
+
+// names returns names of the adapters enabled in order, so the exchange served by several goes to the same one.
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.registrations))
+	for name := range r.registrations {
+		if r.config(name).Get(KeyEnabled, "true") != "false" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Registry) serves(name string, exchange string) bool {
+	exchanges := r.registrations[name].exchanges
+	if configured, ok := r.config(name).Lookup(KeyExchanges); ok {
+		exchanges = strings.Split(configured, ",")
+	}
+	for _, served := range exchanges {
+		if strings.TrimSpace(served) == exchange {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) config(name string) Config {
+	return Config{Name: name, values: r.values[name]}
+}
+
+func (r *Registry) adapter(name string) (Adapter, error) {
+	if adapter, ok := r.adapters[name]; ok {
+		return adapter, nil
+	}
+	adapter, err := r.registrations[name].factory(r.config(name))
+	if err != nil {
+		return nil, fmt.Errorf("adapter %v: %v", name, err)
+	}
+	if r.adapters == nil {
+		r.adapters = map[string]Adapter{}
+	}
+	r.adapters[name] = adapter
+	return adapter, nil
+}
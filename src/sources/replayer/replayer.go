@@ -0,0 +1,209 @@
+// Package replayer plays market data recorded by the recorder as a data feed, so post-mortems and regression tests
+// run on exact historical streams offline.
+package replayer
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/fanout"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/recorder"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Replayer is a data feed of recorded events applied in the order they were received. Its clock is the time the
+// last event applied was received at, data get stale by it.
+type Replayer struct {
+	Speed   float64 // 1 plays at the real speed, 10 ten times faster, 0 as fast as possible
+	Candles indicators.CandleStore
+	Updates fanout.Hub // <string: exchange+pair+marketType>
+
+	events  []recorder.Event
+	next    int
+	now     int64
+	mux     sync.RWMutex
+	prices  map[string]interfaces.OHLCV
+	spreads map[string]interfaces.SpreadData
+	marks   map[string]interfaces.MarkPrice
+	done    chan struct{}
+}
+
+// New returns the replayer of the events given.
+func New(events []recorder.Event) *Replayer {
+	sorted := append([]recorder.Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].At < sorted[j].At })
+	return &Replayer{
+		events:  sorted,
+		prices:  map[string]interfaces.OHLCV{},
+		spreads: map[string]interfaces.SpreadData{},
+		marks:   map[string]interfaces.MarkPrice{},
+		done:    make(chan struct{}),
+	}
+}
+
+// Load returns the replayer of events of the files written by the recorder.
+func Load(paths ...string) (*Replayer, error) {
+	var events []recorder.Event
+	for _, path := range paths {
+		fileEvents, err := recorder.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, fileEvents...)
+	}
+	return New(events), nil
+}
+
+// Run plays all the events at the speed set and closes Done.
+func (r *Replayer) Run() {
+	defer close(r.done)
+	for i := r.next; i < len(r.events); i++ {
+		if r.Speed > 0 && i > 0 {
+			gap := time.Duration(r.events[i].At-r.events[i-1].At) * time.Millisecond
+			time.Sleep(time.Duration(float64(gap) / r.Speed))
+		}
+		r.Step()
+	}
+}
+
+// Done is closed once Run played all the events.
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// Step applies the next event, returns false if there are none left.
+func (r *Replayer) Step() bool {
+	r.mux.Lock()
+	if r.next >= len(r.events) {
+		r.mux.Unlock()
+		return false
+	}
+	event := r.events[r.next]
+	r.next++
+	r.now = event.At
+	key := marketKey(event.Pair, event.Exchange, event.MarketType)
+	var update interfaces.Update
+	switch event.Kind {
+	case recorder.KindTick, recorder.KindCandle:
+		if event.Price == nil {
+			break
+		}
+		price := *event.Price
+		if event.Kind == recorder.KindTick {
+			eventTime := event.EventTime
+			if eventTime == 0 {
+				eventTime = event.At
+			}
+			r.Candles.AddTick(event.Pair, event.Exchange, event.MarketType, price.Close, price.Volume, time.Unix(0, eventTime*int64(time.Millisecond)))
+			if forming := r.Candles.GetCandles(event.Pair, event.Exchange, event.MarketType, 60, 1); len(forming) > 0 {
+				price = forming[0].OHLCV // feeds quote the forming minute candle
+				price.UpdatedAt = event.Price.UpdatedAt
+			}
+		} else {
+			r.Candles.AddTick(event.Pair, event.Exchange, event.MarketType, price.Close, 0, time.Unix(0, event.At*int64(time.Millisecond)))
+		}
+		if previous, ok := r.prices[key]; !ok || previous.Close != price.Close {
+			update.Price = &price
+		}
+		r.prices[key] = price
+	case recorder.KindSpread:
+		if event.Spread == nil {
+			break
+		}
+		spread := *event.Spread
+		if previous, ok := r.spreads[key]; !ok || previous.BestBid != spread.BestBid || previous.BestAsk != spread.BestAsk {
+			update.Spread = &spread
+		}
+		r.spreads[key] = spread
+	case recorder.KindMarkPrice:
+		if event.MarkPrice == nil {
+			break
+		}
+		markPrice := *event.MarkPrice
+		if previous, ok := r.marks[key]; !ok || previous.Mark != markPrice.Mark || previous.Index != markPrice.Index {
+			update.MarkPrice = &markPrice
+		}
+		r.marks[key] = markPrice
+	}
+	r.mux.Unlock()
+	if update.Price != nil || update.Spread != nil || update.MarkPrice != nil {
+		r.Updates.Publish(key, update)
+	}
+	return true
+}
+
+// Now returns the time the last event applied was received at.
+func (r *Replayer) Now() time.Time {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return time.Unix(0, r.now*int64(time.Millisecond))
+}
+
+func (r *Replayer) GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.OHLCV {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if price, ok := r.prices[marketKey(pair, exchange, marketType)]; ok {
+		return &price
+	}
+	return nil
+}
+
+func (r *Replayer) GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.SpreadData {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if spread, ok := r.spreads[marketKey(pair, exchange, marketType)]; ok {
+		return &spread
+	}
+	return nil
+}
+
+func (r *Replayer) IsStale(pair string, exchange string, marketType int64) bool {
+	price := r.GetPriceForPairAtExchange(pair, exchange, marketType)
+	return price == nil || staleness.IsStale(feedExchange(exchange), price.UpdatedAt, r.Now())
+}
+
+func (r *Replayer) GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	if candles := r.Candles.GetCandles(pair, feedExchange(exchange), marketType, timeframe, n); candles != nil {
+		return candles
+	}
+	return r.Candles.GetCandles(strings.Replace(pair, "_", "", -1), feedExchange(exchange), marketType, timeframe, n)
+}
+
+func (r *Replayer) GetMarkPrice(pair string, exchange string, marketType int64) *interfaces.MarkPrice {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if markPrice, ok := r.marks[marketKey(pair, exchange, marketType)]; ok {
+		return &markPrice
+	}
+	return nil
+}
+
+// GetOrderBook returns nil, books are not recorded.
+func (r *Replayer) GetOrderBook(pair string, exchange string, marketType int64, depth int) *interfaces.OrderBook {
+	return nil
+}
+
+func (r *Replayer) Subscribe(pair string, exchange string, marketType int64) <-chan interfaces.Update {
+	return r.Updates.Subscribe(marketKey(pair, exchange, marketType))
+}
+
+func (r *Replayer) Unsubscribe(pair string, exchange string, marketType int64, updates <-chan interfaces.Update) {
+	r.Updates.Unsubscribe(marketKey(pair, exchange, marketType), updates)
+}
+
+// marketKey matches pairs with and without the underscore as feeds record them differently, e.g. BTC_USDT and BTCUSDT.
+func marketKey(pair, exchange string, marketType int64) string {
+	return feedExchange(exchange) + strings.Replace(pair, "_", "", -1) + strconv.FormatInt(marketType, 10)
+}
+
+// feedExchange returns the exchange of the feed, strategies without one are served by binance.
+func feedExchange(exchange string) string {
+	if exchange == "" {
+		return "binance"
+	}
+	return exchange
+}
@@ -0,0 +1,75 @@
+package staleness
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxAge is the age data of exchanges without a max age of their own get stale after.
+const DefaultMaxAge = 30 * time.Second
+
+var defaultMaxAges = map[string]time.Duration{
+	"binance": 10 * time.Second, // tickers are pushed every second
+	"serum":   3 * time.Minute,  // 60 seconds candles
+}
+
+// A Tracker keeps the time feeds were updated at last.
+type Tracker struct {
+	updates sync.Map // <string: exchange.feed, update: last update>
+}
+
+type update struct {
+	exchange  string
+	updatedAt int64
+}
+
+// MaxAge returns the age data of the exchange given get stale after, FEED_MAX_AGE_<EXCHANGE> environment variable
+// in seconds overrides the default.
+func MaxAge(exchange string) time.Duration {
+	if value := os.Getenv("FEED_MAX_AGE_" + strings.ToUpper(exchange)); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if maxAge, ok := defaultMaxAges[exchange]; ok {
+		return maxAge
+	}
+	return DefaultMaxAge
+}
+
+// IsStale reports whether the data of the exchange updated at unix milliseconds given are older than the max age,
+// data with no update time are never stale.
+func IsStale(exchange string, updatedAt int64, now time.Time) bool {
+	if updatedAt == 0 {
+		return false
+	}
+	return now.Sub(time.Unix(0, updatedAt*int64(time.Millisecond))) > MaxAge(exchange)
+}
+
+// Now returns current time in unix milliseconds to stamp data with.
+func Now() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// Touch records the feed of the exchange given updated at unix milliseconds given.
+func (t *Tracker) Touch(exchange, feed string, updatedAt int64) {
+	t.updates.Store(exchange+"."+feed, update{exchange: exchange, updatedAt: updatedAt})
+}
+
+// StaleFeeds returns feeds not updated for longer than the max age of their exchange, feeds never updated are
+// skipped.
+func (t *Tracker) StaleFeeds(now time.Time) []string {
+	var stale []string
+	t.updates.Range(func(feed, value interface{}) bool {
+		if last := value.(update); IsStale(last.exchange, last.updatedAt, now) {
+			stale = append(stale, feed.(string))
+		}
+		return true
+	})
+	sort.Strings(stale)
+	return stale
+}
@@ -0,0 +1,183 @@
+// Package supervisor keeps market data stream connections up, reconnecting them on failures, stalls and before the
+// exchange drops them by connection lifetime.
+package supervisor
+
+import (
+	"gitlab.com/crypto_project/core/strategy_service/src/logging"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"go.uber.org/zap"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stream connection states.
+const (
+	Connecting = "connecting"
+	Connected  = "connected"
+	BackingOff = "backingOff"
+	Stopped    = "stopped"
+)
+
+// Defaults for a stream without its own settings.
+const (
+	DefaultMinBackoff  = 1 * time.Second
+	DefaultMaxBackoff  = 2 * time.Minute
+	DefaultMaxLifetime = 23 * time.Hour // Binance drops connections after 24 hours
+)
+
+// stableAfter is how long a connection should live to reset the backoff.
+const stableAfter = 1 * time.Minute
+
+var log interfaces.ILogger
+
+func init() {
+	logger, _ := logging.GetZapLogger()
+	log = logger.With(zap.String("logger", "supervisor"))
+}
+
+// A Connect opens a stream connection returning its messages, a channel closed once the connection is lost and a
+// function to close it. A nil message tells the connection is alive without data, e.g. on ping.
+type Connect func() (messages <-chan []byte, done <-chan struct{}, closeConn func(), err error)
+
+// A Stream keeps a connection up, reconnecting with exponential backoff and jitter once it fails or no messages come
+// for MaxGap. The connection is rotated proactively after MaxLifetime.
+type Stream struct {
+	Name        string
+	Connect     Connect
+	OnMessage   func(data []byte)
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	MaxLifetime time.Duration
+	MaxGap      time.Duration // no watchdog if 0
+	state       atomic.Value
+	reconnects  int64
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewStream instantiates a stream with default backoff and lifetime and no watchdog.
+func NewStream(name string, connect Connect, onMessage func(data []byte)) *Stream {
+	return &Stream{
+		Name:        name,
+		Connect:     connect,
+		OnMessage:   onMessage,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		MaxLifetime: DefaultMaxLifetime,
+		stop:        make(chan struct{}),
+	}
+}
+
+// State returns the connection state.
+func (s *Stream) State() string {
+	if state, ok := s.state.Load().(string); ok {
+		return state
+	}
+	return Connecting
+}
+
+// Reconnects returns how many times the connection was opened again.
+func (s *Stream) Reconnects() int64 {
+	return atomic.LoadInt64(&s.reconnects)
+}
+
+// Stop closes the connection and ends Run.
+func (s *Stream) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Run keeps the connection up until stopped.
+func (s *Stream) Run() {
+	backoff := s.MinBackoff
+	for connects := 0; ; connects++ {
+		if s.isStopped() {
+			s.state.Store(Stopped)
+			return
+		}
+		if connects > 0 {
+			atomic.AddInt64(&s.reconnects, 1)
+		}
+		s.state.Store(Connecting)
+		messages, done, closeConn, err := s.Connect()
+		if err != nil {
+			log.Error("stream connect", zap.String("stream", s.Name), zap.Error(err))
+			backoff = s.backOff(backoff)
+			continue
+		}
+		s.state.Store(Connected)
+		connectedAt := time.Now()
+		reason := s.consume(messages, done)
+		closeConn()
+		log.Warn("stream disconnected", zap.String("stream", s.Name), zap.String("reason", reason))
+		if reason == "rotation" || reason == "stop" {
+			backoff = s.MinBackoff
+			continue
+		}
+		if time.Since(connectedAt) > stableAfter {
+			backoff = s.MinBackoff
+		}
+		backoff = s.backOff(backoff)
+	}
+}
+
+// consume passes messages until the connection ends, returns the reason it ended for.
+func (s *Stream) consume(messages <-chan []byte, done <-chan struct{}) string {
+	rotation := time.NewTimer(s.MaxLifetime)
+	defer rotation.Stop()
+	var watchdog <-chan time.Time
+	if s.MaxGap > 0 {
+		ticker := time.NewTicker(s.MaxGap / 2)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	lastMessageAt := time.Now()
+	for {
+		select {
+		case data, ok := <-messages:
+			if !ok {
+				return "closed"
+			}
+			lastMessageAt = time.Now()
+			if data != nil {
+				s.OnMessage(data)
+			}
+		case <-done:
+			return "closed"
+		case <-rotation.C:
+			return "rotation"
+		case <-watchdog:
+			if time.Since(lastMessageAt) > s.MaxGap {
+				return "stalled"
+			}
+		case <-s.stop:
+			return "stop"
+		}
+	}
+}
+
+// backOff waits for the backoff given with jitter unless stopped, returns the next backoff.
+func (s *Stream) backOff(backoff time.Duration) time.Duration {
+	s.state.Store(BackingOff)
+	wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	select {
+	case <-time.After(wait):
+	case <-s.stop:
+	}
+	if backoff *= 2; backoff > s.MaxBackoff {
+		backoff = s.MaxBackoff
+	}
+	return backoff
+}
+
+func (s *Stream) isStopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
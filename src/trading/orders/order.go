@@ -11,6 +11,15 @@ type OrderParams struct {
 	RetryCount     int                            `json:"retryCount,omitempty"`
 	Update         bool                           `json:"update,omitempty"`
 	SmartOrder     *models.MongoStrategyCondition `json:"smartOrder,omitempty"`
+
+	// maker-only chase limits, see MongoStrategyCondition
+	MakerMaxDistance     float64 `json:"makerMaxDistance,omitempty"`
+	MakerMaxChaseTime    int64   `json:"makerMaxChaseTime,omitempty"`
+	MakerRepriceInterval int64   `json:"makerRepriceInterval,omitempty"`
+	MakerFallback        string  `json:"makerFallback,omitempty"`
+
+	// WorkingType is the price futures stop orders trigger by, MARK_PRICE or CONTRACT_PRICE
+	WorkingType string `json:"workingType,omitempty"`
 }
 
 type Order struct {
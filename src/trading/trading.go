@@ -235,6 +235,24 @@ func (t *Trading) PlaceHedge(parentSmartOrder *models.MongoStrategy) orders.Orde
 	return response
 }
 
+// PlaceCopy creates a follower smart order with conditions given on the follower key the same way as hedge is created.
+func (t *Trading) PlaceCopy(keyId *primitive.ObjectID, conditions *models.MongoStrategyCondition) orders.OrderResponse {
+	createRequest := orders.CreateOrderRequest{
+		KeyId: keyId,
+		KeyParams: orders.Order{
+			Type: "smart",
+			Params: orders.OrderParams{
+				SmartOrder: conditions,
+			},
+		},
+	}
+
+	rawResponse := Request("createOrder", createRequest)
+	var response orders.OrderResponse
+	_ = mapstructure.Decode(rawResponse, &response)
+	return response
+}
+
 func (t *Trading) Transfer(request orders.TransferRequest) orders.OrderResponse {
 	rawResponse := Request("transfer", request)
 
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
 	"gitlab.com/crypto_project/core/strategy_service/src/sources"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/replayer"
 
 	"github.com/qmuntal/stateless"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
@@ -12,16 +14,35 @@ import (
 	"gitlab.com/crypto_project/core/strategy_service/tests"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+// marketData replays recorded files MARKET_DATA_REPLAY lists separated by commas at the real speed or takes live data
+// if it's not set.
+func marketData(t *testing.T) interfaces.IDataFeed {
+	files := os.Getenv("MARKET_DATA_REPLAY")
+	if files == "" {
+		df := sources.InitDataFeed()
+		time.Sleep(15 * time.Second)
+		return df
+	}
+	feed, err := replayer.Load(strings.Split(files, ",")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed.Speed = 1
+	go feed.Run()
+	return feed
+}
+
 func TestRealSerumDataEntry(t *testing.T) {
 	smartOrderModel := GetTestSmartOrderStrategy("simpleEntry")
 	// price dips in the middle (This has no meaning now, reuse and then remove fake data stream)
-	df := sources.InitDataFeed()
-	time.Sleep(15 * time.Second)
+	df := marketData(t)
 	tradingApi := tests.NewMockedTradingAPI()
 	keyId := primitive.NewObjectID()
 	sm := tests.NewMockedStateMgmtWithOpts(tradingApi, df, "BTC_USDT", "serum", 0)
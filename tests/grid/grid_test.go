@@ -0,0 +1,202 @@
+package grid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/grid"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func gridConditions(marketType int64, bias string) *models.MongoStrategyCondition {
+	return &models.MongoStrategyCondition{
+		Pair:           "BTC_USDT",
+		MarketType:     marketType,
+		GridLowerPrice: 90,
+		GridUpperPrice: 110,
+		GridLevels:     5,
+		GridAmount:     0.01,
+		GridBias:       bias,
+	}
+}
+
+func runGrid(conditions *models.MongoStrategyCondition, feed []interfaces.OHLCV) (*models.MongoStrategy, *tests.MockTrading, *tests.MockStateMgmt) {
+	return runGridOn(tests.NewMockedTradingAPI(), conditions, feed)
+}
+
+func runGridOn(tradingApi *tests.MockTrading, conditions *models.MongoStrategyCondition, feed []interfaces.OHLCV) (*models.MongoStrategy, *tests.MockTrading, *tests.MockStateMgmt) {
+	strategyId := primitive.NewObjectID()
+	model := models.MongoStrategy{
+		ID:         &strategyId,
+		Enabled:    true,
+		Type:       3,
+		Conditions: conditions,
+		State:      &models.MongoStrategyState{},
+	}
+	df := tests.NewMockedDataFeed(feed)
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, statsd := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &model,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	runtime := grid.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	go runtime.Start()
+	time.Sleep(1500 * time.Millisecond)
+	return &model, tradingApi, &sm
+}
+
+// levels should be evenly spaced and the closest to price skipped with buys below and sells above
+func TestGridInitialOrders(t *testing.T) {
+	gridOrders, marketAmount := grid.InitialOrders(gridConditions(1, grid.Neutral), 101)
+	if marketAmount != 0 {
+		t.Errorf("expected no market order for neutral grid, got %v", marketAmount)
+	}
+	expected := []struct {
+		side  string
+		price float64
+	}{{"buy", 90}, {"buy", 95}, {"sell", 105}, {"sell", 110}}
+	if len(gridOrders) != len(expected) {
+		t.Fatalf("expected %d orders, got %d", len(expected), len(gridOrders))
+	}
+	for i, gridOrder := range gridOrders {
+		if gridOrder.Side != expected[i].side || gridOrder.Price != expected[i].price || gridOrder.EntryPrice != 0 {
+			t.Errorf("unexpected order %d: %+v", i, gridOrder)
+		}
+	}
+
+	_, marketAmount = grid.InitialOrders(gridConditions(1, grid.Short), 101)
+	if marketAmount != -0.02 {
+		t.Errorf("expected short grid to sell 0.02 at market, got %v", marketAmount)
+	}
+	_, marketAmount = grid.InitialOrders(gridConditions(0, grid.Short), 101)
+	if marketAmount != 0.02 {
+		t.Errorf("expected spot grid to be long and buy 0.02 at market, got %v", marketAmount)
+	}
+}
+
+// a fill should place the opposite order one level away and realise profit against the entry
+func TestGridNextOrder(t *testing.T) {
+	levels := grid.Levels(gridConditions(1, grid.Neutral))
+	filled := &models.MongoGridOrder{Level: 1, Side: "buy", Price: 95, Amount: 0.01}
+	next := grid.NextOrder(levels, filled, 95, 0.01)
+	if next == nil || next.Side != "sell" || next.Price != 100 || next.EntryPrice != 95 {
+		t.Fatalf("expected sell at 100 with entry 95, got %+v", next)
+	}
+	if pnl := grid.RealizedPnl(next, 100, 0.01); pnl < 0.0499 || pnl > 0.0501 {
+		t.Errorf("expected pnl 0.05, got %v", pnl)
+	}
+	if pnl := grid.RealizedPnl(filled, 95, 0.01); pnl != 0 {
+		t.Errorf("expected no pnl for opening fill, got %v", pnl)
+	}
+	if next := grid.NextOrder(levels, &models.MongoGridOrder{Level: 0, Side: "sell"}, 90, 0.01); next != nil {
+		t.Errorf("expected no order below the lowest level, got %+v", next)
+	}
+}
+
+// spot grid should buy sells amount at market and place limit orders on levels
+func TestGridPlacing(t *testing.T) {
+	model, tradingApi, _ := runGrid(gridConditions(0, ""), []interfaces.OHLCV{{Open: 100, High: 100, Low: 100, Close: 100}})
+	if buyCallCount, _ := tradingApi.CallCount.Load("buy"); buyCallCount != 3 {
+		t.Errorf("expected market buy and 2 limit buys, got %v", buyCallCount)
+	}
+	if sellCallCount, _ := tradingApi.CallCount.Load("sell"); sellCallCount != 2 {
+		t.Errorf("expected 2 limit sells, got %v", sellCallCount)
+	}
+	if model.State.State != grid.Active || len(model.State.GridOrders) != 4 || model.State.GridPosition != 0.02 {
+		t.Errorf("unexpected grid state %+v", model.State)
+	}
+}
+
+// an order failed to place should be kept without id and placed again on retry
+func TestGridPlaceOrderRetry(t *testing.T) {
+	tradingApi := tests.NewMockedTradingAPI()
+	tradingApi.Rejects.Store("limitbuy", 1)
+	model, _, _ := runGridOn(tradingApi, gridConditions(0, ""), []interfaces.OHLCV{{Close: 100}})
+	pending := 0
+	for _, gridOrder := range model.State.GridOrders {
+		if gridOrder.OrderId == "" {
+			pending++
+		}
+	}
+	if pending != 1 || len(model.State.GridOrders) != 4 {
+		t.Fatalf("expected the order rejected kept for retry, got %v pending of %v", pending, len(model.State.GridOrders))
+	}
+
+	time.Sleep(2 * time.Second)
+	for _, gridOrder := range model.State.GridOrders {
+		if gridOrder.OrderId == "" {
+			t.Errorf("expected the order rejected placed on retry, got %+v", gridOrder)
+		}
+	}
+	if model.State.State != grid.Active {
+		t.Errorf("expected grid active, got %v", model.State.State)
+	}
+}
+
+// leaving the stop bounds should close the position and end the grid
+func TestGridStopBounds(t *testing.T) {
+	conditions := gridConditions(1, grid.Long)
+	conditions.GridStopLowerPrice = 80
+	feed := []interfaces.OHLCV{{Close: 100}, {Close: 100}, {Close: 70}} // placing and the first check, then out of bounds
+	model, tradingApi, _ := runGrid(conditions, feed)
+	if model.State.State != grid.End || model.State.GridPosition != 0 || len(model.State.GridOrders) != 0 {
+		t.Errorf("expected grid closed, got %+v", model.State)
+	}
+	if model.State.GridRealizedPnl >= 0 {
+		t.Errorf("expected loss realised below the range, got %v", model.State.GridRealizedPnl)
+	}
+	if amount, _ := tradingApi.AmountSum.Load("BTC_USDTsell"); amount == nil || amount.(float64) < 0.02 {
+		t.Errorf("expected position sold at market, got %v", amount)
+	}
+}
+
+// an order canceled outside the grid should be countered for the amount it filled and placed again for the rest
+func TestGridCanceledOrder(t *testing.T) {
+	model, tradingApi, sm := runGrid(gridConditions(0, grid.Neutral), []interfaces.OHLCV{{Close: 100}})
+	var canceled *models.MongoGridOrder
+	for _, gridOrder := range model.State.GridOrders {
+		if gridOrder.Side == "buy" && gridOrder.Price == 95 {
+			canceled = gridOrder
+		}
+	}
+	if canceled == nil {
+		t.Fatalf("expected buy at 95 placed, got %+v", model.State.GridOrders)
+	}
+	orderRaw, _ := tradingApi.OrdersMap.Load(canceled.OrderId)
+	order := orderRaw.(models.MongoOrder)
+	order.Status = "canceled"
+	order.Filled = 0.004
+	order.Average = 95
+	callback, _ := sm.OrderCallbacks.Load(canceled.OrderId)
+	callback.(func(order *models.MongoOrder))(&order)
+
+	var counter, rest *models.MongoGridOrder
+	for _, gridOrder := range model.State.GridOrders {
+		if gridOrder.Side == "sell" && gridOrder.Price == 100 {
+			counter = gridOrder
+		}
+		if gridOrder.Side == "buy" && gridOrder.Price == 95 {
+			rest = gridOrder
+		}
+	}
+	if counter == nil || counter.Amount != 0.004 || counter.EntryPrice != 95 {
+		t.Errorf("expected sell of the amount filled at 100, got %+v", counter)
+	}
+	if rest == nil || rest.OrderId == canceled.OrderId || rest.Amount < 0.0059 || rest.Amount > 0.0061 {
+		t.Errorf("expected buy of the rest placed again at 95, got %+v", rest)
+	}
+	if model.State.GridPosition < 0.0239 || model.State.GridPosition > 0.0241 {
+		t.Errorf("expected position grown by the amount filled to 0.024, got %v", model.State.GridPosition)
+	}
+}
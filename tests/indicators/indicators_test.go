@@ -0,0 +1,212 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/indicators"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+)
+
+func candlesOf(closes ...float64) []interfaces.Candle {
+	candles := make([]interfaces.Candle, len(closes))
+	for i, close := range closes {
+		candles[i] = interfaces.Candle{
+			OHLCV:     interfaces.OHLCV{Open: close, High: close, Low: close, Close: close},
+			Timestamp: int64(i * 60),
+		}
+	}
+	return candles
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// ticks within a timeframe should form one candle, gaps should be filled with flat candles
+func TestCandleAggregator(t *testing.T) {
+	aggregator := indicators.NewCandleAggregator(60, 3)
+	start := time.Unix(6000, 0)
+	aggregator.AddTick(100, 1, start)
+	aggregator.AddTick(105, 1, start.Add(10*time.Second))
+	aggregator.AddTick(95, 1, start.Add(20*time.Second))
+	aggregator.AddTick(101, 1, start.Add(59*time.Second))
+	candles := aggregator.GetCandles()
+	if len(candles) != 1 {
+		t.Fatalf("expected 1 candle, got %v", len(candles))
+	}
+	expected := interfaces.OHLCV{Open: 100, High: 105, Low: 95, Close: 101, Volume: 4}
+	if candles[0].OHLCV != expected || candles[0].Timestamp != 6000 {
+		t.Errorf("expected candle %+v at 6000, got %+v", expected, candles[0])
+	}
+
+	aggregator.AddTick(110, 0, start.Add(180*time.Second))
+	candles = aggregator.GetCandles()
+	if len(candles) != 3 {
+		t.Fatalf("expected 3 candles limited, got %v", len(candles))
+	}
+	if candles[0].Timestamp != 6060 || candles[0].Close != 101 || candles[1].Close != 101 || candles[2].Open != 110 {
+		t.Errorf("unexpected candles after gap: %+v", candles)
+	}
+}
+
+// the oldest candles should be overwritten once the limit reached
+func TestCandleAggregatorRingBuffer(t *testing.T) {
+	aggregator := indicators.NewCandleAggregator(1, 4)
+	start := time.Unix(6000, 0)
+	for i := 0; i < 10; i++ {
+		aggregator.AddTick(float64(100+i), 1, start.Add(time.Duration(i)*time.Second))
+	}
+	candles := aggregator.GetCandles()
+	if len(candles) != 4 || candles[0].Close != 106 || candles[3].Close != 109 || candles[3].Timestamp != 6009 {
+		t.Errorf("expected candles from 106 to 109, got %+v", candles)
+	}
+	if last := aggregator.GetLastCandles(2); len(last) != 2 || last[0].Close != 108 || last[1].Close != 109 {
+		t.Errorf("expected last candles of 108 and 109, got %+v", last)
+	}
+
+	aggregator.AddTick(120, 1, start.Add(time.Hour))
+	if candles = aggregator.GetCandles(); len(candles) != 4 || candles[0].Close != 109 || candles[3].Close != 120 {
+		t.Errorf("expected long gap filled within the limit, got %+v", candles)
+	}
+}
+
+// ticks should form candles of every timeframe aggregated for the market
+func TestCandleStore(t *testing.T) {
+	var store indicators.CandleStore
+	start := time.Unix(3600*100, 0)
+	store.AddTick("BTCUSDT", "binance", 1, 100, 2, start)
+	store.AddTick("BTCUSDT", "binance", 1, 110, 1, start.Add(90*time.Second))
+	store.AddTick("BTCUSDT", "binance", 1, 90, 1, start.Add(400*time.Second))
+
+	if candles := store.GetCandles("BTCUSDT", "binance", 1, 60, 10); len(candles) != 7 || candles[1].Close != 110 {
+		t.Errorf("expected 7 minute candles, got %+v", candles)
+	}
+	fiveMinutes := store.GetCandles("BTCUSDT", "binance", 1, 300, 10)
+	expected := interfaces.OHLCV{Open: 100, High: 110, Low: 100, Close: 110, Volume: 3}
+	if len(fiveMinutes) != 2 || fiveMinutes[0].OHLCV != expected {
+		t.Errorf("expected 5 minutes candle %+v, got %+v", expected, fiveMinutes)
+	}
+	if hour := store.GetCandles("BTCUSDT", "binance", 1, 3600, 10); len(hour) != 1 || hour[0].Low != 90 || hour[0].Volume != 4 {
+		t.Errorf("expected one hour candle, got %+v", hour)
+	}
+	if store.GetCandles("BTCUSDT", "binance", 0, 60, 10) != nil || store.GetCandles("BTCUSDT", "binance", 1, 900, 10) != nil {
+		t.Error("expected no candles of other market or timeframe not aggregated")
+	}
+}
+
+func TestMovingAverages(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5}
+	if sma, ok := indicators.SimpleMovingAverage(values, 3); !ok || sma != 4 {
+		t.Errorf("expected sma 4, got %v", sma)
+	}
+	// seed 2 from first 3 values, then k = 0.5
+	if ema, ok := indicators.ExponentialMovingAverage(values, 3); !ok || ema != 4 {
+		t.Errorf("expected ema 4, got %v", ema)
+	}
+	if _, ok := indicators.SimpleMovingAverage(values, 6); ok {
+		t.Error("sma should not be computed without enough values")
+	}
+}
+
+func TestRelativeStrengthIndex(t *testing.T) {
+	if rsi, ok := indicators.RelativeStrengthIndex([]float64{1, 2, 3, 4, 5}, 4); !ok || rsi != 100 {
+		t.Errorf("expected rsi 100 for gains only, got %v", rsi)
+	}
+	// average gain 1, average loss 0.5
+	if rsi, ok := indicators.RelativeStrengthIndex([]float64{10, 11, 10.5, 11.5, 11}, 4); !ok || !almostEqual(rsi, 100-100/(1+2.0/1)) {
+		t.Errorf("unexpected rsi %v", rsi)
+	}
+}
+
+func TestBollingerBandsAndVWAP(t *testing.T) {
+	lower, upper, ok := indicators.BollingerBands([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	if !ok || lower != 1 || upper != 9 {
+		t.Errorf("expected bands 1 and 9, got %v and %v", lower, upper)
+	}
+	candles := candlesOf(10, 20)
+	candles[1].Volume = 3
+	candles[0].Volume = 1
+	if vwap, ok := indicators.VolumeWeightedAveragePrice(candles, 0); !ok || vwap != 17.5 {
+		t.Errorf("expected vwap 17.5, got %v", vwap)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cross := &models.MongoIndicatorCondition{Indicator: indicators.SMA, Period: 2, SlowPeriod: 4, Operator: indicators.CrossAbove}
+	if indicators.Evaluate(cross, candlesOf(10, 9, 8, 7, 8)) {
+		t.Error("fast sma should not cross above slow one yet")
+	}
+	if !indicators.Evaluate(cross, candlesOf(10, 9, 8, 7, 8, 11)) {
+		t.Error("fast sma should cross above slow one")
+	}
+	if indicators.Evaluate(cross, candlesOf(10, 9, 8, 7, 8, 11, 12)) {
+		t.Error("cross should hold one candle only")
+	}
+
+	rsi := &models.MongoIndicatorCondition{Indicator: indicators.RSI, Period: 3, Operator: indicators.Below, Value: 30}
+	if !indicators.Evaluate(rsi, candlesOf(10, 9, 8, 7)) {
+		t.Error("rsi should be below 30 on losses only")
+	}
+
+	bollinger := &models.MongoIndicatorCondition{Indicator: indicators.Bollinger, Period: 4, Operator: indicators.Above, Value: 1}
+	if !indicators.Evaluate(bollinger, candlesOf(10, 10, 10, 14)) {
+		t.Error("price should be above the upper band")
+	}
+
+	vwap := &models.MongoIndicatorCondition{Indicator: indicators.VWAP, Period: 4, Operator: indicators.Below, Value: -5}
+	if !indicators.Evaluate(vwap, candlesOf(100, 100, 100, 80)) {
+		t.Error("price should be more than 5% below vwap")
+	}
+	if indicators.Evaluate(vwap, candlesOf(100, 100)) {
+		t.Error("condition should not hold without enough candles")
+	}
+}
+
+func TestAverageTrueRange(t *testing.T) {
+	candles := candlesOf(10, 12, 11, 13)
+	candles[1].High, candles[1].Low = 13, 11 // true range 3 due to previous close
+	candles[2].High, candles[2].Low = 12, 10 // 2
+	candles[3].High, candles[3].Low = 14, 12 // 3
+	if atr, ok := indicators.AverageTrueRange(candles, 2); !ok || atr != 2.75 {
+		t.Errorf("expected atr 2.75, got %v", atr)
+	}
+	if _, ok := indicators.AverageTrueRange(candles, 4); ok {
+		t.Error("atr should not be computed without enough candles")
+	}
+}
+
+// watched markets should be polled before candles are asked for, so history fills up from the watch on
+func TestWatchCandles(t *testing.T) {
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 7000}})
+	release := indicators.Watch(df, "WATCH_USDT", "binance", 0, 1)
+	defer release()
+	time.Sleep(2500 * time.Millisecond)
+	if candles := indicators.GetCandles(df, "WATCH_USDT", "binance", 0, 1); len(candles) < 2 || candles[0].Close != 7000 {
+		t.Errorf("expected second candles polled since the watch, got %+v", candles)
+	}
+	if indicators.Watch(df, "WATCH_USDT", "binance", 0) == nil {
+		t.Error("expected a release of a watch without timeframes")
+	}
+}
+
+// on demand, markets should be aggregated from the first ask for their candles only
+func TestCandleStoreOnDemand(t *testing.T) {
+	store := indicators.CandleStore{OnDemand: true}
+	start := time.Unix(3600*100, 0)
+	store.AddTick("BTCUSDT", "binance", 1, 100, 1, start)
+	if candles := store.GetCandles("BTCUSDT", "binance", 1, 60, 10); candles == nil || len(candles) != 0 {
+		t.Fatalf("expected no candles before the first ask, got %+v", candles)
+	}
+	store.AddTick("BTCUSDT", "binance", 1, 110, 1, start.Add(60*time.Second))
+	store.AddTick("ETHUSDT", "binance", 1, 10, 1, start.Add(60*time.Second))
+	if candles := store.GetCandles("BTCUSDT", "binance", 1, 60, 10); len(candles) != 1 || candles[0].Close != 110 {
+		t.Errorf("expected one candle since the first ask, got %+v", candles)
+	}
+	if candles := store.GetCandles("ETHUSDT", "binance", 1, 60, 10); len(candles) != 0 {
+		t.Errorf("expected ticks of the market not asked for skipped, got %+v", candles)
+	}
+}
@@ -0,0 +1,134 @@
+package maker_only
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/makeronly_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// chasingBuy returns maker-only buy of 0.01 with the best bids given, posted orders never fill.
+func chasingBuy(bids []float64, conditions func(*models.MongoStrategyCondition)) (*makeronly_order.MakerOnlyOrder, *models.MongoStrategy, *tests.MockTrading, *tests.MockStateMgmt) {
+	model := GetTestMakerOnlyOrderStrategy("entryLong")
+	makerOrderId := primitive.NewObjectID()
+	model.Conditions.Pair = "BTC_USDT"
+	model.Conditions.MarketType = 1
+	model.Conditions.MakerOrderId = &makerOrderId
+	model.Conditions.EntryOrder = &models.MongoEntryPoint{Side: "buy", Amount: 0.01, OrderType: "limit"}
+	conditions(model.Conditions)
+	spreads := make([]interfaces.SpreadData, 0, len(bids))
+	for _, bid := range bids {
+		spreads = append(spreads, interfaces.SpreadData{BestBid: bid, BestAsk: bid + 1})
+	}
+	df := tests.NewMockedSpreadDataFeed(spreads, []interfaces.OHLCV{{Close: 99999}})
+	tradingApi := tests.NewMockedTradingAPI()
+	tradingApi.BuyDelay = 10
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, stats := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:     &model,
+		StateMgmt: &sm,
+		Log:       logger,
+		Statsd:    stats,
+	}
+	keyId := primitive.NewObjectID()
+	return makeronly_order.NewMakerOnlyOrder(&strategy, df, tradingApi, &keyId, &sm), &model, tradingApi, &sm
+}
+
+func lastCreatedOrder(tradingApi *tests.MockTrading) models.MongoOrder {
+	return tradingApi.CreatedOrders.Back().Value.(models.MongoOrder)
+}
+
+// best bid changes should be followed not more often than the re-price interval
+func TestMakerOnlyChaseRepriceInterval(t *testing.T) {
+	makerOnly, model, tradingApi, _ := chasingBuy([]float64{7000, 7005, 7005}, func(conditions *models.MongoStrategyCondition) {
+		conditions.MakerRepriceInterval = 10
+	})
+	now := time.Now()
+	makerOnly.Chase(now)
+	makerOnly.Chase(now.Add(5 * time.Second))
+	if tradingApi.CreatedOrders.Len() != 1 {
+		t.Fatalf("expected no re-price within the interval, got %v orders", tradingApi.CreatedOrders.Len())
+	}
+	makerOnly.Chase(now.Add(10 * time.Second))
+	if tradingApi.CreatedOrders.Len() != 2 || lastCreatedOrder(tradingApi).Average != 7005 {
+		t.Fatalf("expected re-price at 7005, got %v orders", tradingApi.CreatedOrders.Len())
+	}
+	if model.State.MakerInitialPrice != 7000 || model.State.MakerPrice != 7005 {
+		t.Errorf("expected chase from 7000 at 7005, got %v %v", model.State.MakerInitialPrice, model.State.MakerPrice)
+	}
+}
+
+// price moving away further than max distance should fall back to immediate-or-cancel limit at the distance
+func TestMakerOnlyChaseDistanceFallback(t *testing.T) {
+	makerOnly, model, tradingApi, _ := chasingBuy([]float64{7000, 7010}, func(conditions *models.MongoStrategyCondition) {
+		conditions.MakerMaxDistance = 0.1
+		conditions.MakerFallback = models.MakerFallbackIOC
+	})
+	now := time.Now()
+	makerOnly.Chase(now)
+	makerOnly.Chase(now.Add(time.Second))
+	order := lastCreatedOrder(tradingApi)
+	if tradingApi.CreatedOrders.Len() != 2 || order.Type != "limit" || order.Average != 7007 {
+		t.Fatalf("expected fallback limit at 7007, got %v %v", order.Type, order.Average)
+	}
+	if model.State.State != makeronly_order.Fallback || model.State.EntryOrderId != order.OrderId {
+		t.Errorf("expected fallback order waited, got %v %v", model.State.State, model.State.EntryOrderId)
+	}
+	makerOnly.Chase(now.Add(2 * time.Second))
+	if tradingApi.CreatedOrders.Len() != 2 {
+		t.Error("expected no chase after fallback")
+	}
+}
+
+// max chase time should take the rest at market and fill the maker-only order
+func TestMakerOnlyChaseTimeFallback(t *testing.T) {
+	makerOnly, _, tradingApi, _ := chasingBuy([]float64{7000}, func(conditions *models.MongoStrategyCondition) {
+		conditions.MakerMaxChaseTime = 60
+		conditions.MakerFallback = models.MakerFallbackMarket
+	})
+	now := time.Now()
+	makerOnly.Chase(now)
+	makerOnly.Chase(now.Add(30 * time.Second))
+	makerOnly.Chase(now.Add(60 * time.Second))
+	if order := lastCreatedOrder(tradingApi); tradingApi.CreatedOrders.Len() != 2 || order.Type != "market" || order.Filled != 0.01 {
+		t.Fatalf("expected market order for 0.01, got %v %v", order.Type, order.Filled)
+	}
+	var state interface{}
+	for i := 0; i < 20 && state != makeronly_order.Filled; i++ {
+		time.Sleep(50 * time.Millisecond)
+		state, _ = makerOnly.State.State(context.Background())
+	}
+	if state != makeronly_order.Filled {
+		t.Errorf("expected filled by the fallback, got %v", state)
+	}
+}
+
+// limit hit should cancel with cancel fallback and keep the order resting without fallback
+func TestMakerOnlyChaseCancelOrHold(t *testing.T) {
+	makerOnly, model, _, _ := chasingBuy([]float64{7000, 7010}, func(conditions *models.MongoStrategyCondition) {
+		conditions.MakerMaxDistance = 0.1
+		conditions.MakerFallback = models.MakerFallbackCancel
+	})
+	now := time.Now()
+	makerOnly.Chase(now)
+	makerOnly.Chase(now.Add(time.Second))
+	if model.Enabled || model.State.State != makeronly_order.Fallback {
+		t.Errorf("expected maker-only order canceled, got enabled %v in %v", model.Enabled, model.State.State)
+	}
+
+	makerOnly, model, tradingApi, _ := chasingBuy([]float64{7000, 7010}, func(conditions *models.MongoStrategyCondition) {
+		conditions.MakerMaxDistance = 0.1
+	})
+	makerOnly.Chase(now)
+	makerOnly.Chase(now.Add(time.Second))
+	if !model.Enabled || tradingApi.CreatedOrders.Len() != 1 || model.State.MakerPrice != 7000 {
+		t.Errorf("expected order resting at 7000, got %v orders at %v", tradingApi.CreatedOrders.Len(), model.State.MakerPrice)
+	}
+}
@@ -0,0 +1,92 @@
+package maker_only
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/makeronly_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+)
+
+// fillWhenSubscribed fills the order given partially once the maker-only order waits for it.
+func fillWhenSubscribed(t *testing.T, sm *tests.MockStateMgmt, orderId string, filled float64) {
+	for i := 0; i < 20; i++ {
+		if _, ok := sm.OrderCallbacks.Load(orderId); ok {
+			sm.FillOrderPartially(orderId, filled)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("order %v not waited", orderId)
+}
+
+// re-priced order should carry the rest only and the synthetic order should get the total filled at average price
+func TestMakerOnlyPartialFills(t *testing.T) {
+	makerOnly, model, tradingApi, sm := chasingBuy([]float64{7000, 7005}, func(conditions *models.MongoStrategyCondition) {})
+	now := time.Now()
+	makerOnly.Chase(now)
+	fillWhenSubscribed(t, sm, "BTC_USDT1", 0.004)
+	if state, _ := makerOnly.State.State(context.Background()); state != makeronly_order.PartiallyFilled || model.State.ExecutedAmount != 0.004 {
+		t.Fatalf("expected 0.004 partially filled, got %v in %v", model.State.ExecutedAmount, state)
+	}
+
+	makerOnly.Chase(now.Add(time.Second))
+	if order := lastCreatedOrder(tradingApi); order.OrderId != "BTC_USDT2" || order.Filled != 0.006 {
+		t.Fatalf("expected the rest of 0.006 re-posted, got %v", order.Filled)
+	}
+	fillWhenSubscribed(t, sm, "BTC_USDT2", 0.006)
+	if state, _ := makerOnly.State.State(context.Background()); state != makeronly_order.Filled {
+		t.Fatalf("expected filled up, got %v", state)
+	}
+	if model.State.ExecutedAmount != 0.01 || math.Abs(model.State.EntryPrice-7003) > 1e-9 {
+		t.Errorf("expected 0.01 filled at 7003, got %v at %v", model.State.ExecutedAmount, model.State.EntryPrice)
+	}
+	time.Sleep(50 * time.Millisecond) // let the synthetic order saved
+	saved, ok := sm.SavedOrdersMap.Load(makerOnly.MakerOnlyOrder.OrderId)
+	if !ok {
+		t.Fatal("synthetic order not saved")
+	}
+	if order := saved.(models.MongoOrder); order.Status != "filled" || order.Filled != 0.01 || math.Abs(order.Average-7003) > 1e-9 {
+		t.Errorf("expected synthetic order filled 0.01 at 7003, got %v %v at %v", order.Status, order.Filled, order.Average)
+	}
+}
+
+// fills repeated by the order updates should be taken once
+func TestMakerOnlyFillsTakenOnce(t *testing.T) {
+	makerOnly, model, _, sm := chasingBuy([]float64{7000}, func(conditions *models.MongoStrategyCondition) {})
+	makerOnly.Chase(time.Now())
+	fillWhenSubscribed(t, sm, "BTC_USDT1", 0.004)
+	sm.FillOrderPartially("BTC_USDT1", 0.004)
+	sm.FillOrderPartially("BTC_USDT1", 0.003)
+	if model.State.ExecutedAmount != 0.004 || len(model.State.MakerFills) != 1 {
+		t.Errorf("expected 0.004 filled by one order, got %v by %v", model.State.ExecutedAmount, len(model.State.MakerFills))
+	}
+}
+
+// order executed while being re-priced should not be posted again, its fill should be taken by the update coming late
+func TestMakerOnlyExecutedOnReprice(t *testing.T) {
+	makerOnly, model, tradingApi, sm := chasingBuy([]float64{7000, 7005}, func(conditions *models.MongoStrategyCondition) {})
+	now := time.Now()
+	makerOnly.Chase(now)
+	fillWhenSubscribed(t, sm, "BTC_USDT1", 0)
+	orderRaw, _ := tradingApi.OrdersMap.Load("BTC_USDT1")
+	order := orderRaw.(models.MongoOrder)
+	order.Status, order.Filled, order.Average = "filled", 0.01, 7000
+	tradingApi.OrdersMap.Store("BTC_USDT1", order)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		callback, _ := sm.OrderCallbacks.Load("BTC_USDT1")
+		callback.(func(order *models.MongoOrder))(&order)
+	}()
+
+	makerOnly.Chase(now.Add(time.Second))
+	if tradingApi.CreatedOrders.Len() != 1 {
+		t.Errorf("expected the order executed not posted again, got %v orders", tradingApi.CreatedOrders.Len())
+	}
+	if state, _ := makerOnly.State.State(context.Background()); state != makeronly_order.Filled || model.State.ExecutedAmount != 0.01 {
+		t.Errorf("expected 0.01 filled, got %v in %v", model.State.ExecutedAmount, state)
+	}
+}
@@ -2,6 +2,8 @@ package tests
 
 import (
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/fanout"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +19,12 @@ type MockDataFeed struct {
 	WaitForOrderInitialization int
 	WaitBetweenTicks           int
 	CycleLastNEntries          int
+	Stale                      bool
+	OrderBook                  *interfaces.OrderBook
+	MarkPrice                  *interfaces.MarkPrice
+	Pushing                    bool // Subscribe returns updates sent by Push, the feed is polled otherwise
+	Updates                    fanout.Hub
+	ticks                      int64
 }
 
 func NewMockedDataFeed(mockedStream []interfaces.OHLCV) *MockDataFeed {
@@ -87,6 +95,7 @@ func (df *MockDataFeed) GetPriceForPairAtExchange(pair string, exchange string,
 		time.Sleep(time.Duration(df.WaitForOrderInitialization) * time.Millisecond)
 	}
 	time.Sleep(time.Duration(df.WaitBetweenTicks) * time.Millisecond)
+	atomic.AddInt64(&df.ticks, 1)
 	df.currentTick += 1
 	len := len(df.tickerData)
 	if df.currentTick >= len && len > 0 {
@@ -114,6 +123,43 @@ func (df *MockDataFeed) GetSpreadForPairAtExchange(pair string, exchange string,
 	return &df.spreadData[df.currentSpreadTick]
 }
 
+func (df *MockDataFeed) IsStale(pair string, exchange string, marketType int64) bool {
+	return df.Stale
+}
+
+func (df *MockDataFeed) GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	return nil
+}
+
+func (df *MockDataFeed) GetMarkPrice(pair string, exchange string, marketType int64) *interfaces.MarkPrice {
+	return df.MarkPrice
+}
+
+func (df *MockDataFeed) GetOrderBook(pair string, exchange string, marketType int64, depth int) *interfaces.OrderBook {
+	return df.OrderBook
+}
+
+func (df *MockDataFeed) Subscribe(pair string, exchange string, marketType int64) <-chan interfaces.Update {
+	if !df.Pushing {
+		return nil
+	}
+	return df.Updates.Subscribe(pair)
+}
+
+func (df *MockDataFeed) Unsubscribe(pair string, exchange string, marketType int64, updates <-chan interfaces.Update) {
+	df.Updates.Unsubscribe(pair, updates)
+}
+
+// Push sends an update of the pair to subscribers.
+func (df *MockDataFeed) Push(pair string, update interfaces.Update) {
+	df.Updates.Publish(pair, update)
+}
+
+// Ticks returns how many times the price was taken.
+func (df *MockDataFeed) Ticks() int64 {
+	return atomic.LoadInt64(&df.ticks)
+}
+
 func (df *MockDataFeed) SubscribeToPairUpdate() {
 
 }
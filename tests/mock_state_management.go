@@ -10,13 +10,23 @@ import (
 
 // should implement IStateMgmt
 type MockStateMgmt struct {
-	StateMap      sync.Map
-	ConditionsMap sync.Map
-	Trading       *MockTrading
-	DataFeed      IDataFeed
-	pair          string
-	exchange      string
-	marketType    int64
+	StateMap        sync.Map
+	ConditionsMap   sync.Map
+	SignalsMap      sync.Map
+	SignalCallbacks sync.Map
+	OrderCallbacks  sync.Map
+	StrategiesMap   sync.Map
+	TemplatesMap    sync.Map
+	KeyAssetsMap    sync.Map
+	KeyAssetTotalsMap sync.Map
+	CopyLeaderPricesMap sync.Map
+	SavedOrdersMap  sync.Map
+	CreateStrategyErr error // returned by CreateStrategy if set, the strategy is not stored then
+	Trading         *MockTrading
+	DataFeed        IDataFeed
+	pair            string
+	exchange        string
+	marketType      int64
 
 }
 
@@ -32,15 +42,19 @@ func (sm *MockStateMgmt) SaveStrategy(strategy *models.MongoStrategy) *models.Mo
 }
 
 func (sm *MockStateMgmt) SaveOrder(order models.MongoOrder, keyId *primitive.ObjectID, marketType int64) {
-	panic("implement me")
+	sm.SavedOrdersMap.Store(order.OrderId, order)
 }
 
 func (sm *MockStateMgmt) EnableStrategy(strategyId *primitive.ObjectID) {
 	return
 }
 
-func (sm *MockStateMgmt) CreateStrategy(strategy *models.MongoStrategy) *models.MongoStrategy {
-	panic("implement me")
+func (sm *MockStateMgmt) CreateStrategy(strategy *models.MongoStrategy) (*models.MongoStrategy, error) {
+	if sm.CreateStrategyErr != nil {
+		return strategy, sm.CreateStrategyErr
+	}
+	sm.StrategiesMap.Store(strategy.ID.Hex(), strategy)
+	return strategy, nil
 }
 
 func (sm *MockStateMgmt) GetOrderById(orderId *primitive.ObjectID) *models.MongoOrder {
@@ -84,8 +98,22 @@ func (sm *MockStateMgmt) SubscribeToOrder(orderId string, onOrderStatusUpdate fu
 	return sm.SubscribeToOrderOpts(orderId, sm.pair, sm.exchange, sm.marketType, onOrderStatusUpdate)
 }
 
+// FillOrderPartially sends an update of the open order filled by the amount given to the order subscriber.
+func (sm *MockStateMgmt) FillOrderPartially(orderId string, filled float64) {
+	orderRaw, ok := sm.Trading.OrdersMap.Load(orderId)
+	callback, subscribed := sm.OrderCallbacks.Load(orderId)
+	if !ok || !subscribed {
+		return
+	}
+	order := orderRaw.(models.MongoOrder)
+	order.Filled = filled
+	sm.Trading.OrdersMap.Store(orderId, order)
+	callback.(func(order *models.MongoOrder))(&order)
+}
+
 func (sm *MockStateMgmt) SubscribeToOrderOpts(orderId string, pair string, exchange string, marketType int64, onOrderStatusUpdate func(order *models.MongoOrder)) error {
 	//panic("implement me")
+	sm.OrderCallbacks.Store(orderId, onOrderStatusUpdate)
 	go func() {
 		for {
 			orderRaw, ok := sm.Trading.OrdersMap.Load(orderId)
@@ -175,3 +203,123 @@ func (sm *MockStateMgmt) SaveStrategyConditions(strategy *models.MongoStrategy)
 func (sm *MockStateMgmt) EnableHedgeLossStrategy(strategyId *primitive.ObjectID) {
 	return
 }
+
+func (sm *MockStateMgmt) UpdateEntrySignal(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	sm.StateMap.Store(strategyId, &state)
+}
+
+func (sm *MockStateMgmt) UpdateAtr(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	sm.StateMap.Store(strategyId, &state)
+}
+
+func (sm *MockStateMgmt) UpdateEntrySlices(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	sm.StateMap.Store(strategyId, &state)
+}
+
+func (sm *MockStateMgmt) UpdateReEntry(strategyId *primitive.ObjectID, state *models.MongoStrategyState) {
+	sm.StateMap.Store(strategyId, &state)
+}
+
+func (sm *MockStateMgmt) UpdateCopyLeaderPrices(strategyId *primitive.ObjectID, entryPrice float64, exitPrice float64) {
+	sm.CopyLeaderPricesMap.Store(strategyId.Hex(), [2]float64{entryPrice, exitPrice})
+}
+
+func (sm *MockStateMgmt) GetKeyAssetFree(keyAssetId *primitive.ObjectID) float64 {
+	free, ok := sm.KeyAssetsMap.Load(keyAssetId.Hex())
+	if !ok {
+		return 0
+	}
+	return free.(float64)
+}
+
+// GetKeyAssetEquity returns the total stored in KeyAssetTotalsMap, the free balance if there's none.
+func (sm *MockStateMgmt) GetKeyAssetEquity(keyAssetId *primitive.ObjectID) float64 {
+	total, ok := sm.KeyAssetTotalsMap.Load(keyAssetId.Hex())
+	if !ok {
+		return sm.GetKeyAssetFree(keyAssetId)
+	}
+	return total.(float64)
+}
+
+func (sm *MockStateMgmt) InitSignalsWatch() {
+	panic("implement me")
+}
+
+func (sm *MockStateMgmt) GetSignal(signalId *primitive.ObjectID) *models.MongoSignal {
+	signalRaw, ok := sm.SignalsMap.Load(signalId.Hex())
+	if !ok {
+		return nil
+	}
+	return signalRaw.(*models.MongoSignal)
+}
+
+func (sm *MockStateMgmt) SubscribeToSignal(signalId *primitive.ObjectID, subscriberId string, onSignalFired func(signal *models.MongoSignal)) error {
+	callbacks := map[string]func(signal *models.MongoSignal){subscriberId: onSignalFired}
+	if callbacksRaw, ok := sm.SignalCallbacks.Load(signalId.Hex()); ok {
+		for id, callback := range callbacksRaw.(map[string]func(signal *models.MongoSignal)) {
+			if id != subscriberId {
+				callbacks[id] = callback
+			}
+		}
+	}
+	sm.SignalCallbacks.Store(signalId.Hex(), callbacks)
+	if signal := sm.GetSignal(signalId); signal != nil && signal.State != nil && signal.State.FiredAt > 0 {
+		onSignalFired(signal)
+	}
+	return nil
+}
+
+// UnsubscribeFromSignal removes the callback of the subscriber.
+func (sm *MockStateMgmt) UnsubscribeFromSignal(signalId *primitive.ObjectID, subscriberId string) {
+	callbacksRaw, ok := sm.SignalCallbacks.Load(signalId.Hex())
+	if !ok {
+		return
+	}
+	callbacks := map[string]func(signal *models.MongoSignal){}
+	for id, callback := range callbacksRaw.(map[string]func(signal *models.MongoSignal)) {
+		if id != subscriberId {
+			callbacks[id] = callback
+		}
+	}
+	sm.SignalCallbacks.Store(signalId.Hex(), callbacks)
+}
+
+func (sm *MockStateMgmt) UpdateSignalState(signalId *primitive.ObjectID, state *models.MongoSignalState) {
+}
+
+// FireSignal stores the signal and invokes callbacks subscribed as the storage watch does.
+func (sm *MockStateMgmt) FireSignal(signal *models.MongoSignal) {
+	sm.SignalsMap.Store(signal.Id.Hex(), signal)
+	callbacksRaw, ok := sm.SignalCallbacks.Load(signal.Id.Hex())
+	if !ok || signal.State == nil || signal.State.FiredAt == 0 {
+		return
+	}
+	for _, callback := range callbacksRaw.(map[string]func(signal *models.MongoSignal)) {
+		callback(signal)
+	}
+}
+
+// DisableSignal marks the signal stored disabled, subscribers aren't notified.
+func (sm *MockStateMgmt) DisableSignal(signalId *primitive.ObjectID, state *models.MongoSignalState) {
+	if signal := sm.GetSignal(signalId); signal != nil {
+		signal.Enabled = false
+		signal.State = state
+	}
+}
+
+// GetTemplateChildren returns strategies created with the template id given.
+func (sm *MockStateMgmt) GetTemplateChildren(templateId *primitive.ObjectID) []*models.MongoStrategy {
+	children := make([]*models.MongoStrategy, 0)
+	sm.StrategiesMap.Range(func(key, value interface{}) bool {
+		strategy := value.(*models.MongoStrategy)
+		if strategy.Conditions.TemplateStrategyId != nil && *strategy.Conditions.TemplateStrategyId == *templateId {
+			children = append(children, strategy)
+		}
+		return true
+	})
+	return children
+}
+
+func (sm *MockStateMgmt) UpdateTemplateState(templateId *primitive.ObjectID, state *models.MongoStrategyTemplateState) {
+	sm.TemplatesMap.Store(templateId.Hex(), state)
+}
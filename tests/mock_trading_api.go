@@ -19,6 +19,9 @@ type MockTrading struct {
 	CanceledOrdersCount *sync.Map
 	CallCount           *sync.Map
 	AmountSum           *sync.Map
+	CopiesMap           *sync.Map
+	WorkingTypes        *sync.Map // <string: order id, string: working type of the stop order>
+	Rejects             *sync.Map // <string: order type and side, e.g. "limitbuy", int: orders of it to reject>
 	Feed                *MockDataFeed
 	BuyDelay            int
 	SellDelay           int
@@ -32,6 +35,9 @@ func NewMockedTradingAPI() *MockTrading {
 	mockTrading := MockTrading{
 		CallCount:           &sync.Map{},
 		AmountSum:           &sync.Map{},
+		CopiesMap:           &sync.Map{},
+		WorkingTypes:        &sync.Map{},
+		Rejects:             &sync.Map{},
 		CreatedOrders:       list.New(),
 		CanceledOrdersCount: &sync.Map{},
 		CanceledOrders:      list.New(),
@@ -47,6 +53,9 @@ func NewMockedTradingAPIWithMarketAccess(feed *MockDataFeed) *MockTrading {
 	mockTrading := MockTrading{
 		CallCount:           &sync.Map{},
 		AmountSum:           &sync.Map{},
+		CopiesMap:           &sync.Map{},
+		WorkingTypes:        &sync.Map{},
+		Rejects:             &sync.Map{},
 		Feed:                feed,
 		CreatedOrders:       list.New(),
 		CanceledOrders:      list.New(),
@@ -66,6 +75,11 @@ func (mt MockTrading) CreateOrder(req orders.CreateOrderRequest) orders.OrderRes
 	callCount, _ = mt.CallCount.LoadOrStore(req.KeyParams.Symbol, 0)
 	mt.CallCount.Store(req.KeyParams.Symbol, callCount.(int)+1)
 
+	if rejects, ok := mt.Rejects.Load(req.KeyParams.Type + req.KeyParams.Side); ok && rejects.(int) > 0 {
+		mt.Rejects.Store(req.KeyParams.Type+req.KeyParams.Side, rejects.(int)-1)
+		return orders.OrderResponse{Status: "ERR", Data: orders.OrderResponseData{Msg: "rejected"}}
+	}
+
 	//amountSumKey := req.KeyParams.Symbol + req.KeyParams.Side + fmt.Sprintf("%f", req.KeyParams.Price)
 	amountSumKey := req.KeyParams.Symbol + req.KeyParams.Side
 
@@ -104,6 +118,9 @@ func (mt MockTrading) CreateOrder(req orders.CreateOrderRequest) orders.OrderRes
 	}
 	mt.OrdersMap.Store(orderId, order)
 	mt.CreatedOrders.PushBack(order)
+	if req.KeyParams.Params.WorkingType != "" {
+		mt.WorkingTypes.Store(orderId, req.KeyParams.Params.WorkingType)
+	}
 	// filled := req.KeyParams.Amount
 	//if req.KeyParams.Type != "market" {
 	//	filled = 0
@@ -134,6 +151,9 @@ func (mt MockTrading) CancelOrder(req orders.CancelOrderRequest) orders.OrderRes
 
 	orderRaw, ok := mt.OrdersMap.Load(orderId)
 	var order models.MongoOrder
+	response := orders.OrderResponse{
+		Status: "OK",
+	}
 
 	if !ok {
 		order = models.MongoOrder{
@@ -146,13 +166,12 @@ func (mt MockTrading) CancelOrder(req orders.CancelOrderRequest) orders.OrderRes
 		if order.Status == "open" {
 			mt.CanceledOrdersCount.Store(req.KeyParams.Pair, callCount.(int)+1)
 			order.Status = "canceled"
+			// fills are reported by order updates, the order executed already gets no order in response
+			response.Data = orders.OrderResponseData{OrderId: orderId, Status: order.Status}
 		}
 	}
 
 	mt.OrdersMap.Store(orderId, order)
-	response := orders.OrderResponse{
-		Status: "OK",
-	}
 	return response
 }
 
@@ -160,6 +179,16 @@ func (mt MockTrading) PlaceHedge(parentSmarOrder *models.MongoStrategy) orders.O
 	panic("implement me")
 }
 
+// PlaceCopy stores follower conditions by the strategy id returned.
+func (mt MockTrading) PlaceCopy(keyId *primitive.ObjectID, conditions *models.MongoStrategyCondition) orders.OrderResponse {
+	id := primitive.NewObjectID()
+	mt.CopiesMap.Store(id.Hex(), conditions)
+	return orders.OrderResponse{
+		Status: "OK",
+		Data:   orders.OrderResponseData{OrderId: id.Hex()},
+	}
+}
+
 func (mt MockTrading) Transfer(request orders.TransferRequest) orders.OrderResponse {
 	panic("implement me")
 }
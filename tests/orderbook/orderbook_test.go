@@ -0,0 +1,103 @@
+package orderbook
+
+import (
+	"math"
+	"testing"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/orderbook"
+)
+
+func levels(priceAmounts ...float64) []interfaces.PriceLevel {
+	result := make([]interfaces.PriceLevel, 0, len(priceAmounts)/2)
+	for i := 0; i+1 < len(priceAmounts); i += 2 {
+		result = append(result, interfaces.PriceLevel{Price: priceAmounts[i], Amount: priceAmounts[i+1]})
+	}
+	return result
+}
+
+// diffs coming before the snapshot should be buffered and replayed after it, older ones skipped
+func TestBookSnapshotReplay(t *testing.T) {
+	var book orderbook.Book
+	if book.Update(orderbook.Diff{FirstUpdateId: 98, FinalUpdateId: 100, Bids: levels(6999, 9)}, 1) {
+		t.Fatal("expected book waiting for the snapshot")
+	}
+	book.Update(orderbook.Diff{FirstUpdateId: 101, FinalUpdateId: 103, Bids: levels(6999, 0, 6998, 2), Asks: levels(7001, 3)}, 2)
+	if book.Get(0) != nil {
+		t.Fatal("expected no book before the snapshot")
+	}
+
+	if !book.Reset(orderbook.Snapshot{LastUpdateId: 101, Bids: levels(7000, 1, 6999, 1), Asks: levels(7001, 1, 7002, 2)}, 3) {
+		t.Fatal("expected book synced by the snapshot")
+	}
+	got := book.Get(0)
+	if len(got.Bids) != 2 || got.Bids[0] != (interfaces.PriceLevel{Price: 7000, Amount: 1}) || got.Bids[1].Price != 6998 {
+		t.Errorf("expected bids 7000, 6998, got %v", got.Bids)
+	}
+	if len(got.Asks) != 2 || got.Asks[0] != (interfaces.PriceLevel{Price: 7001, Amount: 3}) {
+		t.Errorf("expected asks from 7001 of 3, got %v", got.Asks)
+	}
+	if depth := book.Get(1); len(depth.Bids) != 1 || len(depth.Asks) != 1 {
+		t.Errorf("expected one level of each side, got %v", depth)
+	}
+}
+
+// a diff not following the previous one should get the book out of sync until a newer snapshot
+func TestBookGapRecovery(t *testing.T) {
+	var book orderbook.Book
+	book.Reset(orderbook.Snapshot{LastUpdateId: 10, Bids: levels(7000, 1), Asks: levels(7001, 1)}, 1)
+	if !book.Update(orderbook.Diff{FirstUpdateId: 11, FinalUpdateId: 12, Bids: levels(7000, 2)}, 2) {
+		t.Fatal("expected diff following the snapshot applied")
+	}
+	if book.Update(orderbook.Diff{FirstUpdateId: 15, FinalUpdateId: 16, Bids: levels(7000, 5)}, 3) || book.Synced() {
+		t.Fatal("expected gap detected")
+	}
+	if book.Reset(orderbook.Snapshot{LastUpdateId: 12, Bids: levels(7000, 2)}, 4) {
+		t.Fatal("expected snapshot older than the diffs buffered refused")
+	}
+	if !book.Reset(orderbook.Snapshot{LastUpdateId: 15, Bids: levels(7000, 4)}, 5) {
+		t.Fatal("expected newer snapshot taken")
+	}
+	if got := book.Get(0); got.Bids[0].Amount != 5 || got.UpdatedAt != 5 {
+		t.Errorf("expected buffered diff replayed, got %v", got)
+	}
+}
+
+// futures diffs should follow the final update id of the previous one
+func TestBookFuturesSequence(t *testing.T) {
+	var book orderbook.Book
+	book.Reset(orderbook.Snapshot{LastUpdateId: 10, Asks: levels(7001, 1)}, 1)
+	if !book.Update(orderbook.Diff{FirstUpdateId: 8, FinalUpdateId: 12, PrevFinalUpdateId: 7, Asks: levels(7001, 2)}, 2) {
+		t.Fatal("expected the first diff covering the snapshot applied")
+	}
+	if !book.Update(orderbook.Diff{FirstUpdateId: 20, FinalUpdateId: 25, PrevFinalUpdateId: 12, Asks: levels(7002, 1)}, 3) {
+		t.Fatal("expected diff following by the previous final update id applied")
+	}
+	if book.Update(orderbook.Diff{FirstUpdateId: 30, FinalUpdateId: 31, PrevFinalUpdateId: 29}, 4) {
+		t.Error("expected gap detected")
+	}
+}
+
+// market orders should walk the levels and liquidity should be summed up within the distance from the best price
+func TestDepthHelpers(t *testing.T) {
+	book := &interfaces.OrderBook{
+		Bids: levels(7000, 1, 6995, 2, 6990, 5),
+		Asks: levels(7001, 0.5, 7003, 1, 7010, 4),
+	}
+	average, worst, ok := orderbook.PriceToFill(book, "buy", 1)
+	if !ok || worst != 7003 || math.Abs(average-7002) > 1e-9 {
+		t.Errorf("expected 1 bought at 7002 up to 7003, got %v up to %v", average, worst)
+	}
+	if average, worst, ok = orderbook.PriceToFill(book, "sell", 2); !ok || worst != 6995 || math.Abs(average-6997.5) > 1e-9 {
+		t.Errorf("expected 2 sold at 6997.5 down to 6995, got %v down to %v", average, worst)
+	}
+	if _, _, ok = orderbook.PriceToFill(book, "sell", 9); ok {
+		t.Error("expected the book not deep enough")
+	}
+	if amount := orderbook.LiquidityWithin(book, "sell", 15); amount != 8 { // 10.5 from 7000
+		t.Errorf("expected 8 within 15 bps of bids, got %v", amount)
+	}
+	if amount := orderbook.LiquidityWithin(book, "buy", 5); amount != 1.5 {
+		t.Errorf("expected 1.5 within 5 bps of asks, got %v", amount)
+	}
+}
@@ -0,0 +1,187 @@
+package pair
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/pair"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pricesFeed quotes prices set by a test for each pair
+type pricesFeed struct {
+	mux    sync.Mutex
+	prices map[string]float64
+}
+
+func (df *pricesFeed) set(pair string, price float64) {
+	df.mux.Lock()
+	defer df.mux.Unlock()
+	df.prices[pair] = price
+}
+
+func (df *pricesFeed) GetPriceForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.OHLCV {
+	df.mux.Lock()
+	defer df.mux.Unlock()
+	price, ok := df.prices[pair]
+	if !ok {
+		price = 1
+	}
+	return &interfaces.OHLCV{Open: price, High: price, Low: price, Close: price}
+}
+
+func (df *pricesFeed) GetSpreadForPairAtExchange(pair string, exchange string, marketType int64) *interfaces.SpreadData {
+	return nil
+}
+
+func (df *pricesFeed) IsStale(pair string, exchange string, marketType int64) bool {
+	return false
+}
+
+func (df *pricesFeed) GetCandles(pair string, exchange string, marketType int64, timeframe int64, n int) []interfaces.Candle {
+	return nil
+}
+
+func (df *pricesFeed) GetMarkPrice(pair string, exchange string, marketType int64) *interfaces.MarkPrice {
+	return nil
+}
+
+func (df *pricesFeed) GetOrderBook(pair string, exchange string, marketType int64, depth int) *interfaces.OrderBook {
+	return nil
+}
+
+func (df *pricesFeed) Subscribe(pair string, exchange string, marketType int64) <-chan interfaces.Update {
+	return nil
+}
+
+func (df *pricesFeed) Unsubscribe(pair string, exchange string, marketType int64, updates <-chan interfaces.Update) {
+}
+
+func pairConditions() *models.MongoStrategyCondition {
+	return &models.MongoStrategyCondition{
+		MarketType:     1,
+		Pair:           "PAIRA_USDT",
+		PairB:          "PAIRB_USDT",
+		PairAmount:     1,
+		PairHedgeRatio: 2,
+	}
+}
+
+// z-score over the lookback should select the side to enter and the exit on reversion or stop
+func TestPairSignals(t *testing.T) {
+	conditions := pairConditions()
+	conditions.PairStopZScore = 4
+	z, ok := pair.ZScore([]float64{1, 3, 1, 3}, 0)
+	if !ok || z != -2 {
+		t.Fatalf("expected z-score -2, got %v", z)
+	}
+	if side := pair.EntrySide(conditions, z); side != pair.LongSpread {
+		t.Errorf("expected long spread below the mean, got %v", side)
+	}
+	if side := pair.EntrySide(conditions, 1.5); side != 0 {
+		t.Errorf("expected no entry within threshold, got %v", side)
+	}
+	if isExit, _ := pair.IsExit(conditions, pair.LongSpread, -1); isExit {
+		t.Error("expected long spread to be held below the mean")
+	}
+	if isExit, reason := pair.IsExit(conditions, pair.LongSpread, 0.1); !isExit || reason != "mean reversion" {
+		t.Errorf("expected exit on mean reversion, got %v", reason)
+	}
+	if isExit, reason := pair.IsExit(conditions, pair.ShortSpread, 4.5); !isExit || reason != "stop" {
+		t.Errorf("expected exit on stop, got %v", reason)
+	}
+}
+
+// legs should be sized by hedge ratio given or estimated and partial fills trimmed to the hedge
+func TestPairLegs(t *testing.T) {
+	conditions := pairConditions()
+	if amountA, amountB := pair.LegAmounts(conditions, 100, 50, 2); amountA != 1 || amountB != 2 {
+		t.Errorf("expected legs 1 and 2, got %v and %v", amountA, amountB)
+	}
+	conditions.PairHedgeRatio = 0
+	ratio, ok := pair.HedgeRatio(conditions, []float64{10, 14, 18}, []float64{5, 7, 9})
+	if !ok || ratio < 1.999 || ratio > 2.001 {
+		t.Errorf("expected hedge ratio 2 estimated, got %v", ratio)
+	}
+	if hedgedA, hedgedB := pair.Hedged(1, 1, 2); hedgedA != 0.5 || hedgedB != 1 {
+		t.Errorf("expected half of A hedged by B filled partially, got %v and %v", hedgedA, hedgedB)
+	}
+	leg := &models.MongoPairLeg{Side: "sell", EntryPrice: 50, Closed: 2, ExitPrice: 45}
+	if pnl := pair.LegPnl(leg); pnl != 10 {
+		t.Errorf("expected short leg pnl 10, got %v", pnl)
+	}
+}
+
+// runPair starts pair trading of unique pairs quoted at 100 and 25 and moves the spread below the mean to enter
+// long A short B.
+func runPair(tradingApi *tests.MockTrading) (*models.MongoStrategy, *pricesFeed, string, string) {
+	conditions := pairConditions()
+	conditions.PairTimeframe = 1
+	conditions.PairLookback = 4
+	// candles are aggregated once per market for the process, so pairs are unique to not reuse the feed of another run
+	strategyId := primitive.NewObjectID()
+	pairA, pairB := "A"+strategyId.Hex()+"_USDT", "B"+strategyId.Hex()+"_USDT"
+	conditions.Pair, conditions.PairB = pairA, pairB
+	df := &pricesFeed{prices: map[string]float64{pairA: 100, pairB: 25}}
+	model := models.MongoStrategy{ID: &strategyId, Enabled: true, Type: 4, Conditions: conditions, State: &models.MongoStrategyState{}}
+	tradingApi.BuyDelay, tradingApi.SellDelay = 100, 100
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, statsd := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &model,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	keyId := primitive.NewObjectID()
+	runtime := pair.New(&strategy, df, tradingApi, statsd, &keyId, &sm)
+	go runtime.Start()
+
+	for _, price := range []float64{100, 102, 100, 102} {
+		df.set(pairA, price)
+		time.Sleep(2 * time.Second)
+	}
+	df.set(pairA, 90)
+	time.Sleep(2 * time.Second)
+	return &model, df, pairA, pairB
+}
+
+// spread falling below the mean should enter long A short B and rising back should close both legs
+func TestPairTrading(t *testing.T) {
+	tradingApi := tests.NewMockedTradingAPI()
+	model, df, pairA, pairB := runPair(tradingApi)
+	if model.State.State != pair.InEntry || model.State.PairSide != pair.LongSpread {
+		t.Fatalf("expected long spread entered, got %v %v", model.State.State, model.State.PairSide)
+	}
+	df.set(pairA, 115)
+	time.Sleep(2 * time.Second)
+	if model.State.State != pair.End {
+		t.Fatalf("expected legs closed, got %v", model.State.State)
+	}
+	for key, expected := range map[string]float64{pairA + "buy": 1, pairA + "sell": 1, pairB + "sell": 2, pairB + "buy": 2} {
+		if amount, _ := tradingApi.AmountSum.Load(key); amount != expected {
+			t.Errorf("expected %v amount %v, got %v", key, expected, amount)
+		}
+	}
+	if model.State.ReceivedProfitAmount != 25 {
+		t.Errorf("expected profit 25 of A leg, got %v", model.State.ReceivedProfitAmount)
+	}
+}
+
+// a leg not closed after the other failed should leave the strategy failed with the message to close it manually
+func TestPairLegNotClosed(t *testing.T) {
+	tradingApi := tests.NewMockedTradingAPI()
+	tradingApi.Rejects.Store("marketsell", 4) // B leg entry and all attempts to close A leg
+	model, _, pairA, _ := runPair(tradingApi)
+	if model.State.State != pair.Error || model.State.Msg != "can't close "+pairA+" leg, close it manually" {
+		t.Errorf("expected failed with A leg to close manually, got %v %q", model.State.State, model.State.Msg)
+	}
+}
@@ -0,0 +1,89 @@
+package recorder
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/recorder"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/replayer"
+)
+
+const hour = int64(time.Hour / time.Millisecond)
+
+func tick(at int64, pair string, price float64) recorder.Event {
+	return recorder.Event{At: at, Kind: recorder.KindTick, Exchange: "binance", Pair: pair, MarketType: 1,
+		Price: &interfaces.OHLCV{Open: price, High: price, Low: price, Close: price, Volume: 1, UpdatedAt: at}}
+}
+
+// recorded events should be written to files of their hours and replayed in order as a data feed
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	start := 1000 * hour
+	rec := recorder.New(recorder.NewFileSink(dir))
+	rec.Record(tick(start, "BTCUSDT", 7000))
+	rec.Record(recorder.Event{At: start + 500, Kind: recorder.KindSpread, Exchange: "binance", Pair: "BTCUSDT", MarketType: 1,
+		Spread: &interfaces.SpreadData{BestBid: 7000, BestAsk: 7001, UpdatedAt: start + 500}})
+	rec.Record(tick(start+1000, "BTCUSDT", 7100))
+	rec.Record(tick(start+hour, "BTCUSDT", 7200))
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "market-data-*.jsonl.gz"))
+	if len(files) != 2 {
+		t.Fatalf("expected files of two hours, got %v", files)
+	}
+
+	feed, err := replayer.Load(files[1], files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var df interfaces.IDataFeed = feed
+	updates := df.Subscribe("BTC_USDT", "binance", 1)
+	if df.GetPriceForPairAtExchange("BTC_USDT", "binance", 1) != nil || !df.IsStale("BTC_USDT", "binance", 1) {
+		t.Fatal("expected no price before replay")
+	}
+	feed.Step()
+	feed.Step()
+	if price := df.GetPriceForPairAtExchange("BTC_USDT", "binance", 1); price == nil || price.Close != 7000 {
+		t.Fatalf("expected 7000, got %+v", price)
+	}
+	if spread := df.GetSpreadForPairAtExchange("BTC_USDT", "", 1); spread == nil || spread.BestAsk != 7001 {
+		t.Errorf("expected spread with ask 7001, got %+v", spread)
+	}
+	if update := <-updates; update.Price.Close != 7000 || update.Spread.BestAsk != 7001 {
+		t.Errorf("expected price and spread update, got %+v", update)
+	}
+
+	feed.Step()
+	if price := df.GetPriceForPairAtExchange("BTC_USDT", "binance", 1); price.Close != 7100 || price.Open != 7000 || price.High != 7100 {
+		t.Errorf("expected forming minute candle 7000-7100, got %+v", price)
+	}
+	if candles := df.GetCandles("BTC_USDT", "binance", 1, 1, 10); len(candles) != 2 || candles[1].Close != 7100 {
+		t.Errorf("expected two second candles, got %v", candles)
+	}
+	if df.IsStale("BTC_USDT", "binance", 1) {
+		t.Error("expected fresh price by the replay clock")
+	}
+
+	go feed.Run()
+	<-feed.Done()
+	if price := df.GetPriceForPairAtExchange("BTC_USDT", "binance", 1); price.Close != 7200 || feed.Now().UnixNano() != (start+hour)*int64(time.Millisecond) {
+		t.Errorf("expected 7200 an hour later, got %+v at %v", price, feed.Now())
+	}
+	if feed.Step() {
+		t.Error("expected no events left")
+	}
+}
+
+// replay at a speed should keep gaps between events scaled
+func TestReplaySpeed(t *testing.T) {
+	feed := replayer.New([]recorder.Event{tick(0, "BTCUSDT", 7000), tick(1000, "BTCUSDT", 7100)})
+	feed.Speed = 10
+	started := time.Now()
+	feed.Run()
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("expected a second played in 100ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,143 @@
+package signals
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/signals"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestSignal(model *models.MongoSignal) (*signals.Signal, *tests.MockStateMgmt) {
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 7000}})
+	sm := tests.NewMockedStateMgmt(tests.NewMockedTradingAPI(), df)
+	logger, statsd := tests.GetLoggerStatsd()
+	model.Id = primitive.NewObjectID()
+	model.Enabled = true
+	return signals.New(model, df, &sm, statsd, logger), &sm
+}
+
+// price signal without direction given should fire on crossing the target from the side seen first
+func TestSignalPriceCross(t *testing.T) {
+	signal, _ := newTestSignal(&models.MongoSignal{
+		MonType:   models.MongoSignalType{SigType: models.SignalTypePrice},
+		Condition: models.MongoSignalCondition{TargetPrice: 7100},
+	})
+	now := time.Now()
+	for _, price := range []float64{7000, 7050, 7099} {
+		if signal.ShouldFire(price, nil, now) {
+			t.Fatalf("signal should not fire at %v below target", price)
+		}
+	}
+	if !signal.ShouldFire(7100, nil, now) {
+		t.Error("signal should fire at the target crossed")
+	}
+}
+
+// condition should hold the trigger period before the signal fires
+func TestSignalPeriod(t *testing.T) {
+	signal, _ := newTestSignal(&models.MongoSignal{
+		MonType:     models.MongoSignalType{SigType: models.SignalTypePrice},
+		Condition:   models.MongoSignalCondition{TargetPrice: 7100},
+		TriggerWhen: models.TriggerOptions{TrigType: models.TriggerAbove, Period: 10},
+	})
+	now := time.Now()
+	if signal.ShouldFire(7200, nil, now) || signal.ShouldFire(7200, nil, now.Add(5*time.Second)) {
+		t.Fatal("signal should not fire before period passed")
+	}
+	if signal.ShouldFire(7000, nil, now.Add(6*time.Second)) {
+		t.Fatal("signal should not fire below target")
+	}
+	if signal.ShouldFire(7200, nil, now.Add(12*time.Second)) {
+		t.Fatal("signal should restart period count after condition broken")
+	}
+	if !signal.ShouldFire(7200, nil, now.Add(22*time.Second)) {
+		t.Error("signal should fire after condition held for the period")
+	}
+}
+
+func TestSignalPercentChangeAndSpread(t *testing.T) {
+	drop, _ := newTestSignal(&models.MongoSignal{
+		MonType:   models.MongoSignalType{SigType: models.SignalTypePercentChange},
+		Condition: models.MongoSignalCondition{PercentChange: -2, Price: 7000},
+	})
+	now := time.Now()
+	if drop.ShouldFire(6900, nil, now) {
+		t.Error("signal should not fire on 1.4% drop")
+	}
+	if !drop.ShouldFire(6850, nil, now) {
+		t.Error("signal should fire on 2.1% drop")
+	}
+
+	spread, _ := newTestSignal(&models.MongoSignal{
+		MonType:   models.MongoSignalType{SigType: models.SignalTypeSpread},
+		Condition: models.MongoSignalCondition{Spread: 0.5},
+	})
+	if spread.ShouldFire(7000, &interfaces.SpreadData{BestBid: 7000, BestAsk: 7010}, now) {
+		t.Error("signal should not fire on narrow spread")
+	}
+	if !spread.ShouldFire(7000, &interfaces.SpreadData{BestBid: 7000, BestAsk: 7040}, now) {
+		t.Error("signal should fire on wide spread")
+	}
+}
+
+// fired signal should be disabled unless open ended and should notify subscribers
+func TestSignalFire(t *testing.T) {
+	signal, sm := newTestSignal(&models.MongoSignal{
+		MonType: models.MongoSignalType{SigType: models.SignalTypeWebhook},
+	})
+	notified := 0
+	_ = sm.SubscribeToSignal(&signal.Model.Id, "strategy", func(signal *models.MongoSignal) {
+		notified += 1
+	})
+	if !signal.Fire(7000, signals.FiredByWebhook) {
+		t.Fatal("enabled signal should fire")
+	}
+	if signal.Fire(7000, signals.FiredByWebhook) {
+		t.Error("signal not open ended should fire once")
+	}
+	if notified != 1 || signal.Model.State.FiredCount != 1 || len(signal.Model.Events) != 1 {
+		t.Errorf("expected one notification and event, got %v notifications, %v events", notified, len(signal.Model.Events))
+	}
+
+	openEnded, _ := newTestSignal(&models.MongoSignal{
+		MonType:   models.MongoSignalType{SigType: models.SignalTypeWebhook},
+		OpenEnded: true,
+	})
+	if !openEnded.Fire(7000, signals.FiredByWebhook) || !openEnded.Fire(7000, signals.FiredByWebhook) {
+		t.Error("open ended signal should fire many times")
+	}
+}
+
+// disabling should save the signal disabled without recording a fire, and fire events should be capped
+func TestSignalDisable(t *testing.T) {
+	signal, sm := newTestSignal(&models.MongoSignal{
+		MonType:   models.MongoSignalType{SigType: models.SignalTypeWebhook},
+		OpenEnded: true,
+	})
+	notified := 0
+	_ = sm.SubscribeToSignal(&signal.Model.Id, "strategy", func(signal *models.MongoSignal) {
+		notified += 1
+	})
+	for i := 0; i < models.MaxSignalEvents+5; i++ {
+		signal.Fire(7000, signals.FiredByWebhook)
+	}
+	if len(signal.Model.Events) != models.MaxSignalEvents {
+		t.Errorf("expected %v latest events kept, got %v", models.MaxSignalEvents, len(signal.Model.Events))
+	}
+
+	notified = 0
+	signal.Disable()
+	if notified != 0 || len(signal.Model.Events) != models.MaxSignalEvents {
+		t.Errorf("expected disabling not recorded as fired, got %v notifications", notified)
+	}
+	if stored := sm.GetSignal(&signal.Model.Id); stored == nil || stored.Enabled {
+		t.Error("expected signal saved disabled")
+	}
+	if signal.Fire(7000, signals.FiredByWebhook) {
+		t.Error("disabled signal should not fire")
+	}
+}
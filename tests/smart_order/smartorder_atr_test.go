@@ -0,0 +1,83 @@
+package smart_order
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func runAtrSmartOrder(state *models.MongoStrategyState, warmup int64, fallback float64) *tests.MockTrading {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	smartOrderModel.Conditions.AtrMode = true
+	smartOrderModel.Conditions.AtrTimeframe = 3600
+	smartOrderModel.Conditions.AtrWarmup = warmup
+	smartOrderModel.Conditions.AtrFallback = fallback
+	if state != nil {
+		smartOrderModel.State = state
+	}
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Open: 7100, High: 7101, Low: 7000, Close: 7005, Volume: 30}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, statsd := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	go smartOrder.Start()
+	time.Sleep(300 * time.Millisecond)
+	return tradingApi
+}
+
+// entry in ATR mode should wait for ATR measured, ATR frozen and saved before should be used as is
+func TestSmartOrderWaitsForAtr(t *testing.T) {
+	tradingApi := runAtrSmartOrder(nil, 0, 0)
+	if _, found := tradingApi.CallCount.Load("buy"); found {
+		t.Error("entry placed without ATR known")
+	}
+
+	tradingApi = runAtrSmartOrder(&models.MongoStrategyState{Atr: 70, AtrPrice: 7000}, 0, 0)
+	if buyCallCount, found := tradingApi.CallCount.Load("buy"); !found || buyCallCount == 0 {
+		t.Error("entry was not placed with ATR frozen")
+	}
+}
+
+// entry should wait for ATR after the warmup unless the fallback ATR is set, the warmup covers ATR candles by default
+func TestSmartOrderAtrWarmupFallback(t *testing.T) {
+	tradingApi := runAtrSmartOrder(&models.MongoStrategyState{AtrWaitSince: time.Now().Unix() - 30}, 60, 1)
+	if _, found := tradingApi.CallCount.Load("buy"); found {
+		t.Fatal("entry placed before ATR warm up timed out")
+	}
+
+	tradingApi = runAtrSmartOrder(&models.MongoStrategyState{AtrWaitSince: time.Now().Unix() - 61}, 60, 0)
+	if _, found := tradingApi.CallCount.Load("buy"); found {
+		t.Fatal("entry placed without fallback ATR after ATR warm up timed out")
+	}
+
+	tradingApi = runAtrSmartOrder(&models.MongoStrategyState{AtrWaitSince: time.Now().Unix() - 61}, 0, 1)
+	if _, found := tradingApi.CallCount.Load("buy"); found {
+		t.Fatal("entry placed before ATR candles could be collected")
+	}
+
+	state := &models.MongoStrategyState{AtrWaitSince: time.Now().Unix() - 61}
+	tradingApi = runAtrSmartOrder(state, 60, 1)
+	if buyCallCount, found := tradingApi.CallCount.Load("buy"); !found || buyCallCount == 0 {
+		t.Error("entry was not placed with fallback ATR after ATR warm up timed out")
+	}
+	if math.Abs(state.Atr-70.05) > 1e-9 || state.AtrPrice != 7005 {
+		t.Errorf("expected fallback ATR of 1%% of 7005 frozen, got %v at %v", state.Atr, state.AtrPrice)
+	}
+}
@@ -0,0 +1,165 @@
+package smart_order
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// waitingMarketEntry returns smart order waiting for market entry of 0.01 on futures, the entry is placed on trade.
+func waitingMarketEntry(conditions func(*models.MongoStrategyCondition)) (*smart_order.SmartOrder, *models.MongoStrategy, *tests.MockTrading, *tests.MockStateMgmt) {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	id := primitive.NewObjectID()
+	smartOrderModel.ID = &id
+	smartOrderModel.Conditions.MarketType = 1
+	smartOrderModel.Conditions.Leverage = 10
+	smartOrderModel.Conditions.SkipInitialSetup = true
+	smartOrderModel.Conditions.EntryOrder.OrderType = "market"
+	smartOrderModel.Conditions.EntryOrder.ActivatePrice = 0
+	smartOrderModel.Conditions.EntryOrder.Amount = 0.01
+	smartOrderModel.State.State = smart_order.WaitForEntry // not placed on start
+	conditions(smartOrderModel.Conditions)
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 7000}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, stats := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Statsd:          stats,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	return smartOrder, &smartOrderModel, tradingApi, &sm
+}
+
+func TestCopyAmount(t *testing.T) {
+	cases := []struct {
+		follower models.MongoCopyFollower
+		expected float64
+	}{
+		{models.MongoCopyFollower{}, 0.01},
+		{models.MongoCopyFollower{Multiplier: 3}, 0.03},
+		{models.MongoCopyFollower{SizeMode: models.CopySizeNotional, Notional: 140}, 0.02},
+		{models.MongoCopyFollower{SizeMode: models.CopySizeBalanceRatio}, 0.005},
+		{models.MongoCopyFollower{Multiplier: 10, MaxNotional: 350}, 0.05},
+	}
+	for _, c := range cases {
+		if amount := smart_order.CopyAmount(&c.follower, 0.01, 7000, 2000, 1000); math.Abs(amount-c.expected) > 1e-9 {
+			t.Errorf("expected %v for %+v, got %v", c.expected, c.follower, amount)
+		}
+	}
+}
+
+// leader entry should create followers with amount and risk caps applied, leader stop should exit them
+func TestSmartOrderCopyLeader(t *testing.T) {
+	followerKeyId := primitive.NewObjectID()
+	smartOrder, model, tradingApi, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.Followers = []*models.MongoCopyFollower{{KeyId: &followerKeyId, Multiplier: 2, MaxLeverage: 5, MaxStopLoss: 3}}
+	})
+	_ = smartOrder.State.Fire(smart_order.TriggerTrade, interfaces.OHLCV{Close: 7000})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry {
+		t.Fatalf("expected leader in entry, got %v", state)
+	}
+	var conditions *models.MongoStrategyCondition
+	for i := 0; i < 20 && conditions == nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		tradingApi.CopiesMap.Range(func(key, value interface{}) bool {
+			conditions = value.(*models.MongoStrategyCondition)
+			return false
+		})
+	}
+	if conditions == nil {
+		t.Fatal("follower not created")
+	}
+	if conditions.EntryOrder.Amount != 0.02 || conditions.Leverage != 5 || conditions.StopLoss != 3 {
+		t.Errorf("expected amount 0.02, leverage 5, stop-loss 3, got %v %v %v",
+			conditions.EntryOrder.Amount, conditions.Leverage, conditions.StopLoss)
+	}
+	if *conditions.AccountId != followerKeyId || *conditions.CopyLeaderId != *model.ID || conditions.CopyLeaderEntryPrice != 7000 {
+		t.Error("follower should be linked to the leader entered")
+	}
+	time.Sleep(50 * time.Millisecond) // let the follower id saved
+	followerId := model.Conditions.Followers[0].StrategyId
+	if followerId == nil {
+		t.Fatal("follower strategy id not saved")
+	}
+
+	model.State.ExitPrice = 7100
+	smartOrder.Stop()
+	prices, ok := sm.CopyLeaderPricesMap.Load(followerId.Hex())
+	if !ok || prices.([2]float64) != [2]float64{7000, 7100} {
+		t.Errorf("follower should get leader entry and exit prices, got %v", prices)
+	}
+	if model.Conditions.Followers[0].StrategyId != nil {
+		t.Error("follower of the next iteration should be created anew")
+	}
+}
+
+// follower should wait for the leader to enter and report slippage against it
+func TestSmartOrderCopyFollower(t *testing.T) {
+	leaderId := primitive.NewObjectID()
+	smartOrder, model, _, _ := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.CopyLeaderId = &leaderId
+	})
+	_ = smartOrder.State.Fire(smart_order.TriggerTrade, interfaces.OHLCV{Close: 7000})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.WaitForEntry {
+		t.Fatalf("follower should not enter before the leader, got %v", state)
+	}
+	model.Conditions.CopyLeaderEntryPrice = 6965
+	_ = smartOrder.State.Fire(smart_order.TriggerTrade, interfaces.OHLCV{Close: 7000})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry {
+		t.Fatalf("follower should enter after the leader, got %v", state)
+	}
+
+	_, model, _ = stopLossInEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.CopyLeaderId = &leaderId
+		conditions.CopyLeaderEntryPrice = 6965
+		conditions.CopyLeaderExitPrice = 6700
+	})
+	if math.Abs(model.State.CopyEntrySlippage-0.5025) > 0.001 || math.Abs(model.State.CopyExitSlippage-0.7519) > 0.001 {
+		t.Errorf("expected entry slippage 0.5025%% and exit one 0.7519%%, got %v %v",
+			model.State.CopyEntrySlippage, model.State.CopyExitSlippage)
+	}
+}
+
+// leader edits should be pushed to followers on hot reload only
+func TestSmartOrderCopyLeaderReload(t *testing.T) {
+	followerKeyId := primitive.NewObjectID()
+	smartOrder, model, _, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.Followers = []*models.MongoCopyFollower{{KeyId: &followerKeyId}}
+	})
+	_ = smartOrder.State.Fire(smart_order.TriggerTrade, interfaces.OHLCV{Close: 7000})
+	var followerId *primitive.ObjectID
+	for i := 0; i < 20 && followerId == nil; i++ {
+		time.Sleep(50 * time.Millisecond)
+		followerId = model.Conditions.Followers[0].StrategyId
+	}
+	if followerId == nil {
+		t.Fatal("follower not created")
+	}
+	if _, ok := sm.ConditionsMap.Load(followerId); ok {
+		t.Fatal("follower should not be updated without leader edits")
+	}
+
+	previous := *model
+	conditions := *model.Conditions
+	previous.Conditions = &conditions
+	model.Conditions.StopLoss = 7
+	smartOrder.HotReload(previous)
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := sm.ConditionsMap.Load(followerId); !ok {
+		t.Error("follower should get leader conditions edited")
+	}
+}
@@ -0,0 +1,136 @@
+package smart_order
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/execution"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/src/trading/orders"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+)
+
+// fakeAlgorithm records orders it executes and cancels, fills are reported by the test.
+type fakeAlgorithm struct {
+	mux       sync.Mutex
+	executed  []orders.CreateOrderRequest
+	canceled  []string
+	callbacks map[string]func(order *models.MongoOrder)
+}
+
+func newFakeAlgorithm(orderType string) *fakeAlgorithm {
+	algorithm := &fakeAlgorithm{callbacks: map[string]func(order *models.MongoOrder){}}
+	execution.Register(orderType, algorithm)
+	return algorithm
+}
+
+func (a *fakeAlgorithm) Execute(request orders.CreateOrderRequest, onOrderUpdate func(order *models.MongoOrder)) orders.OrderResponse {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.executed = append(a.executed, request)
+	orderId := request.KeyParams.Type + strconv.Itoa(len(a.executed))
+	a.callbacks[orderId] = onOrderUpdate
+	return orders.OrderResponse{Status: "OK", Data: orders.OrderResponseData{OrderId: orderId}}
+}
+
+func (a *fakeAlgorithm) Cancel(request orders.CancelOrderRequest) orders.OrderResponse {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.canceled = append(a.canceled, request.KeyParams.OrderId)
+	return orders.OrderResponse{Status: "OK"}
+}
+
+func (a *fakeAlgorithm) fill(orderId string, filled, average float64) {
+	a.mux.Lock()
+	callback := a.callbacks[orderId]
+	a.mux.Unlock()
+	callback(&models.MongoOrder{OrderId: orderId, Status: "filled", Filled: filled, Average: average})
+}
+
+// entry of the registered order type should be executed by the algorithm and entered once it reports the fill
+func TestSmartOrderEntryByExecutionAlgorithm(t *testing.T) {
+	algorithm := newFakeAlgorithm("fake-entry")
+	smartOrder, model, tradingApi, _ := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.EntryOrder.OrderType = "fake-entry"
+	})
+	smartOrder.PlaceOrder(model.Conditions.EntryOrder.Price, 0.0, smart_order.WaitForEntry) // as placed on start
+	if len(algorithm.executed) != 1 || tradingApi.CreatedOrders.Len() != 0 {
+		t.Fatalf("expected entry executed by the algorithm only, got %v executed, %v placed", len(algorithm.executed), tradingApi.CreatedOrders.Len())
+	}
+	if request := algorithm.executed[0]; request.KeyParams.Side != "buy" || request.KeyParams.Amount != 0.01 {
+		t.Errorf("expected buy of 0.01, got %v of %v", request.KeyParams.Side, request.KeyParams.Amount)
+	}
+
+	algorithm.fill("fake-entry1", 0.01, 6990)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry || model.State.EntryPrice != 6990 {
+		t.Errorf("expected entry at 6990, got %v at %v", state, model.State.EntryPrice)
+	}
+}
+
+// take-profit target of the registered order type should be executed once its price is reached, cancels go to the algorithm
+func TestSmartOrderTakeProfitByExecutionAlgorithm(t *testing.T) {
+	algorithm := newFakeAlgorithm("fake-exit")
+	smartOrder, _, _, _ := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.ExitLevels = []*models.MongoEntryPoint{{Type: 1, OrderType: "fake-exit", Price: 10, Amount: 100}}
+	})
+	_ = smartOrder.State.Fire(smart_order.TriggerTrade, interfaces.OHLCV{Close: 7000})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry || len(algorithm.executed) != 0 {
+		t.Fatalf("expected target waiting for the price in entry, got %v executed in %v", len(algorithm.executed), state)
+	}
+
+	_ = smartOrder.State.Fire(smart_order.CheckProfitTrade, interfaces.OHLCV{Close: 7100})
+	if len(algorithm.executed) != 1 {
+		t.Fatalf("expected target executed by the algorithm, got %v", len(algorithm.executed))
+	}
+	if request := algorithm.executed[0]; request.KeyParams.Side != "sell" || request.KeyParams.Amount != 0.01 {
+		t.Errorf("expected sell of 0.01, got %v of %v", request.KeyParams.Side, request.KeyParams.Amount)
+	}
+
+	smartOrder.TryCancelAllOrdersConsistently([]string{"fake-exit1"})
+	if len(algorithm.canceled) != 1 || algorithm.canceled[0] != "fake-exit1" {
+		t.Errorf("expected cancel routed to the algorithm, got %v", algorithm.canceled)
+	}
+
+	restarted := smart_order.New(smartOrder.Strategy, smartOrder.DataFeed, smartOrder.ExchangeApi, smartOrder.Statsd, smartOrder.KeyId, smartOrder.StateMgmt)
+	restarted.TryCancelAllOrdersConsistently([]string{"fake-exit1"})
+	if len(algorithm.canceled) != 2 {
+		t.Errorf("expected cancel routed to the algorithm after restart, got %v", algorithm.canceled)
+	}
+}
+
+// break-even exit should wait for the price when the target is executed by the algorithm and be executed by it
+func TestSmartOrderWithoutLossByExecutionAlgorithm(t *testing.T) {
+	algorithm := newFakeAlgorithm("fake-break-even")
+	smartOrder, model, tradingApi, _ := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.ExitLevels = []*models.MongoEntryPoint{{Type: 1, OrderType: "fake-break-even", Price: 50, Amount: 100}}
+	})
+	_ = smartOrder.State.Fire(smart_order.TriggerTrade, interfaces.OHLCV{Close: 7000})
+	placed := tradingApi.CreatedOrders.Len()
+	smartOrder.PlaceOrder(0, 0.0, "WithoutLoss")
+	if len(algorithm.executed) != 0 || tradingApi.CreatedOrders.Len() != placed {
+		t.Fatalf("expected break-even exit waiting for the price, got %v executed, %v placed", len(algorithm.executed), tradingApi.CreatedOrders.Len()-placed)
+	}
+	breakEven := model.State.WithoutLossPrice
+	if math.Abs(breakEven-7005.6) > 1e-6 || model.State.WithoutLossStop {
+		t.Fatalf("expected take-profit at 7005.6, got %v stop %v", breakEven, model.State.WithoutLossStop)
+	}
+
+	smartOrder.DataFeed.(*tests.MockDataFeed).AddToFeed([]interfaces.OHLCV{{Open: 7010, High: 7010, Low: 7010, Close: 7010}})
+	go smartOrder.Start()
+	time.Sleep(500 * time.Millisecond)
+	if len(algorithm.executed) != 1 {
+		t.Fatalf("expected break-even exit executed by the algorithm, got %v", len(algorithm.executed))
+	}
+	if request := algorithm.executed[0]; request.KeyParams.Side != "sell" || request.KeyParams.Amount != 0.01 || math.Abs(request.KeyParams.Price-breakEven) > 0.01 {
+		t.Errorf("expected sell of 0.01 at %v, got %v of %v at %v", breakEven, request.KeyParams.Side, request.KeyParams.Amount, request.KeyParams.Price)
+	}
+	if model.State.WithoutLossPrice != 0 {
+		t.Error("break-even exit should not wait any more")
+	}
+}
@@ -0,0 +1,116 @@
+package smart_order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func runPartialEntrySmartOrder(timeout float64) (*smart_order.SmartOrder, *tests.MockTrading, *tests.MockStateMgmt, string) {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	smartOrderModel.Conditions.EntryOrder.Amount = 0.05
+	smartOrderModel.Conditions.MarketType = 1
+	smartOrderModel.Conditions.StopLoss = 5
+	smartOrderModel.Conditions.WaitingEntryTimeout = timeout
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Open: 7100, High: 7101, Low: 7050, Close: 7050, Volume: 30}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, statsd := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	// no event loop is started, entry is placed on creation and fills come through order updates
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	time.Sleep(300 * time.Millisecond)
+	entryOrderId := ""
+	tradingApi.OrdersMap.Range(func(key, value interface{}) bool {
+		if value.(models.MongoOrder).Side == "buy" {
+			entryOrderId = key.(string)
+		}
+		return true
+	})
+	return smartOrder, tradingApi, &sm, entryOrderId
+}
+
+// partial fills of the entry order should place exits for the amount filled so far
+func TestSmartOrderPartialEntry(t *testing.T) {
+	smartOrder, tradingApi, sm, entryOrderId := runPartialEntrySmartOrder(0)
+	model := smartOrder.Strategy.GetModel()
+	if entryOrderId == "" {
+		t.Fatal("expected entry order placed")
+	}
+	sm.FillOrderPartially(entryOrderId, 0.02)
+	time.Sleep(500 * time.Millisecond)
+	if model.State.State != smart_order.PartiallyEntry || model.State.EntryFilledAmount != 0.02 {
+		t.Fatalf("expected partially entry with 0.02 filled, got %v %v", model.State.State, model.State.EntryFilledAmount)
+	}
+	if amount, _ := tradingApi.AmountSum.Load("BTC_USDTsell"); amount != 0.04 {
+		t.Errorf("expected take profit and stop loss for 0.02 placed, got %v", amount)
+	}
+	sm.FillOrderPartially(entryOrderId, 0.03)
+	time.Sleep(500 * time.Millisecond)
+	if model.State.EntryFilledAmount != 0.03 || model.State.PositionAmount != 0.03 {
+		t.Errorf("expected position of 0.03 filled, got %v", model.State.PositionAmount)
+	}
+	if amount, _ := tradingApi.AmountSum.Load("BTC_USDTsell"); amount.(float64) < 0.0999 || amount.(float64) > 0.1001 {
+		t.Errorf("expected exits replaced for 0.03, got %v", amount)
+	}
+}
+
+// entry timeout should cancel the rest of entry order and go on with the amount filled
+func TestSmartOrderPartialEntryTimeout(t *testing.T) {
+	smartOrder, tradingApi, sm, entryOrderId := runPartialEntrySmartOrder(1)
+	model := smartOrder.Strategy.GetModel()
+	sm.FillOrderPartially(entryOrderId, 0.02)
+	time.Sleep(1500 * time.Millisecond)
+	if model.State.State != smart_order.InEntry || model.State.PositionAmount != 0.02 {
+		t.Fatalf("expected in entry with 0.02 position, got %v %v", model.State.State, model.State.PositionAmount)
+	}
+	stop := tradingApi.CreatedOrders.Back().Value.(models.MongoOrder)
+	if stop.Side != "sell" || stop.Filled != 0.02 || stop.Average >= 7000 {
+		t.Errorf("expected stop loss for 0.02 filled placed, got %+v", stop)
+	}
+	if canceledCount, _ := tradingApi.CanceledOrdersCount.Load("BTC_USDT"); canceledCount == nil || canceledCount.(int) == 0 {
+		t.Error("expected rest of entry order canceled")
+	}
+}
+
+// price crossing the stop while the entry fills partially should cancel the rest of entry and stop the amount filled
+func TestSmartOrderPartialEntryStopLoss(t *testing.T) {
+	smartOrder, tradingApi, sm, entryOrderId := runPartialEntrySmartOrder(0)
+	model := smartOrder.Strategy.GetModel()
+	model.Conditions.Leverage = 1
+	model.Conditions.MarketType = 0 // spot stops are not placed in advance, the loss check places it
+	sm.FillOrderPartially(entryOrderId, 0.02)
+	time.Sleep(300 * time.Millisecond)
+	if model.State.State != smart_order.PartiallyEntry {
+		t.Fatalf("expected partially entry, got %v", model.State.State)
+	}
+	smartOrder.DataFeed.(*tests.MockDataFeed).AddToFeed([]interfaces.OHLCV{{Open: 6600, High: 6600, Low: 6600, Close: 6600}})
+	go smartOrder.Start()
+	time.Sleep(500 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.Stoploss && state != smart_order.End {
+		t.Errorf("expected stop loss hit in partially entry, got %v", state)
+	}
+	stop := tradingApi.CreatedOrders.Back().Value.(models.MongoOrder)
+	if stop.Side != "sell" || stop.Filled != 0.02 || stop.Average >= 7000 {
+		t.Errorf("expected stop loss for 0.02 filled placed, got %+v", stop)
+	}
+	if canceledCount, _ := tradingApi.CanceledOrdersCount.Load("BTC_USDT"); canceledCount == nil || canceledCount.(int) == 0 {
+		t.Error("expected rest of entry order canceled")
+	}
+}
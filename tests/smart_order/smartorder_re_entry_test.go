@@ -0,0 +1,86 @@
+package smart_order
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stopLossInEntry returns smart order in entry at 7000 with the stop-loss order filled at 6650.
+func stopLossInEntry(conditions func(*models.MongoStrategyCondition)) (*smart_order.SmartOrder, *models.MongoStrategy, *tests.MockTrading) {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	smartOrderModel.Conditions.MarketType = 1
+	smartOrderModel.Conditions.Leverage = 1
+	smartOrderModel.Conditions.SkipInitialSetup = true
+	smartOrderModel.Conditions.EntryOrder.OrderType = "market"
+	smartOrderModel.Conditions.EntryOrder.Amount = 0.01
+	smartOrderModel.Conditions.StopLoss = 5
+	smartOrderModel.State = &models.MongoStrategyState{State: smart_order.InEntry, EntryPrice: 7000, PositionAmount: 0.01}
+	conditions(smartOrderModel.Conditions)
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 6650}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, stats := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Statsd:          stats,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	smartOrder.StatusByOrderId.Store("stopLoss", smart_order.Stoploss)
+	smartOrder.OrdersMap["stopLoss"] = true
+	_ = smartOrder.State.Fire(smart_order.CheckExistingOrders, models.MongoOrder{OrderId: "stopLoss", Status: "filled", Filled: 0.01, Average: 6650})
+	return smartOrder, &smartOrderModel, tradingApi
+}
+
+// loss should wait for the price to reclaim the level and re-enter with the amount reduced
+func TestSmartOrderReEntryAfterStopLoss(t *testing.T) {
+	smartOrder, model, tradingApi := stopLossInEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.ReEntryAttempts = 2
+		conditions.ReEntryPrice = 7000
+		conditions.ReEntryAmountRate = 0.5
+	})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.WaitForReEntry {
+		t.Fatalf("expected waiting for re-entry after stop-loss, got %v", state)
+	}
+	if model.State.ReEntryAttempts != 1 || model.State.ReEntryLoss != 3.5 || model.Conditions.EntryOrder.Amount != 0.005 {
+		t.Errorf("expected first attempt with 3.5 lost and half amount, got %v %v %v",
+			model.State.ReEntryAttempts, model.State.ReEntryLoss, model.Conditions.EntryOrder.Amount)
+	}
+	_ = smartOrder.State.Fire(smart_order.ReEntry, interfaces.OHLCV{Close: 6900})
+	if _, found := tradingApi.CallCount.Load("buy"); found {
+		t.Fatal("expected no re-entry below the level")
+	}
+	_ = smartOrder.State.Fire(smart_order.ReEntry, interfaces.OHLCV{Close: 7010})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.WaitForEntry {
+		t.Fatalf("expected entry started over, got %v", state)
+	}
+	if amount, _ := tradingApi.AmountSum.Load("BTC_USDTbuy"); amount != 0.005 {
+		t.Errorf("expected re-entry with 0.005, got %v", amount)
+	}
+}
+
+// attempts should stop once their total loss reaches the cap and the entry amount should be restored
+func TestSmartOrderReEntryLossCap(t *testing.T) {
+	smartOrder, model, _ := stopLossInEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.ReEntryAttempts = 2
+		conditions.ReEntryAmountRate = 0.5
+		conditions.ReEntryMaxLoss = 3
+	})
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.End {
+		t.Fatalf("expected end with loss beyond the cap, got %v", state)
+	}
+	if model.State.ReEntryAttempts != 0 || model.Conditions.EntryOrder.Amount != 0.01 {
+		t.Errorf("expected no attempts and entry amount kept, got %v %v", model.State.ReEntryAttempts, model.Conditions.EntryOrder.Amount)
+	}
+}
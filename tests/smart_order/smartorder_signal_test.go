@@ -0,0 +1,111 @@
+package smart_order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// smart order linked to a signal should not place entry until the signal fired
+func TestSmartOrderWaitsForSignal(t *testing.T) {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	signalId := primitive.NewObjectID()
+	smartOrderModel.SignalIds = []primitive.ObjectID{signalId}
+	fakeDataStream := []interfaces.OHLCV{{
+		Open:   7100,
+		High:   7101,
+		Low:    7000,
+		Close:  7005,
+		Volume: 30,
+	}}
+	df := tests.NewMockedDataFeed(fakeDataStream)
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, statsd := tests.GetLoggerStatsd()
+
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	go smartOrder.Start()
+	time.Sleep(300 * time.Millisecond)
+
+	if _, found := tradingApi.CallCount.Load("buy"); found {
+		t.Fatal("entry placed before signal fired")
+	}
+
+	sm.FireSignal(&models.MongoSignal{
+		Id:    signalId,
+		State: &models.MongoSignalState{FiredAt: time.Now().Unix()},
+	})
+	time.Sleep(300 * time.Millisecond)
+
+	if buyCallCount, found := tradingApi.CallCount.Load("buy"); !found || buyCallCount == 0 {
+		t.Error("entry was not placed after signal fired")
+	}
+	if smartOrderModel.State.SignalId == nil || *smartOrderModel.State.SignalId != signalId {
+		t.Error("signal released the entry was not saved in the state")
+	}
+}
+
+// signals fired before the strategy was created should not release its entry, signals unlinked should not notify it
+func TestSmartOrderIgnoresSignalFiredBefore(t *testing.T) {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	signalId := primitive.NewObjectID()
+	smartOrderModel.SignalIds = []primitive.ObjectID{signalId}
+	smartOrderModel.CreatedAt = time.Now()
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 7005}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	sm.SignalsMap.Store(signalId.Hex(), &models.MongoSignal{
+		Id:    signalId,
+		State: &models.MongoSignalState{FiredAt: time.Now().Add(-time.Hour).Unix()},
+	})
+	logger, statsd := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	strategy.StrategyRuntime = smartOrder
+	if smartOrderModel.State.SignalId != nil {
+		t.Fatal("entry released by signal fired before the strategy created")
+	}
+
+	reloaded := smartOrderModel
+	newSignalId := primitive.NewObjectID()
+	reloaded.SignalIds = []primitive.ObjectID{newSignalId}
+	strategy.HotReload(reloaded)
+	sm.FireSignal(&models.MongoSignal{
+		Id:    signalId,
+		State: &models.MongoSignalState{FiredAt: time.Now().Unix()},
+	})
+	if smartOrderModel.State.SignalId != nil {
+		t.Error("entry released by signal unlinked")
+	}
+	sm.FireSignal(&models.MongoSignal{
+		Id:    newSignalId,
+		State: &models.MongoSignalState{FiredAt: time.Now().Unix()},
+	})
+	if smartOrderModel.State.SignalId == nil || *smartOrderModel.State.SignalId != newSignalId {
+		t.Error("entry not released by signal linked on reload")
+	}
+}
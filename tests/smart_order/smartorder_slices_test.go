@@ -0,0 +1,69 @@
+package smart_order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func runSlicedSmartOrder(priceLimit float64, orderType string, wait time.Duration) *tests.MockTrading {
+	smartOrderModel := GetTestSmartOrderStrategy("entryLong")
+	smartOrderModel.Conditions.EntryOrder.OrderType = orderType
+	smartOrderModel.Conditions.EntryOrder.Amount = 0.03
+	smartOrderModel.Conditions.EntrySlices = 3
+	smartOrderModel.Conditions.EntrySlicesDuration = 1
+	smartOrderModel.Conditions.EntrySlicesPriceLimit = priceLimit
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Open: 7100, High: 7101, Low: 7000, Close: 7005, Volume: 30}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, statsd := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Datafeed:        df,
+		Statsd:          statsd,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+	go smartOrder.Start()
+	time.Sleep(wait)
+	return tradingApi
+}
+
+// sliced entry should place the entry amount in slices over the duration
+func TestSmartOrderSlicedEntry(t *testing.T) {
+	tradingApi := runSlicedSmartOrder(0, "market", 1500*time.Millisecond)
+	if buyCallCount, found := tradingApi.CallCount.Load("buy"); !found || buyCallCount != 3 {
+		t.Errorf("expected 3 entry slices placed, got %v", buyCallCount)
+	}
+	if amount, found := tradingApi.AmountSum.Load("BTC_USDTbuy"); !found || amount.(float64) < 0.0299 || amount.(float64) > 0.0301 {
+		t.Errorf("expected entry amount 0.03 placed by slices, got %v", amount)
+	}
+}
+
+// slices should be skipped if price is beyond the limit
+func TestSmartOrderSlicedEntryPriceLimit(t *testing.T) {
+	tradingApi := runSlicedSmartOrder(7000, "market", 1500*time.Millisecond)
+	if buyCallCount, found := tradingApi.CallCount.Load("buy"); found && buyCallCount != 0 {
+		t.Errorf("expected no slices placed above the price cap, got %v", buyCallCount)
+	}
+}
+
+// limit slice not filled should be canceled before the next slice is placed
+func TestSmartOrderSlicedEntryCancelsUnfilled(t *testing.T) {
+	tradingApi := runSlicedSmartOrder(0, "limit", 800*time.Millisecond)
+	if buyCallCount, found := tradingApi.CallCount.Load("buy"); !found || buyCallCount != 2 {
+		t.Errorf("expected 2 entry slices placed, got %v", buyCallCount)
+	}
+	if canceledCount, found := tradingApi.CanceledOrdersCount.Load("BTC_USDT"); !found || canceledCount != 1 {
+		t.Errorf("expected the first slice canceled, got %v canceled", canceledCount)
+	}
+}
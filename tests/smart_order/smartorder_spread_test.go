@@ -7,6 +7,7 @@ import (
 	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
 	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
 	"gitlab.com/crypto_project/core/strategy_service/tests"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"testing"
@@ -78,3 +79,52 @@ func TestSmartOrderEntryBySpread(t *testing.T) {
 		t.Error("SmartOrder state is not InEntry (State: " + stateStr + ")")
 	}
 }
+
+// spread hunter should place sell entry inside the spread by offset from the best ask, skip thin or tight books and
+// reprice the order not filled in waiting time
+func TestSmartOrderSpreadHunterPlacement(t *testing.T) {
+	smartOrderModel := GetTestSmartOrderStrategy("entrySpread")
+	smartOrderModel.Conditions.EntryOrder.Side = "sell"
+	smartOrderModel.Conditions.EntryWaitingTime = 500
+	smartOrderModel.Conditions.SpreadHunterOffset = 0.25
+	smartOrderModel.Conditions.SpreadHunterMinQty = 1
+	df := tests.NewMockedSpreadDataFeed([]interfaces.SpreadData{{BestBid: 6900, BestAsk: 6950}}, []interfaces.OHLCV{{Close: 6900}})
+	tradingApi := tests.NewMockedTradingAPI()
+	keyId := primitive.NewObjectID()
+	sm := tests.NewMockedStateMgmt(tradingApi, df)
+	logger, stats := tests.GetLoggerStatsd()
+	strategy := strategies.Strategy{
+		Model:           &smartOrderModel,
+		StateMgmt:       &sm,
+		Log:             logger,
+		Statsd:          stats,
+		SettlementMutex: &redsync.Mutex{},
+	}
+	smartOrder := smart_order.New(&strategy, df, tradingApi, strategy.Statsd, &keyId, &sm)
+
+	_ = smartOrder.State.Fire(smart_order.TriggerSpread, interfaces.SpreadData{BestBid: 7000, BestAsk: 7100, BestAskQty: 0.5, BestBidQty: 5})
+	_ = smartOrder.State.Fire(smart_order.TriggerSpread, interfaces.SpreadData{BestBid: 7000, BestAsk: 7004, BestAskQty: 5})
+	if _, found := tradingApi.CallCount.Load("sell"); found {
+		t.Fatal("expected no entry placed at thin level or tight spread")
+	}
+	_ = smartOrder.State.Fire(smart_order.TriggerSpread, interfaces.SpreadData{BestBid: 7000, BestAsk: 7100, BestAskQty: 5})
+	if amount, _ := tradingApi.AmountSum.Load("BTC_USDTsell"); amount != 0.01 {
+		t.Fatalf("expected entry placed in spread, got %v", amount)
+	}
+	order, _ := tradingApi.OrdersMap.Load(smartOrderModel.State.WaitForEntryIds[0])
+	if price := order.(models.MongoOrder).Average; price != 7075 {
+		t.Errorf("expected sell entry at 7075 by a quarter of spread below best ask, got %v", price)
+	}
+	time.Sleep(800 * time.Millisecond)
+	_ = smartOrder.State.Fire(smart_order.TriggerSpread, interfaces.SpreadData{BestBid: 7000, BestAsk: 7080, BestAskQty: 5})
+	if sellCallCount, _ := tradingApi.CallCount.Load("sell"); sellCallCount != 2 {
+		t.Fatalf("expected entry repriced after waiting time, got %v orders", sellCallCount)
+	}
+	if order, _ := tradingApi.OrdersMap.Load(smartOrderModel.State.WaitForEntryIds[0]); order.(models.MongoOrder).Status != "canceled" {
+		t.Errorf("expected first entry canceled, got %v", order.(models.MongoOrder).Status)
+	}
+	order, _ = tradingApi.OrdersMap.Load(smartOrderModel.State.WaitForEntryIds[1])
+	if price := order.(models.MongoOrder).Average; price != 7060 {
+		t.Errorf("expected repriced entry at 7060, got %v", price)
+	}
+}
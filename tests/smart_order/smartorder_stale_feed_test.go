@@ -0,0 +1,33 @@
+package smart_order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+)
+
+// price triggers should be held while the feed is stale and resume once it's fresh again
+func TestSmartOrderHoldsOnStaleFeed(t *testing.T) {
+	smartOrder, model, tradingApi, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {})
+	df := sm.DataFeed.(*tests.MockDataFeed)
+	df.Stale = true
+	go smartOrder.Start()
+	defer func() { model.Enabled = false }()
+	time.Sleep(300 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.WaitForEntry || tradingApi.CreatedOrders.Len() != 0 {
+		t.Fatalf("expected entry held on stale feed, got %v with %v orders", state, tradingApi.CreatedOrders.Len())
+	}
+	if !smartOrder.IsFeedStale {
+		t.Error("expected feed marked stale")
+	}
+
+	df.Stale = false
+	time.Sleep(300 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry {
+		t.Errorf("expected entry on fresh feed, got %v", state)
+	}
+}
@@ -0,0 +1,104 @@
+package smart_order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies/smart_order"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+)
+
+// futures stop-loss should trigger by the mark price while the last price stays
+func TestSmartOrderStopLossByMarkPrice(t *testing.T) {
+	smartOrder, model, _, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.StopLoss = 10
+		conditions.StopLossType = "market"
+		conditions.TimeoutLoss = 60 // checked by the service, the exchange stop order works by itself otherwise
+		conditions.TriggerPriceSource = models.TriggerPriceMark
+	})
+	df := sm.DataFeed.(*tests.MockDataFeed)
+	df.MarkPrice = &interfaces.MarkPrice{Mark: 7000, Index: 7000}
+	go smartOrder.Start()
+	defer func() { model.Enabled = false }()
+	time.Sleep(300 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry {
+		t.Fatalf("expected entry, got %v", state)
+	}
+
+	df.MarkPrice = &interfaces.MarkPrice{Mark: 6900, Index: 7000} // last price stays at 7000
+	time.Sleep(300 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.Stoploss {
+		t.Errorf("expected stop-loss by mark price, got %v", state)
+	}
+}
+
+// exchange stop orders should work by the price the service triggers by
+func TestSmartOrderStopWorkingType(t *testing.T) {
+	cases := map[string]string{
+		"":                      "CONTRACT_PRICE",
+		models.TriggerPriceLast: "CONTRACT_PRICE",
+		models.TriggerPriceMark: "MARK_PRICE",
+	}
+	for source, expected := range cases {
+		smartOrder, model, tradingApi, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+			conditions.StopLoss = 10
+			conditions.StopLossType = "market"
+			conditions.TriggerPriceSource = source
+		})
+		sm.DataFeed.(*tests.MockDataFeed).MarkPrice = &interfaces.MarkPrice{Mark: 7000, Index: 7000}
+		go smartOrder.Start()
+		time.Sleep(300 * time.Millisecond)
+		model.Enabled = false
+		workingTypes := 0
+		tradingApi.WorkingTypes.Range(func(orderId, workingType interface{}) bool {
+			workingTypes++
+			if workingType != expected {
+				t.Errorf("expected stop order %v of %q source working by %v, got %v", orderId, source, expected, workingType)
+			}
+			return true
+		})
+		if workingTypes == 0 {
+			t.Errorf("expected stop-loss order of %q source placed with working type", source)
+		}
+	}
+}
+
+// index triggers should be rejected, exchange stop orders can't work by index price
+func TestSmartOrderRejectsIndexPrice(t *testing.T) {
+	smartOrder, model, tradingApi, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.StopLoss = 10
+		conditions.StopLossType = "market"
+		conditions.TriggerPriceSource = models.TriggerPriceIndex
+	})
+	sm.DataFeed.(*tests.MockDataFeed).MarkPrice = &interfaces.MarkPrice{Mark: 7000, Index: 7000}
+	smartOrder.Start()
+	if model.Enabled || model.State.State != smart_order.Error || tradingApi.CreatedOrders.Len() != 0 {
+		t.Errorf("expected strategy disabled in error state with no orders, got %v %v with %v orders",
+			model.Enabled, model.State.State, tradingApi.CreatedOrders.Len())
+	}
+}
+
+// triggers should be held while the mark price is missing rather than fall back to the last price
+func TestSmartOrderHoldsWithoutMarkPrice(t *testing.T) {
+	smartOrder, model, tradingApi, sm := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {
+		conditions.TriggerPriceSource = models.TriggerPriceMark
+	})
+	go smartOrder.Start()
+	defer func() { model.Enabled = false }()
+	time.Sleep(300 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.WaitForEntry || tradingApi.CreatedOrders.Len() != 0 {
+		t.Fatalf("expected entry held without mark price, got %v with %v orders", state, tradingApi.CreatedOrders.Len())
+	}
+	if !smartOrder.IsTriggerPriceMissing {
+		t.Error("expected mark price marked missing")
+	}
+
+	sm.DataFeed.(*tests.MockDataFeed).MarkPrice = &interfaces.MarkPrice{Mark: 7000, Index: 7000}
+	time.Sleep(300 * time.Millisecond)
+	if state, _ := smartOrder.State.State(context.Background()); state != smart_order.InEntry {
+		t.Errorf("expected entry once mark price comes, got %v", state)
+	}
+}
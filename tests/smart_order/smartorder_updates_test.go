@@ -0,0 +1,36 @@
+package smart_order
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+)
+
+// smart order on a pushing feed should take the price on updates and once a second on a quiet market only
+func TestSmartOrderDrivenByUpdates(t *testing.T) {
+	smartOrder, model, _, _ := waitingMarketEntry(func(conditions *models.MongoStrategyCondition) {})
+	df := smartOrder.DataFeed.(*tests.MockDataFeed)
+	df.Pushing = true
+	go smartOrder.Start()
+	time.Sleep(300 * time.Millisecond)
+	if ticks := df.Ticks(); ticks != 1 {
+		t.Fatalf("expected the price taken once on start, got %v", ticks)
+	}
+
+	for i := 0; i < 3; i++ {
+		df.Push(model.Conditions.Pair, interfaces.Update{Price: &interfaces.OHLCV{Close: 7000}})
+		time.Sleep(50 * time.Millisecond)
+	}
+	if ticks := df.Ticks(); ticks != 4 {
+		t.Errorf("expected the price taken on each update, got %v", ticks)
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+	if ticks := df.Ticks(); ticks < 5 || ticks > 10 { // polling would take it 35 times
+		t.Errorf("expected the price taken by the timer once a second, got %v", ticks)
+	}
+	model.Enabled = false
+}
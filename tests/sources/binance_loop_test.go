@@ -0,0 +1,231 @@
+package sources
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/binance"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/orderbook"
+)
+
+// mini tickers should form candles with volume traded between them and the price should be the forming minute candle
+func TestBinanceLoopCandles(t *testing.T) {
+	loop := &binance.BinanceLoop{}
+	loop.UpdateOHLCV([]byte(`[{"e":"24hrMiniTicker","E":600000000,"s":"BTCUSDT","c":"7000","v":"100"}]`), 1)
+	loop.UpdateOHLCV([]byte(`[{"e":"24hrMiniTicker","E":600010000,"s":"BTCUSDT","c":"7100","v":"102.5"}]`), 1)
+	loop.UpdateOHLCV([]byte(`[{"e":"24hrMiniTicker","E":600020000,"s":"BTCUSDT","c":"6950","v":"103"}]`), 1)
+
+	price := loop.GetPrice("BTC_USDT", "binance", 1)
+	if price == nil || price.UpdatedAt == 0 {
+		t.Fatal("expected price stamped")
+	}
+	expected := interfaces.OHLCV{Open: 7000, High: 7100, Low: 6950, Close: 6950, Volume: 3}
+	if price.UpdatedAt = 0; *price != expected {
+		t.Errorf("expected forming minute candle %+v, got %+v", expected, *price)
+	}
+	if seconds := loop.GetCandles("BTC_USDT", "binance", 1, 1, 100); len(seconds) != 21 || seconds[10].Close != 7100 {
+		t.Errorf("expected 21 second candles, got %v", len(seconds))
+	}
+	if loop.GetCandles("BTC_USDT", "binance", 0, 60, 10) != nil {
+		t.Error("expected no spot candles")
+	}
+}
+
+// spot and futures book tickers should be kept apart with their quantities
+func TestBinanceLoopSpreads(t *testing.T) {
+	loop := &binance.BinanceLoop{}
+	loop.UpdateSpread([]byte(`{"u":1,"s":"BTCUSDT","b":"7000.1","B":"2.5","a":"7000.2","A":"0.4"}`), 1)
+	loop.UpdateSpread([]byte(`{"u":2,"s":"BTCUSDT","b":"6999","B":"1","a":"7001","A":"3"}`), 0)
+	loop.UpdateSpread([]byte(`{"result":null,"id":1}`), 0)
+
+	futures := loop.GetSpread("BTC_USDT", "binance", 1)
+	if futures == nil || futures.BestBid != 7000.1 || futures.BestBidQty != 2.5 || futures.BestAskQty != 0.4 {
+		t.Errorf("expected futures spread with quantities, got %+v", futures)
+	}
+	spot := loop.GetSpread("BTC_USDT", "binance", 0)
+	if spot == nil || spot.BestBid != 6999 || spot.BestAsk != 7001 || spot.BestAskQty != 3 || spot.UpdatedAt == 0 {
+		t.Errorf("expected spot spread stamped, got %+v", spot)
+	}
+}
+
+// the spot spread asked for least recently should be released for a new pair once the connection is full
+func TestBinanceLoopSpotSpreadReleased(t *testing.T) {
+	loop := &binance.BinanceLoop{}
+	loop.GetSpread("BTC_USDT", "binance", 0)
+	loop.UpdateSpread([]byte(`{"u":1,"s":"BTCUSDT","b":"6999","B":"1","a":"7001","A":"3"}`), 0)
+	time.Sleep(time.Millisecond)
+	for i := 0; i < 1023; i++ {
+		loop.GetSpread(fmt.Sprintf("PAIR%v_USDT", i), "binance", 0)
+	}
+	loop.UpdateSpread([]byte(`{"u":2,"s":"PAIR0USDT","b":"1","B":"1","a":"2","A":"1"}`), 0)
+	if loop.GetSpread("BTC_USDT", "binance", 0) == nil {
+		t.Fatal("expected spread kept while the connection is not full")
+	}
+	time.Sleep(time.Millisecond)
+	loop.GetSpread("ETH_USDT", "binance", 0)
+	if loop.GetSpread("PAIR0_USDT", "binance", 0) != nil {
+		t.Error("expected spread asked for least recently released")
+	}
+	if loop.GetSpread("BTC_USDT", "binance", 0) == nil {
+		t.Error("expected spread asked for recently kept")
+	}
+}
+
+// subscribers should get the price and spread changed only, merged while not taken
+func TestBinanceLoopUpdates(t *testing.T) {
+	loop := &binance.BinanceLoop{}
+	updates := loop.Subscribe("BTC_USDT", "binance", 1)
+	loop.UpdateOHLCV([]byte(`[{"E":600000000,"s":"BTCUSDT","c":"7000","v":"100"}]`), 1)
+	loop.UpdateSpread([]byte(`{"u":1,"s":"BTCUSDT","b":"7000.1","B":"2.5","a":"7000.2","A":"0.4"}`), 1)
+	loop.UpdateOHLCV([]byte(`[{"E":600000000,"s":"ETHUSDT","c":"200","v":"100"}]`), 1)
+
+	update := <-updates
+	if update.Price == nil || update.Price.Close != 7000 || update.Spread == nil || update.Spread.BestBid != 7000.1 {
+		t.Fatalf("expected price and spread merged, got %+v", update)
+	}
+	loop.UpdateOHLCV([]byte(`[{"E":600001000,"s":"BTCUSDT","c":"7000","v":"101"}]`), 1)
+	loop.UpdateSpread([]byte(`{"u":2,"s":"BTCUSDT","b":"7000.1","B":"1","a":"7000.2","A":"1"}`), 1)
+	select {
+	case update := <-updates:
+		t.Errorf("expected no update of the same price, got %+v", update)
+	default:
+	}
+
+	loop.Unsubscribe("BTC_USDT", "binance", 1, updates)
+	if _, ok := <-updates; ok {
+		t.Error("expected updates closed")
+	}
+}
+
+// mark prices of futures should be kept and pushed to subscribers, spot markets have none
+func TestBinanceLoopMarkPrices(t *testing.T) {
+	loop := &binance.BinanceLoop{}
+	updates := loop.Subscribe("BTC_USDT", "binance", 1)
+	loop.UpdateMarkPrices([]byte(`[{"e":"markPriceUpdate","E":600000000,"s":"BTCUSDT","p":"6990.5","i":"6991","r":"0.0001","T":600100000},{"s":"ETHUSDT","p":"200","i":"200.1","r":"0","T":600100000}]`))
+
+	markPrice := loop.GetMarkPrice("BTC_USDT", "binance", 1)
+	if markPrice == nil || markPrice.Mark != 6990.5 || markPrice.Index != 6991 || markPrice.FundingRate != 0.0001 || markPrice.NextFundingTime != 600100000 || markPrice.UpdatedAt == 0 {
+		t.Fatalf("expected mark price of BTCUSDT, got %+v", markPrice)
+	}
+	if loop.GetMarkPrice("BTC_USDT", "binance", 0) != nil {
+		t.Error("expected no mark price of spot")
+	}
+	if update := <-updates; update.MarkPrice == nil || update.MarkPrice.Mark != 6990.5 {
+		t.Errorf("expected mark price update, got %+v", update)
+	}
+	loop.UpdateMarkPrices([]byte(`[{"s":"BTCUSDT","p":"6990.5","i":"6991","r":"0.0002","T":600100000}]`))
+	select {
+	case update := <-updates:
+		t.Errorf("expected no update of the same mark price, got %+v", update)
+	default:
+	}
+	loop.Unsubscribe("BTC_USDT", "binance", 1, updates)
+}
+
+// diff-depth events should be applied on top of the snapshot fetched for the book out of sync
+func TestBinanceLoopOrderBook(t *testing.T) {
+	fetched := make(chan string, 10)
+	loop := &binance.BinanceLoop{FetchDepthSnapshot: func(symbol string, marketType int64) (orderbook.Snapshot, error) {
+		fetched <- symbol
+		return orderbook.Snapshot{
+			LastUpdateId: 100,
+			Bids:         []interfaces.PriceLevel{{Price: 7000, Amount: 1}},
+			Asks:         []interfaces.PriceLevel{{Price: 7001, Amount: 1}},
+		}, nil
+	}}
+	if loop.GetOrderBook("BTC_USDT", "binance", 1, 10) != nil {
+		t.Fatal("expected no book before events")
+	}
+	loop.UpdateDepth([]byte(`{"e":"depthUpdate","s":"BTCUSDT","U":99,"u":101,"pu":98,"b":[["7000","2"]],"a":[]}`), 1)
+	if symbol := <-fetched; symbol != "BTCUSDT" {
+		t.Fatalf("expected snapshot of BTCUSDT, got %v", symbol)
+	}
+	var book *interfaces.OrderBook
+	for i := 0; i < 20 && book == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		book = loop.GetOrderBook("BTC_USDT", "binance", 1, 10)
+	}
+	if book == nil || book.Bids[0].Amount != 2 || book.Asks[0].Price != 7001 {
+		t.Fatalf("expected book synced with the diff, got %+v", book)
+	}
+
+	loop.UpdateDepth([]byte(`{"e":"depthUpdate","s":"BTCUSDT","U":110,"u":111,"pu":105,"b":[],"a":[]}`), 1)
+	if loop.GetOrderBook("BTC_USDT", "binance", 1, 10) != nil {
+		t.Error("expected book out of sync on the gap")
+	}
+	select {
+	case <-fetched:
+	case <-time.After(time.Second):
+		t.Error("expected snapshot fetched again on the gap")
+	}
+}
+
+// the book asked for least recently should be released for a new pair once the connection keeps max books
+func TestBinanceLoopOrderBookReleased(t *testing.T) {
+	loop := &binance.BinanceLoop{FetchDepthSnapshot: func(symbol string, marketType int64) (orderbook.Snapshot, error) {
+		return orderbook.Snapshot{
+			LastUpdateId: 100,
+			Bids:         []interfaces.PriceLevel{{Price: 7000, Amount: 1}},
+			Asks:         []interfaces.PriceLevel{{Price: 7001, Amount: 1}},
+		}, nil
+	}}
+	loop.GetOrderBook("BTC_USDT", "binance", 1, 10)
+	loop.UpdateDepth([]byte(`{"e":"depthUpdate","s":"BTCUSDT","U":99,"u":101,"pu":98,"b":[],"a":[]}`), 1)
+	var book *interfaces.OrderBook
+	for i := 0; i < 20 && book == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		book = loop.GetOrderBook("BTC_USDT", "binance", 1, 10)
+	}
+	if book == nil {
+		t.Fatal("expected book synced")
+	}
+	time.Sleep(time.Millisecond)
+	for i := 0; i < 200; i++ {
+		loop.GetOrderBook(fmt.Sprintf("PAIR%v_USDT", i), "binance", 1, 10)
+	}
+	loop.UpdateDepth([]byte(`{"e":"depthUpdate","s":"BTCUSDT","U":102,"u":102,"pu":101,"b":[],"a":[]}`), 1)
+	if loop.GetOrderBook("BTC_USDT", "binance", 1, 10) != nil {
+		t.Error("expected book asked for least recently released and its updates skipped")
+	}
+}
+
+// the book kept should not be released for new pairs and should be released once the last strategy stops
+func TestBinanceLoopOrderBookKept(t *testing.T) {
+	loop := &binance.BinanceLoop{FetchDepthSnapshot: func(symbol string, marketType int64) (orderbook.Snapshot, error) {
+		return orderbook.Snapshot{
+			LastUpdateId: 100,
+			Bids:         []interfaces.PriceLevel{{Price: 7000, Amount: 1}},
+			Asks:         []interfaces.PriceLevel{{Price: 7001, Amount: 1}},
+		}, nil
+	}}
+	loop.KeepOrderBook("BTC_USDT", "binance", 1)
+	loop.KeepOrderBook("BTC_USDT", "binance", 1)
+	loop.UpdateDepth([]byte(`{"e":"depthUpdate","s":"BTCUSDT","U":99,"u":101,"pu":98,"b":[],"a":[]}`), 1)
+	var book *interfaces.OrderBook
+	for i := 0; i < 20 && book == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		book = loop.GetOrderBook("BTC_USDT", "binance", 1, 10)
+	}
+	if book == nil {
+		t.Fatal("expected book synced")
+	}
+	time.Sleep(time.Millisecond)
+	for i := 0; i < 200; i++ {
+		loop.GetOrderBook(fmt.Sprintf("PAIR%v_USDT", i), "binance", 1, 10)
+	}
+	if loop.GetOrderBook("BTC_USDT", "binance", 1, 10) == nil {
+		t.Fatal("expected book kept while asked for least recently")
+	}
+
+	loop.ReleaseOrderBook("BTC_USDT", "binance", 1)
+	if loop.GetOrderBook("BTC_USDT", "binance", 1, 10) == nil {
+		t.Fatal("expected book kept while a strategy keeps it")
+	}
+	loop.ReleaseOrderBook("BTC_USDT", "binance", 1)
+	loop.UpdateDepth([]byte(`{"e":"depthUpdate","s":"BTCUSDT","U":102,"u":102,"pu":101,"b":[],"a":[]}`), 1)
+	if loop.GetOrderBook("BTC_USDT", "binance", 1, 10) != nil {
+		t.Error("expected book released once no strategy keeps it")
+	}
+}
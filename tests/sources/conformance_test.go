@@ -0,0 +1,257 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/binance"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry/conformance"
+)
+
+// binanceStandIn serves Binance raw streams and depth snapshots of spot under /spot and futures under /futures.
+type binanceStandIn struct {
+	server *httptest.Server
+	mux    sync.Mutex
+	conns  map[*standInConn]bool
+	books  map[string]interfaces.OrderBook // <string: symbol+marketType>
+	seq    int64                           // last depth update id
+	volume float64
+}
+
+type standInConn struct {
+	mux        sync.Mutex // one writer at a time
+	conn       *websocket.Conn
+	marketType int64
+	stream     string          // the all market stream opened by path, "" for subscriptions
+	subscribed map[string]bool // streams subscribed by the connection
+}
+
+var standInMarkets = [2]string{"spot", "futures"}
+
+func newBinanceStandIn() *binanceStandIn {
+	s := &binanceStandIn{conns: map[*standInConn]bool{}, books: map[string]interfaces.OrderBook{}}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *binanceStandIn) Config() map[string]string {
+	url := strings.TrimPrefix(s.server.URL, "http://")
+	return map[string]string{
+		binance.KeySpotStreamUrl:    "ws://" + url + "/spot/ws",
+		binance.KeyFuturesStreamUrl: "ws://" + url + "/futures/ws",
+		binance.KeySpotRestUrl:      s.server.URL + "/spot",
+		binance.KeyFuturesRestUrl:   s.server.URL + "/futures",
+	}
+}
+
+func (s *binanceStandIn) serve(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	marketType := int64(0)
+	if parts[0] == "futures" {
+		marketType = 1
+	}
+	if len(parts) > 1 && parts[1] == "ws" {
+		stream := ""
+		if len(parts) > 2 {
+			stream = parts[2]
+		}
+		s.accept(w, r, marketType, stream)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/depth") {
+		s.mux.Lock()
+		book, seq := s.books[r.URL.Query().Get("symbol")+strconv.FormatInt(marketType, 10)], s.seq
+		s.mux.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"lastUpdateId": seq, "bids": levels(book.Bids), "asks": levels(book.Asks)})
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (s *binanceStandIn) accept(w http.ResponseWriter, r *http.Request, marketType int64, stream string) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &standInConn{conn: conn, marketType: marketType, stream: stream, subscribed: map[string]bool{}}
+	s.mux.Lock()
+	s.conns[c] = true
+	s.mux.Unlock()
+	go func() {
+		defer func() {
+			s.mux.Lock()
+			delete(s.conns, c)
+			s.mux.Unlock()
+			_ = conn.Close()
+		}()
+		for {
+			var request struct {
+				Method string   `json:"method"`
+				Params []string `json:"params"`
+				Id     int      `json:"id"`
+			}
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			s.mux.Lock()
+			for _, subscribed := range request.Params {
+				c.subscribed[subscribed] = true
+			}
+			s.mux.Unlock()
+			c.write(map[string]interface{}{"result": nil, "id": request.Id})
+		}
+	}()
+}
+
+func (c *standInConn) write(message interface{}) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	_ = c.conn.WriteJSON(message)
+}
+
+// send writes the message to connections of the market type having the stream given open or subscribed.
+func (s *binanceStandIn) send(marketType int64, stream string, message interface{}) {
+	s.mux.Lock()
+	var receivers []*standInConn
+	for c := range s.conns {
+		if c.marketType == marketType && (c.stream == stream || c.subscribed[stream]) {
+			receivers = append(receivers, c)
+		}
+	}
+	s.mux.Unlock()
+	for _, c := range receivers {
+		c.write(message)
+	}
+}
+
+func (s *binanceStandIn) Ticker(pair string, marketType int64, price float64) {
+	s.mux.Lock()
+	s.volume++
+	volume := s.volume
+	s.mux.Unlock()
+	s.send(marketType, "!miniTicker@arr", []map[string]interface{}{{
+		"e": "24hrMiniTicker",
+		"E": time.Now().UnixNano() / int64(time.Millisecond),
+		"s": symbol(pair),
+		"c": format(price),
+		"v": format(volume),
+	}})
+}
+
+func (s *binanceStandIn) Spread(pair string, marketType int64, bid float64, ask float64) {
+	stream := strings.ToLower(symbol(pair)) + "@bookTicker"
+	if marketType == 1 {
+		stream = "!bookTicker"
+	}
+	s.send(marketType, stream, map[string]interface{}{"u": 1, "s": symbol(pair), "b": format(bid), "B": "1", "a": format(ask), "A": "1"})
+}
+
+func (s *binanceStandIn) MarkPrice(pair string, mark float64, index float64) {
+	s.send(1, "!markPrice@arr@1s", []map[string]interface{}{{
+		"e": "markPriceUpdate",
+		"E": time.Now().UnixNano() / int64(time.Millisecond),
+		"s": symbol(pair),
+		"p": format(mark),
+		"i": format(index),
+		"r": "0.0001",
+		"T": time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond),
+	}})
+}
+
+// Book serves the book as the snapshot and sends an empty diff continuing the sequence, so the adapter fetches the
+// snapshot on its first diff.
+func (s *binanceStandIn) Book(pair string, marketType int64, book interfaces.OrderBook) {
+	s.mux.Lock()
+	s.books[symbol(pair)+strconv.FormatInt(marketType, 10)] = book
+	s.seq++
+	seq := s.seq
+	s.mux.Unlock()
+	s.send(marketType, strings.ToLower(symbol(pair))+"@depth@100ms", map[string]interface{}{
+		"e": "depthUpdate", "s": symbol(pair), "U": seq, "u": seq, "pu": seq - 1, "b": [][2]string{}, "a": [][2]string{},
+	})
+}
+
+func (s *binanceStandIn) Disconnect() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for c := range s.conns {
+		_ = c.conn.Close()
+	}
+}
+
+func (s *binanceStandIn) Close() {
+	s.Disconnect()
+	s.server.Close()
+}
+
+func symbol(pair string) string {
+	return strings.Replace(pair, "_", "", -1)
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func levels(priceLevels []interfaces.PriceLevel) [][2]string {
+	raw := make([][2]string, len(priceLevels))
+	for i, level := range priceLevels {
+		raw[i] = [2]string{format(level.Price), format(level.Amount)}
+	}
+	return raw
+}
+
+// the Binance adapter should serve all it declares for spot and futures
+func TestBinanceConformance(t *testing.T) {
+	standIn := newBinanceStandIn()
+	defer standIn.Close()
+	conformance.Run(t, conformance.Suite{
+		Name:     "binance",
+		Factory:  binance.New,
+		Exchange: "binance",
+		Pair:     "BTC_USDT",
+		StandIn:  standIn,
+	})
+}
+
+// adapters should be created once asked for and serve exchanges configured instead of the ones registered
+func TestRegistryRouting(t *testing.T) {
+	created := 0
+	feeds := &registry.Registry{}
+	feeds.Register("fake", func(config registry.Config) (registry.Adapter, error) {
+		created++
+		if config.Get("SETTING", "") != "set" {
+			return nil, fmt.Errorf("expected setting configured, got %q", config.Get("SETTING", ""))
+		}
+		return &fakeAdapter{}, nil
+	}, "one")
+	feeds.Configure("fake", map[string]string{"SETTING": "set"})
+	if feeds.Adapter("one") == nil || feeds.Adapter("one") == nil || created != 1 {
+		t.Fatalf("expected adapter created once, created %v times", created)
+	}
+	if feeds.Adapter("two") != nil {
+		t.Error("expected no adapter of the exchange not registered")
+	}
+
+	feeds.Configure("fake", map[string]string{"SETTING": "set", registry.KeyExchanges: "two, three"})
+	if feeds.Adapter("one") != nil || feeds.Adapter("three") == nil {
+		t.Error("expected exchanges configured served instead of the ones registered")
+	}
+	feeds.Configure("fake", map[string]string{"SETTING": "set", registry.KeyEnabled: "false"})
+	if feeds.Adapter("one") != nil {
+		t.Error("expected adapter disabled")
+	}
+}
+
+type fakeAdapter struct {
+	registry.Adapter
+}
@@ -0,0 +1,45 @@
+package sources
+
+import (
+	"testing"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/fanout"
+)
+
+// every subscriber should get the latest update, a slow one gets updates not taken merged
+func TestHubFanOut(t *testing.T) {
+	var hub fanout.Hub
+	fast, slow := hub.Subscribe("BTCUSDT1"), hub.Subscribe("BTCUSDT1")
+	other := hub.Subscribe("ETHUSDT1")
+
+	hub.Publish("BTCUSDT1", interfaces.Update{Price: &interfaces.OHLCV{Close: 7000}})
+	if update := <-fast; update.Price.Close != 7000 {
+		t.Fatalf("expected 7000, got %+v", update.Price)
+	}
+	hub.Publish("BTCUSDT1", interfaces.Update{Spread: &interfaces.SpreadData{BestBid: 7001}})
+	hub.Publish("BTCUSDT1", interfaces.Update{Price: &interfaces.OHLCV{Close: 7002}})
+	if update := <-fast; update.Price.Close != 7002 || update.Spread.BestBid != 7001 {
+		t.Errorf("expected price 7002 with spread 7001, got %+v %+v", update.Price, update.Spread)
+	}
+	if update := <-slow; update.Price.Close != 7002 || update.Spread.BestBid != 7001 {
+		t.Errorf("expected slow subscriber to get the latest values, got %+v %+v", update.Price, update.Spread)
+	}
+	if hub.Coalesced() != 3 {
+		t.Errorf("expected 3 updates merged, got %v", hub.Coalesced())
+	}
+	select {
+	case update := <-other:
+		t.Errorf("expected no updates of other markets, got %+v", update)
+	default:
+	}
+
+	hub.Unsubscribe("BTCUSDT1", slow)
+	hub.Publish("BTCUSDT1", interfaces.Update{Price: &interfaces.OHLCV{Close: 7003}})
+	if _, ok := <-slow; ok {
+		t.Error("expected unsubscribed updates closed")
+	}
+	if !hub.HasSubscribers("BTCUSDT1") {
+		t.Error("expected the fast subscriber left")
+	}
+}
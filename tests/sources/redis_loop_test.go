@@ -0,0 +1,251 @@
+package sources
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/redis"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/registry/conformance"
+)
+
+// redisStandIn is a Redis server of pattern subscriptions only, publishing candles of all markets to candles:<pair>
+// and spreads to the default best:<exchange>:<pair>:<market type>.
+type redisStandIn struct {
+	listener net.Listener
+	mux      sync.Mutex
+	conns    map[*redisConn]bool
+	volume   float64
+}
+
+type redisConn struct {
+	mux      sync.Mutex // one writer at a time
+	conn     net.Conn
+	patterns []string
+}
+
+// standInChannels are candles of a publisher other than the default one with the exchange and market type in messages.
+const standInChannels = `[
+	{"name": "candles", "pattern": "candles:*", "kind": "candle",
+		"schema": {"pair": "pair", "exchange": "exchange", "marketType": "marketType", "close": "close", "volume": "volume"}},
+	{"name": "spreads", "pattern": "best:*:*:*", "kind": "spread",
+		"schema": {"pair": "symbol", "exchange": "exchange", "marketType": "marketType", "bestBid": "bestBidPrice", "bestAsk": "bestAskPrice"}}
+]`
+
+func newRedisStandIn(t *testing.T) *redisStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &redisStandIn{listener: listener, conns: map[*redisConn]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			c := &redisConn{conn: conn}
+			s.mux.Lock()
+			s.conns[c] = true
+			s.mux.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *redisStandIn) Config() map[string]string {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return map[string]string{redis.KeyHost: host, redis.KeyPort: port, redis.KeyChannels: standInChannels}
+}
+
+// serve replies to commands of the connection until it's closed.
+func (s *redisStandIn) serve(c *redisConn) {
+	defer func() {
+		s.mux.Lock()
+		delete(s.conns, c)
+		s.mux.Unlock()
+		_ = c.conn.Close()
+	}()
+	reader := bufio.NewReader(c.conn)
+	for {
+		command, err := readCommand(reader)
+		if err != nil || len(command) == 0 {
+			return
+		}
+		switch strings.ToUpper(command[0]) {
+		case "PSUBSCRIBE":
+			for _, pattern := range command[1:] {
+				s.mux.Lock()
+				c.patterns = append(c.patterns, pattern)
+				count := len(c.patterns)
+				s.mux.Unlock()
+				c.write("*3\r\n" + bulk("psubscribe") + bulk(pattern) + ":" + strconv.Itoa(count) + "\r\n")
+			}
+		case "PUNSUBSCRIBE":
+			s.mux.Lock()
+			c.patterns = nil
+			s.mux.Unlock()
+			c.write("*3\r\n" + bulk("punsubscribe") + "$-1\r\n:0\r\n")
+		case "PING":
+			c.write("*2\r\n" + bulk("pong") + bulk(strings.Join(command[1:], "")))
+		default:
+			c.write("+OK\r\n")
+		}
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	command := make([]string, n)
+	for i := range command {
+		if _, err := reader.ReadString('\n'); err != nil { // the length
+			return nil, err
+		}
+		if command[i], err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		command[i] = strings.TrimSuffix(command[i], "\r\n")
+	}
+	return command, nil
+}
+
+func bulk(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+func (c *redisConn) write(reply string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	_, _ = c.conn.Write([]byte(reply))
+}
+
+// publish sends the message to connections subscribed to a pattern of the channel.
+func (s *redisStandIn) publish(channel string, message interface{}) {
+	data, _ := json.Marshal(message)
+	s.mux.Lock()
+	var receivers = map[*redisConn]string{}
+	for c := range s.conns {
+		for _, pattern := range c.patterns {
+			if matched, _ := filepath.Match(pattern, channel); matched {
+				receivers[c] = pattern
+			}
+		}
+	}
+	s.mux.Unlock()
+	for c, pattern := range receivers {
+		c.write("*4\r\n" + bulk("pmessage") + bulk(pattern) + bulk(channel) + bulk(string(data)))
+	}
+}
+
+func (s *redisStandIn) Ticker(pair string, marketType int64, price float64) {
+	s.mux.Lock()
+	s.volume++
+	volume := s.volume
+	s.mux.Unlock()
+	s.publish("candles:"+pair, map[string]interface{}{
+		"pair": pair, "exchange": "serum", "marketType": marketType, "close": format(price), "volume": volume,
+	})
+}
+
+func (s *redisStandIn) Spread(pair string, marketType int64, bid float64, ask float64) {
+	s.publish(fmt.Sprintf("best:serum:%v:%v", pair, marketType), map[string]interface{}{
+		"symbol": pair, "exchange": "serum", "marketType": marketType, "bestBidPrice": format(bid), "bestAskPrice": ask,
+	})
+}
+
+// MarkPrice sends nothing, channels carry no mark prices.
+func (s *redisStandIn) MarkPrice(pair string, mark float64, index float64) {}
+
+// Book sends nothing, channels carry no order books.
+func (s *redisStandIn) Book(pair string, marketType int64, book interfaces.OrderBook) {}
+
+func (s *redisStandIn) Disconnect() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for c := range s.conns {
+		_ = c.conn.Close()
+	}
+}
+
+func (s *redisStandIn) Close() {
+	_ = s.listener.Close()
+	s.Disconnect()
+}
+
+// the Redis adapter should serve all it declares from the channels configured
+func TestRedisConformance(t *testing.T) {
+	standIn := newRedisStandIn(t)
+	defer standIn.Close()
+	conformance.Run(t, conformance.Suite{
+		Name:     "redis",
+		Factory:  redis.New,
+		Exchange: "serum",
+		Pair:     "SRM_USDT",
+		StandIn:  standIn,
+	})
+}
+
+// messages should be decoded by the schema of their channel and ones not decoded counted by channel and reason
+func TestRedisLoopSchemas(t *testing.T) {
+	loop := &redis.RedisLoop{}
+	candles := redis.DefaultChannels[0]
+	loop.Update(candles, []byte(`{"fsym":"SRM","tsym":"USDT","open_price":"1.1","high_price":1.3,"low_price":"1","close_price":"1.2","volume":"10"}`))
+	price := loop.GetPrice("SRM_USDT", "serum", 0)
+	if price == nil {
+		t.Fatal("expected price of the default candles channel")
+	}
+	expected := interfaces.OHLCV{Open: 1.1, High: 1.3, Low: 1, Close: 1.2, Volume: 10}
+	if price.UpdatedAt = 0; *price != expected {
+		t.Errorf("expected candle %+v, got %+v", expected, *price)
+	}
+
+	channels, err := redis.ParseChannels(`[{"pattern":"dex:*","kind":"candle","exchange":"dex","marketType":1,"schema":{"pairParts":["base","quote"],"close":"price"}}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dex := channels[0]
+	loop.Update(dex, []byte(`{"base":"RAY","quote":"USDC","price":2.5}`))
+	if price := loop.GetPrice("RAY_USDC", "dex", 1); price == nil || price.Open != 2.5 || price.Low != 2.5 || price.Volume != 0 {
+		t.Errorf("expected keys not in the schema default to the close, got %+v", price)
+	}
+
+	loop.Update(dex, []byte(`{"base":"RAY"`))
+	loop.Update(dex, []byte(`{"base":"RAY","quote":"USDC","price":"none"}`))
+	loop.Update(dex, []byte(`{"base":"RAY","quote":"USDC"}`))
+	loop.Update(redis.DefaultChannels[1], []byte(`{"symbol":"RAY_USDC","exchange":"dex","marketType":0}`))
+	expectedErrors := map[string]int64{"redis.candle.decode": 1, "redis.candle.schema": 2, "redis.spreads.schema": 1}
+	if errors := loop.FeedErrors(); fmt.Sprint(errors) != fmt.Sprint(expectedErrors) {
+		t.Errorf("expected errors %v, got %v", expectedErrors, errors)
+	}
+	if price := loop.GetPrice("RAY_USDC", "dex", 1); price == nil || price.Close != 2.5 {
+		t.Errorf("expected messages not decoded ignored, got %+v", price)
+	}
+
+	for _, invalid := range []string{
+		`[{"pattern":"dex:*","kind":"trade","exchange":"dex","schema":{"pair":"pair","close":"price"}}]`,
+		`[{"pattern":"dex:*","kind":"candle","exchange":"dex","schema":{"pair":"pair"}}]`,
+		`[{"pattern":"dex:*","kind":"candle","schema":{"pair":"pair","close":"price"}}]`,
+		`[{"pattern":"dex:*","kind":"candle","exchange":"dex","schema":{"pair":"pair","close":"price"}},
+		  {"pattern":"dex:*","kind":"spread","exchange":"dex","schema":{"pair":"pair","bestBid":"bid","bestAsk":"ask"}}]`,
+	} {
+		if _, err := redis.ParseChannels(invalid); err == nil {
+			t.Errorf("expected channels %v rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package sources
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/supervisor"
+)
+
+// fakeConnect fails the first failures connects, then opens connections sending the message given every interval
+// or nothing if interval is 0.
+func fakeConnect(failures int32, interval time.Duration, connects *int32) supervisor.Connect {
+	return func() (<-chan []byte, <-chan struct{}, func(), error) {
+		if atomic.AddInt32(connects, 1) <= failures {
+			return nil, nil, nil, errors.New("refused")
+		}
+		messages := make(chan []byte)
+		done := make(chan struct{})
+		go func() {
+			if interval == 0 {
+				return
+			}
+			for {
+				select {
+				case messages <- []byte("tick"):
+					time.Sleep(interval)
+				case <-done:
+					return
+				}
+			}
+		}()
+		return messages, done, func() { close(done) }, nil
+	}
+}
+
+func runStream(stream *supervisor.Stream) chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		stream.Run()
+		close(stopped)
+	}()
+	return stopped
+}
+
+// failed connects should be retried with backoff until connected
+func TestStreamReconnectsWithBackoff(t *testing.T) {
+	var connects, received int32
+	stream := supervisor.NewStream("test", fakeConnect(2, time.Millisecond, &connects), func(data []byte) {
+		atomic.AddInt32(&received, 1)
+	})
+	stream.MinBackoff, stream.MaxBackoff = time.Millisecond, 4*time.Millisecond
+	stopped := runStream(stream)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&connects) != 3 || stream.Reconnects() != 2 || atomic.LoadInt32(&received) == 0 {
+		t.Errorf("expected 3 connects delivering messages, got %v connects, %v messages", connects, received)
+	}
+	if stream.State() != supervisor.Connected {
+		t.Errorf("expected connected, got %v", stream.State())
+	}
+	stream.Stop()
+	<-stopped
+	if stream.State() != supervisor.Stopped {
+		t.Errorf("expected stopped, got %v", stream.State())
+	}
+}
+
+// connection with no messages for the max gap should be reconnected
+func TestStreamWatchdog(t *testing.T) {
+	var connects int32
+	stream := supervisor.NewStream("test", fakeConnect(0, 0, &connects), func(data []byte) {})
+	stream.MinBackoff, stream.MaxBackoff = time.Millisecond, time.Millisecond
+	stream.MaxGap = 20 * time.Millisecond
+	stopped := runStream(stream)
+	time.Sleep(150 * time.Millisecond)
+	stream.Stop()
+	<-stopped
+	if atomic.LoadInt32(&connects) < 3 {
+		t.Errorf("expected stalled connections reconnected, got %v connects", connects)
+	}
+}
+
+// connection should be rotated after the max lifetime even if it works
+func TestStreamRotation(t *testing.T) {
+	var connects int32
+	stream := supervisor.NewStream("test", fakeConnect(0, time.Millisecond, &connects), func(data []byte) {})
+	stream.MinBackoff, stream.MaxBackoff = time.Hour, time.Hour // rotation doesn't back off
+	stream.MaxLifetime = 20 * time.Millisecond
+	stopped := runStream(stream)
+	time.Sleep(150 * time.Millisecond)
+	stream.Stop()
+	<-stopped
+	if atomic.LoadInt32(&connects) < 3 {
+		t.Errorf("expected connection rotated, got %v connects", connects)
+	}
+}
@@ -0,0 +1,40 @@
+package staleness
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/staleness"
+)
+
+func TestMaxAge(t *testing.T) {
+	if maxAge := staleness.MaxAge("binance"); maxAge != 10*time.Second {
+		t.Errorf("expected binance default of 10s, got %v", maxAge)
+	}
+	if maxAge := staleness.MaxAge("unknown"); maxAge != staleness.DefaultMaxAge {
+		t.Errorf("expected default max age, got %v", maxAge)
+	}
+	_ = os.Setenv("FEED_MAX_AGE_BINANCE", "2.5")
+	defer os.Unsetenv("FEED_MAX_AGE_BINANCE")
+	if maxAge := staleness.MaxAge("binance"); maxAge != 2500*time.Millisecond {
+		t.Errorf("expected max age set by environment of 2.5s, got %v", maxAge)
+	}
+}
+
+// feeds should get stale by the max age of their exchange
+func TestStaleFeeds(t *testing.T) {
+	now := time.Now()
+	minuteAgo := now.Add(-time.Minute).UnixNano() / int64(time.Millisecond)
+	var tracker staleness.Tracker
+	tracker.Touch("binance", "ticker.1", minuteAgo)
+	tracker.Touch("binance", "spread.1", staleness.Now())
+	tracker.Touch("serum", "candles.0", minuteAgo)
+	if stale := tracker.StaleFeeds(now); !reflect.DeepEqual(stale, []string{"binance.ticker.1"}) {
+		t.Errorf("expected binance ticker stale only, got %v", stale)
+	}
+	if staleness.IsStale("binance", 0, now) {
+		t.Error("data with no update time should not be stale")
+	}
+}
@@ -0,0 +1,93 @@
+package strategies
+
+import (
+	"math"
+	"testing"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/strategies"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func riskConditions() *models.MongoStrategyCondition {
+	return &models.MongoStrategyCondition{
+		MarketType:  1,
+		Leverage:    10,
+		StopLoss:    10, // 1% price distance at 10x
+		RiskPercent: 1,
+		FeeEstimate: 0.05,
+		EntryOrder:  &models.MongoEntryPoint{Side: "buy", OrderType: "limit", Price: 100},
+	}
+}
+
+// losing on stop-loss with fees and slippage should be close to the risk given
+func TestRiskEntryAmount(t *testing.T) {
+	conditions := riskConditions()
+	conditions.Slippage = 0.1
+	amount, err := strategies.CalculateRiskEntryAmount(conditions, 1000, 100, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopPrice := strategies.StopLossPriceFor(conditions, 100)
+	if stopPrice != 99 {
+		t.Fatalf("expected stop-loss price 99, got %v", stopPrice)
+	}
+	loss := amount*(100-99) + amount*(100+99)*0.05/100 + amount*99*0.1/100
+	if loss > 10 || loss < 9.99 {
+		t.Errorf("expected loss just under risk 10, got %v for amount %v", loss, amount)
+	}
+	if amount != math.Floor(amount*1000)/1000 {
+		t.Errorf("amount %v should be rounded to precision", amount)
+	}
+}
+
+func TestRiskEntryAmountCaps(t *testing.T) {
+	conditions := riskConditions()
+	conditions.RiskAmount = 500
+	amount, _ := strategies.CalculateRiskEntryAmount(conditions, 1000, 100, 3)
+	if amount != 100 {
+		t.Errorf("expected amount capped by leverage at 100, got %v", amount)
+	}
+	conditions.MaxNotional = 2000
+	amount, _ = strategies.CalculateRiskEntryAmount(conditions, 1000, 100, 3)
+	if amount != 20 {
+		t.Errorf("expected amount capped by max notional at 20, got %v", amount)
+	}
+}
+
+func TestRiskEntryAmountErrors(t *testing.T) {
+	conditions := riskConditions()
+	conditions.StopLoss = 0
+	if _, err := strategies.CalculateRiskEntryAmount(conditions, 1000, 100, 3); err == nil {
+		t.Error("sizing without stop-loss should fail")
+	}
+	conditions.StopLossPrice = 101
+	if _, err := strategies.CalculateRiskEntryAmount(conditions, 1000, 100, 3); err == nil {
+		t.Error("sizing with stop-loss above long entry should fail")
+	}
+	conditions.EntryOrder.Side = "sell"
+	if amount, err := strategies.CalculateRiskEntryAmount(conditions, 1000, 100, 0); err != nil || amount != 9 {
+		t.Errorf("expected short amount 9, got %v, %v", amount, err)
+	}
+}
+
+// risk should be taken of the equity, funds locked in orders and positions included, read through state management
+func TestRiskEntryAmountOnEquity(t *testing.T) {
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 100}})
+	sm := tests.NewMockedStateMgmt(tests.NewMockedTradingAPI(), df)
+	logger, _ := tests.GetLoggerStatsd()
+	keyAssetId := primitive.NewObjectID()
+	sm.KeyAssetsMap.Store(keyAssetId.Hex(), 200.0)
+	sm.KeyAssetTotalsMap.Store(keyAssetId.Hex(), 1000.0)
+	conditions := riskConditions()
+	conditions.KeyAssetId = &keyAssetId
+	strategy := &strategies.Strategy{Model: &models.MongoStrategy{Conditions: conditions}, StateMgmt: &sm, Log: logger}
+
+	strategies.DetermineRiskEntryAmount(strategy, df)
+	expected, _ := strategies.CalculateRiskEntryAmount(riskConditions(), 1000, 100, 3)
+	if conditions.EntryOrder.Amount != expected {
+		t.Errorf("expected amount %v sized on equity 1000, got %v", expected, conditions.EntryOrder.Amount)
+	}
+}
@@ -0,0 +1,202 @@
+package templates
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/crypto_project/core/strategy_service/src/service/interfaces"
+	"gitlab.com/crypto_project/core/strategy_service/src/service/templates"
+	"gitlab.com/crypto_project/core/strategy_service/src/sources/mongodb/models"
+	"gitlab.com/crypto_project/core/strategy_service/tests"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestTemplate(model *models.MongoStrategyTemplate) (*templates.Template, *tests.MockStateMgmt) {
+	df := tests.NewMockedDataFeed([]interfaces.OHLCV{{Close: 7000}})
+	sm := tests.NewMockedStateMgmt(tests.NewMockedTradingAPI(), df)
+	logger, statsd := tests.GetLoggerStatsd()
+	model.Id = primitive.NewObjectID()
+	model.Enabled = true
+	if model.Conditions == nil {
+		model.Conditions = map[string]interface{}{
+			"pair":       "{{pair}}",
+			"marketType": int64(1),
+			"entryOrder": map[string]interface{}{
+				"side":   "buy",
+				"price":  "{{price}}",
+				"amount": "{{amount}}",
+			},
+		}
+		model.Params = map[string]interface{}{"amount": 0.01}
+	}
+	return templates.New(model, df, &sm, statsd, logger), &sm
+}
+
+// placeholders taking the whole value should keep the value type, others should be printed into the string
+func TestTemplateInstantiate(t *testing.T) {
+	conditions, err := templates.Instantiate(map[string]interface{}{
+		"pair":       "{{base}}_USDT",
+		"leverage":   "{{ leverage }}",
+		"entryOrder": map[string]interface{}{"amount": "{{amount}}"},
+		"exitLevels": []interface{}{map[string]interface{}{"price": "{{target}}", "type": int64(1)}},
+	}, map[string]interface{}{"base": "BTC", "leverage": 20.0, "amount": 0.01, "target": 3.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conditions.Pair != "BTC_USDT" || conditions.Leverage != 20 || conditions.EntryOrder.Amount != 0.01 {
+		t.Errorf("wrong conditions filled %v, leverage %v, amount %v", conditions.Pair, conditions.Leverage, conditions.EntryOrder.Amount)
+	}
+	if len(conditions.ExitLevels) != 1 || conditions.ExitLevels[0].Price != 3.5 {
+		t.Error("exit levels not filled")
+	}
+
+	if _, err := templates.Instantiate(map[string]interface{}{"pair": "{{pair}}"}, map[string]interface{}{}); err == nil {
+		t.Error("unknown placeholder should fail instantiation")
+	}
+}
+
+// pairs rule should keep one child per pair within max active limit and spawn on the pair freed
+func TestTemplatePairsLimits(t *testing.T) {
+	template, sm := newTestTemplate(&models.MongoStrategyTemplate{
+		Spawn:     models.MongoTemplateSpawn{Rule: models.TemplateSpawnByPairs, Pairs: []string{"BTC_USDT", "ETH_USDT", "XRP_USDT"}},
+		MaxActive: 2,
+	})
+	template.SpawnByRule(time.Now())
+	template.SpawnByRule(time.Now())
+	children := sm.GetTemplateChildren(&template.Model.Id)
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children within max active limit, got %v", len(children))
+	}
+	for _, child := range children {
+		if !child.Conditions.CreatedByTemplate || *child.Conditions.TemplateStrategyId != template.Model.Id {
+			t.Error("child should be linked to the template")
+		}
+		if child.Conditions.EntryOrder.Price != 7000 || child.Conditions.EntryOrder.Amount != 0.01 {
+			t.Errorf("child placeholders not filled, price %v, amount %v", child.Conditions.EntryOrder.Price, child.Conditions.EntryOrder.Amount)
+		}
+	}
+
+	children[0].Enabled = false
+	template.Refresh()
+	template.SpawnByRule(time.Now())
+	children = sm.GetTemplateChildren(&template.Model.Id)
+	pairs := map[string]int{}
+	for _, child := range children {
+		if child.Enabled {
+			pairs[child.Conditions.Pair]++
+		}
+	}
+	if len(children) != 3 || len(pairs) != 2 {
+		t.Errorf("expected a child spawned on the pair free, got %v children on %v", len(children), pairs)
+	}
+}
+
+// a child not written should not be counted as spawned or active
+func TestTemplateSpawnNotCreated(t *testing.T) {
+	template, sm := newTestTemplate(&models.MongoStrategyTemplate{
+		Spawn:       models.MongoTemplateSpawn{Rule: models.TemplateSpawnByPairs, Pairs: []string{"BTC_USDT"}},
+		MaxChildren: 1,
+	})
+	sm.CreateStrategyErr = errors.New("insert failed")
+	if child := template.Spawn("BTC_USDT", 0); child != nil {
+		t.Fatal("child not written should not be returned")
+	}
+	if template.Model.State.Spawned != 0 || template.Model.State.Active != 0 {
+		t.Errorf("expected no child counted, got %v spawned, %v active", template.Model.State.Spawned, template.Model.State.Active)
+	}
+
+	sm.CreateStrategyErr = nil
+	if child := template.Spawn("BTC_USDT", 0); child == nil {
+		t.Error("expected the child spawned once written")
+	}
+}
+
+// schedule rule should spawn each interval until max children spawned
+func TestTemplateSchedule(t *testing.T) {
+	template, sm := newTestTemplate(&models.MongoStrategyTemplate{
+		Spawn:       models.MongoTemplateSpawn{Rule: models.TemplateSpawnBySchedule, Interval: 60, Pairs: []string{"BTC_USDT"}},
+		MaxChildren: 2,
+	})
+	now := time.Now()
+	for _, passed := range []time.Duration{0, 30, 60, 120} {
+		template.SpawnByRule(now.Add(passed * time.Second))
+	}
+	if spawned := len(sm.GetTemplateChildren(&template.Model.Id)); spawned != 2 || template.Model.State.Spawned != 2 {
+		t.Errorf("expected 2 children spawned, got %v", spawned)
+	}
+}
+
+// signal rule should spawn on the signal pair at the price fired once per firing
+func TestTemplateSignal(t *testing.T) {
+	signalId := primitive.NewObjectID()
+	template, sm := newTestTemplate(&models.MongoStrategyTemplate{
+		Spawn: models.MongoTemplateSpawn{Rule: models.TemplateSpawnBySignal, SignalId: &signalId},
+	})
+	template.Model.State.StartedAt = time.Now().Unix()
+	_ = sm.SubscribeToSignal(&signalId, template.ID(), template.OnSignalFired)
+	signal := &models.MongoSignal{
+		Id:        signalId,
+		Condition: models.MongoSignalCondition{Pair: "ETH_USDT"},
+		State:     &models.MongoSignalState{FiredAt: time.Now().Unix(), FiredPrice: 200},
+	}
+	sm.FireSignal(signal)
+	sm.FireSignal(signal)
+	children := sm.GetTemplateChildren(&template.Model.Id)
+	if len(children) != 1 {
+		t.Fatalf("expected one child per firing, got %v", len(children))
+	}
+	if children[0].Conditions.Pair != "ETH_USDT" || children[0].Conditions.EntryOrder.Price != 200 {
+		t.Errorf("child should take signal pair and price, got %v at %v", children[0].Conditions.Pair, children[0].Conditions.EntryOrder.Price)
+	}
+}
+
+// hot reload should apply spawn rule changes, firing of the signal replaced should not spawn
+func TestTemplateHotReloadSignal(t *testing.T) {
+	template, sm := newTestTemplate(&models.MongoStrategyTemplate{
+		Spawn: models.MongoTemplateSpawn{Rule: models.TemplateSpawnBySchedule, Interval: 60},
+	})
+	template.Model.State.StartedAt = time.Now().Unix()
+	oldSignalId, newSignalId := primitive.NewObjectID(), primitive.NewObjectID()
+	reloaded := *template.Model
+	reloaded.Spawn = models.MongoTemplateSpawn{Rule: models.TemplateSpawnBySignal, SignalId: &oldSignalId}
+	template.HotReload(reloaded)
+	reloaded.Spawn = models.MongoTemplateSpawn{Rule: models.TemplateSpawnBySignal, SignalId: &newSignalId}
+	template.HotReload(reloaded)
+
+	fired := &models.MongoSignalState{FiredAt: time.Now().Unix(), FiredPrice: 200}
+	sm.FireSignal(&models.MongoSignal{Id: oldSignalId, Condition: models.MongoSignalCondition{Pair: "ETH_USDT"}, State: fired})
+	if children := sm.GetTemplateChildren(&template.Model.Id); len(children) != 0 {
+		t.Fatalf("expected no child on the signal replaced, got %v", len(children))
+	}
+	sm.FireSignal(&models.MongoSignal{Id: newSignalId, Condition: models.MongoSignalCondition{Pair: "ETH_USDT"}, State: fired})
+	if children := sm.GetTemplateChildren(&template.Model.Id); len(children) != 1 {
+		t.Errorf("expected a child on the signal reloaded, got %v", len(children))
+	}
+}
+
+// ended children should count to win rate and drawdown, active ones to pnl only
+func TestTemplateAggregate(t *testing.T) {
+	results := []struct {
+		pnl     float64
+		enabled bool
+	}{{10, false}, {-5, false}, {-10, false}, {0, false}, {3, true}}
+	children := make([]*models.MongoStrategy, 0, len(results))
+	for i, result := range results {
+		id := primitive.NewObjectIDFromTimestamp(time.Now().Add(time.Duration(i) * time.Second))
+		children = append(children, &models.MongoStrategy{
+			ID:         &id,
+			Enabled:    result.enabled,
+			Conditions: &models.MongoStrategyCondition{Pair: "BTC_USDT"},
+			State:      &models.MongoStrategyState{ReceivedProfitAmount: result.pnl},
+		})
+	}
+	state := &models.MongoStrategyTemplateState{}
+	templates.Aggregate(state, children)
+	if state.Pnl != -2 || state.Active != 1 || state.Wins != 1 || state.Losses != 2 {
+		t.Errorf("wrong totals, pnl %v, active %v, wins %v, losses %v", state.Pnl, state.Active, state.Wins, state.Losses)
+	}
+	if state.WinRate < 33.3 || state.WinRate > 33.4 || state.MaxDrawdown != 15 {
+		t.Errorf("wrong win rate %v or drawdown %v", state.WinRate, state.MaxDrawdown)
+	}
+}